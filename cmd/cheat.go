@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cheats"
+	"github.com/spf13/cobra"
+)
+
+func newCheatCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cheat",
+		Short: "Manage the cheats stored for a ROM",
+	}
+	cmd.PersistentFlags().String("cheats", "", "cheat file to edit (default the .cheats.toml file next to the ROM)")
+
+	add := &cobra.Command{
+		Use:   "add rom.gba name code...",
+		Short: "Add a cheat, enabled",
+		Args:  cobra.MinimumNArgs(3),
+		RunE:  runCheatAdd,
+	}
+	add.Flags().StringP("type", "t", "", "code type: raw, gsa, par3 or cb (default detected from the code)")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "list rom.gba",
+			Short: "List the cheats for a ROM",
+			Args:  cobra.ExactArgs(1),
+			RunE:  runCheatList,
+		},
+		add,
+		&cobra.Command{
+			Use:   "enable rom.gba name",
+			Short: "Enable a cheat",
+			Args:  cobra.ExactArgs(2),
+			RunE:  runCheatToggle(true),
+		},
+		&cobra.Command{
+			Use:   "disable rom.gba name",
+			Short: "Disable a cheat",
+			Args:  cobra.ExactArgs(2),
+			RunE:  runCheatToggle(false),
+		},
+		&cobra.Command{
+			Use:   "remove rom.gba name",
+			Short: "Remove a cheat",
+			Args:  cobra.ExactArgs(2),
+			RunE:  runCheatRemove,
+		},
+	)
+	return cmd
+}
+
+// cheatFile loads the cheat file named by --cheats, or the one next to the ROM
+func cheatFile(cmd *cobra.Command, romPath string) (*cheats.File, string, error) {
+	path, err := cmd.Flags().GetString("cheats")
+	if err != nil {
+		return nil, "", err
+	}
+	if path == "" {
+		path = cheats.FilePath(romPath)
+	}
+	file, err := cheats.LoadFile(path)
+	return file, path, err
+}
+
+func runCheatList(cmd *cobra.Command, args []string) error {
+	file, path, err := cheatFile(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if len(file.Cheats) == 0 {
+		fmt.Fprintf(out, "No cheats in %s\n", path)
+		return nil
+	}
+	for _, cheat := range file.Cheats {
+		state := " "
+		if cheat.Enabled {
+			state = "x"
+		}
+		cheatType := string(cheat.Type)
+		if cheatType == "" {
+			cheatType = "auto"
+		}
+		fmt.Fprintf(out, "[%s] %s (%s)\n", state, cheat.Name, cheatType)
+		for _, code := range cheat.Codes {
+			fmt.Fprintf(out, "      %s\n", code)
+		}
+		if _, err := cheats.Compile(cheat); err != nil {
+			fmt.Fprintf(out, "      error: %v\n", err)
+		}
+	}
+	return nil
+}
+
+func runCheatAdd(cmd *cobra.Command, args []string) error {
+	file, path, err := cheatFile(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	typeName, err := cmd.Flags().GetString("type")
+	if err != nil {
+		return err
+	}
+	cheatType, err := cheats.ParseType(typeName)
+	if err != nil {
+		return err
+	}
+
+	cheat := cheats.Cheat{
+		Name:    args[1],
+		Type:    cheatType,
+		Enabled: true,
+	}
+	// Codes may be passed as one argument per code or as "op1 op2" pairs
+	// split across arguments
+	codes := args[2:]
+	if len(codes)%2 == 0 && !strings.ContainsAny(strings.Join(codes, ""), ": ") && len(codes[0]) == 8 {
+		for i := 0; i < len(codes); i += 2 {
+			cheat.Codes = append(cheat.Codes, codes[i]+" "+codes[i+1])
+		}
+	} else {
+		cheat.Codes = codes
+	}
+	if _, err := cheats.Compile(cheat); err != nil {
+		return err
+	}
+
+	if existing, ok := file.Find(cheat.Name); ok {
+		*existing = cheat
+	} else {
+		file.Cheats = append(file.Cheats, cheat)
+	}
+	if err := file.Save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Added %q to %s\n", cheat.Name, path)
+	return nil
+}
+
+func runCheatToggle(enabled bool) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		file, path, err := cheatFile(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		cheat, ok := file.Find(args[1])
+		if !ok {
+			return fmt.Errorf("no cheat named %q in %s", args[1], path)
+		}
+		cheat.Enabled = enabled
+		return file.Save(path)
+	}
+}
+
+func runCheatRemove(cmd *cobra.Command, args []string) error {
+	file, path, err := cheatFile(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	if !file.Remove(args[1]) {
+		return fmt.Errorf("no cheat named %q in %s", args[1], path)
+	}
+	return file.Save(path)
+}
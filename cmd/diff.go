@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/difftest"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff rom.gba reference.log",
+		Short: "Run a ROM in lockstep with a trace recorded by a reference emulator",
+		Long: "Run a ROM in lockstep with a trace recorded by a reference emulator and report the first instruction where they disagree.\n" +
+			"The trace can be an mGBA or NanoBoyAdvance style log, or a trace written with --trace in any format.",
+		Args: cobra.ExactArgs(2),
+		RunE: runDiff,
+	}
+	cmd.Flags().StringP("bios", "b", "", "path to the GBA BIOS, which the reference must have used too")
+	cmd.Flags().String("rom-entry", "", "file to load from a ROM archive holding more than one .gba file")
+	cmd.Flags().StringArray("patch", nil, "IPS, UPS or BPS patch to apply to the ROM, can be repeated")
+	cmd.Flags().Bool("writes", false, "compare memory writes too, the reference must be traced with --trace-writes")
+	cmd.Flags().Int("context", 10, "number of instructions to show before the divergence")
+	cmd.Flags().Uint64("limit", 0, "stop after this many instructions (default the whole trace)")
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	writes, err := cmd.Flags().GetBool("writes")
+	if err != nil {
+		return err
+	}
+	context, err := cmd.Flags().GetInt("context")
+	if err != nil {
+		return err
+	}
+	limit, err := cmd.Flags().GetUint64("limit")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reference, err := trace.NewReader(file)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := diffConfig(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	c, err := cpu.NewARM7TDMI(cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	divergence, count, err := difftest.Run(c, reference, difftest.Options{Writes: writes, Context: context, Limit: limit})
+	if err != nil {
+		return err
+	}
+	if divergence != nil {
+		divergence.Report(os.Stdout)
+		return fmt.Errorf("%s diverged from %s after %d instructions", args[0], args[1], divergence.Index)
+	}
+	fmt.Printf("%d instructions matched %s\n", count, args[1])
+	return nil
+}
+
+// diffConfig builds the config from the diff flags alone. The config file,
+// environment and per-game overrides would turn on tracing, lockstep or
+// other settings the reference never ran with.
+func diffConfig(cmd *cobra.Command, romPath string) (*config.Config, error) {
+	bios, err := cmd.Flags().GetString("bios")
+	if err != nil {
+		return nil, err
+	}
+	romEntry, err := cmd.Flags().GetString("rom-entry")
+	if err != nil {
+		return nil, err
+	}
+	patches, err := cmd.Flags().GetStringArray("patch")
+	if err != nil {
+		return nil, err
+	}
+	return &config.Config{
+		BIOSPath: bios,
+		ROMPath:  romPath,
+		ROMEntry: romEntry,
+		Patches:  patches,
+	}, nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/USA-RedDragon/go-gba/internal/debugger"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/disasm"
+	"github.com/spf13/cobra"
+)
+
+func newDisasmCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disasm rom.gba",
+		Short: "Disassemble ARM or THUMB code from a ROM or BIOS",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runDisasm,
+	}
+	cmd.Flags().String("base", "0x08000000", "address the file is mapped at, 0 for a BIOS")
+	cmd.Flags().String("start", "", "address or symbol to start at (default the base address)")
+	cmd.Flags().Int("count", 32, "number of instructions to disassemble")
+	cmd.Flags().Bool("thumb", false, "disassemble THUMB code")
+	cmd.Flags().String("symbols", "", "ELF to load symbols from")
+	cmd.Flags().String("rom-entry", "", "file to load from a ROM archive holding more than one .gba file")
+	return cmd
+}
+
+// fileMemory maps a file at a base address for the disassembler
+type fileMemory struct {
+	base uint32
+	data []byte
+}
+
+func (m fileMemory) slice(addr, size uint32) ([]byte, error) {
+	offset := addr - m.base
+	if addr < m.base || uint64(offset)+uint64(size) > uint64(len(m.data)) {
+		return nil, fmt.Errorf("address 0x%08X is outside the file", addr)
+	}
+	return m.data[offset : offset+size], nil
+}
+
+func (m fileMemory) Read16(addr uint32) (uint16, error) {
+	b, err := m.slice(addr, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (m fileMemory) Read32(addr uint32) (uint32, error) {
+	b, err := m.slice(addr, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+//nolint:golint,gocyclo
+func runDisasm(cmd *cobra.Command, args []string) error {
+	entry, err := cmd.Flags().GetString("rom-entry")
+	if err != nil {
+		return err
+	}
+	baseText, err := cmd.Flags().GetString("base")
+	if err != nil {
+		return err
+	}
+	startText, err := cmd.Flags().GetString("start")
+	if err != nil {
+		return err
+	}
+	count, err := cmd.Flags().GetInt("count")
+	if err != nil {
+		return err
+	}
+	thumb, err := cmd.Flags().GetBool("thumb")
+	if err != nil {
+		return err
+	}
+	symbolsPath, err := cmd.Flags().GetString("symbols")
+	if err != nil {
+		return err
+	}
+
+	base, err := strconv.ParseUint(baseText, 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid base address %q", baseText)
+	}
+	data, err := cartridge.LoadROM(args[0], entry)
+	if err != nil {
+		return err
+	}
+	var symbols *debugger.Symbols
+	if symbolsPath != "" {
+		symbols, err = debugger.LoadSymbols(symbolsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	addr := uint32(base)
+	if startText != "" {
+		if start, err := strconv.ParseUint(startText, 0, 32); err == nil {
+			addr = uint32(start)
+		} else if start, ok := symbols.Lookup(startText); ok {
+			addr = start
+		} else {
+			return fmt.Errorf("%q is not an address or a known symbol", startText)
+		}
+	}
+	if thumb {
+		addr &^= 1
+	} else {
+		addr &^= 3
+	}
+
+	mem := fileMemory{base: uint32(base), data: data}
+	out := cmd.OutOrStdout()
+	for i := 0; i < count; i++ {
+		if name := symbols.Describe(addr); name != "" && !strings.Contains(name, "+") {
+			fmt.Fprintf(out, "\n%08x <%s>:\n", addr, name)
+		}
+		text, size, err := disasm.Disassemble(mem, addr, thumb)
+		if err != nil {
+			break
+		}
+		fmt.Fprintf(out, "%08x:  %-9s  %s\n", addr, disasm.Opcode(mem, addr, size, thumb), text)
+		addr += size
+	}
+	return nil
+}
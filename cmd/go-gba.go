@@ -4,14 +4,11 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"strings"
 
-	"github.com/USA-RedDragon/go-gba/internal"
 	"github.com/USA-RedDragon/go-gba/internal/config"
-	"github.com/USA-RedDragon/go-gba/internal/emulator"
+	"github.com/USA-RedDragon/go-gba/internal/debugger"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/USA-RedDragon/go-gba/internal/gdb"
 	"github.com/spf13/cobra"
 )
 
@@ -28,74 +25,57 @@ func New(version, commit string) *cobra.Command {
 		DisableAutoGenTag: true,
 	}
 
+	cmd.Flags().String("config", "", "path to a TOML, YAML or JSON config file (default $XDG_CONFIG_HOME/go-gba/config.toml)")
 	cmd.Flags().StringP("bios", "b", "", "path to the GBA BIOS")
-	cmd.Flags().StringP("rom", "r", "", "path to the GBA ROM")
+	cmd.Flags().StringP("rom", "r", "", "path to the GBA ROM, optionally inside a .zip, .7z or .gz archive")
+	cmd.Flags().String("rom-entry", "", "file to load from a ROM archive holding more than one .gba file")
+	cmd.Flags().StringArray("patch", nil, "IPS, UPS or BPS patch to apply to the ROM, can be repeated (patches next to the ROM are applied automatically)")
+	cmd.Flags().String("cheats", "", "cheat file to load (default the .cheats.toml file next to the ROM)")
+	cmd.Flags().String("save-dir", "", "directory to store save files in")
+	cmd.Flags().Float64P("scale", "s", 2.0, "initial window scale")
 	cmd.Flags().BoolP("fullscreen", "f", false, "enable fullscreen")
+	cmd.Flags().String("filter", "", "upscaling filter: nearest, bilinear, catmullrom, scale2x, scale3x, scale4x, xbr, hq2x, hq3x or hq4x")
+	cmd.Flags().Bool("integer-scaling", false, "only scale the screen by whole numbers")
+	cmd.Flags().String("color-correction", "", "LCD color correction: none, gba, gbasp or gbplayer")
+	cmd.Flags().Bool("frame-blending", false, "blend each frame with the previous one to emulate LCD ghosting")
 	cmd.Flags().BoolP("trace-registers", "t", false, "trace CPU registers")
-	cmd.Flags().BoolP("debug", "d", false, "enable debug logging")
-	cmd.Flags().BoolP("diff", "c", false, "enable diff mode")
-	cmd.Flags().BoolP("interactive", "i", false, "enable interactive mode, implies --cpu-only and --debug")
+	cmd.Flags().BoolP("debug", "d", false, "log debug messages from every subsystem")
+	cmd.Flags().String("log-level", "", "log level (trace, debug, info, warn or error), optionally per subsystem (cpu, mmio, ppu, dma, apu or bios), like info,cpu=debug")
+	cmd.Flags().BoolP("interactive", "i", false, "run the CPU under the command-line debugger, implies --cpu-only")
+	cmd.Flags().String("symbols", "", "ELF to load debugger symbols from")
+	cmd.Flags().String("gdb", "", "serve the GDB remote protocol on this address (e.g. localhost:2345) and run the CPU only under its control")
+	cmd.Flags().String("trace", "", "write an execution trace to this file")
+	cmd.Flags().String("trace-format", "", "execution trace format: text, mgba or binary")
+	cmd.Flags().StringArray("trace-range", nil, "only trace addresses in this range (start-end, end exclusive), can be repeated")
+	cmd.Flags().String("trace-mode", "", "only trace these comma separated CPU modes (usr, fiq, irq, svc, abt, und, sys)")
+	cmd.Flags().String("trace-frames", "", "only trace these frames (first-last, first- or a single frame)")
+	cmd.Flags().Bool("trace-writes", false, "include the memory writes of each instruction in the execution trace")
+	cmd.Flags().Int("trace-ring", 0, "keep only the last N traced instructions and write them if the emulator crashes")
+	cmd.Flags().Bool("block-cache", true, "run hot code from a cache of decoded instructions")
+	cmd.Flags().Bool("lockstep", false, "check the block cache against the plain interpreter after every instruction")
+	cmd.Flags().Bool("idle-skip", false, "skip busy-wait loops to the next event")
 	cmd.Flags().Bool("cpu-only", false, "only run the CPU (for debugging)")
 	cmd.Flags().Bool("no-gui", false, "disable the GUI (for debugging)")
 
-	return cmd
-}
-
-// This mode will run `mgba` in debug mode with a given BIOS and ROM file. It will then
-// start up our GBA emulator and step through both, comparing the memory and registers at each step.
-// If there is a difference, it will print out the difference and exit.
-func runComparer(cmd *cobra.Command) error {
-	c := cpu.NewARM7TDMI(config.GetConfig(cmd))
-	c.Step()
-
-	mgba := internal.NewMGBAHarness("gba_bios.bin", "arm.gba")
-	if err := mgba.Start(); err != nil {
-		return err
-	}
-	defer (func() {
-		_ = mgba.Stop()
-	})()
-	err := mgba.Step()
-	for err == nil {
-		err = mgba.Step()
-		if err != nil {
-			break
-		}
-		registers := uint(16)
-		if c.GetThumbMode() {
-			registers = 8
-		}
-		for i := uint(0); i < registers; i++ {
-			if mgba.GetRegister(i) != c.ReadRegister(uint8(i)) && !(i > 12) {
-				if i == 15 && c.GetThumbMode() {
-					// mgba PC will be 2 bytes ahead of ours
-					if mgba.GetRegister(i)-2 == c.ReadRegister(uint8(i)) {
-						continue
-					}
-					fmt.Printf("Register %d: %08x != %08x\n", i, mgba.GetRegister(i), c.ReadRegister(uint8(i)))
-					return nil
-				}
-				fmt.Printf("Register %d: %08x != %08x\n", i, mgba.GetRegister(i), c.ReadRegister(uint8(i)))
-				return nil
-			}
-			c.Step()
-		}
-	}
-	if err != nil {
-		return err
-	}
+	cmd.AddCommand(newInfoCommand())
+	cmd.AddCommand(newCheatCommand())
+	cmd.AddCommand(newDisasmCommand())
+	cmd.AddCommand(newDiffCommand())
+	cmd.AddCommand(newTestCommand())
 
-	return mgba.Wait()
+	return cmd
 }
 
 func run(cmd *cobra.Command, _ []string) error {
 	fmt.Printf("go-gba %s-%s\n", cmd.Annotations["version"], cmd.Annotations["commit"])
-	diff, err := cmd.Flags().GetBool("diff")
-	if err != nil {
-		return err
-	}
-	if diff {
-		return runComparer(cmd)
+	cfg := config.GetConfig(cmd)
+	if cfg.GDBAddress != "" {
+		c, err := cpu.NewARM7TDMI(cfg)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		return gdb.NewServer(c).ListenAndServe(cfg.GDBAddress)
 	}
 	cpuOnly, err := cmd.Flags().GetBool("cpu-only")
 	if err != nil {
@@ -105,47 +85,69 @@ func run(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
-	if interactive || cpuOnly {
-		c := cpu.NewARM7TDMI(config.GetConfig(cmd))
-		if !interactive {
-			c.Run()
-		} else {
-			c.InteractiveRun()
-		}
-		return nil
+	if interactive {
+		return runDebugger(cfg)
+	}
+	if cpuOnly {
+		return runCPU(cfg)
 	}
 	noGUI, err := cmd.Flags().GetBool("no-gui")
 	if err != nil {
 		return err
 	}
 	if noGUI {
-		c := cpu.NewARM7TDMI(config.GetConfig(cmd))
-		c.Run()
-		return nil
+		return runCPU(cfg)
 	}
-	config := config.GetConfig(cmd)
+	return runGUI(cfg)
+}
 
-	emu := emulator.New(config)
+// runCPU runs the CPU alone until it is interrupted or fails
+func runCPU(config *config.Config) error {
+	c, err := cpu.NewARM7TDMI(config)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	defer signal.Stop(ch)
 	go func() {
-		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, os.Interrupt)
 		for range ch {
 			fmt.Println("Exiting")
-			emu.Stop()
+			c.Quit()
 		}
 	}()
-
-	ebiten.SetWindowSize(int(config.Scale*240), int(config.Scale*160))
-	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
-	ebiten.SetFullscreen(config.Fullscreen)
-	ebiten.SetScreenClearedEveryFrame(true)
-
-	if config.ROMPath != "" {
-		name := strings.TrimSuffix(filepath.Base(config.ROMPath), filepath.Ext(config.ROMPath))
-		ebiten.SetWindowTitle(name + " | go-gba")
-	} else {
-		ebiten.SetWindowTitle("go-gba")
+	if err := c.Run(); err != nil {
+		fmt.Print(c.DebugRegisters())
+		return err
 	}
+	return nil
+}
 
-	return ebiten.RunGame(emu)
+// runDebugger runs the CPU under the command-line debugger. Ctrl-C stops
+// the target instead of exiting.
+func runDebugger(config *config.Config) error {
+	var symbols *debugger.Symbols
+	if config.SymbolsPath != "" {
+		var err error
+		symbols, err = debugger.LoadSymbols(config.SymbolsPath)
+		if err != nil {
+			return err
+		}
+	}
+	c, err := cpu.NewARM7TDMI(config)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	d := debugger.New(c, symbols)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	defer signal.Stop(ch)
+	go func() {
+		for range ch {
+			d.Interrupt()
+		}
+	}()
+	return d.Run(os.Stdin, os.Stdout)
 }
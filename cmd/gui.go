@@ -0,0 +1,43 @@
+//go:build !headless
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// runGUI runs the emulator in a window
+func runGUI(config *config.Config) error {
+	emu, err := emulator.New(config)
+	if err != nil {
+		return err
+	}
+	defer emu.Stop()
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt)
+		for range ch {
+			fmt.Println("Exiting")
+			emu.Stop()
+		}
+	}()
+
+	ebiten.SetWindowSize(int(config.Scale*240), int(config.Scale*160))
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetFullscreen(config.Fullscreen)
+	ebiten.SetScreenClearedEveryFrame(true)
+
+	if title := emu.Title(); title != "" {
+		ebiten.SetWindowTitle(title + " | go-gba")
+	} else {
+		ebiten.SetWindowTitle("go-gba")
+	}
+
+	return ebiten.RunGame(emu)
+}
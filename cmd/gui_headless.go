@@ -0,0 +1,14 @@
+//go:build headless
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+)
+
+// runGUI fails in builds without a GUI, which only run the CPU
+func runGUI(*config.Config) error {
+	return errors.New("go-gba was built without a GUI (-tags headless), run with --no-gui or use a subcommand")
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"fmt"
+	"hash/crc32"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+	"github.com/spf13/cobra"
+)
+
+func newInfoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "info rom.gba",
+		Short: "Print the cartridge header of a ROM",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runInfo,
+	}
+	cmd.Flags().String("rom-entry", "", "file to load from a ROM archive holding more than one .gba file")
+	return cmd
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	entry, err := cmd.Flags().GetString("rom-entry")
+	if err != nil {
+		return err
+	}
+	rom, err := cartridge.LoadROM(args[0], entry)
+	if err != nil {
+		return err
+	}
+	header, err := cartridge.ParseHeader(rom)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprint(out, header.String())
+	fmt.Fprintf(out, "Save type: %s\n", cartridge.DetectSaveType(rom))
+	fmt.Fprintf(out, "Size: %d bytes\n", len(rom))
+	fmt.Fprintf(out, "CRC32: %08X\n", crc32.ChecksumIEEE(rom))
+	fmt.Fprintf(out, "SHA1: %X\n", sha1.Sum(rom)) //nolint:gosec
+	for _, warning := range header.Validate(rom) {
+		fmt.Fprintf(out, "Warning: %s\n", warning)
+	}
+	return nil
+}
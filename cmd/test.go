@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/testrom"
+	"github.com/spf13/cobra"
+)
+
+func newTestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test rom.gba",
+		Short: "Run a test ROM headless and exit nonzero if it fails",
+		Long: "Run a test ROM without a window until it stops, then check its result.\n" +
+			"By default the ROM stops at an instruction that branches to itself. For jsmolka's gba-tests, check the failed test number with --expect r12=0.",
+		Args: cobra.ExactArgs(1),
+		RunE: runTest,
+		// A failing ROM isn't a usage error
+		SilenceUsage: true,
+	}
+	cmd.Flags().StringP("bios", "b", "", "path to the GBA BIOS")
+	cmd.Flags().String("rom-entry", "", "file to load from a ROM archive holding more than one .gba file")
+	cmd.Flags().StringArray("patch", nil, "IPS, UPS or BPS patch to apply to the ROM, can be repeated")
+	cmd.Flags().Bool("self-branch", true, "stop at an instruction that branches to itself")
+	cmd.Flags().String("marker", "", "stop once the word at an address holds a value (address=value)")
+	cmd.Flags().Uint64("frames", 0, "stop after this many frames")
+	cmd.Flags().Uint64("timeout", 3600, "fail if the ROM hasn't stopped after this many frames, 0 runs forever")
+	cmd.Flags().StringArray("expect", nil, "register value the ROM must stop with (like r12=0), can be repeated")
+	cmd.Flags().String("frame-hash", "", "SHA-256 of the frame the ROM must stop on")
+	cmd.Flags().Bool("block-cache", true, "run hot code from a cache of decoded instructions")
+	cmd.Flags().Bool("lockstep", false, "check the block cache against the plain interpreter after every instruction")
+	cmd.Flags().Bool("idle-skip", false, "skip busy-wait loops to the next event")
+	return cmd
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	var opts testrom.Options
+	var err error
+	if opts.SelfBranch, err = cmd.Flags().GetBool("self-branch"); err != nil {
+		return err
+	}
+	if opts.Frames, err = cmd.Flags().GetUint64("frames"); err != nil {
+		return err
+	}
+	if opts.Timeout, err = cmd.Flags().GetUint64("timeout"); err != nil {
+		return err
+	}
+	if opts.FrameHash, err = cmd.Flags().GetString("frame-hash"); err != nil {
+		return err
+	}
+	marker, err := cmd.Flags().GetString("marker")
+	if err != nil {
+		return err
+	}
+	if marker != "" {
+		if opts.Marker, err = testrom.ParseMarker(marker); err != nil {
+			return err
+		}
+	}
+	expects, err := cmd.Flags().GetStringArray("expect")
+	if err != nil {
+		return err
+	}
+	for _, text := range expects {
+		expect, err := testrom.ParseExpectation(text)
+		if err != nil {
+			return err
+		}
+		opts.Expect = append(opts.Expect, expect)
+	}
+
+	cfg := config.GetConfig(cmd)
+	cfg.ROMPath = args[0]
+	c, err := cpu.NewARM7TDMI(cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	result := testrom.Run(c, opts)
+	if result.FrameHash != "" {
+		fmt.Printf("Frame hash: %s\n", result.FrameHash)
+	}
+	if !result.Passed {
+		fmt.Print(c.DebugRegisters())
+		return fmt.Errorf("FAIL %s: %s after %d frames", args[0], result.Reason, result.Frames)
+	}
+	fmt.Printf("PASS %s: %s after %d frames and %d instructions\n", args[0], result.Reason, result.Frames, result.Instructions)
+	return nil
+}
@@ -3,18 +3,33 @@ module github.com/USA-RedDragon/go-gba
 go 1.22.2
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/bodgit/sevenzip v1.6.0
 	github.com/hajimehoshi/ebiten/v2 v2.7.3
 	github.com/spf13/cobra v1.8.0
+	github.com/ulikunitz/xz v0.5.12
 	golang.org/x/image v0.15.0
+	golang.org/x/term v0.19.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/bodgit/plumbing v1.3.0 // indirect
+	github.com/bodgit/windows v1.0.1 // indirect
 	github.com/ebitengine/gomobile v0.0.0-20240329170434-1771503ff0a8 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
 	github.com/ebitengine/purego v0.7.1 // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sync v0.7.0 // indirect
+	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
+	golang.org/x/sync v0.9.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
+	golang.org/x/text v0.20.0 // indirect
 )
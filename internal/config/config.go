@@ -1,107 +1,447 @@
 package config
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 	"github.com/spf13/cobra"
 )
 
+// Source describes where a configuration value came from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "config file"
+	SourceGame    Source = "game override"
+	SourceEnv     Source = "environment"
+	SourceFlag    Source = "flag"
+)
+
 // Config stores the application configuration.
 type Config struct {
-	BIOSPath       string
-	ROMPath        string
-	Scale          float64
-	TraceRegisters bool
-	Debug          bool
-	Fullscreen     bool
-	Interactive    bool
+	ConfigPath      string
+	GameCode        string
+	BIOSPath        string
+	ROMPath         string
+	ROMEntry        string
+	Patches         []string
+	CheatsPath      string
+	SaveDir         string
+	Scale           float64
+	Filter          string
+	IntegerScaling  bool
+	ColorCorrection string
+	FrameBlending   bool
+	KeyBindings     map[string]string
+	LogLevel        string
+	TraceRegisters  bool
+	Debug           bool
+	Fullscreen      bool
+	Interactive     bool
+	GDBAddress      string
+	SymbolsPath     string
+	TracePath       string
+	TraceFormat     string
+	TraceRanges     []string
+	TraceModes      string
+	TraceFrames     string
+	TraceRing       int
+	TraceWrites     bool
+	BlockCache      bool
+	Lockstep        bool
+	IdleSkip        bool
+
+	sources map[string]Source
 }
 
-func loadConfigFromEnv() Config {
-	scaleStr := os.Getenv("SCALE")
-	scale, err := strconv.ParseFloat(scaleStr, 64)
-	if err != nil {
-		scale = 2.0
-	} else if scale < 1.0 {
-		scale = 1.0
+// DefaultKeyBindings maps each GBA button to a keyboard key
+func DefaultKeyBindings() map[string]string {
+	return map[string]string{
+		"a":      "X",
+		"b":      "Z",
+		"l":      "A",
+		"r":      "S",
+		"start":  "Enter",
+		"select": "Backspace",
+		"up":     "ArrowUp",
+		"down":   "ArrowDown",
+		"left":   "ArrowLeft",
+		"right":  "ArrowRight",
 	}
+}
 
-	tmpConfig := Config{
-		BIOSPath:       os.Getenv("BIOS_PATH"),
-		ROMPath:        os.Getenv("ROM_PATH"),
-		TraceRegisters: os.Getenv("TRACE_REGISTERS") != "",
-		Debug:          os.Getenv("DEBUG") != "",
-		Scale:          scale,
-		Fullscreen:     os.Getenv("FULLSCREEN") != "",
-		Interactive:    os.Getenv("INTERACTIVE") != "",
+func defaultConfig() Config {
+	return Config{
+		Scale:           2.0,
+		Filter:          "nearest",
+		ColorCorrection: "none",
+		KeyBindings:     DefaultKeyBindings(),
+		LogLevel:        "info",
+		BlockCache:      true,
+		sources:         map[string]Source{},
 	}
+}
 
-	return tmpConfig
+func clampScale(scale float64) float64 {
+	if scale < 1.0 {
+		return 1.0
+	}
+	return scale
 }
 
-// GetConfig obtains the current configuration
-func GetConfig(cmd *cobra.Command) *Config {
-	currentConfig := loadConfigFromEnv()
+// Source returns where the named field got its value from
+func (config *Config) Source(field string) Source {
+	if source, ok := config.sources[field]; ok {
+		return source
+	}
+	return SourceDefault
+}
 
-	// Override with command line flags
-	if cmd != nil {
-		biosPath, err := cmd.Flags().GetString("bios")
-		if err == nil && biosPath != "" {
-			currentConfig.BIOSPath = biosPath
+func (config *Config) setSource(field string, source Source) {
+	if config.sources == nil {
+		config.sources = map[string]Source{}
+	}
+	config.sources[field] = source
+}
+
+func (config *Config) envString(name string, value *string, field string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = v
+		config.setSource(field, SourceEnv)
+	}
+}
+
+func (config *Config) envList(name string, value *[]string, field string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = filepath.SplitList(v)
+		config.setSource(field, SourceEnv)
+	}
+}
+
+func (config *Config) envBool(name string, value *bool, field string) {
+	if v, ok := os.LookupEnv(name); ok {
+		// An empty value turns the option off
+		b := false
+		if v != "" {
+			var err error
+			b, err = strconv.ParseBool(v)
+			if err != nil {
+				logging.CPU.Warn("Ignoring invalid environment variable", "name", name, "err", err)
+				return
+			}
 		}
+		*value = b
+		config.setSource(field, SourceEnv)
+	}
+}
 
-		romPath, err := cmd.Flags().GetString("rom")
-		if err == nil && romPath != "" {
-			currentConfig.ROMPath = romPath
+func (config *Config) envFloat(name string, value *float64, field string) {
+	if v, ok := os.LookupEnv(name); ok {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			logging.CPU.Warn("Ignoring invalid environment variable", "name", name, "err", err)
+			return
 		}
+		*value = f
+		config.setSource(field, SourceEnv)
+	}
+}
 
-		traceRegisters, err := cmd.Flags().GetBool("trace-registers")
-		if err == nil && traceRegisters {
-			currentConfig.TraceRegisters = traceRegisters
+func (config *Config) envInt(name string, value *int, field string) {
+	if v, ok := os.LookupEnv(name); ok {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			logging.CPU.Warn("Ignoring invalid environment variable", "name", name, "err", err)
+			return
 		}
+		*value = i
+		config.setSource(field, SourceEnv)
+	}
+}
+
+func (config *Config) loadFromEnv() {
+	config.envString("BIOS_PATH", &config.BIOSPath, "BIOSPath")
+	config.envString("ROM_PATH", &config.ROMPath, "ROMPath")
+	config.envString("ROM_ENTRY", &config.ROMEntry, "ROMEntry")
+	config.envList("PATCHES", &config.Patches, "Patches")
+	config.envString("CHEATS_PATH", &config.CheatsPath, "CheatsPath")
+	config.envString("SAVE_DIR", &config.SaveDir, "SaveDir")
+	config.envFloat("SCALE", &config.Scale, "Scale")
+	config.Scale = clampScale(config.Scale)
+	config.envString("FILTER", &config.Filter, "Filter")
+	config.envBool("INTEGER_SCALING", &config.IntegerScaling, "IntegerScaling")
+	config.envString("COLOR_CORRECTION", &config.ColorCorrection, "ColorCorrection")
+	config.envBool("FRAME_BLENDING", &config.FrameBlending, "FrameBlending")
+	config.envString("LOG_LEVEL", &config.LogLevel, "LogLevel")
+	config.envBool("TRACE_REGISTERS", &config.TraceRegisters, "TraceRegisters")
+	config.envBool("DEBUG", &config.Debug, "Debug")
+	config.envBool("FULLSCREEN", &config.Fullscreen, "Fullscreen")
+	config.envBool("INTERACTIVE", &config.Interactive, "Interactive")
+	config.envString("GDB_ADDRESS", &config.GDBAddress, "GDBAddress")
+	config.envString("SYMBOLS_PATH", &config.SymbolsPath, "SymbolsPath")
+	config.envString("TRACE_PATH", &config.TracePath, "TracePath")
+	config.envString("TRACE_FORMAT", &config.TraceFormat, "TraceFormat")
+	config.envList("TRACE_RANGES", &config.TraceRanges, "TraceRanges")
+	config.envString("TRACE_MODES", &config.TraceModes, "TraceModes")
+	config.envString("TRACE_FRAMES", &config.TraceFrames, "TraceFrames")
+	config.envInt("TRACE_RING", &config.TraceRing, "TraceRing")
+	config.envBool("TRACE_WRITES", &config.TraceWrites, "TraceWrites")
+	config.envBool("BLOCK_CACHE", &config.BlockCache, "BlockCache")
+	config.envBool("LOCKSTEP", &config.Lockstep, "Lockstep")
+	config.envBool("IDLE_SKIP", &config.IdleSkip, "IdleSkip")
+}
+
+func (config *Config) flagString(cmd *cobra.Command, name string, value *string, field string) {
+	if !cmd.Flags().Changed(name) {
+		return
+	}
+	v, err := cmd.Flags().GetString(name)
+	if err == nil {
+		*value = v
+		config.setSource(field, SourceFlag)
+	}
+}
+
+func (config *Config) flagStringArray(cmd *cobra.Command, name string, value *[]string, field string) {
+	if !cmd.Flags().Changed(name) {
+		return
+	}
+	v, err := cmd.Flags().GetStringArray(name)
+	if err == nil {
+		*value = v
+		config.setSource(field, SourceFlag)
+	}
+}
+
+func (config *Config) flagBool(cmd *cobra.Command, name string, value *bool, field string) {
+	if !cmd.Flags().Changed(name) {
+		return
+	}
+	v, err := cmd.Flags().GetBool(name)
+	if err == nil {
+		*value = v
+		config.setSource(field, SourceFlag)
+	}
+}
+
+func (config *Config) flagFloat(cmd *cobra.Command, name string, value *float64, field string) {
+	if !cmd.Flags().Changed(name) {
+		return
+	}
+	v, err := cmd.Flags().GetFloat64(name)
+	if err == nil {
+		*value = v
+		config.setSource(field, SourceFlag)
+	}
+}
+
+func (config *Config) flagInt(cmd *cobra.Command, name string, value *int, field string) {
+	if !cmd.Flags().Changed(name) {
+		return
+	}
+	v, err := cmd.Flags().GetInt(name)
+	if err == nil {
+		*value = v
+		config.setSource(field, SourceFlag)
+	}
+}
 
-		debug, err := cmd.Flags().GetBool("debug")
-		if err == nil && debug {
-			currentConfig.Debug = debug
+func (config *Config) loadFromFlags(cmd *cobra.Command) {
+	config.flagString(cmd, "bios", &config.BIOSPath, "BIOSPath")
+	config.flagString(cmd, "rom", &config.ROMPath, "ROMPath")
+	config.flagString(cmd, "rom-entry", &config.ROMEntry, "ROMEntry")
+	config.flagStringArray(cmd, "patch", &config.Patches, "Patches")
+	config.flagString(cmd, "cheats", &config.CheatsPath, "CheatsPath")
+	config.flagString(cmd, "save-dir", &config.SaveDir, "SaveDir")
+	config.flagFloat(cmd, "scale", &config.Scale, "Scale")
+	config.Scale = clampScale(config.Scale)
+	config.flagString(cmd, "filter", &config.Filter, "Filter")
+	config.flagBool(cmd, "integer-scaling", &config.IntegerScaling, "IntegerScaling")
+	config.flagString(cmd, "color-correction", &config.ColorCorrection, "ColorCorrection")
+	config.flagBool(cmd, "frame-blending", &config.FrameBlending, "FrameBlending")
+	config.flagString(cmd, "log-level", &config.LogLevel, "LogLevel")
+	config.flagBool(cmd, "trace-registers", &config.TraceRegisters, "TraceRegisters")
+	config.flagBool(cmd, "debug", &config.Debug, "Debug")
+	config.flagBool(cmd, "fullscreen", &config.Fullscreen, "Fullscreen")
+	config.flagBool(cmd, "interactive", &config.Interactive, "Interactive")
+	config.flagString(cmd, "gdb", &config.GDBAddress, "GDBAddress")
+	config.flagString(cmd, "symbols", &config.SymbolsPath, "SymbolsPath")
+	config.flagString(cmd, "trace", &config.TracePath, "TracePath")
+	config.flagString(cmd, "trace-format", &config.TraceFormat, "TraceFormat")
+	config.flagStringArray(cmd, "trace-range", &config.TraceRanges, "TraceRanges")
+	config.flagString(cmd, "trace-mode", &config.TraceModes, "TraceModes")
+	config.flagString(cmd, "trace-frames", &config.TraceFrames, "TraceFrames")
+	config.flagInt(cmd, "trace-ring", &config.TraceRing, "TraceRing")
+	config.flagBool(cmd, "trace-writes", &config.TraceWrites, "TraceWrites")
+	config.flagBool(cmd, "block-cache", &config.BlockCache, "BlockCache")
+	config.flagBool(cmd, "lockstep", &config.Lockstep, "Lockstep")
+	config.flagBool(cmd, "idle-skip", &config.IdleSkip, "IdleSkip")
+
+	if config.Interactive {
+		err := cmd.Flags().Set("cpu-only", "true")
+		if err != nil {
+			logging.CPU.Warn("Failed to set --cpu-only", "err", err)
 		}
+	}
+}
 
-		scale, err := cmd.Flags().GetFloat64("scale")
-		if err == nil {
-			currentConfig.Scale = scale
+// configFilePath returns the config file named by --config or CONFIG_PATH,
+// falling back to the first config file found in the config directory.
+func configFilePath(cmd *cobra.Command) string {
+	if cmd != nil {
+		path, err := cmd.Flags().GetString("config")
+		if err == nil && path != "" {
+			return path
 		}
+	}
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	return findConfigFile()
+}
+
+// GetConfig obtains the current configuration. Values are taken from, in
+// order of precedence: flags, environment, per-game overrides, the config
+// file and finally the defaults.
+func GetConfig(cmd *cobra.Command) *Config {
+	currentConfig := defaultConfig()
 
-		fullscreen, err := cmd.Flags().GetBool("fullscreen")
-		if err == nil {
-			currentConfig.Fullscreen = fullscreen
+	var file *configFile
+	if path := configFilePath(cmd); path != "" {
+		var err error
+		file, err = loadConfigFile(path)
+		if err != nil {
+			logging.CPU.Warn("Failed to load config file", "err", err)
+		} else {
+			currentConfig.ConfigPath = path
+			currentConfig.applySettings(&file.fileSettings, SourceFile)
 		}
+	}
 
-		interactive, err := cmd.Flags().GetBool("interactive")
-		if err == nil {
-			currentConfig.Interactive = interactive
-			if interactive {
-				currentConfig.Debug = true
-				err := cmd.Flags().Set("cpu-only", "true")
-				if err != nil {
-					fmt.Println("Error setting cpu-only flag")
-				}
-			}
+	// The ROM has to be known before the per-game overrides can be picked,
+	// so resolve it with the same precedence as the other settings first.
+	romConfig := currentConfig
+	romConfig.sources = map[string]Source{}
+	romConfig.envString("ROM_PATH", &romConfig.ROMPath, "ROMPath")
+	romConfig.envString("ROM_ENTRY", &romConfig.ROMEntry, "ROMEntry")
+	if cmd != nil {
+		romConfig.flagString(cmd, "rom", &romConfig.ROMPath, "ROMPath")
+		romConfig.flagString(cmd, "rom-entry", &romConfig.ROMEntry, "ROMEntry")
+	}
+	if romConfig.ROMPath != "" {
+		currentConfig.GameCode = readGameCode(romConfig.ROMPath, romConfig.ROMEntry)
+	}
+	if file != nil && currentConfig.GameCode != "" {
+		if game, ok := file.game(currentConfig.GameCode); ok {
+			currentConfig.applySettings(game, SourceGame)
 		}
 	}
 
-	fmt.Println(currentConfig.ToString())
+	currentConfig.loadFromEnv()
+
+	// Override with command line flags
+	if cmd != nil {
+		currentConfig.loadFromFlags(cmd)
+	}
+
+	currentConfig.configureLogging()
+
+	logging.CPU.Debug("Loaded config", "config", &currentConfig)
 
 	return &currentConfig
 }
 
-// ToString returns a string representation of the configuration
+// configureLogging sets the log level of each subsystem. --debug lowers
+// the default level to debug, keeping any level set for a subsystem.
+func (config *Config) configureLogging() {
+	spec := config.LogLevel
+	if config.Debug {
+		spec += ",debug"
+	}
+	if err := logging.Configure(os.Stderr, spec); err != nil {
+		logging.CPU.Warn("Ignoring invalid log level", "err", err)
+	}
+}
+
+func formatKeyBindings(bindings map[string]string) string {
+	buttons := make([]string, 0, len(bindings))
+	for button := range bindings {
+		buttons = append(buttons, button)
+	}
+	sort.Strings(buttons)
+	pairs := make([]string, 0, len(buttons))
+	for _, button := range buttons {
+		pairs = append(pairs, button+"="+bindings[button])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// configField is a config value formatted for display
+type configField struct {
+	name  string
+	value string
+}
+
+func (config *Config) fields() []configField {
+	return []configField{
+		{"BIOSPath", config.BIOSPath},
+		{"ROMPath", config.ROMPath},
+		{"ROMEntry", config.ROMEntry},
+		{"Patches", strings.Join(config.Patches, ", ")},
+		{"CheatsPath", config.CheatsPath},
+		{"SaveDir", config.SaveDir},
+		{"Scale", strconv.FormatFloat(config.Scale, 'f', 2, 64)},
+		{"Filter", config.Filter},
+		{"IntegerScaling", strconv.FormatBool(config.IntegerScaling)},
+		{"ColorCorrection", config.ColorCorrection},
+		{"FrameBlending", strconv.FormatBool(config.FrameBlending)},
+		{"KeyBindings", formatKeyBindings(config.KeyBindings)},
+		{"LogLevel", config.LogLevel},
+		{"TraceRegisters", strconv.FormatBool(config.TraceRegisters)},
+		{"Debug", strconv.FormatBool(config.Debug)},
+		{"Fullscreen", strconv.FormatBool(config.Fullscreen)},
+		{"Interactive", strconv.FormatBool(config.Interactive)},
+		{"GDBAddress", config.GDBAddress},
+		{"SymbolsPath", config.SymbolsPath},
+		{"TracePath", config.TracePath},
+		{"TraceFormat", config.TraceFormat},
+		{"TraceRanges", strings.Join(config.TraceRanges, ", ")},
+		{"TraceModes", config.TraceModes},
+		{"TraceFrames", config.TraceFrames},
+		{"TraceRing", strconv.Itoa(config.TraceRing)},
+		{"TraceWrites", strconv.FormatBool(config.TraceWrites)},
+		{"BlockCache", strconv.FormatBool(config.BlockCache)},
+		{"Lockstep", strconv.FormatBool(config.Lockstep)},
+		{"IdleSkip", strconv.FormatBool(config.IdleSkip)},
+	}
+}
+
+// ToString returns a string representation of the configuration, including
+// where each value came from
 func (config *Config) ToString() string {
-	return "BIOSPath: " + config.BIOSPath + "\n" +
-		"ROMPath: " + config.ROMPath + "\n" +
-		"Scale: " + strconv.FormatFloat(config.Scale, 'f', 2, 64) + "\n" +
-		"TraceRegisters: " + strconv.FormatBool(config.TraceRegisters) + "\n" +
-		"Debug: " + strconv.FormatBool(config.Debug) + "\n" +
-		"Fullscreen: " + strconv.FormatBool(config.Fullscreen) + "\n" +
-		"Interactive: " + strconv.FormatBool(config.Interactive) + "\n"
+	ret := "ConfigPath: " + config.ConfigPath + "\n" +
+		"GameCode: " + config.GameCode + "\n"
+	for _, field := range config.fields() {
+		ret += field.name + ": " + field.value + " (" + string(config.Source(field.name)) + ")\n"
+	}
+	return ret
+}
+
+// LogValue logs the configuration as a group, each value followed by where
+// it came from
+func (config *Config) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("ConfigPath", config.ConfigPath),
+		slog.String("GameCode", config.GameCode),
+	}
+	for _, field := range config.fields() {
+		attrs = append(attrs, slog.String(field.name, field.value+" ("+string(config.Source(field.name))+")"))
+	}
+	return slog.GroupValue(attrs...)
 }
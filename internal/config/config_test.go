@@ -0,0 +1,215 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// The tests set environment variables, so none of them run in parallel
+
+// writeROM writes a ROM whose cartridge header holds the game code BPEE
+func writeROM(t *testing.T) string {
+	t.Helper()
+	rom := make([]byte, 0x200)
+	copy(rom[0xAC:], "BPEE")
+	return writeFile(t, "rom.gba", string(rom))
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// unsetenv unsets an environment variable for the rest of the test
+func unsetenv(t *testing.T, name string) {
+	t.Helper()
+	t.Setenv(name, "")
+	os.Unsetenv(name)
+}
+
+// command returns a command with the flags GetConfig reads, set from args
+func command(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", "", "")
+	cmd.Flags().String("rom", "", "")
+	cmd.Flags().String("filter", "", "")
+	cmd.Flags().Float64("scale", 2.0, "")
+	cmd.Flags().Bool("idle-skip", false, "")
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestPrecedence(t *testing.T) {
+	rom := writeROM(t)
+	tests := []struct {
+		name   string
+		global bool
+		game   bool
+		env    bool
+		flag   bool
+		want   string
+		source config.Source
+	}{
+		{"default", false, false, false, false, "nearest", config.SourceDefault},
+		{"config file", true, false, false, false, "global", config.SourceFile},
+		{"game override", true, true, false, false, "game", config.SourceGame},
+		{"game override without a global value", false, true, false, false, "game", config.SourceGame},
+		{"environment", true, true, true, false, "env", config.SourceEnv},
+		{"flag", true, true, true, true, "flag", config.SourceFlag},
+		{"flag over the defaults", false, false, false, true, "flag", config.SourceFlag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetenv(t, "CONFIG_PATH")
+			unsetenv(t, "ROM_PATH")
+			unsetenv(t, "FILTER")
+
+			var file strings.Builder
+			if tt.global {
+				file.WriteString("filter = \"global\"\n")
+			}
+			if tt.game {
+				file.WriteString("[games.bpee]\nfilter = \"game\"\n")
+			}
+			args := []string{"--config", writeFile(t, "config.toml", file.String()), "--rom", rom}
+			if tt.env {
+				t.Setenv("FILTER", "env")
+			}
+			if tt.flag {
+				args = append(args, "--filter", "flag")
+			}
+
+			cfg := config.GetConfig(command(t, args...))
+			if cfg.GameCode != "BPEE" {
+				t.Errorf("got game code %q, expected BPEE", cfg.GameCode)
+			}
+			if cfg.Filter != tt.want {
+				t.Errorf("got filter %q, expected %q", cfg.Filter, tt.want)
+			}
+			if source := cfg.Source("Filter"); source != tt.source {
+				t.Errorf("got source %q, expected %q", source, tt.source)
+			}
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	unsetenv(t, "CONFIG_PATH")
+	unsetenv(t, "ROM_PATH")
+	unsetenv(t, "IDLE_SKIP")
+	t.Setenv("SCALE", "3")
+	path := writeFile(t, "config.toml", "bios = \"gba_bios.bin\"\nscale = 4\n")
+
+	cfg := config.GetConfig(command(t, "--config", path, "--idle-skip"))
+	if cfg.ConfigPath != path {
+		t.Errorf("got config path %q, expected %q", cfg.ConfigPath, path)
+	}
+	tests := []struct {
+		field  string
+		source config.Source
+	}{
+		{"BIOSPath", config.SourceFile},
+		{"Scale", config.SourceEnv},
+		{"IdleSkip", config.SourceFlag},
+		{"Filter", config.SourceDefault},
+		// The game code is read from the ROM, never from a source
+		{"GameCode", config.SourceDefault},
+	}
+	for _, tt := range tests {
+		if source := cfg.Source(tt.field); source != tt.source {
+			t.Errorf("%s: got source %q, expected %q", tt.field, source, tt.source)
+		}
+	}
+	if cfg.BIOSPath != "gba_bios.bin" || cfg.Scale != 3 || !cfg.IdleSkip {
+		t.Errorf("got bios %q, scale %v and idle skip %v", cfg.BIOSPath, cfg.Scale, cfg.IdleSkip)
+	}
+	if dump := cfg.ToString(); !strings.Contains(dump, "Scale: 3.00 (environment)\n") {
+		t.Errorf("config dump is missing the scale and its source:\n%s", dump)
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	rom := writeROM(t)
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "config.toml",
+			data: "bios = \"gba_bios.bin\"\nscale = 3\n\n[keys]\nA = \"K\"\n\n" +
+				"[log]\nlevel = \"warn\"\n\n[games.BPEE]\nframe_blending = true\n",
+		},
+		{
+			name: "config.yaml",
+			data: "bios: gba_bios.bin\nscale: 3\nkeys:\n  A: K\nlog:\n  level: warn\n" +
+				"games:\n  BPEE:\n    frame_blending: true\n",
+		},
+		{
+			name: "config.yml",
+			data: "bios: gba_bios.bin\nscale: 3\nkeys: {A: K}\nlog: {level: warn}\n" +
+				"games: {BPEE: {frame_blending: true}}\n",
+		},
+		{
+			name: "config.json",
+			data: `{"bios": "gba_bios.bin", "scale": 3, "keys": {"A": "K"}, "log": {"level": "warn"},` +
+				` "games": {"BPEE": {"frame_blending": true}}}`,
+		},
+		{name: "bad.toml", data: "scale = \n", err: "failed to parse"},
+		{name: "bad.json", data: `{"scale": "big"}`, err: "failed to parse"},
+		{name: "config.ini", data: "scale=3\n", err: "unsupported config file format"},
+		{name: "game rom.toml", data: "[games.BPEE]\nrom = \"other.gba\"\n", err: "games.BPEE can't set rom"},
+		{name: "game rom entry.yaml", data: "games:\n  BPEE:\n    rom_entry: other.gba\n", err: "games.BPEE can't set rom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetenv(t, "CONFIG_PATH")
+			unsetenv(t, "ROM_PATH")
+			path := writeFile(t, tt.name, tt.data)
+			if tt.err != "" {
+				err := config.CheckConfigFile(path)
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, expected one containing %q", err, tt.err)
+				}
+				// A config file that can't be read is left out
+				if cfg := config.GetConfig(command(t, "--config", path)); cfg.ConfigPath != "" {
+					t.Errorf("got config path %q, expected none", cfg.ConfigPath)
+				}
+				return
+			}
+
+			cfg := config.GetConfig(command(t, "--config", path, "--rom", rom))
+			if cfg.ConfigPath != path {
+				t.Errorf("got config path %q, expected %q", cfg.ConfigPath, path)
+			}
+			if cfg.BIOSPath != "gba_bios.bin" {
+				t.Errorf("got bios %q, expected gba_bios.bin", cfg.BIOSPath)
+			}
+			if cfg.Scale != 3 {
+				t.Errorf("got scale %v, expected 3", cfg.Scale)
+			}
+			// Rebinding a button keeps the default bindings of the rest
+			if cfg.KeyBindings["a"] != "K" || cfg.KeyBindings["b"] != "Z" {
+				t.Errorf("got key bindings %v", cfg.KeyBindings)
+			}
+			if cfg.LogLevel != "warn" {
+				t.Errorf("got log level %q, expected warn", cfg.LogLevel)
+			}
+			if !cfg.FrameBlending || cfg.Source("FrameBlending") != config.SourceGame {
+				t.Errorf("got frame blending %v from %q, expected the game override", cfg.FrameBlending, cfg.Source("FrameBlending"))
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package config
+
+// CheckConfigFile parses a config file and returns why it can't be used
+func CheckConfigFile(path string) error {
+	_, err := loadConfigFile(path)
+	return err
+}
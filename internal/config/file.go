@@ -0,0 +1,213 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are the names looked up in the config directory, in order
+//
+//nolint:golint,gochecknoglobals
+var configFileNames = []string{"config.toml", "config.yaml", "config.yml", "config.json"}
+
+// fileSettings holds the settings that can be set globally or per game.
+// Pointers are used so unset values can be told apart from zero values.
+type fileSettings struct {
+	BIOSPath        *string           `toml:"bios" yaml:"bios" json:"bios"`
+	ROMPath         *string           `toml:"rom" yaml:"rom" json:"rom"`
+	ROMEntry        *string           `toml:"rom_entry" yaml:"rom_entry" json:"rom_entry"`
+	Patches         []string          `toml:"patches" yaml:"patches" json:"patches"`
+	CheatsPath      *string           `toml:"cheats" yaml:"cheats" json:"cheats"`
+	SaveDir         *string           `toml:"save_dir" yaml:"save_dir" json:"save_dir"`
+	Scale           *float64          `toml:"scale" yaml:"scale" json:"scale"`
+	Filter          *string           `toml:"filter" yaml:"filter" json:"filter"`
+	IntegerScaling  *bool             `toml:"integer_scaling" yaml:"integer_scaling" json:"integer_scaling"`
+	ColorCorrection *string           `toml:"color_correction" yaml:"color_correction" json:"color_correction"`
+	FrameBlending   *bool             `toml:"frame_blending" yaml:"frame_blending" json:"frame_blending"`
+	Fullscreen      *bool             `toml:"fullscreen" yaml:"fullscreen" json:"fullscreen"`
+	TraceRegisters  *bool             `toml:"trace_registers" yaml:"trace_registers" json:"trace_registers"`
+	Debug           *bool             `toml:"debug" yaml:"debug" json:"debug"`
+	BlockCache      *bool             `toml:"block_cache" yaml:"block_cache" json:"block_cache"`
+	IdleSkip        *bool             `toml:"idle_skip" yaml:"idle_skip" json:"idle_skip"`
+	KeyBindings     map[string]string `toml:"keys" yaml:"keys" json:"keys"`
+	Log             logSettings       `toml:"log" yaml:"log" json:"log"`
+}
+
+type logSettings struct {
+	Level *string `toml:"level" yaml:"level" json:"level"`
+}
+
+// configFile is the on-disk configuration. Games holds per-game overrides
+// keyed by the 4 character game code from the cartridge header.
+type configFile struct {
+	fileSettings `yaml:",inline"`
+	Games        map[string]fileSettings `toml:"games" yaml:"games" json:"games"`
+}
+
+// DefaultConfigDir returns the directory holding the config file, $XDG_CONFIG_HOME/go-gba
+func DefaultConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-gba"), nil
+}
+
+// findConfigFile returns the first config file that exists in the config directory
+func findConfigFile() string {
+	dir, err := DefaultConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile parses a TOML, YAML or JSON config file, picked by extension
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &configFile{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = toml.Unmarshal(data, file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, file)
+	case ".json":
+		err = json.Unmarshal(data, file)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// The game code is read from the ROM, so a game can't pick its own ROM
+	for code, settings := range file.Games {
+		if settings.ROMPath != nil || settings.ROMEntry != nil {
+			return nil, fmt.Errorf("failed to parse %s: games.%s can't set rom or rom_entry", path, code)
+		}
+	}
+
+	return file, nil
+}
+
+// game returns the override section for a game code, matched case-insensitively
+func (f *configFile) game(code string) (*fileSettings, bool) {
+	for key, settings := range f.Games {
+		if strings.EqualFold(key, code) {
+			return &settings, true
+		}
+	}
+	return nil, false
+}
+
+// applySettings copies every value set in the file into the config
+//
+//nolint:golint,gocyclo
+func (config *Config) applySettings(settings *fileSettings, source Source) {
+	if settings.BIOSPath != nil {
+		config.BIOSPath = *settings.BIOSPath
+		config.setSource("BIOSPath", source)
+	}
+	if settings.ROMPath != nil {
+		config.ROMPath = *settings.ROMPath
+		config.setSource("ROMPath", source)
+	}
+	if settings.ROMEntry != nil {
+		config.ROMEntry = *settings.ROMEntry
+		config.setSource("ROMEntry", source)
+	}
+	if settings.Patches != nil {
+		config.Patches = settings.Patches
+		config.setSource("Patches", source)
+	}
+	if settings.CheatsPath != nil {
+		config.CheatsPath = *settings.CheatsPath
+		config.setSource("CheatsPath", source)
+	}
+	if settings.SaveDir != nil {
+		config.SaveDir = *settings.SaveDir
+		config.setSource("SaveDir", source)
+	}
+	if settings.Scale != nil {
+		config.Scale = clampScale(*settings.Scale)
+		config.setSource("Scale", source)
+	}
+	if settings.Filter != nil {
+		config.Filter = *settings.Filter
+		config.setSource("Filter", source)
+	}
+	if settings.IntegerScaling != nil {
+		config.IntegerScaling = *settings.IntegerScaling
+		config.setSource("IntegerScaling", source)
+	}
+	if settings.ColorCorrection != nil {
+		config.ColorCorrection = *settings.ColorCorrection
+		config.setSource("ColorCorrection", source)
+	}
+	if settings.FrameBlending != nil {
+		config.FrameBlending = *settings.FrameBlending
+		config.setSource("FrameBlending", source)
+	}
+	if settings.Fullscreen != nil {
+		config.Fullscreen = *settings.Fullscreen
+		config.setSource("Fullscreen", source)
+	}
+	if settings.TraceRegisters != nil {
+		config.TraceRegisters = *settings.TraceRegisters
+		config.setSource("TraceRegisters", source)
+	}
+	if settings.Debug != nil {
+		config.Debug = *settings.Debug
+		config.setSource("Debug", source)
+	}
+	if settings.BlockCache != nil {
+		config.BlockCache = *settings.BlockCache
+		config.setSource("BlockCache", source)
+	}
+	if settings.IdleSkip != nil {
+		config.IdleSkip = *settings.IdleSkip
+		config.setSource("IdleSkip", source)
+	}
+	if len(settings.KeyBindings) > 0 {
+		// Individual buttons can be rebound without repeating the whole map
+		bindings := make(map[string]string, len(config.KeyBindings))
+		for button, key := range config.KeyBindings {
+			bindings[button] = key
+		}
+		for button, key := range settings.KeyBindings {
+			bindings[strings.ToLower(button)] = key
+		}
+		config.KeyBindings = bindings
+		config.setSource("KeyBindings", source)
+	}
+	if settings.Log.Level != nil {
+		config.LogLevel = *settings.Log.Level
+		config.setSource("LogLevel", source)
+	}
+}
+
+// readGameCode returns the 4 character game code from the ROM's cartridge header
+func readGameCode(romPath, romEntry string) string {
+	header, err := cartridge.LoadHeader(romPath, romEntry)
+	if err != nil {
+		return ""
+	}
+	return header.GameCode
+}
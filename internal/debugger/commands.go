@@ -0,0 +1,438 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/disasm"
+	"github.com/USA-RedDragon/go-gba/internal/watch"
+)
+
+type command struct {
+	names []string
+	usage string
+	help  string
+	run   func(d *Debugger, args []string) error
+}
+
+const (
+	// maxFrames bounds the backtrace when frame pointers loop
+	maxFrames = 64
+	// listContext is how many instructions list shows before PC
+	listContext = 3
+)
+
+var errUsage = errors.New("wrong arguments")
+
+func commands() []command {
+	return []command{
+		{[]string{"step", "s", "stepi", "si"}, "step [n]", "execute n instructions (default 1)", (*Debugger).cmdStep},
+		{[]string{"continue", "c"}, "continue", "run until a breakpoint, watchpoint or Ctrl-C", (*Debugger).cmdContinue},
+		{[]string{"until", "u"}, "until vblank | scanline n", "run until VBlank or until the PPU starts a scanline", (*Debugger).cmdUntil},
+		{[]string{"break", "b"}, "break <addr|symbol> [if <cond>]", "stop before executing an address, optionally only when a condition like r0 == 5 or [0x02000000] != 0 holds", (*Debugger).cmdBreak},
+		{[]string{"watch"}, "watch <addr|symbol> [len]", "stop after an instruction writes memory",
+			func(d *Debugger, args []string) error { return d.addWatchpoint(watch.Write, args) }},
+		{[]string{"rwatch"}, "rwatch <addr|symbol> [len]", "stop after an instruction reads memory",
+			func(d *Debugger, args []string) error { return d.addWatchpoint(watch.Read, args) }},
+		{[]string{"awatch"}, "awatch <addr|symbol> [len]", "stop after an instruction reads or writes memory",
+			func(d *Debugger, args []string) error { return d.addWatchpoint(watch.Access, args) }},
+		{[]string{"delete", "d"}, "delete [id]", "delete a breakpoint or watchpoint, or all of them", (*Debugger).cmdDelete},
+		{[]string{"info", "i"}, "info", "list breakpoints and watchpoints", (*Debugger).cmdInfo},
+		{[]string{"regs", "r"}, "regs", "show the registers", (*Debugger).cmdRegs},
+		{[]string{"set"}, "set <reg> <value>", "write a register", (*Debugger).cmdSet},
+		{[]string{"mem", "x"}, "mem <addr|symbol> [len]", "hexdump memory (default 64 bytes)", (*Debugger).cmdMem},
+		{[]string{"write", "w"}, "write <addr|symbol> <value> [8|16|32]", "write memory (default 32 bits)", (*Debugger).cmdWrite},
+		{[]string{"list", "l"}, "list [addr|symbol] [n]", "list instructions around PC or from an address", (*Debugger).cmdList},
+		{[]string{"bt", "backtrace"}, "bt", "show the call stack from frame pointers and LR", (*Debugger).cmdBacktrace},
+		{[]string{"history"}, "history", "list previous commands, rerun one with !n or the last with !!", (*Debugger).cmdHistory},
+		{[]string{"help", "h", "?"}, "help", "show this help", (*Debugger).cmdHelp},
+		{[]string{"quit", "q", "exit"}, "quit", "exit the debugger", (*Debugger).cmdQuit},
+	}
+}
+
+func (d *Debugger) cmdStep(args []string) error {
+	steps := 1
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid step count %q", args[0])
+		}
+		steps = n
+	}
+	d.run(steps, nil)
+	return nil
+}
+
+func (d *Debugger) cmdContinue(_ []string) error {
+	d.run(-1, nil)
+	return nil
+}
+
+func (d *Debugger) cmdUntil(args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "vblank":
+		d.run(-1, d.untilScanline(vblankLine))
+	case len(args) == 2 && args[0] == "scanline":
+		line, err := strconv.ParseUint(args[1], 0, 8)
+		if err != nil || line >= 228 {
+			return fmt.Errorf("invalid scanline %q", args[1])
+		}
+		d.run(-1, d.untilScanline(uint16(line)))
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+func (d *Debugger) cmdBreak(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	addr, err := d.parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+	bp := &breakpoint{id: d.nextID, addr: addr &^ 1}
+	if len(args) > 1 {
+		if args[1] != "if" || len(args) < 3 {
+			return errUsage
+		}
+		bp.condition, err = d.parseCondition(strings.Join(args[2:], " "))
+		if err != nil {
+			return err
+		}
+	}
+	d.nextID++
+	d.breakpoints = append(d.breakpoints, bp)
+	fmt.Fprintf(d.out, "Breakpoint %d at %s\n", bp.id, d.describe(bp.addr))
+	return nil
+}
+
+func (d *Debugger) addWatchpoint(kind watch.Kind, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errUsage
+	}
+	addr, err := d.parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+	length := uint64(4)
+	if len(args) == 2 {
+		length, err = strconv.ParseUint(args[1], 0, 32)
+		if err != nil || length == 0 {
+			return fmt.Errorf("invalid length %q", args[1])
+		}
+	}
+	wp := &watchpoint{id: d.nextID, Point: watch.Point{Kind: kind, Addr: addr, Length: uint32(length)}}
+	d.nextID++
+	d.watchpoints = append(d.watchpoints, wp)
+	d.updateWatchHook()
+	fmt.Fprintf(d.out, "Watchpoint %d (%s) at %s, %d bytes\n", wp.id, kind, d.describe(addr), wp.Length)
+	return nil
+}
+
+func (d *Debugger) cmdDelete(args []string) error {
+	if len(args) == 0 {
+		d.breakpoints = nil
+		d.watchpoints = nil
+		d.updateWatchHook()
+		fmt.Fprintln(d.out, "Deleted all breakpoints and watchpoints")
+		return nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid id %q", args[0])
+	}
+	for i, bp := range d.breakpoints {
+		if bp.id == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return nil
+		}
+	}
+	for i, wp := range d.watchpoints {
+		if wp.id == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			d.updateWatchHook()
+			return nil
+		}
+	}
+	return fmt.Errorf("no breakpoint or watchpoint %d", id)
+}
+
+func (d *Debugger) cmdInfo(_ []string) error {
+	if len(d.breakpoints) == 0 && len(d.watchpoints) == 0 {
+		fmt.Fprintln(d.out, "No breakpoints or watchpoints")
+	}
+	for _, bp := range d.breakpoints {
+		fmt.Fprintf(d.out, "%3d break  %s, hit %d times", bp.id, d.describe(bp.addr), bp.hits)
+		if bp.condition != nil {
+			fmt.Fprintf(d.out, " if %s", bp.condition.text)
+		}
+		fmt.Fprintln(d.out)
+	}
+	for _, wp := range d.watchpoints {
+		fmt.Fprintf(d.out, "%3d %-6s %s, %d bytes\n", wp.id, wp.Kind, d.describe(wp.Addr), wp.Length)
+	}
+	return nil
+}
+
+func (d *Debugger) cmdRegs(_ []string) error {
+	fmt.Fprint(d.out, d.target.DebugRegisters())
+	return nil
+}
+
+func (d *Debugger) cmdSet(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	reg, ok := parseRegister(args[0])
+	if !ok {
+		return fmt.Errorf("unknown register %q", args[0])
+	}
+	value, err := d.parseLocation(args[1])
+	if err != nil {
+		return err
+	}
+	if err := d.target.WriteDebugRegister(reg, value); err != nil {
+		return err
+	}
+	if reg == cpu.PC_REG || reg == cpu.CPSR_REG {
+		d.showLocation()
+	}
+	return nil
+}
+
+func (d *Debugger) cmdMem(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errUsage
+	}
+	addr, err := d.parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+	length := uint64(64)
+	if len(args) == 2 {
+		length, err = strconv.ParseUint(args[1], 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid length %q", args[1])
+		}
+	}
+	bus := d.target.GetMMIO()
+	for line := uint32(0); line < uint32(length); line += 16 {
+		var hexPart, textPart strings.Builder
+		for i := line; i < line+16 && i < uint32(length); i++ {
+			b, err := bus.Read8(addr + i)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&hexPart, "%02x ", b)
+			if b >= 0x20 && b < 0x7F {
+				textPart.WriteByte(b)
+			} else {
+				textPart.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(d.out, "%08X  %-48s %s\n", addr+line, hexPart.String(), textPart.String())
+	}
+	return nil
+}
+
+func (d *Debugger) cmdWrite(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return errUsage
+	}
+	addr, err := d.parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+	value, err := d.parseLocation(args[1])
+	if err != nil {
+		return err
+	}
+	width := "32"
+	if len(args) == 3 {
+		width = args[2]
+	}
+	bus := d.target.GetMMIO()
+	switch width {
+	case "8":
+		if bus.Write8(addr, uint8(value)) == nil {
+			return nil
+		}
+		// VRAM and ROM don't take byte writes, merge into the halfword
+		half, err := bus.Read16(addr &^ 1)
+		if err != nil {
+			return err
+		}
+		shift := (addr & 1) * 8
+		half = half&^(0xFF<<shift) | uint16(uint8(value))<<shift
+		return bus.Patch16(addr&^1, half)
+	case "16":
+		// Write16 refuses read-only memory, so patch instead
+		return bus.Patch16(addr, uint16(value))
+	case "32":
+		if err := bus.Patch16(addr&^3, uint16(value)); err != nil {
+			return err
+		}
+		return bus.Patch16(addr&^3+2, uint16(value>>16))
+	}
+	return fmt.Errorf("invalid width %q", width)
+}
+
+func (d *Debugger) cmdList(args []string) error {
+	width := uint32(4)
+	if d.target.GetThumbMode() {
+		width = 2
+	}
+	count := uint64(8)
+	start := d.target.ReadDebugRegister(cpu.PC_REG) - listContext*width
+	if len(args) > 0 {
+		addr, err := d.parseLocation(args[0])
+		if err != nil {
+			return err
+		}
+		start = addr &^ (width - 1)
+	}
+	if len(args) > 1 {
+		var err error
+		count, err = strconv.ParseUint(args[1], 0, 16)
+		if err != nil {
+			return fmt.Errorf("invalid count %q", args[1])
+		}
+	}
+	d.listing(start, int(count))
+	return nil
+}
+
+// listing prints count instructions from addr in the current state
+func (d *Debugger) listing(addr uint32, count int) {
+	pc := d.target.ReadDebugRegister(cpu.PC_REG)
+	thumb := d.target.GetThumbMode()
+	bus := d.target.GetMMIO()
+	for i := 0; i < count; i++ {
+		marker := "  "
+		if addr == pc {
+			marker = "=>"
+		}
+		text, size, err := disasm.Disassemble(bus, addr, thumb)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(d.out, "%s %s:  %-9s  %s\n", marker, d.describe(addr), disasm.Opcode(bus, addr, size, thumb), text)
+		addr += size
+	}
+}
+
+// cmdBacktrace walks the frame pointer chain. GCC keeps the frame pointer
+// in R11 for ARM code, pointing at the saved LR with the caller's frame
+// pointer below it, and in R7 for THUMB code, pointing at the saved R7
+// with LR above it.
+func (d *Debugger) cmdBacktrace(_ []string) error {
+	thumb := d.target.GetThumbMode()
+	fp := d.target.ReadDebugRegister(11)
+	if thumb {
+		fp = d.target.ReadDebugRegister(7)
+	}
+	bus := d.target.GetMMIO()
+	var returns []uint32
+	for len(returns) < maxFrames && fp >= 0x02000000 && fp < 0x04000000 {
+		var ret, next uint32
+		var err error
+		if thumb {
+			next, err = bus.Read32(fp)
+			if err == nil {
+				ret, err = bus.Read32(fp + 4)
+			}
+		} else {
+			ret, err = bus.Read32(fp)
+			if err == nil {
+				next, err = bus.Read32(fp - 4)
+			}
+		}
+		if err != nil || ret == 0 {
+			break
+		}
+		returns = append(returns, ret&^1)
+		if next <= fp {
+			break
+		}
+		fp = next
+	}
+	// A leaf function may not have pushed a frame, so LR is the only
+	// record of its caller
+	if lr := d.target.ReadDebugRegister(cpu.LR_REG) &^ 1; lr != 0 && (len(returns) == 0 || returns[0] != lr) {
+		returns = append([]uint32{lr}, returns...)
+	}
+
+	fmt.Fprintf(d.out, "#0  %s\n", d.describe(d.target.ReadDebugRegister(cpu.PC_REG)))
+	for i, ret := range returns {
+		fmt.Fprintf(d.out, "#%-2d %s\n", i+1, d.describe(ret))
+	}
+	return nil
+}
+
+func (d *Debugger) cmdHistory(_ []string) error {
+	for i, line := range d.history {
+		fmt.Fprintf(d.out, "%4d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+func (d *Debugger) cmdHelp(_ []string) error {
+	for _, cmd := range commands() {
+		fmt.Fprintf(d.out, "  %-40s %s\n", cmd.usage, cmd.help)
+	}
+	fmt.Fprintln(d.out, "An empty line repeats the last command. There is no line editing, rerun")
+	fmt.Fprintln(d.out, "earlier commands with !n and !! instead.")
+	return nil
+}
+
+func (d *Debugger) cmdQuit(_ []string) error {
+	d.quit = true
+	return nil
+}
+
+// parseLocation parses a number, a symbol or symbol+offset
+func (d *Debugger) parseLocation(text string) (uint32, error) {
+	if value, err := strconv.ParseUint(text, 0, 32); err == nil {
+		return uint32(value), nil
+	}
+	name, offsetText, hasOffset := strings.Cut(text, "+")
+	addr, ok := d.symbols.Lookup(name)
+	if !ok {
+		return 0, fmt.Errorf("%q is not a number or a known symbol", text)
+	}
+	if hasOffset {
+		offset, err := strconv.ParseUint(offsetText, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", offsetText)
+		}
+		addr += uint32(offset)
+	}
+	return addr, nil
+}
+
+// parseRegister parses r0-r15, sp, lr, pc and cpsr
+func parseRegister(text string) (uint8, bool) {
+	switch strings.ToLower(text) {
+	case "sp":
+		return cpu.SP_REG, true
+	case "lr":
+		return cpu.LR_REG, true
+	case "pc":
+		return cpu.PC_REG, true
+	case "cpsr":
+		return cpu.CPSR_REG, true
+	}
+	if !strings.HasPrefix(strings.ToLower(text), "r") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(text[1:], 10, 8)
+	if err != nil || n > 15 {
+		return 0, false
+	}
+	return uint8(n), true
+}
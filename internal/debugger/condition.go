@@ -0,0 +1,91 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operand is a register, a constant, or a word of memory written [addr]
+type operand struct {
+	register  uint8
+	value     uint32
+	isReg     bool
+	isMemory  bool
+	isLiteral bool
+}
+
+// condition compares two operands, like "r0 == 5" or "[0x02000000] != 0"
+type condition struct {
+	left, right operand
+	op          string
+	text        string
+}
+
+//nolint:golint,gochecknoglobals
+var comparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (d *Debugger) parseCondition(text string) (*condition, error) {
+	for _, op := range comparisons {
+		left, right, ok := strings.Cut(text, op)
+		if !ok {
+			continue
+		}
+		l, err := d.parseOperand(strings.TrimSpace(left))
+		if err != nil {
+			return nil, err
+		}
+		r, err := d.parseOperand(strings.TrimSpace(right))
+		if err != nil {
+			return nil, err
+		}
+		return &condition{left: l, right: r, op: op, text: text}, nil
+	}
+	return nil, fmt.Errorf("condition %q needs one of %s", text, strings.Join(comparisons, " "))
+}
+
+func (d *Debugger) parseOperand(text string) (operand, error) {
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		addr, err := d.parseLocation(text[1 : len(text)-1])
+		return operand{value: addr, isMemory: true}, err
+	}
+	if reg, ok := parseRegister(text); ok {
+		return operand{register: reg, isReg: true}, nil
+	}
+	value, err := d.parseLocation(text)
+	return operand{value: value, isLiteral: true}, err
+}
+
+func (o operand) eval(d *Debugger) (uint32, error) {
+	switch {
+	case o.isReg:
+		return d.target.ReadDebugRegister(o.register), nil
+	case o.isMemory:
+		return d.target.GetMMIO().Read32(o.value)
+	}
+	return o.value, nil
+}
+
+func (c *condition) eval(d *Debugger) (bool, error) {
+	left, err := c.left.eval(d)
+	if err != nil {
+		return false, err
+	}
+	right, err := c.right.eval(d)
+	if err != nil {
+		return false, err
+	}
+	switch c.op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<=":
+		return left <= right, nil
+	case ">=":
+		return left >= right, nil
+	case "<":
+		return left < right, nil
+	default:
+		return left > right, nil
+	}
+}
@@ -0,0 +1,265 @@
+// Package debugger is a command-line debugger for the CPU
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/watch"
+)
+
+const (
+	prompt = "(go-gba) "
+	// maxHistory is how many commands the history keeps
+	maxHistory = 500
+	// vcountAddress is the scanline being drawn
+	vcountAddress = 0x04000006
+	vblankLine    = 160
+)
+
+// Target is the CPU being debugged. cpu.ARM7TDMI implements it.
+type Target interface {
+	watch.Target
+	GetThumbMode() bool
+	DebugRegisters() string
+}
+
+type breakpoint struct {
+	id        int
+	addr      uint32
+	condition *condition
+	hits      int
+}
+
+type watchpoint struct {
+	id int
+	watch.Point
+}
+
+type watchHit struct {
+	watchpoint *watchpoint
+	addr       uint32
+	write      bool
+}
+
+// Debugger reads commands and runs the target under their control
+type Debugger struct {
+	target      Target
+	symbols     *Symbols
+	out         io.Writer
+	breakpoints []*breakpoint
+	watchpoints []*watchpoint
+	nextID      int
+	hit         *watchHit
+	interrupted atomic.Bool
+	history     []string
+	quit        bool
+}
+
+// New returns a debugger for target. symbols may be nil.
+func New(target Target, symbols *Symbols) *Debugger {
+	return &Debugger{
+		target:  target,
+		symbols: symbols,
+		nextID:  1,
+	}
+}
+
+// Interrupt stops a running continue, step or until command
+func (d *Debugger) Interrupt() {
+	d.interrupted.Store(true)
+}
+
+// Run reads commands from in until it ends or the quit command. An empty
+// line repeats the previous command, and !! and !N rerun one from the
+// history. When in is a terminal lines can be edited, with up and down
+// going through the history.
+func (d *Debugger) Run(in io.Reader, out io.Writer) error {
+	d.out = out
+	if n := d.symbols.Len(); n > 0 {
+		fmt.Fprintf(out, "Loaded %d symbols\n", n)
+	}
+	fmt.Fprintln(out, `Type "help" for a list of commands`)
+	d.showLocation()
+
+	lines := newLineReader(in, out)
+	for !d.quit {
+		text, err := lines.readLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, err := d.expandHistory(strings.TrimSpace(text))
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		d.remember(line)
+		if err := d.execute(line); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// expandHistory resolves an empty line, !! and !N to a previous command
+func (d *Debugger) expandHistory(line string) (string, error) {
+	switch {
+	case line == "" || line == "!!":
+		if len(d.history) == 0 {
+			return "", nil
+		}
+		return d.history[len(d.history)-1], nil
+	case strings.HasPrefix(line, "!"):
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 1 || n > len(d.history) {
+			return "", fmt.Errorf("no command %s in the history", line)
+		}
+		return d.history[n-1], nil
+	}
+	return line, nil
+}
+
+func (d *Debugger) remember(line string) {
+	if len(d.history) > 0 && d.history[len(d.history)-1] == line {
+		return
+	}
+	d.history = append(d.history, line)
+	if len(d.history) > maxHistory {
+		d.history = d.history[1:]
+	}
+}
+
+func (d *Debugger) execute(line string) error {
+	fields := strings.Fields(line)
+	name := strings.ToLower(fields[0])
+	for _, cmd := range commands() {
+		for _, alias := range cmd.names {
+			if alias == name {
+				return cmd.run(d, fields[1:])
+			}
+		}
+	}
+	return fmt.Errorf("unknown command %q, try help", name)
+}
+
+// run steps the target until it has run steps instructions (forever when
+// negative), until returns true, it hits a breakpoint or watchpoint, or
+// the CPU fails
+func (d *Debugger) run(steps int, until func() bool) {
+	d.interrupted.Store(false)
+	d.hit = nil
+	for i := 0; steps < 0 || i < steps; i++ {
+		if err := d.target.StepInstruction(); err != nil {
+			fmt.Fprintf(d.out, "Stopped: %v\n", err)
+			break
+		}
+		if d.hit != nil {
+			action := "read"
+			if d.hit.write {
+				action = "written"
+			}
+			fmt.Fprintf(d.out, "Watchpoint %d: 0x%08X %s\n", d.hit.watchpoint.id, d.hit.addr, action)
+			d.hit = nil
+			break
+		}
+		if until != nil && until() {
+			break
+		}
+		if d.checkBreakpoints() {
+			break
+		}
+		if d.interrupted.Load() {
+			fmt.Fprintln(d.out, "Interrupted")
+			break
+		}
+	}
+	d.showLocation()
+}
+
+// checkBreakpoints reports whether a breakpoint stops the target at PC
+func (d *Debugger) checkBreakpoints() bool {
+	pc := d.target.ReadDebugRegister(cpu.PC_REG)
+	for _, bp := range d.breakpoints {
+		if bp.addr != pc {
+			continue
+		}
+		if bp.condition != nil {
+			ok, err := bp.condition.eval(d)
+			if err != nil {
+				fmt.Fprintf(d.out, "Breakpoint %d: %v\n", bp.id, err)
+				return true
+			}
+			if !ok {
+				continue
+			}
+		}
+		bp.hits++
+		fmt.Fprintf(d.out, "Breakpoint %d, %s\n", bp.id, d.describe(pc))
+		return true
+	}
+	return false
+}
+
+// watch is called for every memory access of an executing instruction
+func (d *Debugger) watch(addr uint32, size uint8, write bool) {
+	for _, wp := range d.watchpoints {
+		if wp.Matches(addr, size, write) {
+			d.hit = &watchHit{watchpoint: wp, addr: addr, write: write}
+			return
+		}
+	}
+}
+
+func (d *Debugger) updateWatchHook() {
+	if len(d.watchpoints) > 0 {
+		d.target.SetWatchHook(d.watch)
+	} else {
+		d.target.SetWatchHook(nil)
+	}
+}
+
+// showLocation prints the next instruction
+func (d *Debugger) showLocation() {
+	pc := d.target.ReadDebugRegister(cpu.PC_REG)
+	d.listing(pc, 1)
+}
+
+// describe formats an address with the symbol it falls in
+func (d *Debugger) describe(addr uint32) string {
+	if name := d.symbols.Describe(addr); name != "" {
+		return fmt.Sprintf("0x%08X <%s>", addr, name)
+	}
+	return fmt.Sprintf("0x%08X", addr)
+}
+
+// scanline returns the line the PPU is drawing
+func (d *Debugger) scanline() uint16 {
+	vcount, err := d.target.GetMMIO().Read16(vcountAddress)
+	if err != nil {
+		return 0
+	}
+	return vcount & 0xFF
+}
+
+// untilScanline returns a predicate that becomes true when the PPU starts
+// drawing line
+func (d *Debugger) untilScanline(line uint16) func() bool {
+	previous := d.scanline()
+	return func() bool {
+		current := d.scanline()
+		started := current == line && previous != line
+		previous = current
+		return started
+	}
+}
@@ -0,0 +1,197 @@
+package debugger_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/debugger"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+func loadSymbols(t *testing.T) *debugger.Symbols {
+	t.Helper()
+	symbols, err := debugger.LoadSymbols("testdata/symbols.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return symbols
+}
+
+func TestSymbols(t *testing.T) {
+	t.Parallel()
+	symbols := loadSymbols(t)
+	// The local label and the section symbol are left out
+	if n := symbols.Len(); n != 3 {
+		t.Errorf("loaded %d symbols, expected 3", n)
+	}
+	for name, want := range map[string]uint32{"start": 0x08000000, "main": 0x08000100, "counter": 0x03000000} {
+		if addr, ok := symbols.Lookup(name); !ok || addr != want {
+			t.Errorf("%s is at 0x%08X, %v, expected 0x%08X", name, addr, ok, want)
+		}
+	}
+	if _, ok := symbols.Lookup("loop"); ok {
+		t.Error("found the local label loop")
+	}
+
+	for addr, want := range map[uint32]string{
+		0x08000000: "start",
+		// start has no size, so it runs up to main
+		0x080000FF: "start+0xff",
+		0x08000100: "main",
+		0x08000106: "main+0x6",
+		0x08000108: "",
+		0x03000003: "counter+0x3",
+		0x03000004: "",
+		0x02000000: "",
+	} {
+		if got := symbols.Describe(addr); got != want {
+			t.Errorf("described 0x%08X as %q, expected %q", addr, got, want)
+		}
+	}
+
+	var none *debugger.Symbols
+	if _, ok := none.Lookup("main"); ok || none.Len() != 0 || none.Describe(0x08000000) != "" {
+		t.Error("nil symbols aren't empty")
+	}
+	if _, err := debugger.LoadSymbols("testdata/missing.elf"); err == nil {
+		t.Error("expected an error loading a missing file")
+	}
+	notELF := filepath.Join(t.TempDir(), "game.gba")
+	if err := os.WriteFile(notELF, testutil.ROM(nil), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := debugger.LoadSymbols(notELF); err == nil {
+		t.Error("expected an error loading a ROM as an ELF")
+	}
+}
+
+func TestCondition(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, testutil.CountLoops, nil)
+	for reg, value := range map[uint8]uint32{0: 5, 1: 0xFFFFFFFF, 2: 0x08000104} {
+		if err := c.WriteDebugRegister(reg, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.GetMMIO().Write32(0x02000000, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.GetMMIO().Write32(0x03000000, 0x11); err != nil {
+		t.Fatal(err)
+	}
+	d := debugger.New(c, loadSymbols(t))
+
+	tests := []struct {
+		text string
+		want bool
+		err  string
+	}{
+		{text: "r0 == 5", want: true},
+		{text: "r0==6"},
+		{text: "R0 != 6", want: true},
+		{text: "r0 <= 5", want: true},
+		{text: "r0 >= 6"},
+		{text: "r0 < 6", want: true},
+		{text: "r0 > 5"},
+		// Unsigned, so -1 is the largest value
+		{text: "r1 > 0x7FFFFFFF", want: true},
+		{text: "4 < r0", want: true},
+		{text: "sp == 0x03007F00", want: true},
+		{text: "r2 == main+4", want: true},
+		{text: "[0x02000000] == 7", want: true},
+		{text: "[counter] == 0x11", want: true},
+		{text: "[counter+4] == 0", want: true},
+		{text: "r0 = 5", err: "needs one of"},
+		{text: "r16 == 1", err: `"r16" is not a number or a known symbol`},
+		{text: "r0 == main+x", err: `invalid offset "x"`},
+		{text: "[nowhere] == 1", err: `"nowhere" is not a number or a known symbol`},
+		{text: "[0x04000400] == 0", err: "0x04000400"},
+	}
+	for _, tt := range tests {
+		got, err := d.Condition(tt.text)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got error %v, expected one containing %q", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+		} else if got != tt.want {
+			t.Errorf("%q is %v, expected %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// run feeds the commands to a debugger on CountLoops and returns what it
+// printed after the banner
+func run(t *testing.T, commands ...string) string {
+	t.Helper()
+	c := testutil.NewCPU(t, testutil.CountLoops, nil)
+	var out strings.Builder
+	d := debugger.New(c, loadSymbols(t))
+	if err := d.Run(strings.NewReader(strings.Join(commands, "\n")+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	_, after, _ := strings.Cut(out.String(), "(go-gba) ")
+	return "(go-gba) " + after
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	got := run(t,
+		"!!",
+		"!1",
+		"break start+0x10",
+		"info",
+		"!!",
+		"",
+		"!1",
+		"!x",
+		"!0",
+		"!4",
+		"history",
+		"quit",
+	)
+	want := strings.Join([]string{
+		// Nothing to repeat yet
+		"(go-gba) (go-gba) Error: no command !1 in the history",
+		"(go-gba) Breakpoint 1 at 0x08000010 <start+0x10>",
+		"(go-gba)   1 break  0x08000010 <start+0x10>, hit 0 times",
+		"(go-gba)   1 break  0x08000010 <start+0x10>, hit 0 times",
+		"(go-gba)   1 break  0x08000010 <start+0x10>, hit 0 times",
+		"(go-gba) Breakpoint 2 at 0x08000010 <start+0x10>",
+		"(go-gba) Error: no command !x in the history",
+		"(go-gba) Error: no command !0 in the history",
+		"(go-gba) Error: no command !4 in the history",
+		// Repeats aren't added twice in a row
+		"(go-gba)    1  break start+0x10",
+		"   2  info",
+		"   3  break start+0x10",
+		"   4  history",
+		"(go-gba) ",
+	}, "\n")
+	if got != want {
+		t.Errorf("got\n%s\nexpected\n%s", got, want)
+	}
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	t.Parallel()
+	// The ARM loop adds r1 to r0 counting r1 down from 100
+	got := run(t, "break 0x08000008 if r1 == 0x60", "continue", "regs", "quit")
+	for _, want := range []string{
+		"Breakpoint 1 at 0x08000008 <start+0x8>",
+		"Breakpoint 1, 0x08000008 <start+0x8>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, got)
+		}
+	}
+	// 100 + 99 + 98 + 97 have been added when r1 reaches 96
+	if !strings.Contains(got, "R0: 0x0000018A") || !strings.Contains(got, "R1: 0x00000060") {
+		t.Errorf("stopped with the wrong registers:\n%s", got)
+	}
+}
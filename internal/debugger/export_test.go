@@ -0,0 +1,10 @@
+package debugger
+
+// Condition parses a breakpoint condition and evaluates it right away
+func (d *Debugger) Condition(text string) (bool, error) {
+	c, err := d.parseCondition(text)
+	if err != nil {
+		return false, err
+	}
+	return c.eval(d)
+}
@@ -0,0 +1,96 @@
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	ctrlC = 0x03
+	ctrlU = 0x15
+)
+
+// lineReader reads commands at the prompt
+type lineReader interface {
+	readLine() (string, error)
+}
+
+// newLineReader edits lines in place with up and down recalling the
+// history when in is a terminal, and reads them as they come otherwise
+func newLineReader(in io.Reader, out io.Writer) lineReader {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{keyReader{f}, out}
+		return &terminalReader{fd: int(f.Fd()), terminal: term.NewTerminal(rw, prompt)}
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// scannerReader reads lines from a pipe or file
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) readLine() (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		fmt.Fprintln(r.out)
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// terminalReader puts the terminal in raw mode only while a line is read,
+// so Ctrl-C still interrupts a running command
+type terminalReader struct {
+	fd       int
+	terminal *term.Terminal
+}
+
+func (r *terminalReader) readLine() (string, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(r.fd, state)
+	if width, height, err := term.GetSize(r.fd); err == nil && width > 0 {
+		_ = r.terminal.SetSize(width, height)
+	}
+	line, err := r.terminal.ReadLine()
+	if errors.Is(err, io.EOF) {
+		fmt.Fprint(r.terminal, "\n")
+	}
+	return line, err
+}
+
+// keyReader turns Ctrl-C into Ctrl-U, which clears the line like a shell
+// does where the terminal would end the input. Lines typed ahead while a
+// command ran end in \n, which the terminal only takes as Enter in raw
+// mode's \r.
+type keyReader struct {
+	r io.Reader
+}
+
+func (k keyReader) Read(p []byte) (int, error) {
+	n, err := k.r.Read(p)
+	for i, b := range p[:n] {
+		switch b {
+		case ctrlC:
+			p[i] = ctrlU
+		case '\n':
+			p[i] = '\r'
+		}
+	}
+	return n, err
+}
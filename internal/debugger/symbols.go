@@ -0,0 +1,96 @@
+package debugger
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+)
+
+type symbol struct {
+	name  string
+	addr  uint32
+	size  uint32
+	thumb bool
+}
+
+// Symbols maps the function and object names of an ELF to addresses
+type Symbols struct {
+	byName map[string]symbol
+	sorted []symbol
+}
+
+// LoadSymbols reads the symbol table of an ELF, like the one devkitARM
+// links before objcopy turns it into a .gba
+func LoadSymbols(path string) (*Symbols, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	elfSymbols, err := file.Symbols()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read symbols from %s: %w", path, err)
+	}
+
+	symbols := &Symbols{byName: map[string]symbol{}}
+	for _, s := range elfSymbols {
+		kind := elf.ST_TYPE(s.Info)
+		if s.Name == "" || (kind != elf.STT_FUNC && kind != elf.STT_OBJECT) {
+			continue
+		}
+		// THUMB functions have bit 0 of their address set
+		sym := symbol{
+			name:  s.Name,
+			addr:  uint32(s.Value) &^ 1,
+			size:  uint32(s.Size),
+			thumb: kind == elf.STT_FUNC && s.Value&1 != 0,
+		}
+		symbols.byName[sym.name] = sym
+		symbols.sorted = append(symbols.sorted, sym)
+	}
+	sort.Slice(symbols.sorted, func(i, j int) bool {
+		return symbols.sorted[i].addr < symbols.sorted[j].addr
+	})
+	return symbols, nil
+}
+
+// Len returns the number of symbols
+func (s *Symbols) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.sorted)
+}
+
+// Lookup returns the address of a symbol
+func (s *Symbols) Lookup(name string) (uint32, bool) {
+	if s == nil {
+		return 0, false
+	}
+	sym, ok := s.byName[name]
+	return sym.addr, ok
+}
+
+// Describe names an address as symbol+offset, or returns "" outside of
+// every symbol
+func (s *Symbols) Describe(addr uint32) string {
+	if s == nil {
+		return ""
+	}
+	i := sort.Search(len(s.sorted), func(i int) bool {
+		return s.sorted[i].addr > addr
+	}) - 1
+	if i < 0 {
+		return ""
+	}
+	// Symbols without a size, like assembly labels, cover everything up to
+	// the next symbol
+	sym := s.sorted[i]
+	if sym.size != 0 && addr >= sym.addr+sym.size {
+		return ""
+	}
+	if addr == sym.addr {
+		return sym.name
+	}
+	return fmt.Sprintf("%s+0x%x", sym.name, addr-sym.addr)
+}
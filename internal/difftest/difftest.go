@@ -0,0 +1,132 @@
+// Package difftest steps the CPU in lockstep with a trace recorded by a
+// reference emulator and finds where they first disagree
+package difftest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/disasm"
+	"github.com/USA-RedDragon/go-gba/internal/trace"
+)
+
+// CPU is the emulator being tested
+type CPU interface {
+	SetTracer(tracer trace.Tracer, writes bool)
+	StepInstruction() error
+}
+
+// Options configures a run
+type Options struct {
+	// Writes compares the memory writes of each instruction, which the
+	// reference trace must have recorded
+	Writes bool
+	// Context is the number of instructions shown before a divergence
+	Context int
+	// Limit stops after this many instructions, 0 runs the whole trace
+	Limit uint64
+}
+
+// Divergence is the first instruction where the CPU disagreed with the
+// reference
+type Divergence struct {
+	// Index is the number of instructions that matched before it
+	Index       uint64
+	Want, Got   trace.Record
+	Differences []trace.Difference
+	// Crash is set when the CPU failed instead of executing the
+	// instruction
+	Crash string
+	// Context holds the instructions before it, oldest first
+	Context []trace.Record
+}
+
+// recorder keeps the record of the last executed instruction
+type recorder struct {
+	last *trace.Record
+}
+
+func (r *recorder) Match(uint32, uint8, uint64) bool {
+	return true
+}
+
+func (r *recorder) Write(record *trace.Record) error {
+	r.last = record
+	return nil
+}
+
+// Run steps c one instruction per reference record. It returns the first
+// divergence, or nil, and the number of instructions that matched.
+func Run(c CPU, reference *trace.Reader, opts Options) (*Divergence, uint64, error) {
+	rec := &recorder{}
+	c.SetTracer(rec, opts.Writes)
+	defer c.SetTracer(nil, false)
+
+	var context []trace.Record
+	for index := uint64(0); opts.Limit == 0 || index < opts.Limit; index++ {
+		want, err := reference.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, index, nil
+		}
+		if err != nil {
+			return nil, index, fmt.Errorf("reading the reference trace: %w", err)
+		}
+
+		rec.last = nil
+		if err := c.StepInstruction(); err != nil {
+			return &Divergence{Index: index, Want: *want, Crash: err.Error(), Context: context}, index, nil
+		}
+		if rec.last == nil {
+			return nil, index, fmt.Errorf("the CPU halted after %d instructions", index)
+		}
+		if differences := trace.Compare(want, rec.last, opts.Writes); len(differences) > 0 {
+			return &Divergence{Index: index, Want: *want, Got: *rec.last, Differences: differences, Context: context}, index, nil
+		}
+
+		if opts.Context > 0 {
+			if len(context) == opts.Context {
+				context = context[1:]
+			}
+			context = append(context, *rec.last)
+		}
+	}
+	return nil, opts.Limit, nil
+}
+
+func formatInstruction(r *trace.Record) string {
+	if r.Thumb() {
+		return fmt.Sprintf("%08x      %04x  %s", r.Address, r.Opcode&0xFFFF, disasm.Thumb(uint16(r.Opcode), r.Address))
+	}
+	return fmt.Sprintf("%08x  %08x  %s", r.Address, r.Opcode, disasm.ARM(r.Opcode, r.Address))
+}
+
+// Report prints the divergence with the instructions leading up to it
+func (d *Divergence) Report(w io.Writer) {
+	fmt.Fprintf(w, "Diverged from the reference after %d matching instructions\n\n", d.Index)
+	for i := range d.Context {
+		fmt.Fprintf(w, "  %s\n", formatInstruction(&d.Context[i]))
+	}
+	fmt.Fprintf(w, "> %s\n\n", formatInstruction(&d.Want))
+	if d.Crash != "" {
+		fmt.Fprintf(w, "The CPU crashed executing it: %s\n", d.Crash)
+		return
+	}
+	for _, difference := range d.Differences {
+		fmt.Fprintf(w, "%s\n", difference)
+	}
+	fmt.Fprintln(w, "\nRegisters are compared before the instruction marked with >, so register differences come from the one above it.")
+	fmt.Fprintf(w, "\n%-6s %-10s %-10s\n", "", "want", "got")
+	for i := range d.Want.Registers {
+		marker := ""
+		if d.Want.Registers[i] != d.Got.Registers[i] {
+			marker = " *"
+		}
+		fmt.Fprintf(w, "%-6s %08x   %08x%s\n", fmt.Sprintf("r%d", i), d.Want.Registers[i], d.Got.Registers[i], marker)
+	}
+	marker := ""
+	if d.Want.CPSR != d.Got.CPSR {
+		marker = " *"
+	}
+	fmt.Fprintf(w, "%-6s %08x   %08x%s\n", "cpsr", d.Want.CPSR, d.Got.CPSR, marker)
+}
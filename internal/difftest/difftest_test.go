@@ -0,0 +1,121 @@
+package difftest_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/difftest"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+	"github.com/USA-RedDragon/go-gba/internal/trace"
+)
+
+// fixtureRecords is the number of instructions in testdata/countloops.log
+const fixtureRecords = 457
+
+// fixture reads testdata/countloops.log, with tamper applied to the lines
+// of its records
+func fixture(t *testing.T, tamper func(lines []string)) *trace.Reader {
+	t.Helper()
+	data, err := os.ReadFile("testdata/countloops.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if tamper != nil {
+		// The first line is a comment
+		tamper(lines[1:])
+	}
+	reference, err := trace.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reference
+}
+
+func TestRunFixture(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, testutil.CountLoops, nil)
+	divergence, count, err := difftest.Run(c, fixture(t, nil), difftest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if divergence != nil {
+		var report bytes.Buffer
+		divergence.Report(&report)
+		t.Fatalf("diverged from the fixture:\n%s", report.String())
+	}
+	if count != fixtureRecords {
+		t.Errorf("matched %d instructions, expected %d", count, fixtureRecords)
+	}
+
+	c = testutil.NewCPU(t, testutil.CountLoops, nil)
+	if _, count, _ := difftest.Run(c, fixture(t, nil), difftest.Options{Limit: 10}); count != 10 {
+		t.Errorf("matched %d instructions with a limit of 10", count)
+	}
+}
+
+func TestRunTampered(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		tamper func(lines []string)
+		index  uint64
+		field  string
+	}{
+		{
+			// r0 after 50 iterations of the ARM loop
+			name:   "register",
+			tamper: func(lines []string) { lines[152] = "0000FFFF" + lines[152][8:] },
+			index:  152,
+			field:  "r0",
+		},
+		{
+			name:   "cpsr",
+			tamper: func(lines []string) { lines[300] = strings.Replace(lines[300], "cpsr: 2000001F", "cpsr: 0000001F", 1) },
+			index:  300,
+			field:  "cpsr",
+		},
+		{
+			name:   "opcode",
+			tamper: func(lines []string) { lines[399] = strings.Replace(lines[399], "3B01:", "3B02:", 1) },
+			index:  399,
+			field:  "opcode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := testutil.NewCPU(t, testutil.CountLoops, nil)
+			divergence, count, err := difftest.Run(c, fixture(t, tt.tamper), difftest.Options{Context: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if divergence == nil {
+				t.Fatalf("matched all %d instructions of the tampered trace", count)
+			}
+			if divergence.Index != tt.index {
+				t.Errorf("diverged after %d instructions, expected %d", divergence.Index, tt.index)
+			}
+			if len(divergence.Differences) != 1 || divergence.Differences[0].Field != tt.field {
+				t.Errorf("got differences %v, expected only %s", divergence.Differences, tt.field)
+			}
+			if len(divergence.Context) != 3 {
+				t.Errorf("got %d instructions of context, expected 3", len(divergence.Context))
+			}
+
+			var report bytes.Buffer
+			divergence.Report(&report)
+			for _, want := range []string{
+				fmt.Sprintf("after %d matching instructions", tt.index),
+				tt.field + ": want",
+			} {
+				if !strings.Contains(report.String(), want) {
+					t.Errorf("report doesn't contain %q:\n%s", want, report.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package cartridge
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/sevenzip"
+)
+
+// MaxROMSize is the largest ROM that fits in the 32MB Game Pak address space
+const MaxROMSize = 0x2000000
+
+// archiveEntry is a file inside a ROM archive
+type archiveEntry struct {
+	name string
+	// size is the uncompressed size
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+// LoadROM reads a ROM image from disk. Zip, 7z and gzip archives are
+// decompressed in memory. If the archive holds more than one .gba file,
+// entry names the one to load.
+func LoadROM(path, entry string) ([]byte, error) {
+	return load(path, entry, readROM)
+}
+
+// LoadHeader reads the cartridge header of a ROM on disk like LoadROM,
+// without reading or decompressing the rest of the ROM
+func LoadHeader(path, entry string) (*Header, error) {
+	header, err := load(path, entry, readHeader)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHeader(header)
+}
+
+// load opens the ROM at path, or its entry in an archive, and reads it
+// with read
+func load(path, entry string, read func(io.Reader) ([]byte, error)) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return loadArchiveEntry(path, zipEntries(r), entry, read)
+	case ".7z":
+		r, err := sevenzip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer r.Close()
+		return loadArchiveEntry(path, sevenZipEntries(r), entry, read)
+	case ".gz":
+		return loadGzip(path, read)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return read(f)
+}
+
+// readROM reads a whole ROM, refusing anything larger than MaxROMSize
+// without reading past the limit
+func readROM(r io.Reader) ([]byte, error) {
+	rom, err := io.ReadAll(io.LimitReader(r, MaxROMSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(rom) > MaxROMSize {
+		return nil, fmt.Errorf("ROM is larger than the maximum of %d bytes", MaxROMSize)
+	}
+	return rom, nil
+}
+
+// readHeader reads the cartridge header, or as much of it as a short ROM
+// holds, which ParseHeader rejects
+func readHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(r, header)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return header[:n], err
+}
+
+func zipEntries(r *zip.ReadCloser) []archiveEntry {
+	entries := make([]archiveEntry, 0, len(r.File))
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, archiveEntry{
+			name: f.Name,
+			size: int64(f.UncompressedSize64),
+			open: f.Open,
+		})
+	}
+	return entries
+}
+
+func sevenZipEntries(r *sevenzip.ReadCloser) []archiveEntry {
+	entries := make([]archiveEntry, 0, len(r.File))
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, archiveEntry{
+			name: f.Name,
+			size: int64(f.UncompressedSize),
+			open: f.Open,
+		})
+	}
+	return entries
+}
+
+// loadGzip decompresses a gzipped ROM. Gzip holds a single file, so there is
+// no entry to pick.
+func loadGzip(path string, read func(io.Reader) ([]byte, error)) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer r.Close()
+	return read(r)
+}
+
+func loadArchiveEntry(path string, entries []archiveEntry, name string, read func(io.Reader) ([]byte, error)) ([]byte, error) {
+	entry, err := pickEntry(entries, name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if entry.size > MaxROMSize {
+		return nil, fmt.Errorf("%s: %s is %d bytes, larger than the maximum of %d", path, entry.name, entry.size, MaxROMSize)
+	}
+	r, err := entry.open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to open %s: %w", path, entry.name, err)
+	}
+	defer r.Close()
+	rom, err := read(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read %s: %w", path, entry.name, err)
+	}
+	return rom, nil
+}
+
+// pickEntry finds the named entry, matching either the full path or the
+// base name. Without a name the archive must hold exactly one .gba file.
+func pickEntry(entries []archiveEntry, name string) (*archiveEntry, error) {
+	if name != "" {
+		for i := range entries {
+			if entries[i].name == name || filepath.Base(entries[i].name) == name {
+				return &entries[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no entry named %q, archive contains: %s", name, entryNames(entries))
+	}
+
+	var roms []*archiveEntry
+	for i := range entries {
+		if strings.EqualFold(filepath.Ext(entries[i].name), ".gba") {
+			roms = append(roms, &entries[i])
+		}
+	}
+	switch len(roms) {
+	case 1:
+		return roms[0], nil
+	case 0:
+		return nil, fmt.Errorf("no .gba file found, archive contains: %s", entryNames(entries))
+	default:
+		return nil, fmt.Errorf("archive contains several .gba files, pick one with --rom-entry: %s", entryNames(entries))
+	}
+}
+
+func entryNames(entries []archiveEntry) string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,149 @@
+package cartridge_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+)
+
+type file struct {
+	name string
+	data []byte
+}
+
+func writeZip(t *testing.T, files ...file) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, "rom.zip", buf.Bytes())
+}
+
+func writeGzip(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, "rom.gba.gz", buf.Bytes())
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadROM(t *testing.T) {
+	t.Parallel()
+	large := make([]byte, cartridge.MaxROMSize+1)
+	multiZip := writeZip(t,
+		file{"readme.txt", []byte("two games")},
+		file{"roms/first.gba", []byte("FIRST")},
+		file{"roms/second.gba", []byte("SECOND")},
+	)
+
+	tests := []struct {
+		name  string
+		path  string
+		entry string
+		want  []byte
+		err   string
+	}{
+		{name: "plain", path: writeFile(t, "rom.gba", []byte("PLAIN")), want: []byte("PLAIN")},
+		{name: "plain too large", path: writeFile(t, "rom.gba", large), err: "larger than the maximum"},
+		{name: "zip", path: writeZip(t, file{"game.gba", []byte("ZIP")}), want: []byte("ZIP")},
+		{name: "zip entry by base name", path: multiZip, entry: "second.gba", want: []byte("SECOND")},
+		{name: "zip entry by path", path: multiZip, entry: "roms/first.gba", want: []byte("FIRST")},
+		{name: "zip ambiguous", path: multiZip, err: "pick one with --rom-entry"},
+		{name: "zip missing entry", path: multiZip, entry: "third.gba", err: `no entry named "third.gba"`},
+		{name: "zip without a ROM", path: writeZip(t, file{"readme.txt", nil}), err: "no .gba file found"},
+		{name: "zip too large", path: writeZip(t, file{"game.gba", large}), err: "larger than the maximum"},
+		{name: "gzip", path: writeGzip(t, []byte("GZIP")), want: []byte("GZIP")},
+		// Gzip doesn't record the size, only decompressing shows it's too large
+		{name: "gzip too large", path: writeGzip(t, large), err: "larger than the maximum"},
+		{name: "7z lzma", path: "testdata/lzma.7z", want: bytes.Repeat([]byte("LZMA ROM"), 64)},
+		{name: "7z lzma2", path: "testdata/lzma2.7z", want: bytes.Repeat([]byte("LZMA2 ROM"), 64)},
+		{name: "7z entry by base name", path: "testdata/multi.7z", entry: "second.gba", want: bytes.Repeat([]byte("SECOND"), 64)},
+		{name: "7z entry by path", path: "testdata/multi.7z", entry: "roms/first.gba", want: bytes.Repeat([]byte("FIRST"), 64)},
+		{name: "7z ambiguous", path: "testdata/multi.7z", err: "pick one with --rom-entry"},
+		{name: "7z too large", path: "testdata/large.7z", err: "larger than the maximum"},
+		{name: "missing", path: "testdata/missing.7z", err: "missing.7z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rom, err := cartridge.LoadROM(tt.path, tt.entry)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, expected one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(rom, tt.want) {
+				t.Errorf("got %q, expected %q", rom, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHeader(t *testing.T) {
+	t.Parallel()
+	rom := make([]byte, 0x10000)
+	copy(rom[0xAC:], "BPEE")
+	tests := []struct {
+		name string
+		path string
+		err  string
+	}{
+		{name: "plain", path: writeFile(t, "rom.gba", rom)},
+		{name: "zip", path: writeZip(t, file{"rom.gba", rom})},
+		{name: "gzip", path: writeGzip(t, rom)},
+		{name: "short", path: writeFile(t, "short.gba", rom[:0xB0]), err: "too small"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			header, err := cartridge.LoadHeader(tt.path, "")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, expected one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.GameCode != "BPEE" {
+				t.Errorf("got game code %q, expected BPEE", header.GameCode)
+			}
+		})
+	}
+}
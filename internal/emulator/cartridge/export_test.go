@@ -0,0 +1,6 @@
+package cartridge
+
+// NintendoLogo lets the tests build headers the BIOS would accept
+//
+//nolint:golint,gochecknoglobals
+var NintendoLogo = nintendoLogo
@@ -0,0 +1,147 @@
+package cartridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	// HeaderSize is the size of the cartridge header at the start of the ROM
+	HeaderSize = 0xC0
+
+	// FixedValue must be present at 0xB2 in every header
+	FixedValue = 0x96
+
+	// ROMBase is the address the ROM is mapped to
+	ROMBase = 0x08000000
+
+	logoOffset       = 0x04
+	titleOffset      = 0xA0
+	gameCodeOffset   = 0xAC
+	makerCodeOffset  = 0xB0
+	fixedValueOffset = 0xB2
+	unitCodeOffset   = 0xB3
+	deviceTypeOffset = 0xB4
+	versionOffset    = 0xBC
+	checksumOffset   = 0xBD
+)
+
+// nintendoLogo is the compressed logo bitmap the BIOS checks on boot
+//
+//nolint:golint,gochecknoglobals
+var nintendoLogo = [156]byte{
+	0x24, 0xFF, 0xAE, 0x51, 0x69, 0x9A, 0xA2, 0x21, 0x3D, 0x84, 0x82, 0x0A,
+	0x84, 0xE4, 0x09, 0xAD, 0x11, 0x24, 0x8B, 0x98, 0xC0, 0x81, 0x7F, 0x21,
+	0xA3, 0x52, 0xBE, 0x19, 0x93, 0x09, 0xCE, 0x20, 0x10, 0x46, 0x4A, 0x4A,
+	0xF8, 0x27, 0x31, 0xEC, 0x58, 0xC7, 0xE8, 0x33, 0x82, 0xE3, 0xCE, 0xBF,
+	0x85, 0xF4, 0xDF, 0x94, 0xCE, 0x4B, 0x09, 0xC1, 0x94, 0x56, 0x8A, 0xC0,
+	0x13, 0x72, 0xA7, 0xFC, 0x9F, 0x84, 0x4D, 0x73, 0xA3, 0xCA, 0x9A, 0x61,
+	0x58, 0x97, 0xA3, 0x27, 0xFC, 0x03, 0x98, 0x76, 0x23, 0x1D, 0xC7, 0x61,
+	0x03, 0x04, 0xAE, 0x56, 0xBF, 0x38, 0x84, 0x00, 0x40, 0xA7, 0x0E, 0xFD,
+	0xFF, 0x52, 0xFE, 0x03, 0x6F, 0x95, 0x30, 0xF1, 0x97, 0xFB, 0xC0, 0x85,
+	0x60, 0xD6, 0x80, 0x25, 0xA9, 0x63, 0xBE, 0x03, 0x01, 0x4E, 0x38, 0xE2,
+	0xF9, 0xA2, 0x34, 0xFF, 0xBB, 0x3E, 0x03, 0x44, 0x78, 0x00, 0x90, 0xCB,
+	0x88, 0x11, 0x3A, 0x94, 0x65, 0xC0, 0x7C, 0x63, 0x87, 0xF0, 0x3C, 0xAF,
+	0xD6, 0x25, 0xE4, 0x8B, 0x38, 0x0A, 0xAC, 0x72, 0x21, 0xD4, 0xF8, 0x07,
+}
+
+// Header is the cartridge header found in the first 192 bytes of a ROM
+type Header struct {
+	// EntryBranch is the ARM branch instruction the BIOS jumps to
+	EntryBranch uint32
+	Logo        [156]byte
+	Title       string
+	GameCode    string
+	MakerCode   string
+	FixedValue  uint8
+	UnitCode    uint8
+	DeviceType  uint8
+	Version     uint8
+	Checksum    uint8
+}
+
+// ParseHeader reads the cartridge header from the start of a ROM image
+func ParseHeader(rom []byte) (*Header, error) {
+	if len(rom) < HeaderSize {
+		return nil, fmt.Errorf("ROM is %d bytes, too small to hold a %d byte header", len(rom), HeaderSize)
+	}
+	header := &Header{
+		EntryBranch: binary.LittleEndian.Uint32(rom[0:4]),
+		Title:       headerString(rom[titleOffset:gameCodeOffset]),
+		GameCode:    headerString(rom[gameCodeOffset:makerCodeOffset]),
+		MakerCode:   headerString(rom[makerCodeOffset:fixedValueOffset]),
+		FixedValue:  rom[fixedValueOffset],
+		UnitCode:    rom[unitCodeOffset],
+		DeviceType:  rom[deviceTypeOffset],
+		Version:     rom[versionOffset],
+		Checksum:    rom[checksumOffset],
+	}
+	copy(header.Logo[:], rom[logoOffset:titleOffset])
+	return header, nil
+}
+
+// headerString trims the zero padding off a fixed size header field
+func headerString(field []byte) string {
+	return strings.TrimRight(string(field), "\x00 ")
+}
+
+// ComputeChecksum calculates the complement checksum over 0xA0-0xBC
+func ComputeChecksum(rom []byte) uint8 {
+	var chk uint8
+	for _, b := range rom[titleOffset:checksumOffset] {
+		chk -= b
+	}
+	return chk - 0x19
+}
+
+// EntryPoint decodes the entry branch into the address it jumps to
+func (h *Header) EntryPoint() (uint32, bool) {
+	// Only an unconditional B is valid here
+	if h.EntryBranch&0xFF000000 != 0xEA000000 {
+		return 0, false
+	}
+	offset := int32(h.EntryBranch<<8) >> 6
+	return uint32(int32(ROMBase+8) + offset), true
+}
+
+// Validate checks the header against the rules the BIOS enforces on boot,
+// returning a warning for each one that is broken. A ROM with warnings
+// may still run without the BIOS.
+func (h *Header) Validate(rom []byte) []string {
+	var warnings []string
+	if _, ok := h.EntryPoint(); !ok {
+		warnings = append(warnings, fmt.Sprintf("entry point 0x%08X is not a branch", h.EntryBranch))
+	}
+	if !bytes.Equal(h.Logo[:], nintendoLogo[:]) {
+		warnings = append(warnings, "Nintendo logo does not match")
+	}
+	if h.FixedValue != FixedValue {
+		warnings = append(warnings, fmt.Sprintf("fixed value is 0x%02X, expected 0x%02X", h.FixedValue, FixedValue))
+	}
+	if len(rom) >= HeaderSize {
+		if chk := ComputeChecksum(rom); chk != h.Checksum {
+			warnings = append(warnings, fmt.Sprintf("header checksum is 0x%02X, expected 0x%02X", h.Checksum, chk))
+		}
+	}
+	return warnings
+}
+
+// String formats the header for display
+func (h *Header) String() string {
+	ret := fmt.Sprintf("Title: %s\n", h.Title)
+	ret += fmt.Sprintf("Game code: %s\n", h.GameCode)
+	ret += fmt.Sprintf("Maker code: %s\n", h.MakerCode)
+	if entry, ok := h.EntryPoint(); ok {
+		ret += fmt.Sprintf("Entry point: 0x%08X\n", entry)
+	} else {
+		ret += fmt.Sprintf("Entry point: invalid (0x%08X)\n", h.EntryBranch)
+	}
+	ret += fmt.Sprintf("Fixed value: 0x%02X\n", h.FixedValue)
+	ret += fmt.Sprintf("Unit code: 0x%02X\n", h.UnitCode)
+	ret += fmt.Sprintf("Device type: 0x%02X\n", h.DeviceType)
+	ret += fmt.Sprintf("Version: %d\n", h.Version)
+	ret += fmt.Sprintf("Checksum: 0x%02X\n", h.Checksum)
+	return ret
+}
@@ -0,0 +1,96 @@
+package cartridge_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+)
+
+// header builds a ROM with a header the BIOS accepts, its checksum
+// worked out by hand
+func header() []byte {
+	rom := make([]byte, cartridge.HeaderSize)
+	copy(rom, []byte{0x2E, 0x00, 0x00, 0xEA}) // b 0x080000C0
+	copy(rom[0x04:], cartridge.NintendoLogo[:])
+	copy(rom[0xA0:], "TEST")
+	copy(rom[0xAC:], "BTST01")
+	rom[0xB2] = cartridge.FixedValue
+	rom[0xBD] = 0x73
+	return rom
+}
+
+func TestParseHeader(t *testing.T) {
+	t.Parallel()
+	h, err := cartridge.ParseHeader(header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Title != "TEST" || h.GameCode != "BTST" || h.MakerCode != "01" {
+		t.Errorf("got title %q, game code %q and maker code %q", h.Title, h.GameCode, h.MakerCode)
+	}
+	if h.FixedValue != cartridge.FixedValue || h.Checksum != 0x73 {
+		t.Errorf("got fixed value 0x%02X and checksum 0x%02X", h.FixedValue, h.Checksum)
+	}
+	if entry, ok := h.EntryPoint(); !ok || entry != 0x080000C0 {
+		t.Errorf("got entry point 0x%08X, %v, expected 0x080000C0", entry, ok)
+	}
+
+	if _, err := cartridge.ParseHeader(header()[:cartridge.HeaderSize-1]); err == nil {
+		t.Error("parsed a header one byte short")
+	}
+}
+
+func TestComputeChecksum(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		rom  []byte
+		want uint8
+	}{
+		{"empty", make([]byte, cartridge.HeaderSize), 0xE7},
+		{"header", header(), 0x73},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := cartridge.ComputeChecksum(tt.rom); got != tt.want {
+				t.Errorf("got 0x%02X, expected 0x%02X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		modify func(rom []byte)
+		want   []string
+	}{
+		{"valid", func([]byte) {}, nil},
+		{"bad checksum", func(rom []byte) { rom[0xBD] = 0x74 }, []string{"header checksum is 0x74, expected 0x73"}},
+		// The fixed value is inside the checksum, so both are wrong
+		{"bad fixed value", func(rom []byte) { rom[0xB2] = 0x97 }, []string{
+			"fixed value is 0x97, expected 0x96",
+			"header checksum is 0x73, expected 0x72",
+		}},
+		{"bad logo", func(rom []byte) { rom[0x04] = 0 }, []string{"Nintendo logo does not match"}},
+		{"bad entry point", func(rom []byte) { rom[0x03] = 0xEB }, []string{"entry point 0xEB00002E is not a branch"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rom := header()
+			tt.modify(rom)
+			h, err := cartridge.ParseHeader(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := h.Validate(rom)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got warnings %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
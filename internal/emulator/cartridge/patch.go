@@ -0,0 +1,375 @@
+package cartridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PatchExtensions are the patch formats looked for next to a ROM
+//
+//nolint:golint,gochecknoglobals
+var PatchExtensions = []string{".ips", ".ups", ".bps"}
+
+var errPatchRange = errors.New("patch reaches outside the ROM")
+
+// BasePath strips the extensions off a ROM path, so game.gba, game.zip and
+// game.gba.gz all become game. Files belonging to the ROM are named after it.
+func BasePath(romPath string) string {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	if strings.EqualFold(filepath.Ext(base), ".gba") {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return base
+}
+
+// FindPatches returns the patches sharing the ROM's base name, so game.gba
+// picks up game.ips, game.ups and game.bps
+func FindPatches(romPath string) []string {
+	base := BasePath(romPath)
+	var patches []string
+	for _, ext := range PatchExtensions {
+		path := base + ext
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			patches = append(patches, path)
+		}
+	}
+	return patches
+}
+
+// ApplyPatches applies each patch file to the ROM in order. The ROM passed
+// in may be modified; the file on disk never is.
+func ApplyPatches(rom []byte, paths []string) ([]byte, error) {
+	for _, path := range paths {
+		patch, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rom, err = ApplyPatch(rom, patch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply %s: %w", path, err)
+		}
+	}
+	return rom, nil
+}
+
+// ApplyPatch applies an IPS, UPS or BPS patch, detected from its magic
+func ApplyPatch(rom, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		return applyIPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte("UPS1")):
+		return applyUPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		return applyBPS(rom, patch)
+	default:
+		return nil, errors.New("unknown patch format")
+	}
+}
+
+// applyIPS applies an IPS patch. Records are a 24-bit offset and a 16-bit
+// length followed by the data, or by a 16-bit count and a fill byte when the
+// length is zero. An optional 24-bit size after the EOF marker truncates.
+func applyIPS(rom, patch []byte) ([]byte, error) {
+	const eof = 0x454F46 // "EOF"
+	pos := 5
+	read := func(n int) (uint32, error) {
+		if pos+n > len(patch) {
+			return 0, errors.New("truncated IPS patch")
+		}
+		var v uint32
+		for _, b := range patch[pos : pos+n] {
+			v = v<<8 | uint32(b)
+		}
+		pos += n
+		return v, nil
+	}
+	// grow makes room for a record, as IPS records may extend the ROM
+	grow := func(end int) error {
+		if end > MaxROMSize {
+			return errPatchRange
+		}
+		if end > len(rom) {
+			rom = append(rom, make([]byte, end-len(rom))...)
+		}
+		return nil
+	}
+
+	for {
+		offset, err := read(3)
+		if err != nil {
+			return nil, err
+		}
+		if offset == eof {
+			break
+		}
+		size, err := read(2)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			count, err := read(2)
+			if err != nil {
+				return nil, err
+			}
+			value, err := read(1)
+			if err != nil {
+				return nil, err
+			}
+			if err := grow(int(offset + count)); err != nil {
+				return nil, err
+			}
+			for i := offset; i < offset+count; i++ {
+				rom[i] = byte(value)
+			}
+			continue
+		}
+		if pos+int(size) > len(patch) {
+			return nil, errors.New("truncated IPS patch")
+		}
+		if err := grow(int(offset + size)); err != nil {
+			return nil, err
+		}
+		copy(rom[offset:], patch[pos:pos+int(size)])
+		pos += int(size)
+	}
+
+	if truncate, err := read(3); err == nil && int(truncate) < len(rom) {
+		rom = rom[:truncate]
+	}
+	return rom, nil
+}
+
+// patchReader decodes the variable length integers shared by UPS and BPS
+type patchReader struct {
+	data []byte
+	pos  int
+	end  int
+}
+
+func (r *patchReader) byte() (byte, error) {
+	if r.pos >= r.end {
+		return 0, errors.New("truncated patch")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// number reads a 7 bit encoded integer where each continuation also adds
+// one, so every value has a single encoding
+func (r *patchReader) number() (uint64, error) {
+	var value uint64
+	shift := uint64(1)
+	for i := 0; i < 10; i++ {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		value += uint64(b&0x7F) * shift
+		if b&0x80 != 0 {
+			return value, nil
+		}
+		shift <<= 7
+		value += shift
+	}
+	return 0, errors.New("patch number too large")
+}
+
+// patchFooter holds the CRC32s at the end of UPS and BPS patches
+type patchFooter struct {
+	source uint32
+	target uint32
+}
+
+func readPatchFooter(patch []byte, format string) (*patchFooter, error) {
+	if len(patch) < 4+12 {
+		return nil, fmt.Errorf("truncated %s patch", format)
+	}
+	footer := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, fmt.Errorf("%s patch is corrupt, checksum mismatch", format)
+	}
+	return &patchFooter{
+		source: binary.LittleEndian.Uint32(footer[0:]),
+		target: binary.LittleEndian.Uint32(footer[4:]),
+	}, nil
+}
+
+func (f *patchFooter) checkSource(rom []byte) error {
+	if crc := crc32.ChecksumIEEE(rom); crc != f.source {
+		return fmt.Errorf("patch is for a different ROM, CRC32 is %08X, expected %08X", crc, f.source)
+	}
+	return nil
+}
+
+func (f *patchFooter) checkTarget(rom []byte) error {
+	if crc := crc32.ChecksumIEEE(rom); crc != f.target {
+		return fmt.Errorf("patched ROM CRC32 is %08X, expected %08X", crc, f.target)
+	}
+	return nil
+}
+
+// applyUPS applies a UPS patch: runs of bytes XORed into the ROM, each
+// preceded by the distance from the end of the previous run
+func applyUPS(rom, patch []byte) ([]byte, error) {
+	footer, err := readPatchFooter(patch, "UPS")
+	if err != nil {
+		return nil, err
+	}
+	if err := footer.checkSource(rom); err != nil {
+		return nil, err
+	}
+	r := &patchReader{data: patch, pos: 4, end: len(patch) - 12}
+	if _, err := r.number(); err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if targetSize > MaxROMSize {
+		return nil, errPatchRange
+	}
+	target := make([]byte, targetSize)
+	copy(target, rom)
+
+	var offset uint64
+	for r.pos < r.end {
+		skip, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		offset += skip
+		for {
+			b, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if offset < targetSize {
+				target[offset] ^= b
+			}
+			offset++
+			if b == 0 {
+				break
+			}
+		}
+	}
+
+	if err := footer.checkTarget(target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// BPS actions, stored in the low 2 bits of each command
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// applyBPS applies a BPS patch, which builds the new ROM from runs copied
+// from the original, the patch itself or earlier output
+//
+//nolint:golint,gocyclo
+func applyBPS(rom, patch []byte) ([]byte, error) {
+	footer, err := readPatchFooter(patch, "BPS")
+	if err != nil {
+		return nil, err
+	}
+	if err := footer.checkSource(rom); err != nil {
+		return nil, err
+	}
+	r := &patchReader{data: patch, pos: 4, end: len(patch) - 12}
+	if _, err := r.number(); err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	metadataSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if targetSize > MaxROMSize || metadataSize > uint64(r.end-r.pos) {
+		return nil, errPatchRange
+	}
+	r.pos += int(metadataSize)
+
+	target := make([]byte, targetSize)
+	var output, sourceOffset, targetOffset int64
+	// relative reads a signed offset: the low bit is the sign
+	relative := func() (int64, error) {
+		v, err := r.number()
+		if err != nil {
+			return 0, err
+		}
+		if v&1 != 0 {
+			return -int64(v >> 1), nil
+		}
+		return int64(v >> 1), nil
+	}
+
+	for r.pos < r.end {
+		command, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		length := int64(command>>2) + 1
+		if length > int64(targetSize)-output {
+			return nil, errPatchRange
+		}
+		switch command & 3 {
+		case bpsSourceRead:
+			if output+length > int64(len(rom)) {
+				return nil, errPatchRange
+			}
+			copy(target[output:output+length], rom[output:])
+		case bpsTargetRead:
+			if length > int64(r.end-r.pos) {
+				return nil, errPatchRange
+			}
+			copy(target[output:output+length], patch[r.pos:])
+			r.pos += int(length)
+		case bpsSourceCopy:
+			delta, err := relative()
+			if err != nil {
+				return nil, err
+			}
+			sourceOffset += delta
+			if sourceOffset < 0 || sourceOffset+length > int64(len(rom)) {
+				return nil, errPatchRange
+			}
+			copy(target[output:output+length], rom[sourceOffset:])
+			sourceOffset += length
+		case bpsTargetCopy:
+			delta, err := relative()
+			if err != nil {
+				return nil, err
+			}
+			targetOffset += delta
+			if targetOffset < 0 || targetOffset >= output {
+				return nil, errPatchRange
+			}
+			// Byte by byte, as the copy may overlap the output to repeat a pattern
+			for i := int64(0); i < length; i++ {
+				target[output+i] = target[targetOffset]
+				targetOffset++
+			}
+		}
+		output += length
+	}
+
+	if err := footer.checkTarget(target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,244 @@
+package cartridge_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+)
+
+// number encodes a UPS and BPS integer
+func number(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(out, 0x80|b)
+		}
+		out = append(out, b)
+		v--
+	}
+}
+
+// join concatenates the parts of a patch
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// footer appends the CRC32s of source and target, then of the patch itself
+func footer(patch, source, target []byte) []byte {
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+// ups builds a UPS patch from source to target out of its hunks
+func ups(source, target []byte, hunks ...[]byte) []byte {
+	patch := join([]byte("UPS1"), number(uint64(len(source))), number(uint64(len(target))), join(hunks...))
+	return footer(patch, source, target)
+}
+
+// bps builds a BPS patch from source to target out of its actions
+func bps(source, target []byte, metadata string, actions ...[]byte) []byte {
+	patch := join([]byte("BPS1"), number(uint64(len(source))), number(uint64(len(target))),
+		number(uint64(len(metadata))), []byte(metadata), join(actions...))
+	return footer(patch, source, target)
+}
+
+// action encodes a BPS command of length bytes
+func action(kind, length uint64) []byte {
+	return number((length-1)<<2 | kind)
+}
+
+// relative encodes a BPS offset, with the sign in the low bit
+func relative(delta int64) []byte {
+	if delta < 0 {
+		return number(uint64(-delta)<<1 | 1)
+	}
+	return number(uint64(delta) << 1)
+}
+
+const (
+	sourceRead = iota
+	targetRead
+	sourceCopy
+	targetCopy
+)
+
+// corrupt flips a bit in the last byte, the patch's own CRC32
+func corrupt(patch []byte) []byte {
+	patch = bytes.Clone(patch)
+	patch[len(patch)-1] ^= 1
+	return patch
+}
+
+func TestApplyPatch(t *testing.T) {
+	t.Parallel()
+	rom := []byte("ABCDEFGH")
+	upsTarget := []byte("AbCDEfGH")
+	upsHunks := [][]byte{
+		// Skip 1, XOR b into B, and the zero ends the run one byte further
+		number(1), {'B' ^ 'b', 0},
+		// 2 after the end of the previous run
+		number(2), {'F' ^ 'f', 0},
+	}
+	grownTarget := []byte("ABCDEFGHIJ")
+	bpsTarget := []byte("ABCDxyxyxyxGHEF")
+	bpsActions := [][]byte{
+		action(sourceRead, 4),
+		action(targetRead, 2), []byte("xy"),
+		// Repeats xy by copying from output that is still being written
+		action(targetCopy, 5), relative(4),
+		action(sourceCopy, 2), relative(6),
+		action(sourceCopy, 2), relative(-4),
+	}
+
+	tests := []struct {
+		name  string
+		rom   []byte
+		patch []byte
+		want  []byte
+		err   string
+	}{
+		{
+			name:  "ips",
+			patch: []byte("PATCH\x00\x00\x01\x00\x02xy\x00\x00\x06\x00\x01zEOF"),
+			want:  []byte("AxyDEFzH"),
+		},
+		{
+			name:  "ips rle",
+			patch: []byte("PATCH\x00\x00\x02\x00\x00\x00\x03-EOF"),
+			want:  []byte("AB---FGH"),
+		},
+		{
+			name:  "ips grows the rom",
+			patch: []byte("PATCH\x00\x00\x07\x00\x03xyzEOF"),
+			want:  []byte("ABCDEFGxyz"),
+		},
+		{
+			name:  "ips truncates after eof",
+			patch: []byte("PATCH\x00\x00\x00\x00\x01aEOF\x00\x00\x03"),
+			want:  []byte("aBC"),
+		},
+		{
+			name:  "ips truncation past the end",
+			patch: []byte("PATCHEOF\x00\x01\x00"),
+			want:  rom,
+		},
+		{
+			// IPS has no checksums, only truncation is detected
+			name:  "ips truncated",
+			patch: []byte("PATCH\x00\x00\x01\x00\x04xy"),
+			err:   "truncated IPS patch",
+		},
+		{name: "ups", patch: ups(rom, upsTarget, upsHunks...), want: upsTarget},
+		{
+			name:  "ups grows the rom",
+			patch: ups(rom, grownTarget, number(8), []byte{'I', 'J', 0}),
+			want:  grownTarget,
+		},
+		{
+			name:  "ups source crc",
+			rom:   []byte("abcdefgh"),
+			patch: ups(rom, upsTarget, upsHunks...),
+			err:   "patch is for a different ROM",
+		},
+		{
+			name:  "ups target crc",
+			patch: ups(rom, []byte("AbCDEFGH"), upsHunks...),
+			err:   "patched ROM CRC32",
+		},
+		{
+			name:  "ups patch crc",
+			patch: corrupt(ups(rom, upsTarget, upsHunks...)),
+			err:   "UPS patch is corrupt",
+		},
+		{name: "bps", patch: bps(rom, bpsTarget, "<metadata/>", bpsActions...), want: bpsTarget},
+		{
+			name:  "bps source crc",
+			rom:   []byte("abcdefgh"),
+			patch: bps(rom, bpsTarget, "", bpsActions...),
+			err:   "patch is for a different ROM",
+		},
+		{
+			name:  "bps target crc",
+			patch: bps(rom, []byte("ABCDxyxyxyxGHEE"), "", bpsActions...),
+			err:   "patched ROM CRC32",
+		},
+		{
+			name:  "bps patch crc",
+			patch: corrupt(bps(rom, bpsTarget, "", bpsActions...)),
+			err:   "BPS patch is corrupt",
+		},
+		{
+			name:  "bps source copy outside the rom",
+			patch: bps(rom, []byte("ABCD"), "", action(sourceCopy, 4), relative(6)),
+			err:   "patch reaches outside the ROM",
+		},
+		{
+			name:  "bps target copy before any output",
+			patch: bps(rom, []byte("ABCD"), "", action(targetCopy, 4), relative(0)),
+			err:   "patch reaches outside the ROM",
+		},
+		{name: "unknown", patch: []byte("PK\x03\x04"), err: "unknown patch format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			source := tt.rom
+			if source == nil {
+				source = rom
+			}
+			// Patches may modify the ROM they are given
+			got, err := cartridge.ApplyPatch(bytes.Clone(source), tt.patch)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, expected one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindPatches(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, name := range []string{"game.bps", "game.ips", "other.ups"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("PATCHEOF"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "game.ups"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	base := filepath.Join(dir, "game")
+	want := []string{base + ".ips", base + ".bps"}
+	for _, rom := range []string{"game.gba", "game.zip", "game.GBA.gz"} {
+		if got := cartridge.FindPatches(filepath.Join(dir, rom)); !reflect.DeepEqual(got, want) {
+			t.Errorf("found %v for %s, expected %v", got, rom, want)
+		}
+	}
+
+	patched, err := cartridge.ApplyPatches([]byte("ROM"), want)
+	if err != nil || string(patched) != "ROM" {
+		t.Errorf("got %q, %v applying empty patches", patched, err)
+	}
+	if _, err := cartridge.ApplyPatches([]byte("ROM"), []string{base + ".ups"}); err == nil {
+		t.Error("expected an error applying a directory")
+	}
+}
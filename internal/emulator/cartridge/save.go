@@ -0,0 +1,48 @@
+package cartridge
+
+import (
+	"bytes"
+)
+
+// SaveType is the kind of backup memory a cartridge uses
+type SaveType string
+
+const (
+	SaveTypeNone     SaveType = "none"
+	SaveTypeEEPROM   SaveType = "EEPROM"
+	SaveTypeSRAM     SaveType = "SRAM"
+	SaveTypeFlash64  SaveType = "Flash 64K"
+	SaveTypeFlash128 SaveType = "Flash 128K"
+)
+
+// saveIDs are the library ID strings linked into ROMs by Nintendo's SDK.
+// FLASH_V and FLASH512_V are both 64K parts.
+//
+//nolint:golint,gochecknoglobals
+var saveIDs = []struct {
+	id       []byte
+	saveType SaveType
+}{
+	{[]byte("EEPROM_V"), SaveTypeEEPROM},
+	{[]byte("SRAM_V"), SaveTypeSRAM},
+	{[]byte("SRAM_F_V"), SaveTypeSRAM},
+	{[]byte("FLASH_V"), SaveTypeFlash64},
+	{[]byte("FLASH512_V"), SaveTypeFlash64},
+	{[]byte("FLASH1M_V"), SaveTypeFlash128},
+}
+
+// DetectSaveType scans the ROM for a save library ID string
+func DetectSaveType(rom []byte) SaveType {
+	// IDs are word aligned, so skip the unaligned offsets
+	for i := HeaderSize; i < len(rom); i += 4 {
+		if rom[i] != 'E' && rom[i] != 'S' && rom[i] != 'F' {
+			continue
+		}
+		for _, save := range saveIDs {
+			if bytes.HasPrefix(rom[i:], save.id) {
+				return save.saveType
+			}
+		}
+	}
+	return SaveTypeNone
+}
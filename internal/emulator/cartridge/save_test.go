@@ -0,0 +1,40 @@
+package cartridge_test
+
+import (
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+)
+
+func TestDetectSaveType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		id     string
+		offset int
+		want   cartridge.SaveType
+	}{
+		{"none", "", 0x100, cartridge.SaveTypeNone},
+		{"EEPROM", "EEPROM_V124", 0x100, cartridge.SaveTypeEEPROM},
+		{"SRAM", "SRAM_V113", 0x100, cartridge.SaveTypeSRAM},
+		{"SRAM F", "SRAM_F_V102", 0x100, cartridge.SaveTypeSRAM},
+		{"Flash", "FLASH_V126", 0x100, cartridge.SaveTypeFlash64},
+		{"Flash 512", "FLASH512_V131", 0x100, cartridge.SaveTypeFlash64},
+		{"Flash 1M", "FLASH1M_V103", 0x100, cartridge.SaveTypeFlash128},
+		{"at the end of the ROM", "SRAM_V", 0x3FA, cartridge.SaveTypeNone},
+		{"last word", "SRAM_V", 0x3F8, cartridge.SaveTypeSRAM},
+		// The SDK word aligns the IDs, and the header is never scanned
+		{"unaligned", "SRAM_V113", 0x101, cartridge.SaveTypeNone},
+		{"in the header", "SRAM_V113", 0xA0, cartridge.SaveTypeNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rom := make([]byte, 0x400)
+			copy(rom[tt.offset:], tt.id)
+			if got := cartridge.DetectSaveType(rom); got != tt.want {
+				t.Errorf("got %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package cheats
+
+import (
+	"fmt"
+)
+
+// Pro Action Replay v3 codes pack the code type into the top byte of the
+// decrypted address: bits 25-26 are the width, bits 27-29 the comparison of
+// a conditional, and bits 30-31 either what a failed conditional skips or,
+// for plain codes, the operation.
+const (
+	par3Width     = 0x06000000
+	par3Condition = 0x38000000
+	par3Action    = 0xC0000000
+
+	par3Assign   = 0x00000000
+	par3Indirect = 0x40000000
+	par3Add      = 0x80000000
+	par3Other    = 0xC0000000
+
+	par3Hook    = 0xC4
+	par3IO16    = 0xC6
+	par3IO32    = 0xC7
+	par3Patch   = 0x18
+	par3EndIf   = 0x40
+	par3Else    = 0x60
+	par3EndList = 0x00
+)
+
+//nolint:golint,gochecknoglobals
+var par3Conditions = map[uint32]condition{
+	0x08000000: condEQ,
+	0x10000000: condNE,
+	0x18000000: condLT,
+	0x20000000: condGT,
+	0x28000000: condULT,
+	0x30000000: condUGT,
+	0x38000000: condAND,
+}
+
+//nolint:golint,gochecknoglobals
+var par3Skips = map[uint32]int{
+	0x00000000: 1,
+	0x40000000: 2,
+	0x80000000: skipBlock,
+	0xC0000000: skipRest,
+}
+
+// par3Address unpacks a 28-bit address stored as 24 bits, the region
+// nibble in bits 20-23 and the offset in bits 0-19
+func par3Address(op1 uint32) uint32 {
+	return (op1&0x00F00000)<<4 | op1&0x000FFFFF
+}
+
+// par3WidthBytes returns the access width, or 0 for the always-false width
+func par3WidthBytes(op1 uint32) int {
+	return [4]int{1, 2, 4, 0}[(op1&par3Width)>>25]
+}
+
+func maskWidth(value uint32, width int) uint32 {
+	switch width {
+	case 1:
+		return value & 0xFF
+	case 2:
+		return value & 0xFFFF
+	}
+	return value
+}
+
+//nolint:golint,gocyclo
+func compileActionReplay3(codes []string) ([]op, error) {
+	ops := make([]op, 0, len(codes))
+	var pendingPatch *op
+	for _, code := range codes {
+		op1, op2, _, err := splitCode(encryptedPattern, code)
+		if err != nil {
+			return nil, err
+		}
+		op1, op2 = decrypt(op1, op2, &actionReplay3Seeds)
+		if op1 == deadFace {
+			return nil, errReseed
+		}
+
+		// A ROM patch takes its value from the line after it
+		if pendingPatch != nil {
+			pendingPatch.value = op1 & 0xFFFF
+			ops = append(ops, *pendingPatch)
+			pendingPatch = nil
+			continue
+		}
+
+		if op1 == 0 {
+			switch op2 >> 24 {
+			case par3EndList:
+			case par3Patch:
+				pendingPatch = &op{kind: opPatch, addr: 0x08000000 | (op2&0xFFFFFF)<<1, width: 2}
+			case par3EndIf:
+				ops = append(ops, op{kind: opEndIf})
+			case par3Else:
+				ops = append(ops, op{kind: opElse})
+			default:
+				return nil, fmt.Errorf("Action Replay special code %02X is not supported", op2>>24)
+			}
+			continue
+		}
+
+		width := par3WidthBytes(op1)
+		if cond := op1 & par3Condition; cond != 0 {
+			compiled := op{
+				kind:  opIf,
+				addr:  par3Address(op1),
+				value: maskWidth(op2, width),
+				width: width,
+				cond:  par3Conditions[cond],
+				skip:  par3Skips[op1&par3Action],
+			}
+			if width == 0 {
+				compiled.cond = condFalse
+			}
+			ops = append(ops, compiled)
+			continue
+		}
+
+		switch op1 & par3Action {
+		case par3Assign, par3Add:
+			if width == 0 {
+				return nil, fmt.Errorf("invalid Action Replay code %08X", op1)
+			}
+			kind := opWrite
+			if op1&par3Action == par3Add {
+				kind = opAdd
+			}
+			ops = append(ops, op{kind: kind, addr: par3Address(op1), value: maskWidth(op2, width), width: width})
+		case par3Other:
+			switch op1 >> 24 {
+			case par3Hook:
+				ops = append(ops, op{kind: opHook, addr: 0x08000000 | op1&0xFFFFFF})
+			case par3IO16:
+				ops = append(ops, op{kind: opWrite, addr: 0x04000000 | op1&0xFFFFFF, value: op2 & 0xFFFF, width: 2})
+			case par3IO32:
+				ops = append(ops, op{kind: opWrite, addr: 0x04000000 | op1&0xFFFFFF, value: op2, width: 4})
+			default:
+				return nil, fmt.Errorf("Action Replay code type %02X is not supported", op1>>24)
+			}
+		case par3Indirect:
+			return nil, fmt.Errorf("Action Replay pointer codes are not supported")
+		}
+	}
+	if pendingPatch != nil {
+		return nil, fmt.Errorf("Action Replay ROM patch is missing its value")
+	}
+	return ops, nil
+}
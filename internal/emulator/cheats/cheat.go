@@ -0,0 +1,222 @@
+package cheats
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Type is the cheat device a code was written for
+type Type string
+
+const (
+	// TypeAuto picks raw or CodeBreaker from the shape of the code
+	TypeAuto Type = ""
+	// TypeRaw codes are unencrypted address:value writes
+	TypeRaw Type = "raw"
+	// TypeGameShark covers GameShark and Action Replay v1/v2 codes
+	TypeGameShark Type = "gsa"
+	// TypeActionReplay3 covers Pro Action Replay v3 codes
+	TypeActionReplay3 Type = "par3"
+	// TypeCodeBreaker covers unencrypted CodeBreaker codes
+	TypeCodeBreaker Type = "cb"
+)
+
+// Types lists every supported cheat type
+//
+//nolint:golint,gochecknoglobals
+var Types = []Type{TypeRaw, TypeGameShark, TypeActionReplay3, TypeCodeBreaker}
+
+// Cheat is a named list of codes that can be toggled as one
+type Cheat struct {
+	Name    string   `toml:"name"`
+	Type    Type     `toml:"type,omitempty"`
+	Enabled bool     `toml:"enabled"`
+	Codes   []string `toml:"codes"`
+}
+
+type opKind int
+
+const (
+	opWrite opKind = iota
+	opAdd
+	opOr
+	opAnd
+	opIf
+	opElse
+	opEndIf
+	opPatch
+	opHook
+)
+
+type condition int
+
+const (
+	condEQ condition = iota
+	condNE
+	condLT
+	condGT
+	condULT
+	condUGT
+	condAND
+	condFalse
+)
+
+// Conditionals either skip a number of following ops when false, or one of
+// these
+const (
+	skipBlock = -1 // skip to the matching else or end if
+	skipRest  = -2 // skip everything after it
+)
+
+// op is a single decoded code line
+type op struct {
+	kind  opKind
+	addr  uint32
+	value uint32
+	width int
+	cond  condition
+	skip  int
+}
+
+// Program is a compiled cheat, ready to run against the bus
+type Program struct {
+	Name string
+	ops  []op
+}
+
+var (
+	rawPattern         = regexp.MustCompile(`^([0-9A-Fa-f]{1,8})\s*:\s*([0-9A-Fa-f]{2}|[0-9A-Fa-f]{4}|[0-9A-Fa-f]{8})$`)
+	codeBreakerPattern = regexp.MustCompile(`^([0-9A-Fa-f]{8})\s*([0-9A-Fa-f]{4})$`)
+	encryptedPattern   = regexp.MustCompile(`^([0-9A-Fa-f]{8})\s*([0-9A-Fa-f]{8})$`)
+)
+
+// ParseType converts a type name into a Type
+func ParseType(name string) (Type, error) {
+	if name == "" {
+		return TypeAuto, nil
+	}
+	for _, t := range Types {
+		if string(t) == strings.ToLower(name) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown cheat type %q, expected one of %v", name, Types)
+}
+
+// detectType guesses the type from the first code. Encrypted GameShark and
+// Action Replay v3 codes look the same, so those need the type set.
+func detectType(code string) (Type, error) {
+	switch {
+	case rawPattern.MatchString(code):
+		return TypeRaw, nil
+	case codeBreakerPattern.MatchString(code):
+		return TypeCodeBreaker, nil
+	case encryptedPattern.MatchString(code):
+		return "", fmt.Errorf("can't tell GameShark from Action Replay v3 codes, set the type to %s or %s", TypeGameShark, TypeActionReplay3)
+	default:
+		return "", fmt.Errorf("unrecognized code %q", code)
+	}
+}
+
+// splitCode parses a code into its two hex halves using the given pattern
+func splitCode(pattern *regexp.Regexp, code string) (uint32, uint32, int, error) {
+	match := pattern.FindStringSubmatch(code)
+	if match == nil {
+		return 0, 0, 0, fmt.Errorf("malformed code %q", code)
+	}
+	op1, err := strconv.ParseUint(match[1], 16, 32)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	op2, err := strconv.ParseUint(match[2], 16, 32)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return uint32(op1), uint32(op2), len(match[2]), nil
+}
+
+// Compile decodes and decrypts a cheat's codes
+func Compile(cheat Cheat) (*Program, error) {
+	codes := make([]string, 0, len(cheat.Codes))
+	for _, code := range cheat.Codes {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("cheat %q has no codes", cheat.Name)
+	}
+
+	cheatType, err := ParseType(string(cheat.Type))
+	if err != nil {
+		return nil, err
+	}
+	if cheatType == TypeAuto {
+		if cheatType, err = detectType(codes[0]); err != nil {
+			return nil, fmt.Errorf("cheat %q: %w", cheat.Name, err)
+		}
+	}
+
+	var ops []op
+	switch cheatType {
+	case TypeRaw:
+		ops, err = compileRaw(codes)
+	case TypeGameShark:
+		ops, err = compileGameShark(codes)
+	case TypeActionReplay3:
+		ops, err = compileActionReplay3(codes)
+	case TypeCodeBreaker:
+		ops, err = compileCodeBreaker(codes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cheat %q: %w", cheat.Name, err)
+	}
+	for _, op := range ops {
+		if err := op.check(); err != nil {
+			return nil, fmt.Errorf("cheat %q: %w", cheat.Name, err)
+		}
+	}
+	return &Program{Name: cheat.Name, ops: ops}, nil
+}
+
+// check rejects RAM writes outside writable memory, which the bus refuses
+func (o *op) check() error {
+	switch o.kind {
+	case opWrite, opAdd, opOr, opAnd:
+		writable := (o.addr >= 0x02000000 && o.addr < 0x08000000) || (o.addr >= 0x0E000000 && o.addr < 0x10000000)
+		if !writable {
+			return fmt.Errorf("can't write to 0x%08X, ROM needs a ROM patch code", o.addr)
+		}
+		if o.addr%uint32(o.width) != 0 {
+			return fmt.Errorf("unaligned %d byte write to 0x%08X", o.width, o.addr)
+		}
+	case opPatch:
+		if o.addr < 0x08000000 || o.addr >= 0x0A000000 {
+			return fmt.Errorf("ROM patch address 0x%08X is outside the ROM", o.addr)
+		}
+	}
+	return nil
+}
+
+// compileRaw decodes address:value pairs, with the value's length picking
+// the width. Values aimed at the ROM become ROM patches.
+func compileRaw(codes []string) ([]op, error) {
+	ops := make([]op, 0, len(codes))
+	for _, code := range codes {
+		addr, value, digits, err := splitCode(rawPattern, code)
+		if err != nil {
+			return nil, err
+		}
+		if addr >= 0x08000000 && addr < 0x0E000000 {
+			if digits != 4 {
+				return nil, fmt.Errorf("ROM patch %q must be 16 bits", code)
+			}
+			ops = append(ops, op{kind: opPatch, addr: 0x08000000 | addr&0x1FFFFFF, value: value, width: 2})
+			continue
+		}
+		ops = append(ops, op{kind: opWrite, addr: addr, value: value, width: digits / 2})
+	}
+	return ops, nil
+}
@@ -0,0 +1,302 @@
+package cheats_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cheats"
+)
+
+// bus is little-endian memory that reads as 0 where nothing was written
+type bus struct {
+	mem     map[uint32]byte
+	patches map[uint32]uint16
+}
+
+func newBus(setup []word) *bus {
+	b := &bus{mem: map[uint32]byte{}, patches: map[uint32]uint16{}}
+	for _, w := range setup {
+		b.write(w.addr, w.value, w.width)
+	}
+	return b
+}
+
+func (b *bus) read(addr uint32, width int) uint32 {
+	var value uint32
+	for i := width - 1; i >= 0; i-- {
+		value = value<<8 | uint32(b.mem[addr+uint32(i)])
+	}
+	return value
+}
+
+func (b *bus) write(addr, value uint32, width int) {
+	for i := 0; i < width; i++ {
+		b.mem[addr+uint32(i)] = byte(value >> (8 * i))
+	}
+}
+
+func (b *bus) Read8(addr uint32) (uint8, error)   { return uint8(b.read(addr, 1)), nil }
+func (b *bus) Read16(addr uint32) (uint16, error) { return uint16(b.read(addr, 2)), nil }
+func (b *bus) Read32(addr uint32) (uint32, error) { return b.read(addr, 4), nil }
+
+func (b *bus) Write8(addr uint32, data uint8) error {
+	b.write(addr, uint32(data), 1)
+	return nil
+}
+
+func (b *bus) Write16(addr uint32, data uint16) error {
+	b.write(addr, uint32(data), 2)
+	return nil
+}
+
+func (b *bus) Write32(addr uint32, data uint32) error {
+	b.write(addr, data, 4)
+	return nil
+}
+
+func (b *bus) Patch16(addr uint32, data uint16) error {
+	b.patches[addr] = data
+	return nil
+}
+
+// word is a value of width bytes at addr
+type word struct {
+	addr  uint32
+	width int
+	value uint32
+}
+
+// encrypt turns decrypted address and value pairs into codes for the
+// device using seeds
+func encrypt(seeds *[4]uint32, pairs ...[2]uint32) []string {
+	codes := make([]string, len(pairs))
+	for i, pair := range pairs {
+		op1, op2 := cheats.Encrypt(pair[0], pair[1], seeds)
+		codes[i] = fmt.Sprintf("%08X %08X", op1, op2)
+	}
+	return codes
+}
+
+func gsa(pairs ...[2]uint32) cheats.Cheat {
+	return cheats.Cheat{Type: cheats.TypeGameShark, Codes: encrypt(cheats.GameSharkSeeds, pairs...)}
+}
+
+func par3(pairs ...[2]uint32) cheats.Cheat {
+	return cheats.Cheat{Type: cheats.TypeActionReplay3, Codes: encrypt(cheats.ActionReplay3Seeds, pairs...)}
+}
+
+func codes(cheatType cheats.Type, codes ...string) cheats.Cheat {
+	return cheats.Cheat{Type: cheatType, Codes: codes}
+}
+
+// TestTEA checks the cipher against the published TEA test vectors
+func TestTEA(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		key                    [4]uint32
+		plain1, plain2         uint32
+		encrypted1, encrypted2 uint32
+	}{
+		{[4]uint32{}, 0, 0, 0x41EA3A0A, 0x94BAA940},
+		{[4]uint32{0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}, 0xFFFFFFFF, 0xFFFFFFFF, 0x319BBEFB, 0x016ABDB2},
+	} {
+		if op1, op2 := cheats.Decrypt(tt.encrypted1, tt.encrypted2, &tt.key); op1 != tt.plain1 || op2 != tt.plain2 {
+			t.Errorf("decrypting %08X %08X gave %08X %08X, expected %08X %08X", tt.encrypted1, tt.encrypted2, op1, op2, tt.plain1, tt.plain2)
+		}
+		if op1, op2 := cheats.Encrypt(tt.plain1, tt.plain2, &tt.key); op1 != tt.encrypted1 || op2 != tt.encrypted2 {
+			t.Errorf("encrypting %08X %08X gave %08X %08X, expected %08X %08X", tt.plain1, tt.plain2, op1, op2, tt.encrypted1, tt.encrypted2)
+		}
+	}
+}
+
+//nolint:golint,maintidx
+func TestCodes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		cheat   cheats.Cheat
+		setup   []word
+		want    []word
+		patches map[uint32]uint16
+		hook    uint32
+	}{
+		{name: "raw 8-bit", cheat: codes(cheats.TypeAuto, "2000010:12"), want: []word{{0x02000010, 1, 0x12}}},
+		{name: "raw 16-bit", cheat: codes(cheats.TypeRaw, "02000010:1234"), want: []word{{0x02000010, 2, 0x1234}}},
+		{name: "raw 32-bit", cheat: codes(cheats.TypeRaw, "02000010 : 12345678"), want: []word{{0x02000010, 4, 0x12345678}}},
+		{name: "raw ROM patch", cheat: codes(cheats.TypeRaw, "08000100:BEEF"), patches: map[uint32]uint16{0x08000100: 0xBEEF}},
+
+		{name: "gsa 8-bit", cheat: gsa([2]uint32{0x02000010, 0x12}), want: []word{{0x02000010, 1, 0x12}}},
+		{name: "gsa 16-bit", cheat: gsa([2]uint32{0x12000010, 0x1234}), want: []word{{0x02000010, 2, 0x1234}}},
+		{name: "gsa 32-bit", cheat: gsa([2]uint32{0x22000010, 0x12345678}), want: []word{{0x02000010, 4, 0x12345678}}},
+		{name: "gsa ROM patch", cheat: gsa([2]uint32{0x60000080, 0xBEEF}), patches: map[uint32]uint16{0x08000100: 0xBEEF}},
+		{
+			name:  "gsa if equal",
+			cheat: gsa([2]uint32{0xD2000020, 0x5555}, [2]uint32{0x02000010, 0x12}, [2]uint32{0x02000011, 0x34}),
+			setup: []word{{0x02000020, 2, 0x5555}},
+			want:  []word{{0x02000010, 1, 0x12}, {0x02000011, 1, 0x34}},
+		},
+		{
+			name:  "gsa if not equal",
+			cheat: gsa([2]uint32{0xD2000020, 0x5555}, [2]uint32{0x02000010, 0x12}, [2]uint32{0x02000011, 0x34}),
+			setup: []word{{0x02000020, 2, 0x4444}},
+			want:  []word{{0x02000010, 1, 0}, {0x02000011, 1, 0x34}},
+		},
+		{
+			name:  "gsa if equal skips several",
+			cheat: gsa([2]uint32{0xE0025555, 0x02000020}, [2]uint32{0x02000010, 0x12}, [2]uint32{0x02000011, 0x34}, [2]uint32{0x02000012, 0x56}),
+			setup: []word{{0x02000020, 2, 0x4444}},
+			want:  []word{{0x02000010, 1, 0}, {0x02000011, 1, 0}, {0x02000012, 1, 0x56}},
+		},
+		{name: "gsa hook", cheat: gsa([2]uint32{0xF8000100, 0x0001}), hook: 0x08000100},
+
+		{name: "par3 8-bit", cheat: par3([2]uint32{0x00200010, 0x12}), want: []word{{0x02000010, 1, 0x12}}},
+		{name: "par3 16-bit", cheat: par3([2]uint32{0x02200010, 0x1234}), want: []word{{0x02000010, 2, 0x1234}}},
+		{name: "par3 32-bit", cheat: par3([2]uint32{0x04200010, 0x12345678}), want: []word{{0x02000010, 4, 0x12345678}}},
+		{
+			name:  "par3 add",
+			cheat: par3([2]uint32{0x82200010, 0x0005}),
+			setup: []word{{0x02000010, 2, 0x0010}},
+			want:  []word{{0x02000010, 2, 0x0015}},
+		},
+		{name: "par3 I/O", cheat: par3([2]uint32{0xC6000130, 0x03FF}), want: []word{{0x04000130, 2, 0x03FF}}},
+		{
+			name:  "par3 if equal",
+			cheat: par3([2]uint32{0x0A200020, 0x5555}, [2]uint32{0x00200010, 0x12}, [2]uint32{0x00200011, 0x34}),
+			setup: []word{{0x02000020, 2, 0x4444}},
+			want:  []word{{0x02000010, 1, 0}, {0x02000011, 1, 0x34}},
+		},
+		{
+			name:  "par3 if greater skips the rest",
+			cheat: par3([2]uint32{0xE2200020, 0x0010}, [2]uint32{0x00200010, 0x12}, [2]uint32{0x00200011, 0x34}),
+			setup: []word{{0x02000020, 2, 0x0008}},
+			want:  []word{{0x02000010, 1, 0}, {0x02000011, 1, 0}},
+		},
+		{
+			name: "par3 if else true",
+			cheat: par3([2]uint32{0x8A200020, 0x5555}, [2]uint32{0x00200010, 0x01},
+				[2]uint32{0, 0x60000000}, [2]uint32{0x00200011, 0x02}, [2]uint32{0, 0x40000000}, [2]uint32{0x00200012, 0x03}),
+			setup: []word{{0x02000020, 2, 0x5555}},
+			want:  []word{{0x02000010, 1, 0x01}, {0x02000011, 1, 0}, {0x02000012, 1, 0x03}},
+		},
+		{
+			name: "par3 if else false",
+			cheat: par3([2]uint32{0x8A200020, 0x5555}, [2]uint32{0x00200010, 0x01},
+				[2]uint32{0, 0x60000000}, [2]uint32{0x00200011, 0x02}, [2]uint32{0, 0x40000000}, [2]uint32{0x00200012, 0x03}),
+			setup: []word{{0x02000020, 2, 0x4444}},
+			want:  []word{{0x02000010, 1, 0}, {0x02000011, 1, 0x02}, {0x02000012, 1, 0x03}},
+		},
+		{
+			name:    "par3 ROM patch",
+			cheat:   par3([2]uint32{0, 0x18000080}, [2]uint32{0xBEEF, 0}),
+			patches: map[uint32]uint16{0x08000100: 0xBEEF},
+		},
+		{name: "par3 hook", cheat: par3([2]uint32{0xC4000100, 0}), hook: 0x08000100},
+
+		{
+			name:  "cb master code",
+			cheat: codes(cheats.TypeAuto, "00001234 000A", "18000100 0000"),
+			hook:  0x08000100,
+		},
+		{name: "cb 8-bit", cheat: codes(cheats.TypeCodeBreaker, "32000010 0012"), want: []word{{0x02000010, 1, 0x12}}},
+		{name: "cb 16-bit", cheat: codes(cheats.TypeCodeBreaker, "82000010 1234"), want: []word{{0x02000010, 2, 0x1234}}},
+		{
+			name:  "cb or",
+			cheat: codes(cheats.TypeCodeBreaker, "22000010 00F0"),
+			setup: []word{{0x02000010, 2, 0x000F}},
+			want:  []word{{0x02000010, 2, 0x00FF}},
+		},
+		{
+			name:  "cb and",
+			cheat: codes(cheats.TypeCodeBreaker, "62000010 00F0"),
+			setup: []word{{0x02000010, 2, 0x0FFF}},
+			want:  []word{{0x02000010, 2, 0x00F0}},
+		},
+		{
+			name:  "cb add",
+			cheat: codes(cheats.TypeCodeBreaker, "E2000010 0001"),
+			setup: []word{{0x02000010, 2, 0x0010}},
+			want:  []word{{0x02000010, 2, 0x0011}},
+		},
+		{
+			name:  "cb if equal",
+			cheat: codes(cheats.TypeCodeBreaker, "72000020 5555", "32000010 0012", "32000011 0034"),
+			setup: []word{{0x02000020, 2, 0x5555}},
+			want:  []word{{0x02000010, 1, 0x12}, {0x02000011, 1, 0x34}},
+		},
+		{
+			name:  "cb if and",
+			cheat: codes(cheats.TypeCodeBreaker, "F2000020 0001", "32000010 0012", "32000011 0034"),
+			setup: []word{{0x02000020, 2, 0x0002}},
+			want:  []word{{0x02000010, 1, 0}, {0x02000011, 1, 0x34}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.cheat.Name, tt.cheat.Enabled = tt.name, true
+			engine, err := cheats.NewEngine([]cheats.Cheat{tt.cheat})
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := newBus(tt.setup)
+			if err := engine.PatchROM(b); err != nil {
+				t.Fatal(err)
+			}
+			if err := engine.Apply(b); err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if got := b.read(w.addr, w.width); got != w.value {
+					t.Errorf("0x%08X is 0x%X, expected 0x%X", w.addr, got, w.value)
+				}
+			}
+			for addr, value := range tt.patches {
+				if got, ok := b.patches[addr]; !ok || got != value {
+					t.Errorf("ROM patch at 0x%08X is 0x%04X, expected 0x%04X", addr, got, value)
+				}
+			}
+			if len(b.patches) != len(tt.patches) {
+				t.Errorf("got ROM patches %v, expected %v", b.patches, tt.patches)
+			}
+			if hook, ok := engine.Hook(); ok != (tt.hook != 0) || hook != tt.hook {
+				t.Errorf("hook is 0x%08X (%v), expected 0x%08X", hook, ok, tt.hook)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		cheat cheats.Cheat
+		err   string
+	}{
+		{"no codes", codes(cheats.TypeRaw, " "), "has no codes"},
+		{"unknown type", codes("xploder", "02000010:12"), "unknown cheat type"},
+		{"encrypted without a type", codes(cheats.TypeAuto, "01234567 89ABCDEF"), "can't tell GameShark"},
+		{"unrecognized", codes(cheats.TypeAuto, "nonsense"), "unrecognized code"},
+		{"malformed", codes(cheats.TypeRaw, "nonsense"), "malformed code"},
+		{"raw 8-bit ROM patch", codes(cheats.TypeRaw, "08000100:12"), "must be 16 bits"},
+		{"raw BIOS write", codes(cheats.TypeRaw, "00000010:12"), "can't write"},
+		{"raw unaligned", codes(cheats.TypeRaw, "02000011:1234"), "unaligned"},
+		{"gsa reseed", gsa([2]uint32{0xDEADFACE, 0x1234}), "DEADFACE"},
+		{"gsa unsupported", gsa([2]uint32{0x32000010, 0}), "not supported"},
+		{"par3 reseed", par3([2]uint32{0xDEADFACE, 0x1234}), "DEADFACE"},
+		{"par3 pointer", par3([2]uint32{0x40200010, 0}), "not supported"},
+		{"par3 patch without value", par3([2]uint32{0, 0x18000080}), "missing its value"},
+		{"cb encrypted", codes(cheats.TypeCodeBreaker, "9A3B4C5D 1234"), "encrypted CodeBreaker"},
+		{"cb slide", codes(cheats.TypeCodeBreaker, "42000010 0001"), "not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.cheat.Name = tt.name
+			_, err := cheats.Compile(tt.cheat)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, expected one containing %q", err, tt.err)
+			}
+		})
+	}
+}
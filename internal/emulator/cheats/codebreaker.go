@@ -0,0 +1,72 @@
+package cheats
+
+import (
+	"fmt"
+)
+
+// CodeBreaker code types, in the top nibble of the address
+const (
+	cbGameID    = 0x0
+	cbHook      = 0x1
+	cbOr16      = 0x2
+	cbWrite8    = 0x3
+	cbSlide     = 0x4
+	cbSuper     = 0x5
+	cbAnd16     = 0x6
+	cbIfEqual   = 0x7
+	cbWrite16   = 0x8
+	cbEncrypt   = 0x9
+	cbIfNot     = 0xA
+	cbIfGreater = 0xB
+	cbIfLess    = 0xC
+	cbIfButton  = 0xD
+	cbAdd16     = 0xE
+	cbIfAnd     = 0xF
+)
+
+//nolint:golint,gochecknoglobals
+var cbConditions = map[uint32]condition{
+	cbIfEqual:   condEQ,
+	cbIfNot:     condNE,
+	cbIfGreater: condGT,
+	cbIfLess:    condLT,
+	cbIfAnd:     condAND,
+}
+
+// compileCodeBreaker decodes unencrypted CodeBreaker codes. All conditionals
+// guard only the next code.
+func compileCodeBreaker(codes []string) ([]op, error) {
+	ops := make([]op, 0, len(codes))
+	for _, code := range codes {
+		op1, op2, _, err := splitCode(codeBreakerPattern, code)
+		if err != nil {
+			return nil, err
+		}
+		addr := op1 & 0x0FFFFFFF
+		codeType := op1 >> 28
+
+		switch codeType {
+		case cbGameID:
+			// The first half of the master code only identifies the game
+		case cbHook:
+			ops = append(ops, op{kind: opHook, addr: 0x08000000 | op1&0x1FFFFFF})
+		case cbWrite8:
+			ops = append(ops, op{kind: opWrite, addr: addr, value: op2 & 0xFF, width: 1})
+		case cbWrite16:
+			ops = append(ops, op{kind: opWrite, addr: addr, value: op2, width: 2})
+		case cbOr16:
+			ops = append(ops, op{kind: opOr, addr: addr, value: op2, width: 2})
+		case cbAnd16:
+			ops = append(ops, op{kind: opAnd, addr: addr, value: op2, width: 2})
+		case cbAdd16:
+			ops = append(ops, op{kind: opAdd, addr: addr, value: op2, width: 2})
+		case cbIfEqual, cbIfNot, cbIfGreater, cbIfLess, cbIfAnd:
+			ops = append(ops, op{kind: opIf, addr: addr, value: op2, width: 2, cond: cbConditions[codeType], skip: 1})
+		case cbEncrypt:
+			return nil, fmt.Errorf("encrypted CodeBreaker codes are not supported")
+		case cbSlide, cbSuper, cbIfButton:
+			return nil, fmt.Errorf("CodeBreaker code type %X is not supported", codeType)
+		}
+	}
+	return ops, nil
+}
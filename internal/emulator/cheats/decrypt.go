@@ -0,0 +1,32 @@
+package cheats
+
+// Encrypted GameShark and Action Replay codes are a TEA cipher block with
+// the two halves of the code as the two words. Each device generation uses
+// its own key.
+
+//nolint:golint,gochecknoglobals
+var (
+	gameSharkSeeds     = [4]uint32{0x09F4FBBD, 0x9681884A, 0x352027E9, 0xF3DEE5A7}
+	actionReplay3Seeds = [4]uint32{0x7AA9648F, 0x7FAE6994, 0xC0EFAAD5, 0x42712C57}
+)
+
+const (
+	teaDelta  = 0x9E3779B9
+	teaRounds = 32
+	// teaSum is teaDelta * teaRounds, truncated to 32 bits
+	teaSum = 0xC6EF3720
+
+	// deadFace codes change the key of the codes that follow
+	deadFace = 0xDEADFACE
+)
+
+// decrypt runs the TEA rounds backwards, starting from the final sum
+func decrypt(op1, op2 uint32, seeds *[4]uint32) (uint32, uint32) {
+	sum := uint32(teaSum)
+	for i := 0; i < teaRounds; i++ {
+		op2 -= ((op1 << 4) + seeds[2]) ^ (op1 + sum) ^ ((op1 >> 5) + seeds[3])
+		op1 -= ((op2 << 4) + seeds[0]) ^ (op2 + sum) ^ ((op2 >> 5) + seeds[1])
+		sum -= teaDelta
+	}
+	return op1, op2
+}
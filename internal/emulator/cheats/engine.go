@@ -0,0 +1,218 @@
+package cheats
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Bus is the memory the cheats read and write. memory.MMIO implements it.
+type Bus interface {
+	Read8(addr uint32) (uint8, error)
+	Read16(addr uint32) (uint16, error)
+	Read32(addr uint32) (uint32, error)
+	Write8(addr uint32, data uint8) error
+	Write16(addr uint32, data uint16) error
+	Write32(addr uint32, data uint32) error
+	Patch16(addr uint32, data uint16) error
+}
+
+// Engine runs the enabled cheats against the bus
+type Engine struct {
+	programs []*Program
+	hook     uint32
+	hasHook  bool
+}
+
+// NewEngine compiles the enabled cheats. Cheats that fail to compile are
+// left out and reported in the returned error, the rest still run.
+func NewEngine(cheats []Cheat) (*Engine, error) {
+	engine := &Engine{}
+	var errs []error
+	for _, cheat := range cheats {
+		if !cheat.Enabled {
+			continue
+		}
+		program, err := Compile(cheat)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, op := range program.ops {
+			if op.kind == opHook && !engine.hasHook {
+				engine.hook, engine.hasHook = op.addr, true
+			}
+		}
+		engine.programs = append(engine.programs, program)
+	}
+	return engine, errors.Join(errs...)
+}
+
+// Len returns the number of cheats that will run
+func (e *Engine) Len() int {
+	return len(e.programs)
+}
+
+// Hook returns the ROM address set by a master code. Without one, cheats
+// should be applied once per frame instead.
+func (e *Engine) Hook() (uint32, bool) {
+	return e.hook, e.hasHook
+}
+
+// PatchROM applies the ROM patches. They only need to be applied once.
+func (e *Engine) PatchROM(bus Bus) error {
+	for _, program := range e.programs {
+		for _, op := range program.ops {
+			if op.kind != opPatch {
+				continue
+			}
+			if err := bus.Patch16(op.addr, uint16(op.value)); err != nil {
+				return fmt.Errorf("cheat %q: %w", program.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply runs every cheat once. A cheat that fails to access memory is
+// dropped so it doesn't fail again on every frame.
+func (e *Engine) Apply(bus Bus) error {
+	var errs []error
+	kept := e.programs[:0]
+	for _, program := range e.programs {
+		if err := program.run(bus); err != nil {
+			errs = append(errs, fmt.Errorf("cheat %q disabled: %w", program.Name, err))
+			continue
+		}
+		kept = append(kept, program)
+	}
+	e.programs = kept
+	return errors.Join(errs...)
+}
+
+func (p *Program) run(bus Bus) error {
+	for i := 0; i < len(p.ops); i++ {
+		op := &p.ops[i]
+		switch op.kind {
+		case opWrite:
+			if err := write(bus, op.addr, op.value, op.width); err != nil {
+				return err
+			}
+		case opAdd, opOr, opAnd:
+			current, err := read(bus, op.addr, op.width)
+			if err != nil {
+				return err
+			}
+			switch op.kind {
+			case opAdd:
+				current += op.value
+			case opOr:
+				current |= op.value
+			default:
+				current &= op.value
+			}
+			if err := write(bus, op.addr, current, op.width); err != nil {
+				return err
+			}
+		case opIf:
+			ok, err := op.test(bus)
+			if err != nil {
+				return err
+			}
+			if ok {
+				continue
+			}
+			switch op.skip {
+			case skipRest:
+				return nil
+			case skipBlock:
+				i = p.skipBlock(i, true)
+			default:
+				i += op.skip
+			}
+		case opElse:
+			// Reached from a true branch, so skip the else branch
+			i = p.skipBlock(i, false)
+		case opEndIf, opPatch, opHook:
+		}
+	}
+	return nil
+}
+
+// skipBlock returns the index of the else or end if closing the block that
+// starts after index. Else only stops the skip when leaving an if.
+func (p *Program) skipBlock(index int, stopAtElse bool) int {
+	depth := 0
+	for i := index + 1; i < len(p.ops); i++ {
+		switch p.ops[i].kind {
+		case opIf:
+			if p.ops[i].skip == skipBlock {
+				depth++
+			}
+		case opElse:
+			if depth == 0 && stopAtElse {
+				return i
+			}
+		case opEndIf:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(p.ops)
+}
+
+func (o *op) test(bus Bus) (bool, error) {
+	if o.cond == condFalse {
+		return false, nil
+	}
+	current, err := read(bus, o.addr, o.width)
+	if err != nil {
+		return false, err
+	}
+	signed := func(v uint32) int32 {
+		shift := 32 - 8*o.width
+		return int32(v<<shift) >> shift
+	}
+	switch o.cond {
+	case condEQ:
+		return current == o.value, nil
+	case condNE:
+		return current != o.value, nil
+	case condLT:
+		return signed(current) < signed(o.value), nil
+	case condGT:
+		return signed(current) > signed(o.value), nil
+	case condULT:
+		return current < o.value, nil
+	case condUGT:
+		return current > o.value, nil
+	case condAND:
+		return current&o.value != 0, nil
+	}
+	return false, nil
+}
+
+func read(bus Bus, addr uint32, width int) (uint32, error) {
+	switch width {
+	case 1:
+		v, err := bus.Read8(addr)
+		return uint32(v), err
+	case 2:
+		v, err := bus.Read16(addr)
+		return uint32(v), err
+	default:
+		return bus.Read32(addr)
+	}
+}
+
+func write(bus Bus, addr uint32, value uint32, width int) error {
+	switch width {
+	case 1:
+		return bus.Write8(addr, uint8(value))
+	case 2:
+		return bus.Write16(addr, uint16(value))
+	default:
+		return bus.Write32(addr, value)
+	}
+}
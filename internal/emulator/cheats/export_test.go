@@ -0,0 +1,22 @@
+package cheats
+
+// Decrypt and the seeds let the tests check the cipher
+//
+//nolint:golint,gochecknoglobals
+var (
+	Decrypt            = decrypt
+	GameSharkSeeds     = &gameSharkSeeds
+	ActionReplay3Seeds = &actionReplay3Seeds
+)
+
+// Encrypt runs the TEA rounds forwards, to make encrypted codes from
+// decrypted ones
+func Encrypt(op1, op2 uint32, seeds *[4]uint32) (uint32, uint32) {
+	sum := uint32(0)
+	for i := 0; i < teaRounds; i++ {
+		sum += teaDelta
+		op1 += ((op2 << 4) + seeds[0]) ^ (op2 + sum) ^ ((op2 >> 5) + seeds[1])
+		op2 += ((op1 << 4) + seeds[2]) ^ (op1 + sum) ^ ((op1 >> 5) + seeds[3])
+	}
+	return op1, op2
+}
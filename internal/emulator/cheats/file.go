@@ -0,0 +1,77 @@
+package cheats
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+)
+
+// FileExtension is appended to the ROM's base name to find its cheat file
+const FileExtension = ".cheats.toml"
+
+// File is a per-ROM list of cheats, stored as TOML:
+//
+//	[[cheat]]
+//	name = "Infinite health"
+//	type = "gsa"
+//	enabled = true
+//	codes = ["XXXXXXXX YYYYYYYY"]
+type File struct {
+	Cheats []Cheat `toml:"cheat"`
+}
+
+// FilePath returns the cheat file belonging to a ROM, next to it
+func FilePath(romPath string) string {
+	return cartridge.BasePath(romPath) + FileExtension
+}
+
+// LoadFile reads a cheat file. A missing file is an empty list.
+func LoadFile(path string) (*File, error) {
+	file := &File{}
+	if _, err := toml.DecodeFile(path, file); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return file, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Save writes the cheat file
+func (f *File) Save(path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := toml.NewEncoder(out).Encode(f); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Find returns the cheat with the given name, matched case-insensitively
+func (f *File) Find(name string) (*Cheat, bool) {
+	for i := range f.Cheats {
+		if strings.EqualFold(f.Cheats[i].Name, name) {
+			return &f.Cheats[i], true
+		}
+	}
+	return nil, false
+}
+
+// Remove deletes the cheat with the given name
+func (f *File) Remove(name string) bool {
+	for i := range f.Cheats {
+		if strings.EqualFold(f.Cheats[i].Name, name) {
+			f.Cheats = append(f.Cheats[:i], f.Cheats[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
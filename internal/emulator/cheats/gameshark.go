@@ -0,0 +1,68 @@
+package cheats
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GameShark / Action Replay v1 and v2 code types, in the top nibble of the
+// decrypted address
+const (
+	gsaWrite8      = 0x0
+	gsaWrite16     = 0x1
+	gsaWrite32     = 0x2
+	gsaWriteList   = 0x3
+	gsaPatch       = 0x6
+	gsaButton      = 0x8
+	gsaIfEqual     = 0xD
+	gsaIfEqualNext = 0xE
+	gsaHook        = 0xF
+)
+
+var errReseed = errors.New("DEADFACE codes that change the encryption key are not supported")
+
+func compileGameShark(codes []string) ([]op, error) {
+	ops := make([]op, 0, len(codes))
+	for _, code := range codes {
+		op1, op2, _, err := splitCode(encryptedPattern, code)
+		if err != nil {
+			return nil, err
+		}
+		op1, op2 = decrypt(op1, op2, &gameSharkSeeds)
+		if op1 == deadFace {
+			return nil, errReseed
+		}
+		addr := op1 & 0x0FFFFFFF
+
+		switch op1 >> 28 {
+		case gsaWrite8:
+			ops = append(ops, op{kind: opWrite, addr: addr, value: op2 & 0xFF, width: 1})
+		case gsaWrite16:
+			ops = append(ops, op{kind: opWrite, addr: addr, value: op2 & 0xFFFF, width: 2})
+		case gsaWrite32:
+			ops = append(ops, op{kind: opWrite, addr: addr, value: op2, width: 4})
+		case gsaPatch:
+			ops = append(ops, op{kind: opPatch, addr: 0x08000000 | (op1&0xFFFFFF)<<1, value: op2 & 0xFFFF, width: 2})
+		case gsaIfEqual:
+			// Run the next code only if the halfword matches
+			ops = append(ops, op{kind: opIf, addr: addr, value: op2 & 0xFFFF, width: 2, cond: condEQ, skip: 1})
+		case gsaIfEqualNext:
+			// E0nnvvvv aaaaaaaa runs the next nn codes only if the halfword matches
+			ops = append(ops, op{
+				kind:  opIf,
+				addr:  op2 & 0x0FFFFFFF,
+				value: op1 & 0xFFFF,
+				width: 2,
+				cond:  condEQ,
+				skip:  int(op1>>16) & 0xFF,
+			})
+		case gsaHook:
+			ops = append(ops, op{kind: opHook, addr: 0x08000000 | op1&0x1FFFFFF})
+		case gsaWriteList, gsaButton:
+			return nil, fmt.Errorf("GameShark code type %X is not supported", op1>>28)
+		default:
+			return nil, fmt.Errorf("unknown GameShark code type %X", op1>>28)
+		}
+	}
+	return ops, nil
+}
@@ -0,0 +1,288 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/disasm"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/ppu"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+)
+
+const (
+	// ClockRate is the CPU clock of the GBA in Hz
+	ClockRate = 16 * 1024 * 1024
+	// CyclesPerFrame is how long the PPU takes to draw a frame
+	CyclesPerFrame = ppu.Lines * (ppu.DrawCycles + ppu.HBlankCycles)
+	// FrameDuration is how long a frame lasts on hardware, about 59.73 frames a second
+	FrameDuration = time.Duration(CyclesPerFrame * int64(time.Second) / ClockRate)
+)
+
+// freshFrame marks the middle buffer as finished after the reader last
+// took it, the rest of middle is the index of the buffer
+const freshFrame = 1 << 2
+
+// crashContext is how many instructions the crash report shows on each
+// side of the one that failed
+const crashContext = 4
+
+type command uint8
+
+const (
+	commandPause command = iota
+	commandResume
+	commandStep
+	commandReset
+	commandStop
+)
+
+// Core runs the CPU on its own goroutine, a frame at a time, and hands the
+// finished frames to the renderer. Only the goroutine touches the CPU once
+// it started: commands reach it over a channel between two frames, and
+// everything the renderer reads is published atomically. Commands wait for
+// the goroutine, so the core has to be started before sending them.
+type Core struct {
+	cpu           *cpu.ARM7TDMI
+	frameDuration time.Duration
+	// onFrame runs on the goroutine after each frame
+	onFrame func()
+
+	commands chan command
+	done     chan struct{}
+	start    sync.Once
+	stop     sync.Once
+
+	keys atomic.Uint32
+	// buffers are rotated so no frame is written while the reader has it.
+	// The goroutine renders into the back buffer and swaps it with the
+	// middle one, which Frame swaps with the front buffer when it's fresh.
+	buffers [3]*image.RGBA
+	back    uint32
+	middle  atomic.Uint32
+	front   uint32
+	// drawn is set once a frame was published, so Frame has one to return
+	drawn     atomic.Bool
+	frames    atomic.Uint64
+	frameTime atomic.Int64
+	crash     atomic.Pointer[string]
+}
+
+// New creates a core that runs c once started, taking frameDuration for
+// each frame, or running as fast as it can if frameDuration is 0. onFrame
+// may be nil.
+func New(c *cpu.ARM7TDMI, frameDuration time.Duration, onFrame func()) *Core {
+	core := &Core{
+		cpu:           c,
+		frameDuration: frameDuration,
+		onFrame:       onFrame,
+		commands:      make(chan command),
+		done:          make(chan struct{}),
+		front:         2,
+	}
+	for i := range core.buffers {
+		core.buffers[i] = image.NewRGBA(image.Rect(0, 0, 240, 160))
+	}
+	core.middle.Store(1)
+	return core
+}
+
+// Start runs the CPU on a new goroutine. From here on the CPU must only be
+// used through the core.
+func (c *Core) Start() {
+	c.start.Do(func() {
+		go c.run()
+	})
+}
+
+// Pause stops running frames until Resume or Step
+func (c *Core) Pause() {
+	c.send(commandPause)
+}
+
+func (c *Core) Resume() {
+	c.send(commandResume)
+}
+
+// Step runs a single frame and pauses
+func (c *Core) Step() {
+	c.send(commandStep)
+}
+
+// Reset resets the CPU, which also clears a crash
+func (c *Core) Reset() {
+	c.send(commandReset)
+}
+
+// Stop ends the goroutine and waits for it. The CPU can be used again
+// once Stop returns.
+func (c *Core) Stop() {
+	// A core that never started has nothing to wait for
+	c.start.Do(func() {
+		close(c.done)
+	})
+	c.stop.Do(func() {
+		c.send(commandStop)
+	})
+	<-c.done
+}
+
+// send hands cmd to the goroutine between two frames, unless it ended
+func (c *Core) send(cmd command) {
+	select {
+	case c.commands <- cmd:
+	case <-c.done:
+	}
+}
+
+// SetKeys sets the buttons held from the next frame on, as KEYINPUT bits
+func (c *Core) SetKeys(pressed uint16) {
+	c.keys.Store(uint32(pressed))
+}
+
+// Frame returns the last finished frame, nil before the first one the PPU
+// could draw. It keeps the last frame in the display modes it can't draw
+// yet. The frame must not be changed, and is only valid until the next
+// call, so Frame is for a single reader like the renderer.
+func (c *Core) Frame() *image.RGBA {
+	if !c.drawn.Load() {
+		return nil
+	}
+	if c.middle.Load()&freshFrame != 0 {
+		c.front = c.middle.Swap(c.front) &^ freshFrame
+	}
+	return c.buffers[c.front]
+}
+
+// Frames returns the number of frames finished
+func (c *Core) Frames() uint64 {
+	return c.frames.Load()
+}
+
+// FrameTime returns how long emulating the last frame took
+func (c *Core) FrameTime() time.Duration {
+	return time.Duration(c.frameTime.Load())
+}
+
+// Crash returns the report of the CPU state when it failed, or "" if it
+// didn't
+func (c *Core) Crash() string {
+	if report := c.crash.Load(); report != nil {
+		return *report
+	}
+	return ""
+}
+
+func (c *Core) run() {
+	defer close(c.done)
+	paused := false
+	next := time.Now()
+	for {
+		var cmd command
+		if paused || c.crash.Load() != nil {
+			cmd = <-c.commands
+		} else {
+			select {
+			case cmd = <-c.commands:
+			default:
+				c.runFrame()
+				next = c.pace(next)
+				continue
+			}
+		}
+		switch cmd {
+		case commandPause:
+			paused = true
+		case commandResume:
+			paused = false
+			next = time.Now()
+		case commandStep:
+			paused = true
+			if c.crash.Load() == nil {
+				c.runFrame()
+			}
+		case commandReset:
+			c.crash.Store(nil)
+			if err := c.cpu.Reset(); err != nil {
+				c.crashed(err)
+			}
+			next = time.Now()
+		case commandStop:
+			return
+		}
+	}
+}
+
+// runFrame runs the CPU up to the end of the next frame and publishes it
+func (c *Core) runFrame() {
+	start := time.Now()
+	c.cpu.SetKeyInput(uint16(c.keys.Load()))
+	for !c.cpu.PPU.FrameReady() {
+		if err := c.cpu.Step(); err != nil {
+			c.crashed(err)
+			return
+		}
+	}
+	c.cpu.PPU.ClearFrameReady()
+	if c.onFrame != nil {
+		c.onFrame()
+	}
+	if c.cpu.PPU.RenderFrame(c.buffers[c.back]) {
+		c.back = c.middle.Swap(c.back|freshFrame) &^ freshFrame
+		c.drawn.Store(true)
+	}
+	c.frames.Add(1)
+	c.frameTime.Store(int64(time.Since(start)))
+}
+
+// pace waits for the time the frame after the one due at next starts.
+// After falling more than a frame behind it starts over from now instead
+// of rushing to catch up.
+func (c *Core) pace(next time.Time) time.Time {
+	if c.frameDuration == 0 {
+		return next
+	}
+	next = next.Add(c.frameDuration)
+	wait := time.Until(next)
+	switch {
+	case wait > 0:
+		time.Sleep(wait)
+	case wait < -c.frameDuration:
+		next = time.Now()
+	}
+	return next
+}
+
+// crashed keeps a report of the CPU state when it failed on err, showing
+// the code around the failing instruction
+func (c *Core) crashed(err error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The CPU stopped: %v\n\n", err)
+	sb.WriteString(c.cpu.DebugRegisters())
+	sb.WriteString("\n")
+	pc := c.cpu.NextInstructionAddress()
+	thumb := c.cpu.GetThumbMode()
+	size := uint32(4)
+	if thumb {
+		size = 2
+	}
+	bus := c.cpu.GetMMIO()
+	for addr := pc - crashContext*size; addr <= pc+crashContext*size; addr += size {
+		text, _, err := disasm.Disassemble(bus, addr, thumb)
+		if err != nil {
+			continue
+		}
+		marker := "  "
+		if addr == pc {
+			marker = "=>"
+		}
+		fmt.Fprintf(&sb, "%s 0x%08X: %s\n", marker, addr, text)
+	}
+	report := sb.String()
+	logging.CPU.Error("The CPU stopped", "err", err, "pc", logging.Hex(pc))
+	c.crash.Store(&report)
+}
@@ -0,0 +1,149 @@
+package core_test
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/core"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// bitmap switches to display mode 3 and waits
+//
+//nolint:golint,gochecknoglobals
+var bitmap = []uint32{
+	0xE3A00301, // mov r0, #0x04000000
+	0xE3A01003, // mov r1, #3
+	0xE1C010B0, // strh r1, [r0]
+	0xEAFFFFFE, // b .
+}
+
+// counter switches to display mode 3 and keeps counting in the first pixel
+//
+//nolint:golint,gochecknoglobals
+var counter = []uint32{
+	0xE3A00301, // mov r0, #0x04000000
+	0xE3A01003, // mov r1, #3
+	0xE1C010B0, // strh r1, [r0]
+	0xE3A02406, // mov r2, #0x06000000
+	0xE2811001, // loop: add r1, r1, #1
+	0xE1C210B0, // strh r1, [r2]
+	0xEAFFFFFC, // b loop
+}
+
+func newCore(t *testing.T, program []uint32, frameDuration time.Duration) *core.Core {
+	t.Helper()
+	emu := core.New(testutil.NewCPU(t, program, nil), frameDuration, nil)
+	t.Cleanup(emu.Stop)
+	emu.Start()
+	return emu
+}
+
+// waitFor polls until done reports true, giving up after long enough for
+// the race detector
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	t.Parallel()
+	emu := newCore(t, bitmap, 0)
+	waitFor(t, "3 frames", func() bool { return emu.Frames() >= 3 })
+	if frame := emu.Frame(); frame == nil || frame.Rect.Dx() != 240 || frame.Rect.Dy() != 160 {
+		t.Fatalf("got frame %v, expected 240x160", frame)
+	}
+
+	// The core takes commands between frames, so none runs once Pause returns
+	emu.Pause()
+	paused := emu.Frames()
+	emu.Step()
+	emu.Pause()
+	if frames := emu.Frames(); frames != paused+1 {
+		t.Errorf("%d frames after a step, expected %d", frames, paused+1)
+	}
+
+	emu.Resume()
+	waitFor(t, "frames after resuming", func() bool { return emu.Frames() > paused+2 })
+	emu.Reset()
+	emu.Stop()
+	stopped := emu.Frames()
+	emu.Stop()
+	emu.Pause()
+	if frames := emu.Frames(); frames != stopped {
+		t.Errorf("%d frames after stopping at %d", frames, stopped)
+	}
+	if crash := emu.Crash(); crash != "" {
+		t.Errorf("crashed:\n%s", crash)
+	}
+}
+
+func TestFrameBuffers(t *testing.T) {
+	t.Parallel()
+	emu := newCore(t, counter, 0)
+	waitFor(t, "the first frame", func() bool { return emu.Frame() != nil })
+
+	// A frame isn't written again while the reader has it
+	frame := emu.Frame()
+	held := append([]byte(nil), frame.Pix...)
+	frames := emu.Frames()
+	waitFor(t, "3 more frames", func() bool { return emu.Frames() >= frames+3 })
+	if !bytes.Equal(frame.Pix, held) {
+		t.Error("the frame changed while it was held")
+	}
+
+	seen := map[*image.RGBA]bool{}
+	for i := 0; i < 20; i++ {
+		frames := emu.Frames()
+		waitFor(t, "the next frame", func() bool { return emu.Frames() > frames })
+		seen[emu.Frame()] = true
+	}
+	if len(seen) > 3 {
+		t.Errorf("got %d frame buffers, expected them rotated through 3", len(seen))
+	}
+	if bytes.Equal(emu.Frame().Pix, held) {
+		t.Error("the frame didn't change")
+	}
+}
+
+func TestPacing(t *testing.T) {
+	t.Parallel()
+	emu := newCore(t, bitmap, core.FrameDuration)
+	start := time.Now()
+	waitFor(t, "5 frames", func() bool { return emu.Frames() >= 5 })
+	if elapsed := time.Since(start); elapsed < 4*core.FrameDuration {
+		t.Errorf("ran 5 frames in %v, expected at least %v", elapsed, 4*core.FrameDuration)
+	}
+}
+
+func TestCrash(t *testing.T) {
+	t.Parallel()
+	emu := newCore(t, []uint32{
+		0xEFFF0000, // swi 0xFF0000
+	}, 0)
+	waitFor(t, "the crash", func() bool { return emu.Crash() != "" })
+	if crash := emu.Crash(); !strings.HasPrefix(crash, "The CPU stopped: ") {
+		t.Errorf("got crash report:\n%s", crash)
+	}
+	frames := emu.Frames()
+
+	// Nothing runs until a reset
+	emu.Step()
+	emu.Pause()
+	if emu.Frames() != frames {
+		t.Error("ran a frame after crashing")
+	}
+	// The reset is done once the next command is taken
+	emu.Reset()
+	emu.Resume()
+	waitFor(t, "the crash after the reset", func() bool { return emu.Crash() != "" })
+}
@@ -0,0 +1,51 @@
+package cpu
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// SRAMSize is the 64KB the SRAM area spans. Smaller chips are
+	// mirrored on hardware.
+	SRAMSize = 64 * 1024
+	// sramAddress is where the Game Pak SRAM is mapped
+	sramAddress = 0x0E000000
+)
+
+// mapSRAM maps the SRAM area, erased. It is only saved for cartridges
+// with SRAM, as Flash and EEPROM aren't emulated yet.
+func (c *ARM7TDMI) mapSRAM() {
+	for i := range c.sram {
+		c.sram[i] = 0xFF
+	}
+	c.virtualMemory.AddMMIO(c.sram[:], sramAddress, SRAMSize)
+}
+
+// Backup returns a copy of the backup memory of the cartridge, or nil if
+// it has none that is emulated
+func (c *ARM7TDMI) Backup() []byte {
+	if !c.hasSRAM {
+		return nil
+	}
+	return append([]byte(nil), c.sram[:]...)
+}
+
+// LoadBackup fills the backup memory of the cartridge with a save. A
+// shorter save leaves the rest of it erased.
+func (c *ARM7TDMI) LoadBackup(save []byte) error {
+	if !c.hasSRAM {
+		return errors.New("the cartridge has no SRAM, and other save types aren't emulated yet")
+	}
+	if len(save) > SRAMSize {
+		return fmt.Errorf("save is %d bytes, expected at most %d", len(save), SRAMSize)
+	}
+	n := copy(c.sram[:], save)
+	for i := n; i < SRAMSize; i++ {
+		c.sram[i] = 0xFF
+	}
+	if c.shadow != nil {
+		return c.shadow.LoadBackup(save)
+	}
+	return nil
+}
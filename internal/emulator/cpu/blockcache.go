@@ -0,0 +1,256 @@
+package cpu
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/arm"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/thumb"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
+)
+
+const (
+	// maxBlockLength is the most instructions a block holds
+	maxBlockLength = 64
+	// pageShift sizes the pages writes invalidate blocks by, 256 bytes
+	pageShift = 8
+	// selfModifyingLimit is how many times the blocks of a page are
+	// invalidated before its code is left to the interpreter
+	selfModifyingLimit = 8
+)
+
+type armEntry struct {
+	opcode  uint32
+	handler arm.Handler
+}
+
+type thumbEntry struct {
+	opcode  uint16
+	handler thumb.Handler
+}
+
+// block is a straight-line run of decoded instructions starting at start.
+// It holds ARM or THUMB entries, never both.
+type block struct {
+	start uint32
+	end   uint32
+	arm   []armEntry
+	thumb []thumbEntry
+}
+
+func (b *block) contains(addr uint32) bool {
+	return b != nil && addr >= b.start && addr < b.end
+}
+
+// blockCache decodes the code the CPU runs from RAM and ROM once, so
+// fetches skip the bus and execution skips the decoder. Every write to a
+// page holding cached code drops its blocks, so the cache always agrees
+// with memory. Pages rewritten over and over, the BIOS and mirrors are
+// never cached.
+type blockCache struct {
+	bus      *memory.MMIO
+	blocks   map[uint32]*block
+	pages    map[uint32][]*block
+	rewrites map[uint32]int
+
+	// current is the block of the last fetch and previous the one before
+	// it, which holds the instructions still in the pipeline
+	current  *block
+	previous *block
+}
+
+func newBlockCache(bus *memory.MMIO) *blockCache {
+	return &blockCache{
+		bus:      bus,
+		blocks:   map[uint32]*block{},
+		pages:    map[uint32][]*block{},
+		rewrites: map[uint32]int{},
+	}
+}
+
+// flush drops every block, for when memory changed behind the bus's back
+func (bc *blockCache) flush() {
+	*bc = *newBlockCache(bc.bus)
+}
+
+// cacheable reports whether code at addr can be cached: it has to be in
+// EWRAM, IWRAM or the Game Pak ROM at its unmirrored address, and on a
+// page that isn't modified all the time
+func (bc *blockCache) cacheable(addr uint32) bool {
+	switch {
+	case addr >= 0x02000000 && addr < 0x02040000:
+	case addr >= 0x03000000 && addr < 0x03008000:
+	case addr >= 0x08000000 && addr < 0x0A000000:
+	default:
+		return false
+	}
+	return bc.rewrites[addr>>pageShift] < selfModifyingLimit
+}
+
+// find returns the block holding addr in the given state, building one
+// that starts there if needed. It returns nil where code isn't cacheable.
+func (bc *blockCache) find(addr uint32, thumbMode bool) *block {
+	if bc.current.contains(addr) && (bc.current.thumb != nil) == thumbMode {
+		return bc.current
+	}
+	key := addr
+	if thumbMode {
+		key |= 1
+	}
+	b, ok := bc.blocks[key]
+	if !ok {
+		if !bc.cacheable(addr) {
+			return nil
+		}
+		if thumbMode {
+			b = bc.buildThumb(addr)
+		} else {
+			b = bc.buildARM(addr)
+		}
+		if b == nil {
+			return nil
+		}
+		bc.blocks[key] = b
+		for page := b.start >> pageShift; page <= (b.end-1)>>pageShift; page++ {
+			bc.pages[page] = append(bc.pages[page], b)
+		}
+	}
+	bc.previous, bc.current = bc.current, b
+	return b
+}
+
+func (bc *blockCache) buildARM(start uint32) *block {
+	b := &block{start: start, end: start}
+	for len(b.arm) < maxBlockLength && bc.cacheable(b.end) {
+		opcode, err := bc.bus.Read32(b.end)
+		if err != nil {
+			break
+		}
+		b.arm = append(b.arm, armEntry{opcode, arm.Lookup(opcode)})
+		b.end += 4
+		if armEndsBlock(opcode) {
+			break
+		}
+	}
+	if len(b.arm) == 0 {
+		return nil
+	}
+	return b
+}
+
+func (bc *blockCache) buildThumb(start uint32) *block {
+	b := &block{start: start, end: start}
+	for len(b.thumb) < maxBlockLength && bc.cacheable(b.end) {
+		opcode, err := bc.bus.Read16(b.end)
+		if err != nil {
+			break
+		}
+		b.thumb = append(b.thumb, thumbEntry{opcode, thumb.Lookup(opcode)})
+		b.end += 2
+		if thumbEndsBlock(opcode) {
+			break
+		}
+	}
+	if len(b.thumb) == 0 {
+		return nil
+	}
+	return b
+}
+
+// fetchARM returns the ARM instruction at addr if it is cached
+func (bc *blockCache) fetchARM(addr uint32) (uint32, bool) {
+	b := bc.find(addr, false)
+	if b == nil {
+		return 0, false
+	}
+	return b.arm[(addr-b.start)/4].opcode, true
+}
+
+// fetchThumb returns the THUMB instruction at addr if it is cached
+func (bc *blockCache) fetchThumb(addr uint32) (uint16, bool) {
+	b := bc.find(addr, true)
+	if b == nil {
+		return 0, false
+	}
+	return b.thumb[(addr-b.start)/2].opcode, true
+}
+
+// armHandler returns the handler of the ARM instruction executing at addr.
+// The cached one is only used while it decodes the same opcode as the
+// pipeline holds, which was fetched before any later write.
+func (bc *blockCache) armHandler(addr uint32, opcode uint32) arm.Handler {
+	for _, b := range [2]*block{bc.previous, bc.current} {
+		if b.contains(addr) && b.arm != nil {
+			if entry := b.arm[(addr-b.start)/4]; entry.opcode == opcode {
+				return entry.handler
+			}
+		}
+	}
+	return arm.Lookup(opcode)
+}
+
+// thumbHandler is armHandler for THUMB instructions
+func (bc *blockCache) thumbHandler(addr uint32, opcode uint16) thumb.Handler {
+	for _, b := range [2]*block{bc.previous, bc.current} {
+		if b.contains(addr) && b.thumb != nil {
+			if entry := b.thumb[(addr-b.start)/2]; entry.opcode == opcode {
+				return entry.handler
+			}
+		}
+	}
+	return thumb.Lookup(opcode)
+}
+
+// invalidate drops the blocks on the page written at addr
+func (bc *blockCache) invalidate(addr uint32) {
+	page := addr >> pageShift
+	blocks, ok := bc.pages[page]
+	if !ok {
+		return
+	}
+	delete(bc.pages, page)
+	bc.rewrites[page]++
+	for _, b := range blocks {
+		key := b.start
+		if b.thumb != nil {
+			key |= 1
+		}
+		// The block may have been dropped and rebuilt through another page
+		if bc.blocks[key] == b {
+			delete(bc.blocks, key)
+		}
+		if bc.current == b {
+			bc.current = nil
+		}
+		if bc.previous == b {
+			bc.previous = nil
+		}
+	}
+}
+
+// armEndsBlock reports whether an ARM instruction may leave straight-line
+// code: branches, exceptions, undefined instructions and anything that can
+// write PC
+func armEndsBlock(opcode uint32) bool {
+	switch {
+	case opcode&0x0E000000 == 0x0A000000, // B, BL
+		opcode&0x0C000000 == 0x0C000000, // coprocessor, SWI
+		opcode&0x0FFFFFF0 == 0x012FFF10, // BX
+		opcode&0x0E000010 == 0x06000010, // undefined
+		opcode&0x0E108000 == 0x08108000, // LDM with PC
+		opcode&0x0000F000 == 0x0000F000: // Rd is PC
+		return true
+	}
+	return arm.Lookup(opcode) == nil
+}
+
+// thumbEndsBlock is armEndsBlock for THUMB instructions
+func thumbEndsBlock(opcode uint16) bool {
+	switch {
+	case opcode&0xF000 == 0xD000, // conditional branch, SWI
+		opcode&0xF800 == 0xE000, // B
+		opcode&0xF000 == 0xF000, // BL
+		opcode&0xFF00 == 0x4700, // BX
+		opcode&0xFF00 == 0xBD00, // POP with PC
+		opcode&0xFC87 == 0x4487: // hi register operation on PC
+		return true
+	}
+	return thumb.Lookup(opcode) == nil
+}
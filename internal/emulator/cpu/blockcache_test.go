@@ -0,0 +1,131 @@
+package cpu_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// selfModifying writes mov r0, #i to IWRAM and calls it for i from 0 to
+// 19, adding up the results in r8
+//
+//nolint:golint,gochecknoglobals
+var selfModifying = []uint32{
+	0xE3A05403, // mov r5, #0x03000000
+	0xE59F6074, // ldr r6, =0xE3A00000
+	0xE59F7074, // ldr r7, =0xE12FFF1E
+	0xE5857004, // str r7, [r5, #4]
+	0xE3A01000, // mov r1, #0
+	0xE3A08000, // mov r8, #0
+	0xE1862001, // loop: orr r2, r6, r1
+	0xE5852000, // str r2, [r5]
+	0xE1A0E00F, // mov lr, pc
+	0xE1A0F005, // mov pc, r5
+	0xE0888000, // add r8, r8, r0
+	0xE2811001, // add r1, r1, #1
+	0xE3510014, // cmp r1, #20
+	0x1AFFFFF7, // bne loop
+	0xEAFFFFFE, // b .
+	0x80 / 4:   0xE3A00000,
+	0xE12FFF1E,
+}
+
+func newLockstepCPU(t testing.TB, program []uint32) *cpu.ARM7TDMI {
+	t.Helper()
+	return testutil.NewCPU(t, program, &config.Config{BlockCache: true, Lockstep: true})
+}
+
+// runTo steps c until it is about to execute the instruction at end
+func runTo(c *cpu.ARM7TDMI, end uint32) error {
+	for i := 0; i < 100000 && c.NextInstructionAddress() != end; i++ {
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestBlockCacheLockstep(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		program []uint32
+		end     uint32
+		want    map[uint8]uint32
+	}{
+		{
+			name:    "ROM loops",
+			program: testutil.CountLoops,
+			end:     0x08000024,
+			want:    map[uint8]uint32{0: 5050, 1: 0, 3: 0, 4: 150},
+		},
+		{
+			name:    "self-modifying IWRAM code",
+			program: selfModifying,
+			end:     0x08000038,
+			want:    map[uint8]uint32{1: 20, 8: 190},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newLockstepCPU(t, tt.program)
+			if err := runTo(c, tt.end); err != nil {
+				t.Fatal(err)
+			}
+			if pc := c.NextInstructionAddress(); pc != tt.end {
+				t.Fatalf("stopped at 0x%08X, expected 0x%08X", pc, tt.end)
+			}
+			for reg, want := range tt.want {
+				if got := c.ReadDebugRegister(reg); got != want {
+					t.Errorf("r%d is %d, expected %d", reg, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLockstepDivergence(t *testing.T) {
+	t.Parallel()
+	c := newLockstepCPU(t, testutil.CountLoops)
+	// Patches aren't passed on to the interpreter, so subs r1, r1, #1
+	// becomes subs r1, r1, #2 on one side only, as soon as it is fetched
+	if err := c.GetMMIO().Patch16(0x0800000C, 0x1002); err != nil {
+		t.Fatal(err)
+	}
+	err := runTo(c, 0x08000024)
+	var lockstep *cpu.ErrLockstep
+	if !errors.As(err, &lockstep) {
+		t.Fatalf("expected a lockstep error, got %v", err)
+	}
+	want := "block cache diverged from the interpreter at 0x08000004: ARM prefetch 1 is 0xE2511002, interpreter has 0xE2511001"
+	if err.Error() != want {
+		t.Errorf("got %q, expected %q", err, want)
+	}
+}
+
+func BenchmarkStep(b *testing.B) {
+	program := []uint32{
+		0xE2800001, // loop: add r0, r0, #1
+		0xE0211000, // eor r1, r1, r0
+		0xEAFFFFFC, // b loop
+	}
+	for _, blockCache := range []bool{false, true} {
+		name := "interpreter"
+		if blockCache {
+			name = "block cache"
+		}
+		b.Run(name, func(b *testing.B) {
+			c := testutil.NewCPU(b, program, &config.Config{BlockCache: blockCache})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := c.Step(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+func TestWrites(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		mode  uint16
+		size  uint8
+		addr  uint32
+		value uint32
+		want  uint16
+	}{
+		// Bytes land in both halves of background VRAM and are lost in
+		// object VRAM, which starts further in for the bitmap modes
+		{"background VRAM byte", 0, 1, 0x06000001, 0xAB, 0xABAB},
+		{"object VRAM byte", 0, 1, 0x06010000, 0xAB, 0},
+		{"bitmap VRAM byte", 3, 1, 0x06010000, 0xAB, 0xABAB},
+		{"bitmap object VRAM byte", 3, 1, 0x06014000, 0xAB, 0},
+		{"VRAM halfword", 0, 2, 0x06010000, 0xABCD, 0xABCD},
+		// Writes to ROM and the BIOS are ignored
+		{"ROM byte", 0, 1, 0x080000C4, 0xAB, 0},
+		{"ROM halfword", 0, 2, 0x080000C4, 0xABCD, 0},
+		{"ROM word", 0, 4, 0x080000C4, 0xABCD, 0},
+		{"BIOS byte", 0, 1, 0x00003F00, 0xAB, 0},
+		{"BIOS word", 0, 4, 0x00003F00, 0xABCD, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := testutil.NewCPU(t, []uint32{0xEAFFFFFE}, nil) // b .
+			bus := c.GetBus()
+			if err := bus.Write16(0x04000000, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			switch tt.size {
+			case 1:
+				err = bus.Write8(tt.addr, uint8(tt.value))
+			case 2:
+				err = bus.Write16(tt.addr, uint16(tt.value))
+			case 4:
+				err = bus.Write32(tt.addr, tt.value)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := bus.Read16(tt.addr &^ 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("[0x%08X] = 0x%04X, want 0x%04X", tt.addr&^1, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPoke checks debuggers can still patch single bytes of ROM and VRAM
+func TestPoke(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, []uint32{0xEAFFFFFE}, nil) // b .
+	for _, addr := range []uint32{0x080000C5, 0x06000001, 0x06010001} {
+		if err := c.GetMMIO().Poke(addr, []byte{0xAB}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.GetBus().Read16(addr &^ 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 0xAB00 {
+			t.Errorf("[0x%08X] = 0x%04X after poking 0xAB into its high byte", addr&^1, got)
+		}
+	}
+}
@@ -3,13 +3,21 @@ package cpu
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cartridge"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/disasm"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/arm"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/thumb"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interrupts"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/ppu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/scheduler"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+	"github.com/USA-RedDragon/go-gba/internal/trace"
 )
 
 //nolint:golint,revive
@@ -35,32 +43,16 @@ type ARM7TDMI struct {
 	// Registers R0-R16
 	r [17]uint32
 
-	sp_irq   uint32
-	lr_irq   uint32
-	spsr_irq uint32
-
-	r8_fiq   uint32
-	r9_fiq   uint32
-	r10_fiq  uint32
-	r11_fiq  uint32
-	r12_fiq  uint32
-	sp_fiq   uint32
-	lr_fiq   uint32
-	spsr_fiq uint32
-
-	sp_svc   uint32
-	lr_svc   uint32
-	spsr_svc uint32
-
-	sp_abt   uint32
-	lr_abt   uint32
-	spsr_abt uint32
-
-	sp_und   uint32
-	lr_und   uint32
-	spsr_und uint32
+	// The registers of the banks not in use. highUser holds R8-R12 of
+	// every mode but FIQ, and the SPSR of the user bank is unused.
+	highUser [5]uint32
+	highFIQ  [5]uint32
+	spLR     [bankCount][2]uint32
+	spsr     [bankCount]uint32
 
 	virtualMemory *memory.MMIO
+	scheduler     *scheduler.Scheduler
+	interrupts    *interrupts.Controller
 	PPU           *ppu.PPU
 
 	biosROM        [BIOSROMSize]byte
@@ -69,16 +61,36 @@ type ARM7TDMI struct {
 	ioRAM          [IORAMSize]byte
 	unusedBiosByte [1]byte
 	gamePakROM     [GamePakROMSize]byte
+	sram           [SRAMSize]byte
+	hasSRAM        bool
+	header         *cartridge.Header
 
-	halted bool
-	exit   bool
+	hasBIOS bool
+	halted  bool
+	exit    atomic.Bool
+	power   powerMode
+	idle    idleLoop
+
+	execHookAddress uint32
+	execHook        func()
+	watchHook       func(addr uint32, size uint8, write bool)
+	accessHook      func(addr uint32, size uint8, write bool)
 
 	prefetchARMPipeline   [2]uint32
 	prefetchThumbPipeline [2]uint16
+	blocks                *blockCache
+
+	// shadow runs the same code without the block cache in lockstep mode
+	shadow *ARM7TDMI
 
-	config *config.Config
+	config      *config.Config
+	tracer      trace.Tracer
+	traceFile   *trace.Writer
+	traceRecord *trace.Record
+	traceWrites bool
 
 	waitCycles uint16
+	cycles     uint64
 }
 
 // Enum for CPU mode
@@ -94,15 +106,80 @@ const (
 	systemMode     cpuMode = 0b11111
 )
 
-func NewARM7TDMI(config *config.Config) *ARM7TDMI {
-	vmem := memory.MMIO{
-		Config: config,
+// NewARM7TDMI creates a CPU running the ROM, BIOS and patches named in
+// config
+func NewARM7TDMI(config *config.Config) (*ARM7TDMI, error) {
+	var bios []byte
+	if config.BIOSPath != "" {
+		var err error
+		bios, err = loadBIOSROM(config.BIOSPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rom, err := loadROM(config)
+	if err != nil {
+		return nil, err
+	}
+	header, err := cartridge.ParseHeader(rom)
+	if err != nil {
+		logging.CPU.Warn("Failed to parse cartridge header", "err", err)
+	} else {
+		for _, warning := range header.Validate(rom) {
+			logging.CPU.Warn("Bad cartridge header", "warning", warning)
+		}
 	}
+	return New(config, bios, rom)
+}
+
+// New creates a CPU running rom, which is already patched. Without a BIOS
+// it starts at the entry point of the ROM, the way the BIOS would leave
+// it, and handles IRQs with a stand-in for the BIOS handler. The paths in
+// config are ignored.
+func New(config *config.Config, bios, rom []byte) (*ARM7TDMI, error) {
+	if bios != nil && len(bios) != BIOSROMSize {
+		return nil, fmt.Errorf("BIOS ROM size is %d, expected %d", len(bios), BIOSROMSize)
+	}
+	if len(rom) > GamePakROMSize {
+		return nil, fmt.Errorf("ROM size is %d, expected maximum of %d", len(rom), GamePakROMSize)
+	}
+	cpu := newARM7TDMI(config)
+	if bios != nil {
+		copy(cpu.biosROM[:], bios)
+		cpu.hasBIOS = true
+	} else {
+		copy(cpu.biosROM[irqVector:], irqHandler)
+	}
+	copy(cpu.gamePakROM[:], rom)
+	if header, err := cartridge.ParseHeader(rom); err == nil {
+		cpu.header = header
+	}
+	cpu.hasSRAM = cartridge.DetectSaveType(rom) == cartridge.SaveTypeSRAM
+	if config.Lockstep {
+		cpu.shadow = cpu.newShadow()
+	}
+	if config.TracePath != "" {
+		if err := cpu.openTrace(); err != nil {
+			return nil, err
+		}
+	}
+	if err := cpu.Reset(); err != nil {
+		cpu.Close()
+		return nil, err
+	}
+	return cpu, nil
+}
+
+// newARM7TDMI maps the memory of a CPU with nothing loaded
+func newARM7TDMI(config *config.Config) *ARM7TDMI {
+	vmem := memory.MMIO{}
 	cpu := &ARM7TDMI{
 		virtualMemory: &vmem,
+		scheduler:     scheduler.New(),
 		config:        config,
 	}
-	cpu.PPU = ppu.NewPPU(config, &vmem, cpu.ioRAM[:])
+	cpu.interrupts = interrupts.NewController(&vmem, cpu.ioRAM[:])
+	cpu.PPU = ppu.NewPPU(config, &vmem, cpu.ioRAM[:], cpu.scheduler, cpu.interrupts)
 	vmem.AddMMIO(cpu.biosROM[:], 0x00000000, BIOSROMSize)
 	// 0x00004000-0x01FFFFFF is unused
 	vmem.AddMMIO(cpu.onBoardRAM[:], 0x02000000, OnBoardRAMSize)
@@ -113,12 +190,15 @@ func NewARM7TDMI(config *config.Config) *ARM7TDMI {
 	vmem.AddMMIO(cpu.unusedBiosByte[:], 0x04000410, 1)
 	// 0x04000400-0x04FFFFFF is unused
 	vmem.AddMMIO(cpu.gamePakROM[:], 0x08000000, GamePakROMSize)
+	cpu.mapSRAM()
 
-	if config.BIOSPath != "" {
-		cpu.loadBIOSROM()
+	vmem.SetWriteFilter(0x04000301, cpu.writeHALTCNT)
+
+	if config.BlockCache {
+		cpu.blocks = newBlockCache(&vmem)
+		vmem.SetWriteHook(cpu.blocks.invalidate)
 	}
-	cpu.loadROM()
-	cpu.Reset()
+	cpu.updateAccessHook()
 	return cpu
 }
 
@@ -138,570 +218,192 @@ func (c *ARM7TDMI) DebugRegisters() string {
 		ret += fmt.Sprintf("R12: 0x%08X\t SP: 0x%08X\t LR: 0x%08X\t  PC: 0x%08X\n", c.ReadRegister(12), c.ReadRegister(13), c.ReadRegister(14), c.ReadRegister(15))
 	}
 	ret += fmt.Sprintf("%s\n", c.prettyCPSR())
-	if cpuMode(c.ReadRegister(CPSR_REG)&0x1F) != systemMode && cpuMode(c.ReadRegister(CPSR_REG)&0x1F) != userMode {
+	if bankOf(c.r[CPSR_REG]) != bankUser {
 		ret += fmt.Sprintf("SPSR: 0x%08X\n", c.ReadSPSR())
 	}
 	return ret
 }
 
-func (c *ARM7TDMI) loadBIOSROM() {
-	bios, err := os.ReadFile(c.config.BIOSPath)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to load bios rom: %v", err))
+// traceRegisters logs the instruction at address with the registers it
+// starts from, for --trace-registers
+func (c *ARM7TDMI) traceRegisters(address uint32, opcode, text string) {
+	args := []any{"addr", logging.Hex(address), "opcode", opcode, "instruction", text}
+	for reg, name := range registerNames[:PC_REG] {
+		args = append(args, name, logging.Hex(c.ReadDebugRegister(uint8(reg))))
 	}
-	if len(bios) != BIOSROMSize {
-		panic(fmt.Sprintf("BIOS ROM size is %d, expected %d", len(bios), BIOSROMSize))
+	args = append(args, "cpsr", logging.Hex(c.ReadCPSR()))
+	logging.CPU.Info("Executing", args...)
+}
+
+func loadBIOSROM(path string) ([]byte, error) {
+	bios, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bios rom: %w", err)
 	}
-	copy(c.biosROM[:], bios)
+	return bios, nil
 }
 
-func (c *ARM7TDMI) loadROM() {
-	rom, err := os.ReadFile(c.config.ROMPath)
+// loadROM reads the ROM named in config and applies its patches
+func loadROM(config *config.Config) ([]byte, error) {
+	rom, err := cartridge.LoadROM(config.ROMPath, config.ROMEntry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rom: %w", err)
+	}
+	if len(rom) > GamePakROMSize {
+		return nil, fmt.Errorf("ROM size is %d, expected maximum of %d", len(rom), GamePakROMSize)
+	}
+	rom, err = cartridge.ApplyPatches(rom, patchPaths(config))
 	if err != nil {
-		panic(fmt.Sprintf("Failed to load rom: %v", err))
+		return nil, fmt.Errorf("failed to patch rom: %w", err)
 	}
 	if len(rom) > GamePakROMSize {
-		panic(fmt.Sprintf("ROM size is %d, expected maximum of %d", len(rom), GamePakROMSize))
+		return nil, fmt.Errorf("patched ROM size is %d, expected maximum of %d", len(rom), GamePakROMSize)
 	}
-	copy(c.gamePakROM[:], rom)
+	return rom, nil
 }
 
-func (c *ARM7TDMI) Reset() {
-	c.halted = true
-	c.exit = false
+// patchPaths lists the patches found next to the ROM followed by those
+// passed in the config, skipping any listed twice
+func patchPaths(config *config.Config) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, path := range append(cartridge.FindPatches(config.ROMPath), config.Patches...) {
+		key := path
+		if abs, err := filepath.Abs(path); err == nil {
+			key = abs
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		logging.CPU.Info("Applying patch", "path", path)
+		paths = append(paths, path)
+	}
+	return paths
+}
 
-	c.r[SP_REG] = 0x03007F00 // Stack pointer to the top of on-chip RAM
+// GetCartridgeHeader returns the header of the loaded ROM, or nil if it couldn't be parsed
+func (c *ARM7TDMI) GetCartridgeHeader() *cartridge.Header {
+	return c.header
+}
 
-	c.sp_svc = c.r[SP_REG]
-	c.sp_abt = c.r[SP_REG]
-	c.sp_irq = c.r[SP_REG]
-	c.sp_und = c.r[SP_REG]
-	c.sp_fiq = c.r[SP_REG]
+func (c *ARM7TDMI) Reset() error {
+	c.halted = true
+	c.exit.Store(false)
+	c.power = powerRunning
 
-	if c.config.BIOSPath == "" {
+	if !c.hasBIOS {
 		// Start at the entry point of the ROM
-		c.r[CPSR_REG] = 0x6000001F
+		c.WriteCPSR(0x6000001F)
 		c.r[PC_REG] = 0x08000000
 	} else {
 		// Start at the entry point of the BIOS
 		// IRQs disabled, FIQs disabled, ARM mode, system mode
-		c.r[CPSR_REG] = 0x1F
+		c.WriteCPSR(0x1F)
 		c.r[PC_REG] = 0x00000000
 	}
 
+	// Stack pointers to the top of on-chip RAM
+	c.r[SP_REG] = 0x03007F00
+	for b := range c.spLR {
+		c.spLR[b][0] = 0x03007F00
+	}
+	if !c.hasBIOS {
+		// The stacks the BIOS would have set up
+		c.spLR[bankSupervisor][0] = 0x03007FE0
+		c.spLR[bankIRQ][0] = 0x03007FA0
+	}
+
+	// No buttons are pressed
+	c.SetKeyInput(0)
+
 	// Initialize the prefetch buffers
 	var err error
 	c.prefetchARMPipeline[0], err = c.virtualMemory.Read32(c.r[PC_REG])
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read from memory: %v", err))
+		return err
 	}
 	c.prefetchARMPipeline[1], err = c.virtualMemory.Read32(c.r[PC_REG] + 4)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read from memory: %v", err))
+		return err
 	}
 	c.prefetchThumbPipeline[0], err = c.virtualMemory.Read16(c.r[PC_REG])
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read from memory: %v", err))
+		return err
 	}
 	c.prefetchThumbPipeline[1], err = c.virtualMemory.Read16(c.r[PC_REG] + 2)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read from memory: %v", err))
+		return err
 	}
 
-	if c.config.BIOSPath == "" {
+	if !c.hasBIOS {
 		c.r[PC_REG] = 0x08000004
 	} else {
 		c.r[PC_REG] = 0x00000004 // Reset vector
 	}
 
-	if c.config.Debug {
-		fmt.Printf("Resetting CPU\n")
-	}
+	logging.CPU.Debug("Resetting CPU")
 
 	c.halted = false
-	c.exit = false
-}
-
-func (c *ARM7TDMI) ReadSPSR() uint32 {
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		return c.r[CPSR_REG] | (1 << 4)
-	case userMode:
-		return c.r[CPSR_REG] | (1 << 4)
-	case fiqMode:
-		return c.spsr_fiq
-	case irqMode:
-		return c.spsr_irq
-	case supervisorMode:
-		return c.spsr_svc
-	case abortMode:
-		return c.spsr_abt
-	case undefinedMode:
-		return c.spsr_und
-	default:
-		panic(fmt.Sprintf("Unknown CPU mode: %d", c.r[CPSR_REG]&0x1F))
+	c.exit.Store(false)
+	if c.shadow != nil {
+		return c.shadow.Reset()
 	}
+	return nil
 }
 
-func (c *ARM7TDMI) WriteSPSR(value uint32) {
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		c.r[CPSR_REG] = value
-	case userMode:
-		c.r[CPSR_REG] = value
-	case fiqMode:
-		c.spsr_fiq = value
-	case irqMode:
-		c.spsr_irq = value
-	case supervisorMode:
-		c.spsr_svc = value
-	case abortMode:
-		c.spsr_abt = value
-	case undefinedMode:
-		c.spsr_und = value
-	default:
-		panic("Unknown CPU mode")
-	}
-}
-
-func (c *ARM7TDMI) ReadHighRegister(reg uint8) uint32 {
-	if !c.GetThumbMode() {
-		panic("Cannot read high register in ARM mode, use ReadRegister")
-	}
-	if reg > 15 {
-		panic(fmt.Sprintf("Invalid register number %d", reg))
-	}
-	reg += 8
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		return c.r[reg]
-	case userMode:
-		return c.r[reg]
-	case fiqMode:
-		switch reg {
-		case 8:
-			return c.r8_fiq
-		case 9:
-			return c.r9_fiq
-		case 10:
-			return c.r10_fiq
-		case 11:
-			return c.r11_fiq
-		case 12:
-			return c.r12_fiq
-		case 13:
-			return c.sp_fiq
-		case 14:
-			return c.lr_fiq
-		default:
-			return c.r[reg]
-		}
-	case irqMode:
-		switch reg {
-		case 13:
-			return c.sp_irq
-		case 14:
-			return c.lr_irq
-		default:
-			return c.r[reg]
-		}
-	case supervisorMode:
-		switch reg {
-		case 13:
-			return c.sp_svc
-		case 14:
-			return c.lr_svc
-		default:
-			return c.r[reg]
-		}
-	case abortMode:
-		switch reg {
-		case 13:
-			return c.sp_abt
-		case 14:
-			return c.lr_abt
-		default:
-			return c.r[reg]
-		}
-	case undefinedMode:
-		switch reg {
-		case 13:
-			return c.sp_und
-		case 14:
-			return c.lr_und
-		default:
-			return c.r[reg]
-		}
-	default:
-		panic("Unknown CPU mode")
-	}
-}
-
-func (c *ARM7TDMI) WriteHighRegister(reg uint8, value uint32) {
-	if !c.GetThumbMode() {
-		panic("Cannot write high register in ARM mode, use WriteRegister")
-	}
-	if reg > 15 {
-		panic(fmt.Sprintf("Invalid register number %d", reg))
-	}
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		c.r[reg+8] = value
-	case userMode:
-		c.r[reg+8] = value
-	case fiqMode:
-		switch reg {
-		case 8:
-			c.r8_fiq = value
-		case 9:
-			c.r9_fiq = value
-		case 10:
-			c.r10_fiq = value
-		case 11:
-			c.r11_fiq = value
-		case 12:
-			c.r12_fiq = value
-		case 13:
-			c.sp_fiq = value
-		case 14:
-			c.lr_fiq = value
-		default:
-			c.r[reg+8] = value
-		}
-	case irqMode:
-		switch reg {
-		case 13:
-			c.sp_irq = value
-		case 14:
-			c.lr_irq = value
-		default:
-			c.r[reg+8] = value
-		}
-	case supervisorMode:
-		switch reg {
-		case 13:
-			c.sp_svc = value
-		case 14:
-			c.lr_svc = value
-		default:
-			c.r[reg+8] = value
-		}
-	case abortMode:
-		switch reg {
-		case 13:
-			c.sp_abt = value
-		case 14:
-			c.lr_abt = value
-		default:
-			c.r[reg+8] = value
-		}
-	case undefinedMode:
-		switch reg {
-		case 13:
-			c.sp_und = value
-		case 14:
-			c.lr_und = value
-		default:
-			c.r[reg+8] = value
-		}
-	default:
-		panic("Unknown CPU mode")
-	}
-}
-
-//nolint:golint,gocyclo
-func (c *ARM7TDMI) ReadRegister(reg uint8) uint32 {
-	if c.GetThumbMode() {
-		if reg > 7 && reg != PC_REG && reg != LR_REG && reg != SP_REG && reg != CPSR_REG {
-			panic(fmt.Sprintf("Invalid register number %d", reg))
-		}
-		switch reg {
-		case PC_REG:
-			return c.ReadPC()
-		case LR_REG:
-			return c.ReadLR()
-		case SP_REG:
-			return c.ReadSP()
-		case CPSR_REG:
-			return c.ReadCPSR()
-		}
-		return c.r[reg]
-	}
-	if reg > 16 {
-		panic(fmt.Sprintf("Invalid register number %d", reg))
-	}
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		return c.r[reg]
-	case userMode:
-		return c.r[reg]
-	case fiqMode:
-		switch reg {
-		case 8:
-			return c.r8_fiq
-		case 9:
-			return c.r9_fiq
-		case 10:
-			return c.r10_fiq
-		case 11:
-			return c.r11_fiq
-		case 12:
-			return c.r12_fiq
-		case 13:
-			return c.sp_fiq
-		case 14:
-			return c.lr_fiq
-		default:
-			return c.r[reg]
-		}
-	case irqMode:
-		switch reg {
-		case 13:
-			return c.sp_irq
-		case 14:
-			return c.lr_irq
-		default:
-			return c.r[reg]
-		}
-	case supervisorMode:
-		switch reg {
-		case 13:
-			return c.sp_svc
-		case 14:
-			return c.lr_svc
-		default:
-			return c.r[reg]
-		}
-	case abortMode:
-		switch reg {
-		case 13:
-			return c.sp_abt
-		case 14:
-			return c.lr_abt
-		default:
-			return c.r[reg]
-		}
-	case undefinedMode:
-		switch reg {
-		case 13:
-			return c.sp_und
-		case 14:
-			return c.lr_und
-		default:
-			return c.r[reg]
-		}
-	default:
-		panic("Unknown CPU mode")
-	}
-}
+// SetKeyInput updates KEYINPUT with the pressed buttons. The register is
+// active low, so a pressed button reads as 0. The keypad interrupt is
+// raised if KEYCNT asks for it.
+func (c *ARM7TDMI) SetKeyInput(pressed uint16) {
+	keyInput := ^pressed & 0x3FF
+	c.ioRAM[0x130] = byte(keyInput)
+	c.ioRAM[0x131] = byte(keyInput >> 8)
 
-//nolint:golint,gocyclo
-func (c *ARM7TDMI) WriteRegister(reg uint8, value uint32) {
-	if c.GetThumbMode() {
-		if reg > 7 && reg != PC_REG && reg != LR_REG && reg != SP_REG && reg != CPSR_REG {
-			panic(fmt.Sprintf("Invalid register number %d", reg))
-		}
-		switch reg {
-		case PC_REG:
-			c.WritePC(value)
-		case LR_REG:
-			c.WriteLR(value)
-		case SP_REG:
-			c.WriteSP(value)
-		case CPSR_REG:
-			c.WriteCPSR(value)
-		default:
-			c.r[reg] = value
-		}
-	} else {
-		if reg > 16 {
-			panic(fmt.Sprintf("Invalid register number %d", reg))
-		}
-		switch reg {
-		case PC_REG:
-			c.WritePC(value)
-		case LR_REG:
-			c.WriteLR(value)
-		default:
-			switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-			case systemMode:
-				c.r[reg] = value
-			case userMode:
-				c.r[reg] = value
-			case fiqMode:
-				switch reg {
-				case 8:
-					c.r8_fiq = value
-				case 9:
-					c.r9_fiq = value
-				case 10:
-					c.r10_fiq = value
-				case 11:
-					c.r11_fiq = value
-				case 12:
-					c.r12_fiq = value
-				case 13:
-					c.sp_fiq = value
-				case 14:
-					c.lr_fiq = value
-				default:
-					c.r[reg] = value
-				}
-			case irqMode:
-				switch reg {
-				case 13:
-					c.sp_irq = value
-				case 14:
-					c.lr_irq = value
-				default:
-					c.r[reg] = value
-				}
-			case supervisorMode:
-				switch reg {
-				case 13:
-					c.sp_svc = value
-				case 14:
-					c.lr_svc = value
-				default:
-					c.r[reg] = value
-				}
-			case abortMode:
-				switch reg {
-				case 13:
-					c.sp_abt = value
-				case 14:
-					c.lr_abt = value
-				default:
-					c.r[reg] = value
-				}
-			case undefinedMode:
-				switch reg {
-				case 13:
-					c.sp_und = value
-				case 14:
-					c.lr_und = value
-				default:
-					c.r[reg] = value
-				}
-			}
+	keyControl := uint16(c.ioRAM[0x132]) | uint16(c.ioRAM[0x133])<<8
+	selected := keyControl & 0x3FF
+	if keyControl&(1<<14) != 0 && selected != 0 {
+		// Bit 15 asks for all the selected keys instead of any of them
+		all := keyControl&(1<<15) != 0
+		if (all && pressed&selected == selected) || (!all && pressed&selected != 0) {
+			c.interrupts.Request(interrupts.Keypad)
 		}
 	}
-}
-
-func (c *ARM7TDMI) ReadSP() uint32 {
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		return c.r[SP_REG]
-	case userMode:
-		return c.r[SP_REG]
-	case fiqMode:
-		return c.sp_fiq
-	case irqMode:
-		return c.sp_irq
-	case supervisorMode:
-		return c.sp_svc
-	case abortMode:
-		return c.sp_abt
-	case undefinedMode:
-		return c.sp_und
-	default:
-		panic("Unknown CPU mode")
+	if c.shadow != nil {
+		c.shadow.SetKeyInput(pressed)
 	}
 }
 
-func (c *ARM7TDMI) WriteSP(value uint32) {
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		c.r[SP_REG] = value
-	case userMode:
-		c.r[SP_REG] = value
-	case fiqMode:
-		c.sp_fiq = value
-	case irqMode:
-		c.sp_irq = value
-	case supervisorMode:
-		c.sp_svc = value
-	case abortMode:
-		c.sp_abt = value
-	case undefinedMode:
-		c.sp_und = value
-	default:
-		panic("Unknown CPU mode")
-	}
+func (c *ARM7TDMI) GetMMIO() *memory.MMIO {
+	return c.virtualMemory
 }
 
-func (c *ARM7TDMI) ReadLR() uint32 {
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		return c.r[LR_REG]
-	case userMode:
-		return c.r[LR_REG]
-	case fiqMode:
-		return c.lr_fiq
-	case irqMode:
-		return c.lr_irq
-	case supervisorMode:
-		return c.lr_svc
-	case abortMode:
-		return c.lr_abt
-	case undefinedMode:
-		return c.lr_und
-	default:
-		panic("Unknown CPU mode")
-	}
+func (c *ARM7TDMI) GetBus() memory.Bus {
+	return c.virtualMemory
 }
 
-func (c *ARM7TDMI) WriteLR(value uint32) {
-	switch cpuMode(c.r[CPSR_REG] & 0x1F) {
-	case systemMode:
-		c.r[LR_REG] = value
-	case userMode:
-		c.r[LR_REG] = value
-	case fiqMode:
-		c.lr_fiq = value
-	case irqMode:
-		c.lr_irq = value
-	case supervisorMode:
-		c.lr_svc = value
-	case abortMode:
-		c.lr_abt = value
-	case undefinedMode:
-		c.lr_und = value
-	default:
-		panic("Unknown CPU mode")
+// readARM reads the ARM instruction at addr for the pipeline, from the
+// block cache when it holds it
+func (c *ARM7TDMI) readARM(addr uint32) (uint32, error) {
+	if c.blocks != nil {
+		if opcode, ok := c.blocks.fetchARM(addr); ok {
+			return opcode, nil
+		}
 	}
+	return c.virtualMemory.Read32(addr)
 }
 
-func (c *ARM7TDMI) ReadPC() uint32 {
-	return c.r[PC_REG]
-}
-
-func (c *ARM7TDMI) WritePC(value uint32) {
-	// Mask out the bottom two bits in arm mode
-	if c.GetThumbMode() {
-		value &= 0xFFFFFFFE
-	} else {
-		value &= 0xFFFFFFFC
+// readThumb is readARM for THUMB instructions
+func (c *ARM7TDMI) readThumb(addr uint32) (uint16, error) {
+	if c.blocks != nil {
+		if opcode, ok := c.blocks.fetchThumb(addr); ok {
+			return opcode, nil
+		}
 	}
-	c.r[PC_REG] = value
-}
-
-func (c *ARM7TDMI) GetMMIO() *memory.MMIO {
-	return c.virtualMemory
+	return c.virtualMemory.Read16(addr)
 }
 
-func (c *ARM7TDMI) ReadCPSR() uint32 {
-	cpsr := c.r[CPSR_REG]
-	// Top bit of mode is always 1
-	cpsr |= (1 << 4)
-	return cpsr
-}
-
-func (c *ARM7TDMI) WriteCPSR(value uint32) {
-	c.r[CPSR_REG] = value
-}
-
-func (c *ARM7TDMI) fetchARM() uint32 {
+func (c *ARM7TDMI) fetchARM() (uint32, error) {
 	c.r[PC_REG] += 4
 
 	instruction := c.prefetchARMPipeline[0]
@@ -709,66 +411,67 @@ func (c *ARM7TDMI) fetchARM() uint32 {
 
 	// Prefetch the next instruction
 	var err error
-	c.prefetchARMPipeline[1], err = c.virtualMemory.Read32(c.r[PC_REG])
+	c.prefetchARMPipeline[1], err = c.readARM(c.r[PC_REG])
 	if err != nil {
-		panic(fmt.Sprintf("Error reading instruction at 0x%08X: %v", c.r[PC_REG], err))
+		return 0, err
 	}
-	if c.config.Debug {
-		fmt.Printf("fetchARM: Prefetching arm instruction at 0x%08X\n", c.r[PC_REG])
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("fetchARM: Prefetching arm instruction at 0x%08X", c.r[PC_REG])
 
-		fmt.Printf("fetchARM: Prefetch: [0x%08x, 0x%08x]\n", c.prefetchARMPipeline[0], c.prefetchARMPipeline[1])
+		logging.CPU.Tracef("fetchARM: Prefetch: [0x%08x, 0x%08x]", c.prefetchARMPipeline[0], c.prefetchARMPipeline[1])
 	}
 
-	return instruction
+	return instruction, nil
 }
 
-func (c *ARM7TDMI) FlushPipeline() {
+func (c *ARM7TDMI) FlushPipeline() error {
 	// Flush the pipeline
 	var err error
 
 	if !c.GetThumbMode() {
-		if c.config.Debug {
-			fmt.Printf("FlushPipeline: Prefetching arm instruction at 0x%08X\n", c.r[PC_REG])
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("FlushPipeline: Prefetching arm instruction at 0x%08X", c.r[PC_REG])
 		}
-		c.prefetchARMPipeline[0], err = c.virtualMemory.Read32(c.r[PC_REG])
+		c.prefetchARMPipeline[0], err = c.readARM(c.r[PC_REG])
 		if err != nil {
-			panic(fmt.Sprintf("Error reading instruction at 0x%08X: %v", c.r[PC_REG], err))
+			return err
 		}
 
-		if c.config.Debug {
-			fmt.Printf("FlushPipeline: Prefetching arm instruction at 0x%08X\n", c.r[PC_REG]+4)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("FlushPipeline: Prefetching arm instruction at 0x%08X", c.r[PC_REG]+4)
 		}
-		c.prefetchARMPipeline[1], err = c.virtualMemory.Read32(c.r[PC_REG] + 4)
+		c.prefetchARMPipeline[1], err = c.readARM(c.r[PC_REG] + 4)
 		if err != nil {
-			panic(fmt.Sprintf("Error reading instruction at 0x%08X: %v", c.r[PC_REG]+4, err))
+			return err
 		}
 		c.r[PC_REG] += 4
 	} else {
-		if c.config.Debug {
-			fmt.Printf("FlushPipeline: Prefetching thumb instruction at 0x%08X\n", c.r[PC_REG])
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("FlushPipeline: Prefetching thumb instruction at 0x%08X", c.r[PC_REG])
 		}
-		c.prefetchThumbPipeline[0], err = c.virtualMemory.Read16(c.r[PC_REG])
+		c.prefetchThumbPipeline[0], err = c.readThumb(c.r[PC_REG])
 		if err != nil {
-			panic(fmt.Sprintf("Error reading instruction at 0x%08X: %v", c.r[PC_REG], err))
+			return err
 		}
 
-		if c.config.Debug {
-			fmt.Printf("FlushPipeline: Prefetching thumb instruction at 0x%08X\n", c.r[PC_REG]+2)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("FlushPipeline: Prefetching thumb instruction at 0x%08X", c.r[PC_REG]+2)
 		}
-		c.prefetchThumbPipeline[1], err = c.virtualMemory.Read16(c.r[PC_REG] + 2)
+		c.prefetchThumbPipeline[1], err = c.readThumb(c.r[PC_REG] + 2)
 		if err != nil {
-			panic(fmt.Sprintf("Error reading instruction at 0x%08X: %v", c.r[PC_REG]+2, err))
+			return err
 		}
 		c.r[PC_REG] += 2
 	}
 
-	if c.config.Debug {
-		fmt.Printf("FlushPipeline: Prefetch: [0x%08x, 0x%08x]\n", c.prefetchARMPipeline[0], c.prefetchARMPipeline[1])
-		fmt.Printf("FlushPipeline: Prefetch: [0x%04x, 0x%04x]\n", c.prefetchThumbPipeline[0], c.prefetchThumbPipeline[1])
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("FlushPipeline: Prefetch: [0x%08x, 0x%08x]", c.prefetchARMPipeline[0], c.prefetchARMPipeline[1])
+		logging.CPU.Tracef("FlushPipeline: Prefetch: [0x%04x, 0x%04x]", c.prefetchThumbPipeline[0], c.prefetchThumbPipeline[1])
 	}
+	return nil
 }
 
-func (c *ARM7TDMI) fetchThumb() uint16 {
+func (c *ARM7TDMI) fetchThumb() (uint16, error) {
 	c.r[PC_REG] += 2
 
 	instruction := c.prefetchThumbPipeline[0]
@@ -776,177 +479,79 @@ func (c *ARM7TDMI) fetchThumb() uint16 {
 
 	// Prefetch the next instruction
 	var err error
-	c.prefetchThumbPipeline[1], err = c.virtualMemory.Read16(c.r[PC_REG])
+	c.prefetchThumbPipeline[1], err = c.readThumb(c.r[PC_REG])
 	if err != nil {
-		panic(fmt.Sprintf("fetchThumb: Error reading instruction at 0x%08X: %v", c.r[PC_REG], err))
+		return 0, err
 	}
-	if c.config.Debug {
-		fmt.Printf("fetchThumb: Prefetching thumb instruction at 0x%08X\n", c.r[PC_REG])
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("fetchThumb: Prefetching thumb instruction at 0x%08X", c.r[PC_REG])
 	}
 
-	if c.config.Debug {
-		fmt.Printf("fetchThumb: Prefetch: [0x%04x, 0x%04x]\n", c.prefetchThumbPipeline[0], c.prefetchThumbPipeline[1])
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("fetchThumb: Prefetch: [0x%04x, 0x%04x]", c.prefetchThumbPipeline[0], c.prefetchThumbPipeline[1])
 	}
 
-	return instruction
+	return instruction, nil
 }
 
-//nolint:golint,gocyclo
-func (c *ARM7TDMI) stepARM() {
+func (c *ARM7TDMI) stepARM() error {
 	// FETCH
-	instruction := c.fetchARM()
+	instruction, err := c.fetchARM()
+	if err != nil {
+		return err
+	}
 
 	if c.config.TraceRegisters {
-		fmt.Printf("\n\nInstruction 0x%08X\n", instruction)
-		fmt.Print(c.DebugRegisters())
+		address := c.r[PC_REG] - 8
+		c.traceRegisters(address, fmt.Sprintf("%08X", instruction), disasm.ARM(instruction, address))
+	}
+	if c.tracer != nil {
+		c.traceInstruction(c.r[PC_REG]-8, instruction)
 	}
 
 	// DECODE
-	var condition = instruction >> 28
-	conditionFailed := false
-	// c.r[CSPR_REG] has condition flags N Z C V at bits 31-28
-	// c.r[CSPR_REG] has control bits I F T at bits 7-5
-	// c.r[CSPR_REG] has mode bits M4 M3 M2 M1 M0 at bits 4-0
-	switch condition {
-	case 0b1110 /* AL, always */ :
-		// The instruction is always executed
-		break
-	case 0b0000 /* EQ, equal */ :
-		// If the CSPR Z flag (bit 30) is set, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<30) == 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of EQ conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b0001 /* NE, not equal */ :
-		// If the CSPR Z flag (bit 30) is clear, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<30) != 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of NE conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b0010 /* CS, unsigned higher or same */ :
-		// If the CSPR C flag (bit 29) is set, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<29) == 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of CS conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b0011 /* CC, unsigned lower */ :
-		// If the CSPR C flag (bit 29) is clear, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<29) != 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of CC conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b0100 /* MI, negative */ :
-		// If the CSPR N flag (bit 31) is set, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<31) == 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of MI conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b0101 /* PL, positive or zero */ :
-		// If the CSPR N flag (bit 31) is clear, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<31) != 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of PL conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b0110 /* VS, overflow */ :
-		// If the CSPR V flag (bit 28) is set, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<28) == 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of VS conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b0111 /* VC, no overflow */ :
-		// If the CSPR V flag (bit 28) is clear, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<28) != 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of VC conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b1000 /* HI, unsigned higher */ :
-		// If the CSPR C flag (bit 29) is set and the CSPR Z flag (bit 30) is clear, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<29) == 0 || c.r[CPSR_REG]&(1<<30) != 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of HI conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b1001 /* LS, unsigned lower or same */ :
-		// If the CSPR C flag (bit 29) is clear or the CSPR Z flag (bit 30) is set, then the instruction is executed
-		if c.r[CPSR_REG]&(1<<29) != 0 || c.r[CPSR_REG]&(1<<30) == 0 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of LS conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b1010 /* GE, greater than or equal */ :
-		// If the CSPR N flag (bit 31) is equal to the CSPR V flag (bit 28), then the instruction is executed
-		if c.r[CPSR_REG]&(1<<31)>>31 != c.r[CPSR_REG]&(1<<28)>>28 {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of GE conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b1011 /* LT, less than */ :
-		// If the CSPR N flag (bit 31) is not equal to the CSPR V flag (bit 28), then the instruction is executed
-		if c.r[CPSR_REG]&(1<<31) == c.r[CPSR_REG]&(1<<28) {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of LT conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b1100 /* GT, greater than */ :
-		// If the CSPR Z flag (bit 30) is clear, the CSPR N flag (bit 31) is equal to the CSPR V flag (bit 28), then the instruction is executed
-		if c.r[CPSR_REG]&(1<<30)>>30 != 0 && (c.r[CPSR_REG]&(1<<31)>>31 != c.r[CPSR_REG]&(1<<28)>>28) {
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of GT conditional\n", instruction)
-			}
-			conditionFailed = true
-		}
-	case 0b1101 /* LE, less than or equal */ :
-		// If the CSPR Z flag (bit 30) is set, or the CSPR N flag (bit 31) is not equal to the CSPR V flag (bit 28), then the instruction is executed
-		if c.r[CPSR_REG]&(1<<30)>>30 != 1 && (c.r[CPSR_REG]&(1<<31)>>31 == c.r[CPSR_REG]&(1<<28)>>28) {
-			fmt.Println(c.prettyCPSR())
-			if c.config.Debug {
-				fmt.Printf("Skipping instruction 0x%08X becaue of LE conditional\n", instruction)
-			}
-			conditionFailed = true
+	if !arm.ConditionPassed(instruction>>28, c.r[CPSR_REG]) {
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Skipping instruction 0x%08X because its condition failed", instruction)
 		}
+		return nil
 	}
 
 	// EXECUTE
-	if !conditionFailed {
-		oldPC := c.r[PC_REG]
+	oldPC := c.r[PC_REG]
 
-		if c.config.Debug {
-			fmt.Printf("Executing instruction 0x%08X at 0x%08X\n", instruction, c.r[PC_REG])
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Executing instruction 0x%08X at 0x%08X", instruction, c.r[PC_REG])
+	}
+	var handler arm.Handler
+	if c.blocks != nil {
+		handler = c.blocks.armHandler(oldPC-8, instruction)
+	} else {
+		handler = arm.Lookup(instruction)
+	}
+	if handler == nil {
+		return &ErrUnknownInstruction{PC: oldPC - 8, Opcode: instruction, Mode: "ARM"}
+	}
+	c.virtualMemory.SetAccessHook(c.accessHook)
+	repipeline, cycles, err := handler(c, instruction)
+	c.virtualMemory.SetAccessHook(nil)
+	if err != nil {
+		return err
+	}
+	c.waitCycles += cycles
+	if repipeline || oldPC != c.r[PC_REG] {
+		if logging.CPU.Enabled(logging.LevelDebug) {
+			logging.CPU.Debug("Branching, flushing pipeline", "from", logging.Hex(oldPC), "to", logging.Hex(c.r[PC_REG]))
+		}
+		target := c.r[PC_REG]
+		if err := c.FlushPipeline(); err != nil {
+			return err
 		}
-		instr := arm.DecodeInstruction(instruction)
-		if instr != nil {
-			repipeline, cycles := instr.Execute(c)
-			c.waitCycles += cycles
-			if repipeline || oldPC != c.r[PC_REG] {
-				if c.config.Debug {
-					fmt.Printf("Branching from 0x%08X to 0x%08X, flushing pipeline\n", oldPC, c.r[PC_REG])
-				}
-				c.FlushPipeline()
-			}
-		} else {
-			panic(fmt.Sprintf("Unknown instruction 0x%08X\n", instruction))
+		if c.config.IdleSkip {
+			c.branched(oldPC-8, target)
 		}
 	}
+	return nil
 }
 
 func (c *ARM7TDMI) prettyCPSR() string {
@@ -1015,37 +620,67 @@ func (c *ARM7TDMI) GetConfig() *config.Config {
 	return c.config
 }
 
-func (c *ARM7TDMI) stepThumb() {
+// HasBIOS reports whether a BIOS is loaded, without one SWIs are emulated
+func (c *ARM7TDMI) HasBIOS() bool {
+	return c.hasBIOS
+}
+
+func (c *ARM7TDMI) stepThumb() error {
 	// FETCH
-	instruction := c.fetchThumb()
+	instruction, err := c.fetchThumb()
+	if err != nil {
+		return err
+	}
 
 	if c.config.TraceRegisters {
-		fmt.Printf("\n\nInstruction 0x%04X\n", instruction)
-		fmt.Print(c.DebugRegisters())
+		address := c.r[PC_REG] - 4
+		text, _, err := disasm.Disassemble(c.virtualMemory, address, true)
+		if err != nil {
+			text = disasm.Thumb(instruction, address)
+		}
+		c.traceRegisters(address, fmt.Sprintf("%04X", instruction), text)
+	}
+	if c.tracer != nil {
+		c.traceInstruction(c.r[PC_REG]-4, uint32(instruction))
 	}
 
 	// DECODE
-	instr := thumb.DecodeInstruction(instruction)
+	oldPC := c.r[PC_REG]
+	var handler thumb.Handler
+	if c.blocks != nil {
+		handler = c.blocks.thumbHandler(oldPC-4, instruction)
+	} else {
+		handler = thumb.Lookup(instruction)
+	}
 
 	// EXECUTE
-	oldPC := c.r[PC_REG]
-	if instr != nil {
-		repipeline, cycles := instr.Execute(c)
-		c.waitCycles += cycles
-		if repipeline || oldPC != c.r[PC_REG] {
-			if c.config.Debug {
-				fmt.Printf("Branching from 0x%08X to 0x%08X, flushing pipeline\n", oldPC, c.r[PC_REG])
-			}
-			if (c.r[PC_REG] & 0x1) != 0 {
-				// Unaligned PC, align it
-				c.r[PC_REG] &= 0xFFFFFFFE
-			}
-			c.FlushPipeline()
+	if handler == nil {
+		return &ErrUnknownInstruction{PC: oldPC - 4, Opcode: uint32(instruction), Mode: "THUMB"}
+	}
+	c.virtualMemory.SetAccessHook(c.accessHook)
+	repipeline, cycles, err := handler(c, instruction)
+	c.virtualMemory.SetAccessHook(nil)
+	if err != nil {
+		return err
+	}
+	c.waitCycles += cycles
+	if repipeline || oldPC != c.r[PC_REG] {
+		if logging.CPU.Enabled(logging.LevelDebug) {
+			logging.CPU.Debug("Branching, flushing pipeline", "from", logging.Hex(oldPC), "to", logging.Hex(c.r[PC_REG]))
+		}
+		if (c.r[PC_REG] & 0x1) != 0 {
+			// Unaligned PC, align it
+			c.r[PC_REG] &= 0xFFFFFFFE
+		}
+		target := c.r[PC_REG]
+		if err := c.FlushPipeline(); err != nil {
+			return err
+		}
+		if c.config.IdleSkip {
+			c.branched(oldPC-4, target)
 		}
-	} else {
-		fmt.Printf("Unknown instruction 0x%04X\n", instruction)
-		panic("")
 	}
+	return nil
 }
 
 func (c *ARM7TDMI) SetThumbMode(value bool) {
@@ -1060,53 +695,116 @@ func (c *ARM7TDMI) GetThumbMode() bool {
 	return c.r[CPSR_REG]&(1<<5)>>5 != 0
 }
 
-// InteractiveRun runs the CPU one instruction at a time, waiting for user input
-func (c *ARM7TDMI) InteractiveRun() {
-	fmt.Printf("Interactive mode, press enter to step\n")
-	for !c.exit {
-		fmt.Scanln()
-		c.Step()
+// Step runs the next instruction for one cycle, or lets the remaining
+// cycles of the last one pass. An instruction that cannot be fetched,
+// decoded or executed returns an error, leaving PC on the instruction that
+// failed.
+func (c *ARM7TDMI) Step() error {
+	if c.shadow == nil {
+		return c.step()
 	}
+	pc := c.NextInstructionAddress()
+	err := c.step()
+	return c.checkShadow(pc, err)
 }
 
-func (c *ARM7TDMI) Step() {
-	if !c.halted {
-		if c.waitCycles > 0 {
-			c.waitCycles--
-			c.PPU.Step()
-			return
-		}
-		// if c.r[CPSR_REG] bit 5 is set, the CPU is in thumb mode
-		if c.r[CPSR_REG]&(1<<5)>>5 == 0 {
-			c.stepARM()
-		} else {
-			c.stepThumb()
+func (c *ARM7TDMI) step() error {
+	if c.halted {
+		return nil
+	}
+	if c.traceFile != nil {
+		defer c.crashTrace()
+	}
+	if c.waitCycles > 0 {
+		// Nothing happens on the CPU side until they have passed
+		c.cycles += uint64(c.waitCycles)
+		c.scheduler.Advance(uint64(c.waitCycles))
+		c.waitCycles = 0
+		return nil
+	}
+	if c.power != powerRunning && c.sleep() {
+		return nil
+	}
+	c.cycles++
+	if c.interrupts.Enabled() && c.interrupts.Pending() && c.r[CPSR_REG]&(1<<7) == 0 {
+		if err := c.enterIRQ(); err != nil {
+			return err
 		}
-		c.PPU.Step()
 	}
+	if c.execHook != nil && c.NextInstructionAddress() == c.execHookAddress {
+		c.execHook()
+	}
+	pc := c.NextInstructionAddress()
+	var err error
+	// if c.r[CPSR_REG] bit 5 is set, the CPU is in thumb mode
+	if c.r[CPSR_REG]&(1<<5)>>5 == 0 {
+		err = c.stepARM()
+	} else {
+		err = c.stepThumb()
+	}
+	if err != nil {
+		c.dumpTrace()
+		c.traceRecord = nil
+		// Refilling can only fail the same way the fetch already did, and
+		// the original error is the one worth reporting
+		c.r[PC_REG] = pc
+		_ = c.FlushPipeline()
+		return err
+	}
+	if c.traceRecord != nil {
+		c.finishTrace()
+	}
+	c.scheduler.Advance(1)
+	return nil
+}
+
+// NextInstructionAddress returns the address of the instruction the next
+// step executes. PC is one instruction ahead of it because of the prefetch.
+func (c *ARM7TDMI) NextInstructionAddress() uint32 {
+	if c.GetThumbMode() {
+		return c.r[PC_REG] - 2
+	}
+	return c.r[PC_REG] - 4
+}
+
+// SetExecHook calls hook every time the instruction at address is about to
+// execute. Passing a nil hook removes it.
+func (c *ARM7TDMI) SetExecHook(address uint32, hook func()) {
+	c.execHookAddress = address &^ 1
+	c.execHook = hook
 }
 
 // Run runs the CPU at a consistent 16.78MHz
-func (c *ARM7TDMI) Run() {
+func (c *ARM7TDMI) Run() error {
 	cycleTime := time.Second / 16777216
 	prevTime := time.Now()
-	for !c.exit {
-		c.Step()
+	for !c.exit.Load() {
+		if err := c.Step(); err != nil {
+			return err
+		}
 		time.Sleep(cycleTime - time.Since(prevTime))
 		prevTime = time.Now()
 	}
+	return nil
 }
 
 func (c *ARM7TDMI) Halt() {
 	c.halted = true
+	if c.shadow != nil {
+		c.shadow.Halt()
+	}
 }
 
 func (c *ARM7TDMI) Unhalt() {
 	c.halted = false
+	if c.shadow != nil {
+		c.shadow.Unhalt()
+	}
 }
 
+// Quit stops Run, it is safe to call from any goroutine
 func (c *ARM7TDMI) Quit() {
-	c.exit = true
+	c.exit.Store(true)
 }
 
 func (c *ARM7TDMI) SetZ(value bool) {
@@ -0,0 +1,69 @@
+package cpu
+
+// ReadDebugRegister reads R0-R15 of the current mode in either state, or
+// the CPSR for CPSR_REG. PC reads as the address of the next instruction,
+// without the prefetch offset.
+func (c *ARM7TDMI) ReadDebugRegister(reg uint8) uint32 {
+	switch {
+	case reg == PC_REG:
+		return c.NextInstructionAddress()
+	case reg == CPSR_REG:
+		return c.ReadCPSR()
+	case reg > 7 && c.GetThumbMode():
+		return c.ReadHighRegister(reg - 8)
+	}
+	return c.ReadRegister(reg)
+}
+
+// WriteDebugRegister writes a register like ReadDebugRegister reads it.
+// Writing PC or switching state through the CPSR refills the pipeline,
+// which fails if nothing is mapped at the new PC.
+func (c *ARM7TDMI) WriteDebugRegister(reg uint8, value uint32) error {
+	if c.shadow != nil {
+		// It fails the same way, and the error is reported below
+		_ = c.shadow.WriteDebugRegister(reg, value)
+	}
+	switch {
+	case reg == PC_REG:
+		return c.jump(value)
+	case reg == CPSR_REG:
+		pc := c.NextInstructionAddress()
+		c.WriteCPSR(value)
+		return c.jump(pc)
+	case reg > 7 && c.GetThumbMode():
+		return c.WriteHighRegister(reg-8, value)
+	default:
+		c.WriteRegister(reg, value)
+	}
+	return nil
+}
+
+// jump makes address the next instruction to execute
+func (c *ARM7TDMI) jump(address uint32) error {
+	c.r[PC_REG] = address
+	if c.GetThumbMode() {
+		c.r[PC_REG] &^= 1
+	} else {
+		c.r[PC_REG] &^= 3
+	}
+	return c.FlushPipeline()
+}
+
+// StepInstruction runs the CPU until it has executed one instruction,
+// letting the cycles of the previous one elapse first
+func (c *ARM7TDMI) StepInstruction() error {
+	for c.waitCycles > 0 && !c.halted {
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return c.Step()
+}
+
+// SetWatchHook calls hook on every memory access made by an executing
+// instruction. Instruction fetches and PPU accesses are not reported.
+// Passing a nil hook removes it.
+func (c *ARM7TDMI) SetWatchHook(hook func(addr uint32, size uint8, write bool)) {
+	c.watchHook = hook
+	c.updateAccessHook()
+}
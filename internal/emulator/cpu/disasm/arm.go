@@ -0,0 +1,293 @@
+package disasm
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/arm"
+)
+
+//nolint:golint,gochecknoglobals
+var dataProcessingOps = [16]string{"and", "eor", "sub", "rsb", "add", "adc", "sbc", "rsc", "tst", "teq", "cmp", "cmn", "orr", "mov", "bic", "mvn"}
+
+//nolint:golint,gochecknoglobals
+var shiftNames = [4]string{"lsl", "lsr", "asr", "ror"}
+
+// ARM disassembles an ARM instruction at addr. The checks run in the same
+// order as arm.DecodeInstruction, since the formats overlap.
+//
+//nolint:golint,gocyclo
+func ARM(opcode, addr uint32) string {
+	cond := conditions[opcode>>28]
+	switch {
+	case opcode&arm.BranchExchangeMask == arm.BranchExchangeFormat:
+		return fmt.Sprintf("bx%s %s", cond, reg(opcode))
+	case opcode&arm.BlockDataTransferMask == arm.BlockDataTransferFormat:
+		return armBlockTransfer(opcode, cond)
+	case opcode&arm.BranchMask == arm.BranchFormat, opcode&arm.BranchMask == arm.BranchWithLinkFormat:
+		mnemonic := "b"
+		if opcode&arm.BranchMask == arm.BranchWithLinkFormat {
+			mnemonic = "bl"
+		}
+		target := addr + 8 + uint32(signExtend(opcode&0xFFFFFF, 24)<<2)
+		return fmt.Sprintf("%s%s %s", mnemonic, cond, address(target))
+	case opcode&arm.SoftwareInterruptMask == arm.SoftwareInterruptFormat:
+		return fmt.Sprintf("swi%s %s", cond, imm(opcode&0xFFFFFF))
+	case opcode&arm.CoProcessorDataTransferMask == arm.CoProcessorDataTransferFormat:
+		return armCoprocessorTransfer(opcode, cond)
+	case opcode&arm.CoProcessorDataOperationMask == arm.CoProcessorDataOperationFormat,
+		opcode&arm.CoProcessorRegisterTransferMask == arm.CoProcessorRegisterTransferFormat:
+		return armCoprocessorOp(opcode, cond)
+	case opcode&arm.UndefinedMask == arm.UndefinedFormat:
+		return "undefined"
+	case opcode&arm.SingleDataTransferMask == arm.SingleDataTransferFormat:
+		return armSingleTransfer(opcode, addr, cond)
+	case opcode&arm.SingleDataSwapMask == arm.SingleDataSwapFormat:
+		b := ""
+		if opcode&(1<<22) != 0 {
+			b = "b"
+		}
+		return fmt.Sprintf("swp%s%s %s, %s, [%s]", cond, b, reg(opcode>>12), reg(opcode), reg(opcode>>16))
+	case opcode&arm.MultiplyMask == arm.MultiplyFormat:
+		s := sFlag(opcode)
+		if opcode&(1<<21) != 0 {
+			return fmt.Sprintf("mla%s%s %s, %s, %s, %s", cond, s, reg(opcode>>16), reg(opcode), reg(opcode>>8), reg(opcode>>12))
+		}
+		return fmt.Sprintf("mul%s%s %s, %s, %s", cond, s, reg(opcode>>16), reg(opcode), reg(opcode>>8))
+	case opcode&arm.MultiplyLongMask == arm.MultiplyLongFormat:
+		mnemonic := [4]string{"umull", "umlal", "smull", "smlal"}[(opcode>>21)&3]
+		return fmt.Sprintf("%s%s%s %s, %s, %s, %s", mnemonic, cond, sFlag(opcode), reg(opcode>>12), reg(opcode>>16), reg(opcode), reg(opcode>>8))
+	case opcode&arm.HalfwordDataTransferRegisterOffsetMask == arm.HalfwordDataTransferRegisterOffsetFormat,
+		opcode&arm.HalfwordDataTransferImmediateOffsetMask == arm.HalfwordDataTransferImmediateOffsetFormat:
+		return armHalfwordTransfer(opcode, addr, cond)
+	case opcode&arm.PSRTransferMRSMask == arm.PSRTransferMRSFormat:
+		return fmt.Sprintf("mrs%s %s, %s", cond, reg(opcode>>12), psrName(opcode))
+	case opcode&arm.PSRTransferMSRMask == arm.PSRTransferMSRFormat:
+		return fmt.Sprintf("msr%s %s_%s, %s", cond, psrName(opcode), psrFields(opcode), armOperand2(opcode))
+	case opcode&arm.DataProcessingMask == arm.DataProcessingFormat:
+		return armDataProcessing(opcode, addr, cond)
+	}
+	return "undefined"
+}
+
+func sFlag(opcode uint32) string {
+	if opcode&(1<<20) != 0 {
+		return "s"
+	}
+	return ""
+}
+
+func psrName(opcode uint32) string {
+	if opcode&(1<<22) != 0 {
+		return "spsr"
+	}
+	return "cpsr"
+}
+
+// psrFields lists the fields an MSR writes, from bits 16-19
+func psrFields(opcode uint32) string {
+	fields := ""
+	for i, name := range []string{"c", "x", "s", "f"} {
+		if opcode&(1<<(16+i)) != 0 {
+			fields += name
+		}
+	}
+	return fields
+}
+
+func armDataProcessing(opcode, addr uint32, cond string) string {
+	op := (opcode >> 21) & 0xF
+	mnemonic := dataProcessingOps[op]
+	rd, rn := opcode>>12&0xF, opcode>>16&0xF
+	operand := armOperand2(opcode)
+	switch {
+	case op == 0xD || op == 0xF:
+		return fmt.Sprintf("%s%s%s %s, %s", mnemonic, cond, sFlag(opcode), reg(rd), operand)
+	case op >= 0x8 && op <= 0xB:
+		// Comparisons always set the flags
+		return fmt.Sprintf("%s%s %s, %s", mnemonic, cond, reg(rn), operand)
+	}
+	text := fmt.Sprintf("%s%s%s %s, %s, %s", mnemonic, cond, sFlag(opcode), reg(rd), reg(rn), operand)
+	// Resolve PC-relative address calculations, the ADR pseudo instruction
+	if rn == 15 && opcode&(1<<25) != 0 && (op == 0x2 || op == 0x4) {
+		value := bits.RotateLeft32(opcode&0xFF, -int(opcode>>8&0xF)*2)
+		target := addr + 8 + value
+		if op == 0x2 {
+			target = addr + 8 - value
+		}
+		text += " ; " + address(target)
+	}
+	return text
+}
+
+// armOperand2 formats the shifter operand of data processing and MSR
+func armOperand2(opcode uint32) string {
+	if opcode&(1<<25) != 0 {
+		return imm(bits.RotateLeft32(opcode&0xFF, -int(opcode>>8&0xF)*2))
+	}
+	return shiftedRegister(opcode)
+}
+
+// shiftedRegister formats Rm with the shift in bits 4-11
+func shiftedRegister(opcode uint32) string {
+	rm := reg(opcode)
+	shiftType := opcode >> 5 & 3
+	if opcode&(1<<4) != 0 {
+		return fmt.Sprintf("%s, %s %s", rm, shiftNames[shiftType], reg(opcode>>8))
+	}
+	amount := opcode >> 7 & 0x1F
+	switch {
+	case amount == 0 && shiftType == 0:
+		return rm
+	case amount == 0 && shiftType == 3:
+		return rm + ", rrx"
+	case amount == 0:
+		amount = 32
+	}
+	return fmt.Sprintf("%s, %s #%d", rm, shiftNames[shiftType], amount)
+}
+
+func armSingleTransfer(opcode, addr uint32, cond string) string {
+	mnemonic := "str"
+	if opcode&(1<<20) != 0 {
+		mnemonic = "ldr"
+	}
+	mnemonic += cond
+	if opcode&(1<<22) != 0 {
+		mnemonic += "b"
+	}
+	pre := opcode&(1<<24) != 0
+	up := opcode&(1<<23) != 0
+	writeBack := opcode&(1<<21) != 0
+	if !pre && writeBack {
+		mnemonic += "t"
+	}
+	rd, rn := opcode>>12&0xF, opcode>>16&0xF
+
+	var offset string
+	if opcode&(1<<25) == 0 {
+		value := opcode & 0xFFF
+		if value != 0 || !pre {
+			offset = signedImm(value, up)
+		}
+		if rn == 15 && pre {
+			target := addr + 8 + value
+			if !up {
+				target = addr + 8 - value
+			}
+			return fmt.Sprintf("%s %s, [pc, %s] ; %s", mnemonic, reg(rd), signedImm(value, up), address(target))
+		}
+	} else {
+		offset = shiftedRegister(opcode)
+		if !up {
+			offset = "-" + offset
+		}
+	}
+	return fmt.Sprintf("%s %s, %s", mnemonic, reg(rd), addressingMode(reg(rn), offset, pre, writeBack))
+}
+
+func armHalfwordTransfer(opcode, addr uint32, cond string) string {
+	load := opcode&(1<<20) != 0
+	mnemonic := "str"
+	if load {
+		mnemonic = "ldr"
+	}
+	mnemonic += cond + [4]string{"", "h", "sb", "sh"}[opcode>>5&3]
+	pre := opcode&(1<<24) != 0
+	up := opcode&(1<<23) != 0
+	writeBack := opcode&(1<<21) != 0
+	rd, rn := opcode>>12&0xF, opcode>>16&0xF
+
+	var offset string
+	if opcode&(1<<22) != 0 {
+		value := opcode>>4&0xF0 | opcode&0xF
+		if value != 0 || !pre {
+			offset = signedImm(value, up)
+		}
+		if rn == 15 && pre {
+			target := addr + 8 + value
+			if !up {
+				target = addr + 8 - value
+			}
+			return fmt.Sprintf("%s %s, [pc, %s] ; %s", mnemonic, reg(rd), signedImm(value, up), address(target))
+		}
+	} else {
+		offset = reg(opcode)
+		if !up {
+			offset = "-" + offset
+		}
+	}
+	return fmt.Sprintf("%s %s, %s", mnemonic, reg(rd), addressingMode(reg(rn), offset, pre, writeBack))
+}
+
+// addressingMode formats [rn, offset]{!} or [rn], offset
+func addressingMode(rn, offset string, pre, writeBack bool) string {
+	switch {
+	case offset == "":
+		return "[" + rn + "]"
+	case !pre:
+		return fmt.Sprintf("[%s], %s", rn, offset)
+	case writeBack:
+		return fmt.Sprintf("[%s, %s]!", rn, offset)
+	}
+	return fmt.Sprintf("[%s, %s]", rn, offset)
+}
+
+func armBlockTransfer(opcode uint32, cond string) string {
+	load := opcode&(1<<20) != 0
+	writeBack := opcode&(1<<21) != 0
+	userBank := ""
+	if opcode&(1<<22) != 0 {
+		userBank = "^"
+	}
+	rn := opcode >> 16 & 0xF
+	mode := [4]string{"da", "ia", "db", "ib"}[opcode>>23&3]
+	list := registerList(opcode & 0xFFFF)
+
+	// The stack operations GCC emits read better as push and pop
+	if rn == 13 && writeBack && userBank == "" {
+		if load && mode == "ia" {
+			return fmt.Sprintf("pop%s %s", cond, list)
+		}
+		if !load && mode == "db" {
+			return fmt.Sprintf("push%s %s", cond, list)
+		}
+	}
+	mnemonic := "stm"
+	if load {
+		mnemonic = "ldm"
+	}
+	bang := ""
+	if writeBack {
+		bang = "!"
+	}
+	return fmt.Sprintf("%s%s%s %s%s, %s%s", mnemonic, cond, mode, reg(rn), bang, list, userBank)
+}
+
+func armCoprocessorTransfer(opcode uint32, cond string) string {
+	mnemonic := "stc"
+	if opcode&(1<<20) != 0 {
+		mnemonic = "ldc"
+	}
+	if opcode&(1<<22) != 0 {
+		mnemonic += "l"
+	}
+	offset := ""
+	if value := (opcode & 0xFF) << 2; value != 0 || opcode&(1<<24) == 0 {
+		offset = signedImm(value, opcode&(1<<23) != 0)
+	}
+	return fmt.Sprintf("%s%s p%d, c%d, %s", mnemonic, cond, opcode>>8&0xF, opcode>>12&0xF,
+		addressingMode(reg(opcode>>16), offset, opcode&(1<<24) != 0, opcode&(1<<21) != 0))
+}
+
+func armCoprocessorOp(opcode uint32, cond string) string {
+	cp, crn, crm, op2 := opcode>>8&0xF, opcode>>16&0xF, opcode&0xF, opcode>>5&7
+	if opcode&(1<<4) == 0 {
+		return fmt.Sprintf("cdp%s p%d, %d, c%d, c%d, c%d, %d", cond, cp, opcode>>20&0xF, opcode>>12&0xF, crn, crm, op2)
+	}
+	mnemonic := "mcr"
+	if opcode&(1<<20) != 0 {
+		mnemonic = "mrc"
+	}
+	return fmt.Sprintf("%s%s p%d, %d, %s, c%d, c%d, %d", mnemonic, cond, cp, opcode>>21&7, reg(opcode>>12), crn, crm, op2)
+}
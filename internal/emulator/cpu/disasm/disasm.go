@@ -0,0 +1,112 @@
+// Package disasm disassembles ARMv4T ARM and THUMB code into pre-UAL
+// mnemonics, the syntax devkitARM's objdump prints
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Memory is where Disassemble reads code from. memory.MMIO implements it.
+type Memory interface {
+	Read16(addr uint32) (uint16, error)
+	Read32(addr uint32) (uint32, error)
+}
+
+//nolint:golint,gochecknoglobals
+var conditions = [16]string{"eq", "ne", "cs", "cc", "mi", "pl", "vs", "vc", "hi", "ls", "ge", "lt", "gt", "le", "", "nv"}
+
+//nolint:golint,gochecknoglobals
+var registers = [16]string{"r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7", "r8", "r9", "r10", "r11", "r12", "sp", "lr", "pc"}
+
+// Disassemble disassembles the instruction at addr and returns its size,
+// which is 4 for a THUMB BL pair
+func Disassemble(mem Memory, addr uint32, thumb bool) (string, uint32, error) {
+	if !thumb {
+		opcode, err := mem.Read32(addr)
+		if err != nil {
+			return "", 0, err
+		}
+		return ARM(opcode, addr), 4, nil
+	}
+	opcode, err := mem.Read16(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	if isBLPrefix(opcode) {
+		if next, err := mem.Read16(addr + 2); err == nil && isBLSuffix(next) {
+			return ThumbBL(opcode, next, addr), 4, nil
+		}
+	}
+	return Thumb(opcode, addr), 2, nil
+}
+
+// Opcode formats the raw instruction of the given size at addr in hex, as
+// two halfwords for a THUMB BL pair
+func Opcode(mem Memory, addr uint32, size uint32, thumb bool) string {
+	if !thumb {
+		word, _ := mem.Read32(addr)
+		return fmt.Sprintf("%08x", word)
+	}
+	first, _ := mem.Read16(addr)
+	if size == 4 {
+		second, _ := mem.Read16(addr + 2)
+		return fmt.Sprintf("%04x %04x", first, second)
+	}
+	return fmt.Sprintf("%04x", first)
+}
+
+func reg(r uint32) string {
+	return registers[r&0xF]
+}
+
+// registerList formats a register bitmask, collapsing runs into ranges
+func registerList(mask uint32) string {
+	var parts []string
+	for r := uint32(0); r < 16; r++ {
+		if mask&(1<<r) == 0 {
+			continue
+		}
+		end := r
+		for end+1 < 16 && mask&(1<<(end+1)) != 0 {
+			end++
+		}
+		switch {
+		case end == r:
+			parts = append(parts, reg(r))
+		case end == r+1:
+			parts = append(parts, reg(r), reg(end))
+		default:
+			parts = append(parts, reg(r)+"-"+reg(end))
+		}
+		r = end
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func imm(value uint32) string {
+	if value < 10 {
+		return fmt.Sprintf("#%d", value)
+	}
+	return fmt.Sprintf("#0x%x", value)
+}
+
+func signedImm(value uint32, up bool) string {
+	if up {
+		return imm(value)
+	}
+	if value < 10 {
+		return fmt.Sprintf("#-%d", value)
+	}
+	return fmt.Sprintf("#-0x%x", value)
+}
+
+func address(addr uint32) string {
+	return fmt.Sprintf("0x%08x", addr)
+}
+
+// signExtend sign extends the low bits of value
+func signExtend(value uint32, bits uint) int32 {
+	shift := 32 - bits
+	return int32(value<<shift) >> shift
+}
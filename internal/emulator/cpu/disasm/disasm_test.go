@@ -0,0 +1,227 @@
+package disasm_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/disasm"
+)
+
+func TestARM(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		opcode uint32
+		addr   uint32
+		want   string
+	}{
+		// Condition codes
+		{0xE0800001, 0x08000000, "add r0, r0, r1"},
+		{0x10800001, 0x08000000, "addne r0, r0, r1"},
+		{0xC0900001, 0x08000000, "addgts r0, r0, r1"},
+		{0x0A000000, 0x08000000, "beq 0x08000008"},
+		{0x1AFFFFFC, 0x08000010, "bne 0x08000008"},
+		{0x312FFF11, 0x08000000, "bxcc r1"},
+		{0xF0800001, 0x08000000, "addnv r0, r0, r1"},
+		{0xEB000000, 0x08000000, "bl 0x08000008"},
+		{0xEF000006, 0x08000000, "swi #6"},
+		{0xE12FFF1E, 0x08000000, "bx lr"},
+
+		// Shifter operands
+		{0xE1A00101, 0x08000000, "mov r0, r1, lsl #2"},
+		{0xE1A00021, 0x08000000, "mov r0, r1, lsr #32"},
+		{0xE1A002C1, 0x08000000, "mov r0, r1, asr #5"},
+		{0xE1A00061, 0x08000000, "mov r0, r1, rrx"},
+		{0xE1A00231, 0x08000000, "mov r0, r1, lsr r2"},
+		{0xE3A004FF, 0x08000000, "mov r0, #0xff000000"},
+		{0xE3500000, 0x08000000, "cmp r0, #0"},
+		{0xE1F00001, 0x08000000, "mvns r0, r1"},
+
+		// PC-relative addresses and literals
+		{0xE28F0010, 0x08000000, "add r0, pc, #0x10 ; 0x08000018"},
+		{0xE24F0004, 0x08000000, "sub r0, pc, #4 ; 0x08000004"},
+		{0xE59F0010, 0x08000000, "ldr r0, [pc, #0x10] ; 0x08000018"},
+		{0xE51F0004, 0x08000000, "ldr r0, [pc, #-4] ; 0x08000004"},
+		{0xE1DF00B4, 0x08000000, "ldrh r0, [pc, #4] ; 0x0800000c"},
+
+		// Addressing modes
+		{0xE5910004, 0x08000000, "ldr r0, [r1, #4]"},
+		{0xE4910004, 0x08000000, "ldr r0, [r1], #4"},
+		{0xE5B10004, 0x08000000, "ldr r0, [r1, #4]!"},
+		{0xE4B10004, 0x08000000, "ldrt r0, [r1], #4"},
+		{0xE7910102, 0x08000000, "ldr r0, [r1, r2, lsl #2]"},
+		{0xE7110002, 0x08000000, "ldr r0, [r1, -r2]"},
+		{0xE5C10000, 0x08000000, "strb r0, [r1]"},
+		{0xE1D100F2, 0x08000000, "ldrsh r0, [r1, #2]"},
+		{0xE19100D2, 0x08000000, "ldrsb r0, [r1, r2]"},
+		{0xE05100BA, 0x08000000, "ldrh r0, [r1], #-0xa"},
+
+		// Register lists
+		{0xE92D4010, 0x08000000, "push {r4, lr}"},
+		{0xE8BD8010, 0x08000000, "pop {r4, pc}"},
+		{0xE8900007, 0x08000000, "ldmia r0, {r0-r2}"},
+		{0xE9A1000C, 0x08000000, "stmib r1!, {r2, r3}"},
+		{0xE8000003, 0x08000000, "stmda r0, {r0, r1}"},
+		{0xE8D08000, 0x08000000, "ldmia r0, {pc}^"},
+		{0xE92D5FF3, 0x08000000, "push {r0, r1, r4-r12, lr}"},
+
+		// Multiplies and PSR transfers
+		{0xE0000291, 0x08000000, "mul r0, r1, r2"},
+		{0xE0203291, 0x08000000, "mla r0, r1, r2, r3"},
+		{0xE0C10392, 0x08000000, "smull r0, r1, r2, r3"},
+		{0xE0B10392, 0x08000000, "umlals r0, r1, r2, r3"},
+		{0xE10F0000, 0x08000000, "mrs r0, cpsr"},
+		{0xE14F0000, 0x08000000, "mrs r0, spsr"},
+		{0xE129F000, 0x08000000, "msr cpsr_cf, r0"},
+		{0xE328F60F, 0x08000000, "msr cpsr_f, #0xf00000"},
+
+		// Swaps and the coprocessor forms the GBA has no coprocessor for
+		{0xE1010092, 0x08000000, "swp r0, r2, [r1]"},
+		{0xE1410092, 0x08000000, "swpb r0, r2, [r1]"},
+		{0xEE010F10, 0x08000000, "mcr p15, 0, r0, c1, c0, 0"},
+		{0xEE110F10, 0x08000000, "mrc p15, 0, r0, c1, c0, 0"},
+		{0xEE123045, 0x08000000, "cdp p0, 1, c3, c2, c5, 2"},
+		{0xED910104, 0x08000000, "ldc p1, c0, [r1, #0x10]"},
+		{0xEDC10101, 0x08000000, "stcl p1, c0, [r1, #4]"},
+		{0xE7F000F0, 0x08000000, "undefined"},
+	}
+	for _, tt := range tests {
+		if got := disasm.ARM(tt.opcode, tt.addr); got != tt.want {
+			t.Errorf("0x%08X: got %q, expected %q", tt.opcode, got, tt.want)
+		}
+	}
+}
+
+func TestThumb(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		opcode uint16
+		addr   uint32
+		want   string
+	}{
+		{0x0088, 0x08000000, "lsl r0, r1, #2"},
+		{0x0808, 0x08000000, "lsr r0, r1, #32"},
+		{0x1888, 0x08000000, "add r0, r1, r2"},
+		{0x1E48, 0x08000000, "sub r0, r1, #1"},
+		{0x2064, 0x08000000, "mov r0, #0x64"},
+		{0x3B01, 0x08000000, "sub r3, #1"},
+		{0x4348, 0x08000000, "mul r0, r1"},
+		{0x4770, 0x08000000, "bx lr"},
+		{0x46C0, 0x08000000, "nop"},
+		{0x4485, 0x08000000, "add sp, r0"},
+		{0x45D9, 0x08000000, "cmp r9, r11"},
+
+		// PC-relative, from a word aligned PC
+		{0x4801, 0x08000002, "ldr r0, [pc, #4] ; 0x08000008"},
+		{0xA002, 0x08000002, "add r0, pc, #8 ; 0x0800000c"},
+		{0xA802, 0x08000000, "add r0, sp, #8"},
+
+		// Addressing modes
+		{0x5088, 0x08000000, "str r0, [r1, r2]"},
+		{0x5C88, 0x08000000, "ldrb r0, [r1, r2]"},
+		{0x5E88, 0x08000000, "ldrsh r0, [r1, r2]"},
+		{0x6848, 0x08000000, "ldr r0, [r1, #4]"},
+		{0x7008, 0x08000000, "strb r0, [r1]"},
+		{0x8848, 0x08000000, "ldrh r0, [r1, #2]"},
+		{0x9801, 0x08000000, "ldr r0, [sp, #4]"},
+		{0xB082, 0x08000000, "sub sp, #8"},
+
+		// Register lists
+		{0xB510, 0x08000000, "push {r4, lr}"},
+		{0xBD10, 0x08000000, "pop {r4, pc}"},
+		{0xC807, 0x08000000, "ldmia r0!, {r0-r2}"},
+		{0xC106, 0x08000000, "stmia r1!, {r1, r2}"},
+
+		// Branches and condition codes
+		{0xD0FE, 0x08000000, "beq 0x08000000"},
+		{0xDC01, 0x08000000, "bgt 0x08000006"},
+		{0xDEFF, 0x08000000, "undefined"},
+		{0xDF06, 0x08000000, "swi #6"},
+		{0xE7FE, 0x08000000, "b 0x08000000"},
+		{0xF000, 0x08000000, "bl.prefix #0"},
+		{0xF802, 0x08000000, "bl.suffix #2"},
+	}
+	for _, tt := range tests {
+		if got := disasm.Thumb(tt.opcode, tt.addr); got != tt.want {
+			t.Errorf("0x%04X: got %q, expected %q", tt.opcode, got, tt.want)
+		}
+	}
+}
+
+// memory holds code from 0x08000000
+type memory []byte
+
+func (m memory) offset(addr uint32, size uint32) (uint32, error) {
+	offset := addr - 0x08000000
+	if offset+size > uint32(len(m)) {
+		return 0, errors.New("out of bounds")
+	}
+	return offset, nil
+}
+
+func (m memory) Read16(addr uint32) (uint16, error) {
+	offset, err := m.offset(addr, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(m[offset:]), nil
+}
+
+func (m memory) Read32(addr uint32) (uint32, error) {
+	offset, err := m.offset(addr, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(m[offset:]), nil
+}
+
+func thumbCode(halfwords ...uint16) memory {
+	m := make(memory, 0, len(halfwords)*2)
+	for _, h := range halfwords {
+		m = binary.LittleEndian.AppendUint16(m, h)
+	}
+	return m
+}
+
+func TestDisassemble(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		mem      memory
+		addr     uint32
+		thumb    bool
+		want     string
+		size     uint32
+		opcode   string
+		readFail bool
+	}{
+		{name: "arm", mem: memory{0x1E, 0xFF, 0x2F, 0xE1}, want: "bx lr", size: 4, opcode: "e12fff1e"},
+		{name: "bl pair", mem: thumbCode(0xF000, 0xF802), thumb: true, want: "bl 0x08000008", size: 4, opcode: "f000 f802"},
+		{name: "bl pair backwards", mem: thumbCode(0xF7FF, 0xFFFE), thumb: true, want: "bl 0x08000000", size: 4, opcode: "f7ff fffe"},
+		{name: "bl pair far", mem: thumbCode(0xF400, 0xF800), thumb: true, want: "bl 0x07c00004", size: 4, opcode: "f400 f800"},
+		{name: "bl prefix without suffix", mem: thumbCode(0xF000, 0x4770), thumb: true, want: "bl.prefix #0", size: 2, opcode: "f000"},
+		{name: "bl prefix at the end", mem: thumbCode(0xF000), thumb: true, want: "bl.prefix #0", size: 2, opcode: "f000"},
+		{name: "suffix first", mem: thumbCode(0xF802, 0xF000), thumb: true, want: "bl.suffix #2", size: 2, opcode: "f802"},
+		{name: "unreadable", mem: thumbCode(), thumb: true, readFail: true},
+	}
+	for _, tt := range tests {
+		addr := uint32(0x08000000)
+		text, size, err := disasm.Disassemble(tt.mem, addr, tt.thumb)
+		if tt.readFail {
+			if err == nil {
+				t.Errorf("%s: expected a read error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if text != tt.want || size != tt.size {
+			t.Errorf("%s: got %q of %d bytes, expected %q of %d", tt.name, text, size, tt.want, tt.size)
+		}
+		if opcode := disasm.Opcode(tt.mem, addr, size, tt.thumb); opcode != tt.opcode {
+			t.Errorf("%s: got opcode %q, expected %q", tt.name, opcode, tt.opcode)
+		}
+	}
+}
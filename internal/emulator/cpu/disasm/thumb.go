@@ -0,0 +1,156 @@
+package disasm
+
+import (
+	"fmt"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/thumb"
+)
+
+//nolint:golint,gochecknoglobals
+var thumbALUOps = [16]string{"and", "eor", "lsl", "lsr", "asr", "adc", "sbc", "ror", "tst", "neg", "cmp", "cmn", "orr", "mul", "bic", "mvn"}
+
+// BL is split into two halfwords, bit 11 tells them apart
+const blSuffix = 1 << 11
+
+func isBLPrefix(opcode uint16) bool {
+	return opcode&thumb.LongBranchWithLinkMask == thumb.LongBranchWithLinkFormat && opcode&blSuffix == 0
+}
+
+func isBLSuffix(opcode uint16) bool {
+	return opcode&thumb.LongBranchWithLinkMask == thumb.LongBranchWithLinkFormat && opcode&blSuffix != 0
+}
+
+// ThumbBL disassembles a BL pair, whose halfwords only have a target together
+func ThumbBL(prefix, suffix uint16, addr uint32) string {
+	offset := signExtend(uint32(prefix)&0x7FF, 11)<<12 | int32(suffix&0x7FF)<<1
+	return "bl " + address(addr+4+uint32(offset))
+}
+
+// Thumb disassembles a THUMB instruction at addr. The checks run in the
+// same order as thumb.DecodeInstruction, since the formats overlap.
+//
+//nolint:golint,gocyclo
+func Thumb(opcode uint16, addr uint32) string {
+	op := uint32(opcode)
+	rd, rs, rn := reg(op&7), reg(op>>3&7), reg(op>>6&7)
+	switch {
+	case opcode&thumb.SoftwareInterruptMask == thumb.SoftwareInterruptFormat:
+		return "swi " + imm(op&0xFF)
+	case opcode&thumb.UnconditionalBranchMask == thumb.UnconditionalBranchFormat:
+		return "b " + address(addr+4+uint32(signExtend(op&0x7FF, 11)<<1))
+	case opcode&thumb.ConditionalBranchMask == thumb.ConditionalBranchFormat:
+		cond := op >> 8 & 0xF
+		if cond == 0xE {
+			return "undefined"
+		}
+		return fmt.Sprintf("b%s %s", conditions[cond], address(addr+4+uint32(signExtend(op&0xFF, 8)<<1)))
+	case opcode&thumb.MultipleLoadStoreMask == thumb.MultipleLoadStoreFormat:
+		mnemonic := "stmia"
+		if op&(1<<11) != 0 {
+			mnemonic = "ldmia"
+		}
+		return fmt.Sprintf("%s %s!, %s", mnemonic, reg(op>>8&7), registerList(op&0xFF))
+	case opcode&thumb.LongBranchWithLinkMask == thumb.LongBranchWithLinkFormat:
+		// A lone half of a BL pair
+		if op&blSuffix == 0 {
+			return fmt.Sprintf("bl.prefix %s", imm(op&0x7FF))
+		}
+		return fmt.Sprintf("bl.suffix %s", imm(op&0x7FF))
+	case opcode&thumb.AddOffsetToStackPointerMask == thumb.AddOffsetToStackPointerFormat:
+		if op&(1<<7) != 0 {
+			return "sub sp, " + imm((op&0x7F)<<2)
+		}
+		return "add sp, " + imm((op&0x7F)<<2)
+	case opcode&thumb.PushPopRegistersMask == thumb.PushPopRegistersFormat:
+		list := op & 0xFF
+		if op&(1<<8) != 0 {
+			if op&(1<<11) != 0 {
+				list |= 1 << 15
+			} else {
+				list |= 1 << 14
+			}
+		}
+		if op&(1<<11) != 0 {
+			return "pop " + registerList(list)
+		}
+		return "push " + registerList(list)
+	case opcode&thumb.LoadStoreHalfwordMask == thumb.LoadStoreHalfwordFormat:
+		return fmt.Sprintf("%sh %s, %s", loadStore(op), rd, immediateAddress(rs, (op>>6&0x1F)<<1))
+	case opcode&thumb.SPRelativeLoadStoreMask == thumb.SPRelativeLoadStoreFormat:
+		return fmt.Sprintf("%s %s, %s", loadStore(op), reg(op>>8&7), immediateAddress("sp", (op&0xFF)<<2))
+	case opcode&thumb.LoadAddressMask == thumb.LoadAddressFormat:
+		value := (op & 0xFF) << 2
+		if op&(1<<11) != 0 {
+			return fmt.Sprintf("add %s, sp, %s", reg(op>>8&7), imm(value))
+		}
+		return fmt.Sprintf("add %s, pc, %s ; %s", reg(op>>8&7), imm(value), address((addr+4)&^3+value))
+	case opcode&thumb.LoadStoreWithImmediateOffsetMask == thumb.LoadStoreWithImmediateOffsetFormat:
+		if op&(1<<12) != 0 {
+			return fmt.Sprintf("%sb %s, %s", loadStore(op), rd, immediateAddress(rs, op>>6&0x1F))
+		}
+		return fmt.Sprintf("%s %s, %s", loadStore(op), rd, immediateAddress(rs, (op>>6&0x1F)<<2))
+	case opcode&thumb.LoadStoreWithRegisterOffsetMask == thumb.LoadStoreWithRegisterOffsetFormat:
+		b := ""
+		if op&(1<<10) != 0 {
+			b = "b"
+		}
+		return fmt.Sprintf("%s%s %s, [%s, %s]", loadStore(op), b, rd, rs, rn)
+	case opcode&thumb.LoadStoreSignExtendedByteHalfwordMask == thumb.LoadStoreSignExtendedByteHalfwordFormat:
+		mnemonic := [4]string{"strh", "ldrsb", "ldrh", "ldrsh"}[op>>10&3]
+		return fmt.Sprintf("%s %s, [%s, %s]", mnemonic, rd, rs, rn)
+	case opcode&thumb.PCRelativeLoadMask == thumb.PCRelativeLoadFormat:
+		value := (op & 0xFF) << 2
+		return fmt.Sprintf("ldr %s, [pc, %s] ; %s", reg(op>>8&7), imm(value), address((addr+4)&^3+value))
+	case opcode&thumb.HiRegisterOperationsOrBranchExchangeMask == thumb.HiRegisterOperationsOrBranchExchangeFormat:
+		hd := reg(op&7 | op>>4&8)
+		hs := reg(op >> 3 & 0xF)
+		switch op >> 8 & 3 {
+		case 0:
+			return fmt.Sprintf("add %s, %s", hd, hs)
+		case 1:
+			return fmt.Sprintf("cmp %s, %s", hd, hs)
+		case 2:
+			if hd == "r8" && hs == "r8" {
+				return "nop"
+			}
+			return fmt.Sprintf("mov %s, %s", hd, hs)
+		}
+		return "bx " + hs
+	case opcode&thumb.AluOperationMask == thumb.AluOperationFormat:
+		return fmt.Sprintf("%s %s, %s", thumbALUOps[op>>6&0xF], rd, rs)
+	case opcode&thumb.MoveCompareAddSubtractImmediateMask == thumb.MoveCompareAddSubtractImmediateFormat:
+		mnemonic := [4]string{"mov", "cmp", "add", "sub"}[op>>11&3]
+		return fmt.Sprintf("%s %s, %s", mnemonic, reg(op>>8&7), imm(op&0xFF))
+	case opcode&thumb.AddSubtractMask == thumb.AddSubtractFormat:
+		mnemonic := "add"
+		if op&(1<<9) != 0 {
+			mnemonic = "sub"
+		}
+		if op&(1<<10) != 0 {
+			return fmt.Sprintf("%s %s, %s, %s", mnemonic, rd, rs, imm(op>>6&7))
+		}
+		return fmt.Sprintf("%s %s, %s, %s", mnemonic, rd, rs, rn)
+	case opcode&thumb.MoveShiftedRegisterMask == thumb.MoveShiftedRegisterFormat:
+		shift := op >> 11 & 3
+		amount := op >> 6 & 0x1F
+		if amount == 0 && shift != 0 {
+			amount = 32
+		}
+		return fmt.Sprintf("%s %s, %s, #%d", shiftNames[shift], rd, rs, amount)
+	}
+	return "undefined"
+}
+
+func loadStore(op uint32) string {
+	if op&(1<<11) != 0 {
+		return "ldr"
+	}
+	return "str"
+}
+
+func immediateAddress(base string, offset uint32) string {
+	if offset == 0 {
+		return "[" + base + "]"
+	}
+	return fmt.Sprintf("[%s, %s]", base, imm(offset))
+}
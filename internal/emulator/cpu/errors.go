@@ -0,0 +1,29 @@
+package cpu
+
+import "fmt"
+
+// ErrUnknownInstruction is returned when the decoder does not recognize the
+// instruction at PC. Mode is "ARM" or "THUMB".
+type ErrUnknownInstruction struct {
+	PC     uint32
+	Opcode uint32
+	Mode   string
+}
+
+func (e *ErrUnknownInstruction) Error() string {
+	if e.Mode == "THUMB" {
+		return fmt.Sprintf("unknown THUMB instruction 0x%04X at 0x%08X", e.Opcode, e.PC)
+	}
+	return fmt.Sprintf("unknown %s instruction 0x%08X at 0x%08X", e.Mode, e.Opcode, e.PC)
+}
+
+// ErrLockstep is returned in lockstep mode when the block cache and the
+// plain interpreter disagree after the instruction at PC
+type ErrLockstep struct {
+	PC     uint32
+	Detail string
+}
+
+func (e *ErrLockstep) Error() string {
+	return fmt.Sprintf("block cache diverged from the interpreter at 0x%08X: %s", e.PC, e.Detail)
+}
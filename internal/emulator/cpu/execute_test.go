@@ -0,0 +1,83 @@
+package cpu_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/arm"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/thumb"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// bases are where the registers point, with accesses that straddle the
+// end of a region
+//
+//nolint:golint,gochecknoglobals
+var bases = []uint32{0x02000000, 0x0203FFFD, 0x04000408}
+
+// execute runs one instruction on c with the registers pointing at base.
+// Whether the instruction fails doesn't matter, only what it panicked with.
+func execute(t *testing.T, c *cpu.ARM7TDMI, thumbMode bool, base uint32, run func() error) (panicked any) {
+	t.Helper()
+	cpsr := uint32(0x1F)
+	if thumbMode {
+		cpsr |= 1 << 5
+	}
+	// The instruction before may have branched anywhere
+	if err := c.WriteDebugRegister(cpu.PC_REG, 0x08000000); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteDebugRegister(cpu.CPSR_REG, cpsr); err != nil {
+		t.Fatal(err)
+	}
+	for reg := uint8(0); reg < 15; reg++ {
+		if err := c.WriteDebugRegister(reg, base+uint32(reg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		panicked = recover()
+	}()
+	_ = run()
+	return nil
+}
+
+// TestNoPanics runs every THUMB instruction and a sample of ARM ones
+// covering every decoding, none of which may panic whatever it does
+func TestNoPanics(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, nil, nil)
+
+	for i := 0; i < 0x10000; i++ {
+		opcode := uint16(i)
+		handler := thumb.Lookup(opcode)
+		if handler == nil {
+			continue
+		}
+		panicked := execute(t, c, true, bases[i%len(bases)], func() error {
+			_, _, err := handler(c, opcode)
+			return err
+		})
+		if panicked != nil {
+			t.Fatalf("THUMB 0x%04X panicked: %v", opcode, panicked)
+		}
+	}
+
+	// ARM decodes on bits 27-20 and 7-4, the rest is random
+	rng := rand.New(rand.NewSource(1)) //nolint:gosec
+	for i := 0; i < 0x1000*8; i++ {
+		opcode := 0xE0000000 | uint32(i&0xFF0)<<16 | uint32(i&0xF)<<4 | rng.Uint32()&0x000FFF0F
+		handler := arm.Lookup(opcode)
+		if handler == nil {
+			continue
+		}
+		panicked := execute(t, c, false, bases[i%len(bases)], func() error {
+			_, _, err := handler(c, opcode)
+			return err
+		})
+		if panicked != nil {
+			t.Fatalf("ARM 0x%08X panicked: %v", opcode, panicked)
+		}
+	}
+}
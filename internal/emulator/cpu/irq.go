@@ -0,0 +1,37 @@
+package cpu
+
+import "github.com/USA-RedDragon/go-gba/internal/logging"
+
+// irqVector is where IRQs jump to in the BIOS
+const irqVector = 0x00000018
+
+// irqHandler stands in for the IRQ handler of the BIOS when there is none.
+// Like the real one, it saves the registers the ABI lets the user handler
+// clobber and calls the handler at 0x03007FFC.
+//
+//nolint:golint,gochecknoglobals
+var irqHandler = []byte{
+	0x0F, 0x50, 0x2D, 0xE9, // stmfd sp!, {r0-r3, r12, lr}
+	0x01, 0x03, 0xA0, 0xE3, // mov r0, #0x04000000
+	0x00, 0xE0, 0x8F, 0xE2, // add lr, pc, #0
+	0x04, 0xF0, 0x10, 0xE5, // ldr pc, [r0, #-4]
+	0x0F, 0x50, 0xBD, 0xE8, // ldmfd sp!, {r0-r3, r12, lr}
+	0x04, 0xF0, 0x5E, 0xE2, // subs pc, lr, #4
+}
+
+// enterIRQ takes the IRQ exception before the next instruction executes.
+// The handler returns to it with subs pc, lr, #4.
+func (c *ARM7TDMI) enterIRQ() error {
+	ret := c.NextInstructionAddress() + 4
+	cpsr := c.ReadCPSR()
+	if logging.CPU.Enabled(logging.LevelDebug) {
+		logging.CPU.Debug("IRQ", "pc", logging.Hex(ret-4))
+	}
+
+	// Enter IRQ mode in ARM state with IRQs disabled
+	c.WriteCPSR(cpsr&^0x3F | 1<<7 | uint32(irqMode))
+	c.WriteSPSR(cpsr)
+	c.WriteLR(ret)
+	c.r[PC_REG] = irqVector
+	return c.FlushPipeline()
+}
@@ -0,0 +1,116 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// irqCounter enables the VBlank and VCount IRQs, with the VCount IRQ on
+// line 100, and waits in a loop. Its handler counts VBlanks in r4 and
+// VCount matches in r5.
+//
+//nolint:golint,gochecknoglobals
+var irqCounter = []uint32{
+	0xE3A00301, // mov r0, #0x04000000
+	0xE28F10F4, // add r1, pc, #0xF4
+	0xE5001004, // str r1, [r0, #-4]
+	0xE3A01028, // mov r1, #0x28
+	0xE3811C64, // orr r1, r1, #0x6400
+	0xE1C010B4, // strh r1, [r0, #4]
+	0xE2802C02, // add r2, r0, #0x200
+	0xE3A01005, // mov r1, #5
+	0xE1C210B0, // strh r1, [r2]
+	0xE3A01001, // mov r1, #1
+	0xE5821008, // str r1, [r2, #8]
+	0xEAFFFFFE, // b .
+	0x100 / 4:  0xE3A00301, // handler: mov r0, #0x04000000
+	0xE2802C02, // add r2, r0, #0x200
+	0xE1D210B2, // ldrh r1, [r2, #2]
+	0xE1C210B2, // strh r1, [r2, #2]
+	0xE3110001, // tst r1, #1
+	0x12844001, // addne r4, r4, #1
+	0xE3110004, // tst r1, #4
+	0x12855001, // addne r5, r5, #1
+	0xE12FFF1E, // bx lr
+}
+
+func TestFrameTiming(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, []uint32{0xEAFFFFFE}, nil)
+	mmio := c.GetMMIO()
+
+	steps := 0
+	check := func(until, vcount int, dispstat uint16) {
+		t.Helper()
+		for ; steps < until; steps++ {
+			if err := c.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		gotVCount, _ := mmio.Read16(0x04000006)
+		gotDispstat, _ := mmio.Read16(0x04000004)
+		if int(gotVCount) != vcount || gotDispstat != dispstat {
+			t.Errorf("after %d cycles VCOUNT is %d and DISPSTAT 0x%04X, expected %d and 0x%04X", steps, gotVCount, gotDispstat, vcount, dispstat)
+		}
+	}
+	check(959, 0, 0x0004)
+	check(960, 0, 0x0006)
+	check(1231, 0, 0x0006)
+	check(1232, 1, 0x0000)
+	check(1232*160, 160, 0x0001)
+	check(1232*160+960, 160, 0x0003)
+	check(1232*227, 227, 0x0000)
+	check(1232*227+960, 227, 0x0002)
+	if frames := c.PPU.Frames(); frames != 0 {
+		t.Errorf("%d frames after %d cycles, expected 0", frames, steps)
+	}
+	check(1232*228, 0, 0x0004)
+	if frames := c.PPU.Frames(); frames != 1 {
+		t.Errorf("%d frames after %d cycles, expected 1", frames, steps)
+	}
+}
+
+func TestDispstatFlagsAreReadOnly(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, []uint32{0xEAFFFFFE}, nil)
+	mmio := c.GetMMIO()
+	if err := mmio.Write16(0x04000004, 0xFFFB); err != nil {
+		t.Fatal(err)
+	}
+	if err := mmio.Write16(0x04000006, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	// The VCount flag stays set and the VBlank and HBlank flags clear
+	if dispstat, _ := mmio.Read16(0x04000004); dispstat != 0xFF3C {
+		t.Errorf("DISPSTAT is 0x%04X, expected 0xFF3C", dispstat)
+	}
+	if vcount, _ := mmio.Read16(0x04000006); vcount != 0 {
+		t.Errorf("VCOUNT is 0x%04X, expected 0", vcount)
+	}
+}
+
+func TestIRQ(t *testing.T) {
+	t.Parallel()
+	c := newLockstepCPU(t, irqCounter)
+	for c.PPU.Frames() < 2 {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if vblanks := c.ReadDebugRegister(4); vblanks != 2 {
+		t.Errorf("%d VBlank IRQs, expected 2", vblanks)
+	}
+	if matches := c.ReadDebugRegister(5); matches != 2 {
+		t.Errorf("%d VCount IRQs, expected 2", matches)
+	}
+	if flags, _ := c.GetMMIO().Read16(0x04000202); flags != 0 {
+		t.Errorf("IF is 0x%04X, expected the handler to acknowledge everything", flags)
+	}
+	if pc := c.NextInstructionAddress(); pc != 0x0800002C {
+		t.Errorf("PC is 0x%08X, expected the wait loop at 0x0800002C", pc)
+	}
+	if cpsr := c.ReadCPSR(); cpsr&0xBF != 0x1F {
+		t.Errorf("CPSR is 0x%08X, expected system mode with IRQs enabled", cpsr)
+	}
+}
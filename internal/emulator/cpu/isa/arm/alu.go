@@ -1,9 +1,8 @@
 package arm
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 func ALUOp2(inst uint32, cpu interfaces.CPU) uint32 {
@@ -24,19 +23,17 @@ func ALUOp2(inst uint32, cpu interfaces.CPU) uint32 {
 		carryMut := inst&(1<<20)>>20 == 1
 		switch shiftType := (inst >> 5) & 0b11; shiftType {
 		case 0: // LSL
-			fmt.Println("ALUOp2 LSL")
 			return LSL(cpu.ReadRegister(rm)+salt, is, carryMut, !isRegister, cpu)
 		case 1: // LSR
-			fmt.Println("ALUOp2 LSR")
 			return LSR(cpu.ReadRegister(rm)+salt, is, carryMut, !isRegister, cpu)
 		case 2: // ASR
-			fmt.Println("ALUOp2 ASR")
 			return ASR(cpu.ReadRegister(rm)+salt, is, carryMut, !isRegister, cpu)
 		case 3: // ROR
-			fmt.Println("ALUOp2 ROR")
 			return ROR(cpu.ReadRegister(rm)+salt, is, carryMut, !isRegister, cpu)
 		}
-		fmt.Printf("ALUOp2: Invalid shift type: %d\n", (inst>>5)&0b11)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("ALUOp2: Invalid shift type: %d", (inst>>5)&0b11)
+		}
 		return cpu.ReadRegister(rm) + salt
 	}
 
@@ -44,7 +41,6 @@ func ALUOp2(inst uint32, cpu interfaces.CPU) uint32 {
 	op2 := inst & 0b1111_1111
 	is := ((inst >> 8) & 0b1111) * 2
 	carryMut := inst&(1<<20)>>20 == 1
-	fmt.Println("ALUOp2 immediate")
 	op2 = ROR(op2, is, carryMut, false, cpu)
 	return op2
 }
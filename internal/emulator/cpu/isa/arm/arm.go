@@ -6,6 +6,7 @@ import (
 
 	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 // https://iitd-plos.github.io/col718/ref/arm-instructionset.pdf, Figure 4-1
@@ -50,19 +51,21 @@ const (
 	BranchFormat         = 0b0000_1010_0000_0000_0000_0000_0000_0000
 	BranchWithLinkFormat = 0b0000_1011_0000_0000_0000_0000_0000_0000
 
-	// CoProcessorDataTransferMask   = 0b0000_1110_0000_0000_0000_0000_0000_0000
-	// CoProcessorDataTransferFormat = 0b0000_1100_0000_0000_0000_0000_0000_0000
+	CoProcessorDataTransferMask   = 0b0000_1110_0000_0000_0000_0000_0000_0000
+	CoProcessorDataTransferFormat = 0b0000_1100_0000_0000_0000_0000_0000_0000
 
-	// CoProcessorDataOperationMask   = 0b0000_1111_0000_0000_0000_0000_0001_0000
-	// CoProcessorDataOperationFormat = 0b0000_1110_0000_0000_0000_0000_0000_0000
+	CoProcessorDataOperationMask   = 0b0000_1111_0000_0000_0000_0000_0001_0000
+	CoProcessorDataOperationFormat = 0b0000_1110_0000_0000_0000_0000_0000_0000
 
-	// CoProcessorRegisterTransferMask   = 0b0000_1111_0000_0000_0000_0000_0001_0000
-	// CoProcessorRegisterTransferFormat = 0b0000_1110_0000_0000_0000_0000_0001_0000
+	CoProcessorRegisterTransferMask   = 0b0000_1111_0000_0000_0000_0000_0001_0000
+	CoProcessorRegisterTransferFormat = 0b0000_1110_0000_0000_0000_0000_0001_0000
 
 	SoftwareInterruptMask   = 0b0000_1111_0000_0000_0000_0000_0000_0000
 	SoftwareInterruptFormat = 0b0000_1111_0000_0000_0000_0000_0000_0000
 )
 
+// DecodeInstruction decodes instruction by the masks and formats of the
+// manual. The CPU goes through Lookup, whose table is built from it.
 func DecodeInstruction(instruction uint32) isa.Instruction {
 	// This function will check masks against the instruction to determine which
 	// type of operation it is. Then, the opcode will be used to determine which
@@ -78,6 +81,11 @@ func DecodeInstruction(instruction uint32) isa.Instruction {
 		return BL{instruction}
 	case instruction&SoftwareInterruptMask == SoftwareInterruptFormat:
 		return SWI{instruction}
+	case instruction&CoProcessorDataTransferMask == CoProcessorDataTransferFormat,
+		instruction&CoProcessorDataOperationMask == CoProcessorDataOperationFormat,
+		instruction&CoProcessorRegisterTransferMask == CoProcessorRegisterTransferFormat:
+		// The GBA has no coprocessors to answer CDP, LDC, STC, MCR and MRC
+		return Undefined{instruction}
 	case instruction&UndefinedMask == UndefinedFormat:
 		return matchUndefined(instruction)
 	case instruction&SingleDataTransferMask == SingleDataTransferFormat:
@@ -104,8 +112,7 @@ func DecodeInstruction(instruction uint32) isa.Instruction {
 	case instruction&DataProcessingMask == DataProcessingFormat:
 		return matchDataProcessing(instruction)
 	default:
-		fmt.Println("Unknown instruction")
-		panic(fmt.Sprintf("Unknown instruction: 0x%08X", instruction))
+		return nil
 	}
 }
 
@@ -119,9 +126,8 @@ func matchBlockDataTransfer(instruction uint32) isa.Instruction {
 	return STM{instruction}
 }
 
-func matchUndefined(_ uint32) isa.Instruction {
-	fmt.Println("Undefined")
-	return nil
+func matchUndefined(instruction uint32) isa.Instruction {
+	return Undefined{instruction}
 }
 
 func matchSingleDataTransfer(instruction uint32) isa.Instruction {
@@ -135,13 +141,15 @@ func matchSingleDataTransfer(instruction uint32) isa.Instruction {
 	return STR{instruction}
 }
 
-func matchSingleDataSwap(_ uint32) isa.Instruction {
-	fmt.Println("Single Data Swap")
-	return nil
+func matchSingleDataSwap(instruction uint32) isa.Instruction {
+	// Bit 22 == 1 swaps a byte, 0 a word
+	if instruction&(1<<22) != 0 {
+		return SWPB{instruction}
+	}
+	return SWP{instruction}
 }
 
 func matchMultiplyLong(instruction uint32) isa.Instruction {
-	fmt.Println("Multiply Long")
 	// Bits 22 and 21 are the U and A flags, unsigned and accumulate
 	ua := (instruction >> 21) & 0b11
 	switch ua {
@@ -181,21 +189,12 @@ func matchHalfwordDataTransferRegisterOffset(instruction uint32) isa.Instruction
 		if h {
 			return LDRHRegisterOffset{instruction}
 		}
-		// SWP instruction
-		panic("SWP instruction not implemented")
-	} else {
-		if s {
-			if h {
-				return STRSHRegisterOffset{instruction}
-			}
-			return STRSBRegisterOffset{instruction}
-		}
-		if h {
-			return STRHRegisterOffset{instruction}
-		}
-		// SWP instruction
-		panic("SWP instruction not implemented")
+	} else if h && !s {
+		return STRHRegisterOffset{instruction}
 	}
+	// SH == 00 is taken by SWP and the multiplies, and signed stores are
+	// LDRD and STRD from ARMv5TE
+	return Undefined{instruction}
 }
 
 func matchHalfwordDataTransferImmediateOffset(instruction uint32) isa.Instruction {
@@ -218,21 +217,12 @@ func matchHalfwordDataTransferImmediateOffset(instruction uint32) isa.Instructio
 		if h {
 			return LDRH{instruction}
 		}
-		// SWP instruction
-		panic("SWP instruction not implemented")
-	} else {
-		if s {
-			if h {
-				return STRSH{instruction}
-			}
-			return STRSB{instruction}
-		}
-		if h {
-			return STRH{instruction}
-		}
-		// SWP instruction
-		panic("SWP instruction not implemented")
+	} else if h && !s {
+		return STRH{instruction}
 	}
+	// SH == 00 is taken by SWP and the multiplies, and signed stores are
+	// LDRD and STRD from ARMv5TE
+	return Undefined{instruction}
 }
 
 func matchDataProcessing(instruction uint32) isa.Instruction {
@@ -297,8 +287,8 @@ func unshiftRegister(instruction uint32, cpu interfaces.CPU) (uint32, bool) {
 	}
 	switch (shift & 0b0000_0110) >> 1 {
 	case 0b0000_0000: // Logical shift left
-		if cpu.GetConfig().Debug {
-			fmt.Printf("lsl r%d, #%d\n", rm, shiftAmount)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("lsl r%d, #%d", rm, shiftAmount)
 		}
 		if shiftAmount == 0 {
 			return cpu.ReadRegister(rm), cpu.GetC()
@@ -306,22 +296,22 @@ func unshiftRegister(instruction uint32, cpu interfaces.CPU) (uint32, bool) {
 		return cpu.ReadRegister(rm) << shiftAmount, carry
 	case 0b0000_0001: // Logical shift right
 		if shiftAmount == 0 {
-			if cpu.GetConfig().Debug {
-				fmt.Printf("lsr r%d, #32\n", rm)
+			if logging.CPU.Enabled(logging.LevelTrace) {
+				logging.CPU.Tracef("lsr r%d, #32", rm)
 			}
 			// bit 31 of Rm is copied into the carry flag
 			carry = (cpu.ReadRegister(rm) & 0x80000000) != 0
 			return 0, carry
 		}
-		if cpu.GetConfig().Debug {
-			fmt.Printf("lsr r%d, #%d\n", rm, shiftAmount)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("lsr r%d, #%d", rm, shiftAmount)
 		}
 		return cpu.ReadRegister(rm) >> shiftAmount, carry
 	case 0b0000_0010: // Arithmetic shift right
 		// An arithmetic shift right (ASR) is similar to logical shift right, except that the high bits
 		// are filled with bit 31 of Rm instead of zeros
-		if cpu.GetConfig().Debug {
-			fmt.Printf("asr r%d, #%d\n", rm, shiftAmount)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("asr r%d, #%d", rm, shiftAmount)
 		}
 		if shiftAmount == 0 {
 			// bit 31 of Rm is copied into the carry flag
@@ -346,8 +336,8 @@ func unshiftRegister(instruction uint32, cpu interfaces.CPU) (uint32, bool) {
 
 		return shifted, carryBit == 1
 	case 0b0000_0011: // Rotate right
-		if cpu.GetConfig().Debug {
-			fmt.Printf("ror r%d, #%d\n", rm, shiftAmount)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("ror r%d, #%d", rm, shiftAmount)
 		}
 		if shiftAmount == 32 {
 			// Result is the same as the original value, carry flag is bit 31 of Rm
@@ -0,0 +1,278 @@
+package arm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+const ram = 0x02000000
+
+// state is the part of the CPU a test sets up or checks. A zero CPSR
+// means system mode with all flags clear.
+type state struct {
+	regs map[uint8]uint32
+	cpsr uint32
+	spsr uint32
+	mem  map[uint32]uint32
+}
+
+type testCase struct {
+	name   string
+	opcode uint32
+	before state
+	after  state
+}
+
+// runCases executes each opcode as the first instruction of a ROM and
+// compares the registers, CPSR and memory words listed in after
+func runCases(t *testing.T, cases []testCase) {
+	t.Helper()
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newCPU(t, tt.opcode)
+			for addr, value := range tt.before.mem {
+				if err := c.GetBus().Write32(addr, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for reg, value := range tt.before.regs {
+				if err := c.WriteDebugRegister(reg, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cpsr := tt.before.cpsr
+			if cpsr == 0 {
+				cpsr = 0x1F
+			}
+			if err := c.WriteDebugRegister(cpu.CPSR_REG, cpsr); err != nil {
+				t.Fatal(err)
+			}
+			if tt.before.spsr != 0 {
+				c.WriteSPSR(tt.before.spsr)
+			}
+
+			if err := c.StepInstruction(); err != nil {
+				t.Fatal(err)
+			}
+
+			for reg, want := range tt.after.regs {
+				if got := c.ReadDebugRegister(reg); got != want {
+					t.Errorf("r%d = 0x%08X, want 0x%08X", reg, got, want)
+				}
+			}
+			if tt.after.cpsr != 0 {
+				if got := c.ReadCPSR(); got != tt.after.cpsr {
+					t.Errorf("cpsr = 0x%08X, want 0x%08X", got, tt.after.cpsr)
+				}
+			}
+			if tt.after.spsr != 0 {
+				if got := c.ReadSPSR(); got != tt.after.spsr {
+					t.Errorf("spsr = 0x%08X, want 0x%08X", got, tt.after.spsr)
+				}
+			}
+			for addr, want := range tt.after.mem {
+				got, err := c.GetBus().Read32(addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("[0x%08X] = 0x%08X, want 0x%08X", addr, got, want)
+				}
+			}
+		})
+	}
+}
+
+func newCPU(t *testing.T, opcode uint32) *cpu.ARM7TDMI {
+	t.Helper()
+	program := make([]uint32, 0x80)
+	program[0] = opcode
+	for i := 1; i < len(program); i++ {
+		program[i] = 0xEAFFFFFE // b .
+	}
+	return testutil.NewCPU(t, program, nil)
+}
+
+func regs(values ...uint32) map[uint8]uint32 {
+	m := make(map[uint8]uint32, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		m[uint8(values[i])] = values[i+1]
+	}
+	return m
+}
+
+func mem(values ...uint32) map[uint32]uint32 {
+	m := make(map[uint32]uint32, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		m[values[i]] = values[i+1]
+	}
+	return m
+}
+
+func TestDataProcessing(t *testing.T) {
+	t.Parallel()
+	runCases(t, []testCase{
+		{"mov immediate", 0xE3A000FF, state{}, state{regs: regs(0, 0xFF)}},
+		{"mov rotated immediate", 0xE3A004FF, state{}, state{regs: regs(0, 0xFF000000)}},
+		{"adds overflow", 0xE0910002, state{regs: regs(1, 0x7FFFFFFF, 2, 1)}, state{regs: regs(0, 0x80000000), cpsr: 0x9000001F}},
+		{"adds carry", 0xE0910002, state{regs: regs(1, 0xFFFFFFFF, 2, 1)}, state{regs: regs(0, 0), cpsr: 0x6000001F}},
+		{"subs borrow", 0xE0510002, state{regs: regs(1, 1, 2, 2)}, state{regs: regs(0, 0xFFFFFFFF), cpsr: 0x8000001F}},
+		{"subs pc restores cpsr", 0xE250F004, state{regs: regs(0, 0x08000104), cpsr: 0x92, spsr: 0x1F}, state{regs: regs(15, 0x08000100), cpsr: 0x1F}},
+		{"cmp equal", 0xE1510002, state{regs: regs(1, 5, 2, 5)}, state{cpsr: 0x6000001F}},
+		{"movs lsl by register", 0xE1B00211, state{regs: regs(1, 0x80000001, 2, 1)}, state{regs: regs(0, 2), cpsr: 0x2000001F}},
+		{"mov ror immediate", 0xE1A00461, state{regs: regs(1, 0xFF)}, state{regs: regs(0, 0xFF000000)}},
+		{"add pc", 0xE28F0000, state{}, state{regs: regs(0, 0x08000008)}},
+	})
+}
+
+func TestMultiply(t *testing.T) {
+	t.Parallel()
+	runCases(t, []testCase{
+		{"mul", 0xE0000291, state{regs: regs(1, 3, 2, 4)}, state{regs: regs(0, 12)}},
+		{"mla", 0xE0203291, state{regs: regs(1, 3, 2, 4, 3, 5)}, state{regs: regs(0, 17)}},
+		{"umull", 0xE0810392, state{regs: regs(2, 0xFFFFFFFF, 3, 2)}, state{regs: regs(0, 0xFFFFFFFE, 1, 1)}},
+		{"smull", 0xE0C10392, state{regs: regs(2, 0xFFFFFFFF, 3, 2)}, state{regs: regs(0, 0xFFFFFFFE, 1, 0xFFFFFFFF)}},
+		{"umlal", 0xE0A10392, state{regs: regs(0, 1, 1, 0, 2, 0xFFFFFFFF, 3, 2)}, state{regs: regs(0, 0xFFFFFFFF, 1, 1)}},
+		{"smlal", 0xE0E10392, state{regs: regs(0, 5, 1, 0, 2, 0xFFFFFFFF, 3, 2)}, state{regs: regs(0, 3, 1, 0)}},
+	})
+}
+
+func TestSingleDataTransfer(t *testing.T) {
+	t.Parallel()
+	runCases(t, []testCase{
+		{"ldr pre-indexed", 0xE5910004, state{regs: regs(1, ram), mem: mem(ram+4, 0xDEADBEEF)}, state{regs: regs(0, 0xDEADBEEF, 1, ram)}},
+		{"ldr misaligned rotates", 0xE5910000, state{regs: regs(1, ram+1), mem: mem(ram, 0x11223344)}, state{regs: regs(0, 0x44112233)}},
+		{"ldr post-indexed writes back", 0xE4910004, state{regs: regs(1, ram), mem: mem(ram, 7)}, state{regs: regs(0, 7, 1, ram+4)}},
+		{"ldr scaled register offset", 0xE7910102, state{regs: regs(1, ram, 2, 1), mem: mem(ram+4, 9)}, state{regs: regs(0, 9)}},
+		{"ldrb", 0xE5D10001, state{regs: regs(1, ram), mem: mem(ram, 0x11223344)}, state{regs: regs(0, 0x33)}},
+		{"str pre-indexed writeback", 0xE5210004, state{regs: regs(0, 0xCAFE, 1, ram+8)}, state{regs: regs(1, ram+4), mem: mem(ram+4, 0xCAFE)}},
+		{"strb", 0xE5C10000, state{regs: regs(0, 0x1234, 1, ram)}, state{mem: mem(ram, 0x34)}},
+		{"str pc", 0xE581F000, state{regs: regs(1, ram)}, state{mem: mem(ram, 0x0800000C)}},
+	})
+}
+
+func TestHalfwordTransfer(t *testing.T) {
+	t.Parallel()
+	word := mem(ram, 0x80017FFF)
+	runCases(t, []testCase{
+		{"ldrh immediate", 0xE1D100B2, state{regs: regs(1, ram), mem: word}, state{regs: regs(0, 0x8001)}},
+		{"ldrh misaligned rotates", 0xE1D100B0, state{regs: regs(1, ram+1), mem: mem(ram, 0x1234)}, state{regs: regs(0, 0x34000012)}},
+		{"ldrsh immediate", 0xE1D100F2, state{regs: regs(1, ram), mem: word}, state{regs: regs(0, 0xFFFF8001)}},
+		{"ldrsh misaligned loads a byte", 0xE1D100F0, state{regs: regs(1, ram+1), mem: mem(ram, 0x8012)}, state{regs: regs(0, 0xFFFFFF80)}},
+		{"ldrsb post-indexed", 0xE0D100D1, state{regs: regs(1, ram), mem: mem(ram, 0x80)}, state{regs: regs(0, 0xFFFFFF80, 1, ram+1)}},
+		{"ldrh register offset", 0xE19100B2, state{regs: regs(1, ram, 2, 2), mem: word}, state{regs: regs(0, 0x8001)}},
+		{"ldrh register offset misaligned", 0xE19100B2, state{regs: regs(1, ram, 2, 1), mem: mem(ram, 0x1234)}, state{regs: regs(0, 0x34000012)}},
+		{"ldrsh register offset", 0xE19100F2, state{regs: regs(1, ram, 2, 2), mem: word}, state{regs: regs(0, 0xFFFF8001)}},
+		{"ldrsh register offset post-indexed down", 0xE01100F2, state{regs: regs(1, ram+2, 2, 2), mem: word}, state{regs: regs(0, 0xFFFF8001, 1, ram)}},
+		{"ldrsb register offset writeback", 0xE1B100D2, state{regs: regs(1, ram, 2, 3), mem: mem(ram, 0xFF000000)}, state{regs: regs(0, 0xFFFFFFFF, 1, ram+3)}},
+		{"ldrsb loaded base wins", 0xE1B110D2, state{regs: regs(1, ram, 2, 3), mem: mem(ram, 0x7F000000)}, state{regs: regs(1, 0x7F)}},
+		{"strh immediate", 0xE1C100B2, state{regs: regs(0, 0x12345678, 1, ram)}, state{mem: mem(ram, 0x56780000)}},
+		{"strh register offset", 0xE18100B2, state{regs: regs(0, 0xABCD, 1, ram, 2, 2)}, state{mem: mem(ram, 0xABCD0000)}},
+	})
+}
+
+func TestBlockTransfer(t *testing.T) {
+	t.Parallel()
+	words := mem(ram, 1, ram+4, 2, ram+8, 3)
+	runCases(t, []testCase{
+		{"ldmia writeback", 0xE8B00006, state{regs: regs(0, ram), mem: words}, state{regs: regs(0, ram+8, 1, 1, 2, 2)}},
+		{"ldmia without writeback", 0xE8900002, state{regs: regs(0, ram), mem: words}, state{regs: regs(0, ram, 1, 1)}},
+		{"ldmib", 0xE9900002, state{regs: regs(0, ram), mem: words}, state{regs: regs(1, 2)}},
+		{"ldmda", 0xE8100006, state{regs: regs(0, ram+4), mem: words}, state{regs: regs(0, ram+4, 1, 1, 2, 2)}},
+		{"ldmdb writeback", 0xE9300006, state{regs: regs(0, ram+8), mem: words}, state{regs: regs(0, ram, 1, 1, 2, 2)}},
+		{"ldm loaded base wins", 0xE8B00003, state{regs: regs(0, ram), mem: words}, state{regs: regs(0, 1, 1, 2)}},
+		{"ldm empty list loads pc", 0xE8B00000, state{regs: regs(0, ram), mem: mem(ram, 0x08000100)}, state{regs: regs(0, ram+0x40, 15, 0x08000100)}},
+		{"stmdb push", 0xE92D4003, state{regs: regs(0, 1, 1, 2, 13, ram+0x10, 14, 3)}, state{regs: regs(13, ram+4), mem: mem(ram+4, 1, ram+8, 2, ram+12, 3)}},
+		{"stmia base first stores the old base", 0xE8A00003, state{regs: regs(0, ram, 1, 5)}, state{regs: regs(0, ram+8), mem: mem(ram, ram, ram+4, 5)}},
+		{"stmia base later stores the new base", 0xE8A10003, state{regs: regs(0, 5, 1, ram)}, state{regs: regs(1, ram+8), mem: mem(ram, 5, ram+4, ram+8)}},
+		{"stm pc", 0xE8808000, state{regs: regs(0, ram)}, state{mem: mem(ram, 0x0800000C)}},
+		{"stm user bank", 0xE8C02000, state{regs: regs(0, ram, 13, 0x03007F00), cpsr: 0x92}, state{cpsr: 0x92, mem: mem(ram, 0x03007F00)}},
+		{"ldm pc restores cpsr", 0xE8D08000, state{regs: regs(0, ram), cpsr: 0x92, spsr: 0x3F, mem: mem(ram, 0x08000101)}, state{regs: regs(15, 0x08000100), cpsr: 0x3F}},
+	})
+}
+
+func TestSwap(t *testing.T) {
+	t.Parallel()
+	word := mem(ram, 0x11223344)
+	runCases(t, []testCase{
+		{"swp", 0xE1020091, state{regs: regs(1, 0xAABBCCDD, 2, ram), mem: word}, state{regs: regs(0, 0x11223344), mem: mem(ram, 0xAABBCCDD)}},
+		{"swp same register", 0xE1020090, state{regs: regs(0, 5, 2, ram), mem: word}, state{regs: regs(0, 0x11223344), mem: mem(ram, 5)}},
+		{"swp misaligned rotates", 0xE1020091, state{regs: regs(1, 7, 2, ram+1), mem: word}, state{regs: regs(0, 0x44112233), mem: mem(ram, 7)}},
+		{"swpb", 0xE1420091, state{regs: regs(1, 0x1FF, 2, ram+1), mem: word}, state{regs: regs(0, 0x33), mem: mem(ram, 0x1122FF44)}},
+	})
+}
+
+func TestBranch(t *testing.T) {
+	t.Parallel()
+	runCases(t, []testCase{
+		{"b", 0xEA000000, state{}, state{regs: regs(15, 0x08000008)}},
+		{"bl", 0xEB000000, state{}, state{regs: regs(14, 0x08000004, 15, 0x08000008)}},
+		{"bx to thumb", 0xE12FFF10, state{regs: regs(0, 0x08000101)}, state{regs: regs(15, 0x08000100), cpsr: 0x3F}},
+		{"beq not taken", 0x0A000000, state{}, state{regs: regs(15, 0x08000004)}},
+		{"bgt not taken when equal", 0xCA000000, state{cpsr: 0x4000001F}, state{regs: regs(15, 0x08000004)}},
+		{"bgt taken", 0xCA000000, state{}, state{regs: regs(15, 0x08000008)}},
+		{"bls taken when equal", 0x9A000000, state{cpsr: 0x6000001F}, state{regs: regs(15, 0x08000008)}},
+		{"bls taken when lower", 0x9A000000, state{}, state{regs: regs(15, 0x08000008)}},
+		{"bls not taken when higher", 0x9A000000, state{cpsr: 0x2000001F}, state{regs: regs(15, 0x08000004)}},
+		{"blt not taken when n equals v", 0xBA000000, state{cpsr: 0x9000001F}, state{regs: regs(15, 0x08000004)}},
+		{"blt taken", 0xBA000000, state{cpsr: 0x8000001F}, state{regs: regs(15, 0x08000008)}},
+		{"nv never runs", 0xFA000000, state{}, state{regs: regs(15, 0x08000004)}},
+	})
+}
+
+func TestPSRTransfer(t *testing.T) {
+	t.Parallel()
+	runCases(t, []testCase{
+		{"mrs cpsr", 0xE10F0000, state{cpsr: 0x6000001F}, state{regs: regs(0, 0x6000001F)}},
+		{"msr cpsr flags", 0xE128F000, state{regs: regs(0, 0xF0000000)}, state{cpsr: 0xF000001F}},
+		{"msr cpsr control immediate", 0xE321F0D3, state{}, state{cpsr: 0xD3}},
+		{"msr cpsr banks sp and lr", 0xE321F0D2, state{regs: regs(13, 0x1234, 14, 0x5678)}, state{regs: regs(13, 0x03007FA0, 14, 0)}},
+		{"msr cpsr banks fiq r8", 0xE321F0D1, state{regs: regs(7, 1, 8, 2, 12, 3)}, state{regs: regs(7, 1, 8, 0, 12, 0, 13, 0x03007F00)}},
+		// Invalid modes have no bank and no SPSR
+		{"msr cpsr invalid mode", 0xE321F0D5, state{regs: regs(8, 2, 13, 0x1234)}, state{regs: regs(8, 2, 13, 0x1234), cpsr: 0xD5, spsr: 0xD5}},
+	})
+}
+
+func TestSoftwareInterrupt(t *testing.T) {
+	t.Parallel()
+	runCases(t, []testCase{
+		{"div", 0xEF060000, state{regs: regs(0, 7, 1, 2)}, state{regs: regs(0, 3, 1, 1, 3, 3)}},
+		{"div negative", 0xEF060000, state{regs: regs(0, 0xFFFFFFF9, 1, 2)}, state{regs: regs(0, 0xFFFFFFFD, 1, 0xFFFFFFFF, 3, 3)}},
+		{"div negative denominator", 0xEF060000, state{regs: regs(0, 7, 1, 0xFFFFFFFE)}, state{regs: regs(0, 0xFFFFFFFD, 1, 1, 3, 3)}},
+	})
+}
+
+func TestDivideByZero(t *testing.T) {
+	t.Parallel()
+	c := newCPU(t, 0xEF060000) // swi 0x060000
+	if err := c.WriteDebugRegister(0, 7); err != nil {
+		t.Fatal(err)
+	}
+	var swi *isa.ErrSWI
+	if err := c.StepInstruction(); !errors.As(err, &swi) {
+		t.Errorf("got error %v, expected an ErrSWI dividing by zero", err)
+	}
+}
+
+func TestUndefined(t *testing.T) {
+	t.Parallel()
+	// The trap enters undefined mode in ARM state with IRQs off and the
+	// flags kept, returning to the next instruction
+	trapped := state{regs: regs(14, 0x08000004, 15, 0x00000004), cpsr: 0x3000009B, spsr: 0x3000001F}
+	flags := state{cpsr: 0x3000001F}
+	runCases(t, []testCase{
+		{"undefined space", 0xE7F000F0, flags, trapped},
+		{"cdp", 0xEE000000, flags, trapped},
+		{"mcr", 0xEE000010, flags, trapped},
+		{"mrc", 0xEE100010, flags, trapped},
+		{"ldc", 0xED900000, flags, trapped},
+		{"stc", 0xED800000, flags, trapped},
+		{"signed store", 0xE1C000F0, flags, trapped},
+		{"halfword space without sh", 0xE1900090, flags, trapped},
+	})
+}
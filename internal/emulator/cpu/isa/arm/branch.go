@@ -1,16 +1,15 @@
 package arm
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type B struct {
 	instruction uint32
 }
 
-func (b B) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (b B) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	offset := b.instruction & 0x00FFFFFF
 	// Sign extend the offset
 	if offset&0x00800000 != 0 {
@@ -19,8 +18,8 @@ func (b B) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	offset <<= 2
 	// if bit 0 of the offset is set, we're in THUMB mode
 	if offset&0b11 != 0 {
-		if cpu.GetConfig().Debug {
-			fmt.Println("Setting THUMB mode")
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Trace("Setting THUMB mode")
 		}
 		cpu.WritePC(cpu.ReadPC() + offset - 1)
 		cpu.SetThumbMode(true)
@@ -28,9 +27,12 @@ func (b B) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		cpu.WritePC(cpu.ReadPC() + offset)
 		cpu.SetThumbMode(false)
 	}
-	if cpu.GetConfig().Debug {
-		fmt.Printf("New PC 0x%X\n", cpu.ReadPC())
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("New PC 0x%X", cpu.ReadPC())
 	}
+	// A branch to the next fetch address leaves PC unchanged but must
+	// still refill the pipeline
+	repipeline = true
 	return
 }
 
@@ -38,7 +40,7 @@ type BL struct {
 	instruction uint32
 }
 
-func (bl BL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (bl BL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	offset := bl.instruction & 0x00FFFFFF
 	// Sign extend the offset
 	if offset&0x00800000 != 0 {
@@ -48,8 +50,8 @@ func (bl BL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	cpu.WriteLR(cpu.ReadPC() - 4)
 	// if bit 0 of the offset is set, we're in THUMB mode
 	if offset&0b11 != 0 {
-		if cpu.GetConfig().Debug {
-			fmt.Println("Setting THUMB mode")
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Trace("Setting THUMB mode")
 		}
 		cpu.WritePC(cpu.ReadPC() + offset - 1)
 		cpu.SetThumbMode(true)
@@ -57,10 +59,11 @@ func (bl BL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		cpu.WritePC(cpu.ReadPC() + offset)
 		cpu.SetThumbMode(false)
 	}
-	if cpu.GetConfig().Debug {
-		fmt.Printf("Branching by 0x%X\n", offset)
-		fmt.Printf("New PC 0x%X\n", cpu.ReadPC())
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Branching by 0x%X", offset)
+		logging.CPU.Tracef("New PC 0x%X", cpu.ReadPC())
 	}
+	repipeline = true
 	return
 }
 
@@ -68,24 +71,27 @@ type BX struct {
 	instruction uint32
 }
 
-func (bx BX) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (bx BX) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 3-0 are the register to branch to
 	rm := uint8(bx.instruction & 0x0000000F)
+	// Read before switching, THUMB mode can't read r8-r12 this way
+	target := cpu.ReadRegister(rm)
 
 	// if bit 0 of the register is set, we're in THUMB mode
-	if cpu.ReadRegister(rm)&0b11 != 0 {
-		if cpu.GetConfig().Debug {
-			fmt.Println("Setting THUMB mode")
+	if target&1 != 0 {
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Trace("Setting THUMB mode")
 		}
 		cpu.SetThumbMode(true)
-		cpu.WritePC(cpu.ReadRegister(rm) - 1)
+		cpu.WritePC(target - 1)
 	} else {
 		cpu.SetThumbMode(false)
-		cpu.WritePC(cpu.ReadRegister(rm))
+		cpu.WritePC(target)
 	}
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("New PC 0x%X\n", cpu.ReadPC())
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("New PC 0x%X", cpu.ReadPC())
 	}
+	repipeline = true
 	return
 }
@@ -0,0 +1,44 @@
+package arm
+
+// ConditionPassed reports whether an instruction with the condition field
+// cond runs with the flags in cpsr
+func ConditionPassed(cond uint32, cpsr uint32) bool {
+	n := cpsr&(1<<31) != 0
+	z := cpsr&(1<<30) != 0
+	c := cpsr&(1<<29) != 0
+	v := cpsr&(1<<28) != 0
+	switch cond & 0xF {
+	case 0b0000: // EQ, equal
+		return z
+	case 0b0001: // NE, not equal
+		return !z
+	case 0b0010: // CS, unsigned higher or same
+		return c
+	case 0b0011: // CC, unsigned lower
+		return !c
+	case 0b0100: // MI, negative
+		return n
+	case 0b0101: // PL, positive or zero
+		return !n
+	case 0b0110: // VS, overflow
+		return v
+	case 0b0111: // VC, no overflow
+		return !v
+	case 0b1000: // HI, unsigned higher
+		return c && !z
+	case 0b1001: // LS, unsigned lower or same
+		return !c || z
+	case 0b1010: // GE, greater than or equal
+		return n == v
+	case 0b1011: // LT, less than
+		return n != v
+	case 0b1100: // GT, greater than
+		return !z && n == v
+	case 0b1101: // LE, less than or equal
+		return z || n != v
+	case 0b1110: // AL, always
+		return true
+	}
+	// NV, never
+	return false
+}
@@ -1,18 +1,17 @@
 package arm
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type AND struct {
 	instruction uint32
 }
 
-func (a AND) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("AND")
+func (a AND) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("AND")
 	}
 
 	// Rn is bits 19-16
@@ -25,17 +24,15 @@ func (a AND) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	op2 := ALUOp2(a.instruction, cpu)
 	res := rnVal & op2
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("r%d = r%d [%08X] & %08X = %08X\n", rd, rn, rnVal, op2, res)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("r%d = r%d [%08X] & %08X = %08X", rd, rn, rnVal, op2, res)
 	}
 
 	cpu.WriteRegister(rd, res)
 
 	if a.instruction&(1<<20)>>20 == 1 {
-		overflow := (rnVal^op2)>>31 == 0 && (rnVal^res)>>31 == 1
 		cpu.SetZ(res == 0)
 		cpu.SetN(res&(1<<31)>>31 != 0)
-		cpu.SetV(overflow)
 		if rd == 15 {
 			cpu.WriteCPSR(cpu.ReadSPSR())
 		}
@@ -48,7 +45,7 @@ type EOR struct {
 	instruction uint32
 }
 
-func (e EOR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (e EOR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Rn is bits 19-16
 	rn := uint8((e.instruction & 0x000F0000) >> 16)
 	rnVal := cpu.ReadRegister(rn)
@@ -60,9 +57,9 @@ func (e EOR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 
 	res := rnVal ^ op2
 
-	if cpu.GetConfig().Debug {
+	if logging.CPU.Enabled(logging.LevelTrace) {
 		//nolint:golint,dupword
-		fmt.Printf("eor r%d, r%d, %d = %08X\n", rd, rn, op2, res)
+		logging.CPU.Tracef("eor r%d, r%d, %d = %08X", rd, rn, op2, res)
 	}
 
 	cpu.WriteRegister(rd, res)
@@ -82,21 +79,23 @@ type SUB struct {
 	instruction uint32
 }
 
-func (s SUB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (s SUB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Rn is bits 19-16
 	rn := uint8((s.instruction & 0x000F0000) >> 16)
 	rnVal := cpu.ReadRegister(rn)
 
+	// Rd is bits 15-12
+	rd := uint8((s.instruction & 0x0000F000) >> 12)
+
 	op2 := ALUOp2(s.instruction, cpu)
 
-	// Subtract op2 from Rn and update the condition flags, but do not store the result.
 	diff := rnVal - op2
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("sub r%d, %d = %08X\n", rn, op2, diff)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("r%d = r%d [%08X] - %08X = %08X", rd, rn, rnVal, op2, diff)
 	}
 
-	cpu.WriteRegister(rn, diff)
+	cpu.WriteRegister(rd, diff)
 
 	if s.instruction&(1<<20)>>20 == 1 {
 		// Set carry flag if the subtraction would make a positive number.
@@ -107,6 +106,9 @@ func (s SUB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		cpu.SetZ(diff == 0)
 		cpu.SetV(overflow)
 		cpu.SetC(carry)
+		if rd == 15 {
+			cpu.WriteCPSR(cpu.ReadSPSR())
+		}
 	}
 	return
 }
@@ -115,9 +117,9 @@ type RSB struct {
 	instruction uint32
 }
 
-func (r RSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("RSB")
+func (r RSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("RSB")
 	}
 	// Rn is bits 19-16
 	rn := uint8((r.instruction & 0x000F0000) >> 16)
@@ -131,8 +133,8 @@ func (r RSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Reverse subtract op2 from Rn
 	res := op2 - rnVal
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("r%d = %08X - r%d [%08X] = %08X\n", rd, op2, rn, rnVal, res)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("r%d = %08X - r%d [%08X] = %08X", rd, op2, rn, rnVal, res)
 	}
 
 	cpu.WriteRegister(rd, res)
@@ -157,9 +159,9 @@ type ADD struct {
 	instruction uint32
 }
 
-func (a ADD) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("ADD")
+func (a ADD) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("ADD")
 	}
 
 	// Rn is bits 19-16
@@ -172,8 +174,8 @@ func (a ADD) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	op2 := ALUOp2(a.instruction, cpu)
 
 	res := rnVal + op2
-	if cpu.GetConfig().Debug {
-		fmt.Printf("r%d = r%d [%08X] + %08X = %08X\n", rd, rn, rnVal, op2, res)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("r%d = r%d [%08X] + %08X = %08X", rd, rn, rnVal, op2, res)
 	}
 
 	cpu.WriteRegister(rd, res)
@@ -196,9 +198,9 @@ type ADC struct {
 	instruction uint32
 }
 
-func (a ADC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("ADC")
+func (a ADC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("ADC")
 	}
 
 	// Rn is bits 19-16
@@ -211,8 +213,8 @@ func (a ADC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	op2 := ALUOp2(a.instruction, cpu)
 
 	res := rnVal + op2
-	if cpu.GetConfig().Debug {
-		fmt.Printf("r%d = r%d [%08X] + %08X = %08X\n", rd, rn, rnVal, op2, res)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("r%d = r%d [%08X] + %08X = %08X", rd, rn, rnVal, op2, res)
 	}
 
 	if cpu.GetC() {
@@ -239,9 +241,9 @@ type SBC struct {
 	instruction uint32
 }
 
-func (s SBC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("SBC")
+func (s SBC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("SBC")
 	}
 
 	// Rn is bits 19-16
@@ -253,8 +255,8 @@ func (s SBC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Subtract op2 from Rn and update the condition flags, but do not store the result.
 	diff := rnVal - op2
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("sbc r%d, %d = %08X\n", rn, op2, diff)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("sbc r%d, %d = %08X", rn, op2, diff)
 	}
 
 	if !cpu.GetC() {
@@ -281,8 +283,7 @@ type RSC struct {
 	instruction uint32
 }
 
-func (rsc RSC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("RSC")
+func (rsc RSC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Rn is bits 19-16
 	rn := uint8((rsc.instruction & 0x000F0000) >> 16)
 	rnVal := cpu.ReadRegister(rn)
@@ -323,7 +324,7 @@ type TST struct {
 	instruction uint32
 }
 
-func (t TST) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (t TST) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Rn is bits 19-16
 	rn := uint8((t.instruction & 0x000F0000) >> 16)
 
@@ -331,8 +332,8 @@ func (t TST) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 
 	op2 := ALUOp2(t.instruction, cpu)
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("tst r%d [0x%08X] & 0x%08X = 0x%08X\n", rn, rnVal, op2, rnVal&op2)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("tst r%d [0x%08X] & 0x%08X = 0x%08X", rn, rnVal, op2, rnVal&op2)
 	}
 
 	res := rnVal & op2
@@ -347,7 +348,7 @@ type TEQ struct {
 	instruction uint32
 }
 
-func (t TEQ) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (t TEQ) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Rn is bits 19-16
 	rn := uint8((t.instruction & 0x000F0000) >> 16)
 	rnVal := cpu.ReadRegister(rn)
@@ -367,9 +368,9 @@ type CMP struct {
 	instruction uint32
 }
 
-func (c CMP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("CMP")
+func (c CMP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("CMP")
 	}
 
 	// Rn is bits 19-16
@@ -381,7 +382,9 @@ func (c CMP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Subtract op2 from Rn and update the condition flags, but do not store the result.
 	diff := rnVal - op2
 
-	fmt.Printf("cmp r%d, %d = %08X\n", rn, op2, diff)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("cmp r%d, %d = %08X", rn, op2, diff)
+	}
 
 	if c.instruction&(1<<20)>>20 == 1 {
 		// Set carry flag if the subtraction would make a positive number.
@@ -401,9 +404,9 @@ type CMN struct {
 	instruction uint32
 }
 
-func (c CMN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("CMN")
+func (c CMN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("CMN")
 	}
 
 	// Rn is bits 19-16
@@ -429,7 +432,7 @@ type ORR struct {
 	instruction uint32
 }
 
-func (o ORR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (o ORR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Rn is bits 19-16
 	rn := uint8((o.instruction & 0x000F0000) >> 16)
 	rnVal := cpu.ReadRegister(rn)
@@ -439,8 +442,8 @@ func (o ORR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 
 	op2 := ALUOp2(o.instruction, cpu)
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("orr r%d [0x%08X] | 0x%08X = 0x%08X\n", rd, rnVal, op2, rnVal|op2)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("orr r%d [0x%08X] | 0x%08X = 0x%08X", rd, rnVal, op2, rnVal|op2)
 	}
 
 	res := rnVal | op2
@@ -459,14 +462,16 @@ type MOV struct {
 	instruction uint32
 }
 
-func (m MOV) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (m MOV) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Destination register is bits 15-12
 	destination := uint8((m.instruction & 0x0000F000) >> 12)
 
 	// 2nd operand is bits 11-0
 	op2 := ALUOp2(m.instruction, cpu)
 
-	fmt.Printf("mov r%d 0x%08X\n", destination, op2)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("mov r%d 0x%08X", destination, op2)
+	}
 
 	cpu.WriteRegister(destination, op2)
 
@@ -485,11 +490,14 @@ type BIC struct {
 	instruction uint32
 }
 
-func (b BIC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("BIC")
+func (b BIC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("BIC")
 	}
 
+	// Rn is bits 19-16
+	rn := uint8((b.instruction & 0x000F0000) >> 16)
+
 	// Destination register is bits 15-12
 	rd := uint8((b.instruction & 0x0000F000) >> 12)
 
@@ -497,14 +505,13 @@ func (b BIC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	op2 := ALUOp2(b.instruction, cpu)
 
 	// Rd = Rn AND NOT Op2
-	rdVal := cpu.ReadRegister(rd)
-	res := rdVal &^ op2
+	res := cpu.ReadRegister(rn) &^ op2
 	cpu.WriteRegister(rd, res)
 
 	// If bit 20 is set, then the instruction sets the condition codes.
 	if b.instruction&(1<<20)>>20 == 1 {
 		cpu.SetZ(res == 0)
-		cpu.SetN(op2&(1<<31)>>31 != 0)
+		cpu.SetN(res&(1<<31)>>31 != 0)
 		if rd == 15 {
 			cpu.WriteCPSR(cpu.ReadSPSR())
 		}
@@ -517,20 +524,19 @@ type MVN struct {
 	instruction uint32
 }
 
-func (m MVN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("MVN")
+func (m MVN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Destination register is bits 15-12
 	destination := uint8((m.instruction & 0x0000F000) >> 12)
 
 	// 2nd operand is bits 11-0
 	op2 := ALUOp2(m.instruction, cpu)
-	val := uint32(0)
-	cpu.WriteRegister(destination, ^op2)
+	res := ^op2
+	cpu.WriteRegister(destination, res)
 
 	// If bit 20 is set, then the instruction sets the condition codes.
 	if m.instruction&(1<<20)>>20 == 1 {
-		cpu.SetZ(val == 0)
-		cpu.SetN(op2&(1<<31)>>31 != 0)
+		cpu.SetZ(res == 0)
+		cpu.SetN(res&(1<<31)>>31 != 0)
 		if destination == 15 {
 			cpu.WriteCPSR(cpu.ReadSPSR())
 		}
@@ -1,16 +1,17 @@
 package arm
 
 import (
-	"fmt"
+	"math/bits"
 
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type LDR struct {
 	instruction uint32
 }
 
-func (ldr LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (ldr LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	immediate := ldr.instruction&(1<<25)>>25 == 0
 	pre := ldr.instruction&(1<<24)>>24 == 1
 	up := ldr.instruction&(1<<23)>>23 == 1
@@ -20,10 +21,10 @@ func (ldr LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rn := uint8((ldr.instruction >> 16) & 0xF)
 	rd := uint8((ldr.instruction >> 12) & 0xF)
 
-	memory := cpu.GetMMIO()
+	memory := cpu.GetBus()
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("Immediate: %t, Pre: %t, Up: %t, Word: %t, Writeback: %t\n", immediate, pre, up, word, writeback)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Immediate: %t, Pre: %t, Up: %t, Word: %t, Writeback: %t", immediate, pre, up, word, writeback)
 	}
 
 	var offset uint32
@@ -32,8 +33,8 @@ func (ldr LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	} else {
 		offset, _ = unshiftRegister(ldr.instruction&0xFFF, cpu)
 	}
-	if cpu.GetConfig().Debug {
-		fmt.Printf("LDR r%d, [r%d, 0x%X]\n", rd, rn, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("LDR r%d, [r%d, 0x%X]", rd, rn, offset)
 	}
 
 	address := cpu.ReadRegister(rn)
@@ -49,14 +50,13 @@ func (ldr LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	if !word {
 		read8, err := memory.Read8(address)
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 		read = uint32(read8)
 	} else {
-		var err error
 		read, err = memory.Read32(address)
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 	}
 	cpu.WriteRegister(rd, read)
@@ -76,8 +76,8 @@ func (ldr LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		}
 	}
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("Address: 0x%X\n", address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Address: 0x%X", address)
 	}
 	return
 }
@@ -86,7 +86,7 @@ type STR struct {
 	instruction uint32
 }
 
-func (str STR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (str STR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	immediate := str.instruction&(1<<25)>>25 == 0
 	pre := str.instruction&(1<<24)>>24 == 1
 	up := str.instruction&(1<<23)>>23 == 1
@@ -97,10 +97,10 @@ func (str STR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8((str.instruction >> 12) & 0xF)
 	rdVal := cpu.ReadRegister(rd)
 
-	memory := cpu.GetMMIO()
+	memory := cpu.GetBus()
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("Immediate: %t, Pre: %t, Up: %t, Word: %t, Writeback: %t\n", immediate, pre, up, word, writeback)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Immediate: %t, Pre: %t, Up: %t, Word: %t, Writeback: %t", immediate, pre, up, word, writeback)
 	}
 	var offset uint32
 	if immediate {
@@ -108,8 +108,8 @@ func (str STR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	} else {
 		offset, _ = unshiftRegister(str.instruction&0xFFF, cpu)
 	}
-	if cpu.GetConfig().Debug {
-		fmt.Printf("STR r%d, [r%d, 0x%X]\n", rd, rn, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("STR r%d, [r%d, 0x%X]", rd, rn, offset)
 	}
 	if rd == 15 {
 		rdVal += 4
@@ -129,14 +129,14 @@ func (str STR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		// are moved to the bottom bits of the memory at address
 		err := memory.Write8(address, uint8(rdVal&0xFF))
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 	} else {
 		// On a word boundary plus one, bits 15-8 of the value in the register
 		// are moved to the bottom bits of the memory at address
 		err := memory.Write32(address, rdVal)
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 	}
 	if pre {
@@ -151,8 +151,8 @@ func (str STR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		}
 		cpu.WriteRegister(rn, address)
 	}
-	if cpu.GetConfig().Debug {
-		fmt.Printf("Address: 0x%X\n", address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Address: 0x%X", address)
 	}
 	return
 }
@@ -161,7 +161,7 @@ type LDM struct {
 	instruction uint32
 }
 
-func (ldm LDM) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (ldm LDM) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
 	pre := ldm.instruction&(1<<24)>>24 == 1
 	// Bit 23 == 1 means the offset is added to the base register (up)
@@ -171,8 +171,8 @@ func (ldm LDM) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bit 21 == 1 means the base register is written back to
 	writeback := ldm.instruction&(1<<21)>>21 == 1
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("Pre: %t, Up: %t, PSR: %t, Writeback: %t\n", pre, up, psr, writeback)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Pre: %t, Up: %t, PSR: %t, Writeback: %t", pre, up, psr, writeback)
 	}
 
 	// Bits 19-16 are the base register
@@ -180,56 +180,53 @@ func (ldm LDM) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 
 	// Bits 15-0 are the register list
 	registerList := ldm.instruction & 0xFFFF
+	registers, count := blockRegisters(registerList)
+	loadsPC := registers[len(registers)-1] == 15
 
-	address := cpu.ReadRegister(rn)
-
-	// If the PSR bit is set, we need to load the PSR
-	if psr {
-		panic("Not implemented")
-	}
+	address, final := blockAddresses(cpu.ReadRegister(rn), pre, up, count)
 
-	var registers []uint8
-	if up {
-		for i := 0; i < 16; i++ {
-			if registerList&(1<<i)>>i == 1 {
-				registers = append(registers, uint8(i))
-			}
-		}
-	} else {
-		for i := 15; i >= 0; i-- {
-			if registerList&(1<<i)>>i == 1 {
-				registers = append(registers, uint8(i))
-			}
-		}
+	// Without the PC in the list, the S bit loads the user mode registers
+	cpsr := cpu.ReadCPSR()
+	userBank := psr && !loadsPC
+	if userBank {
+		cpu.WriteCPSR(cpsr&^0x1F | systemMode)
 	}
-
+	var pc uint32
 	for _, register := range registers {
-		if pre {
-			if up {
-				address += 4
-			} else {
-				address -= 4
-			}
-		}
-		value, err := cpu.GetMMIO().Read32(address)
+		var value uint32
+		value, err = cpu.GetBus().Read32(address &^ 3)
 		if err != nil {
-			panic(err)
+			break
 		}
-		cpu.WriteRegister(register, value)
-		if cpu.GetConfig().Debug {
-			fmt.Printf("Pulling register r%d @ %08X\n", register, address)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Pulling register r%d @ %08X", register, address)
 		}
-		if !pre {
-			if up {
-				address += 4
-			} else {
-				address -= 4
-			}
+		if register == 15 {
+			pc = value
+		} else {
+			cpu.WriteRegister(register, value)
 		}
+		address += 4
+	}
+	if userBank {
+		cpu.WriteCPSR(cpsr)
+	}
+	if err != nil {
+		return repipeline, cycles, err
 	}
 
-	if (!pre || writeback) && registerList&(1<<rn)>>rn == 0 {
-		cpu.WriteRegister(rn, address)
+	// A loaded base wins over the written back one
+	if writeback && registerList&(1<<rn) == 0 {
+		cpu.WriteRegister(rn, final)
+	}
+
+	if loadsPC {
+		// With the S bit, loading the PC also returns from an exception
+		if psr {
+			cpu.WriteCPSR(cpu.ReadSPSR())
+		}
+		cpu.WritePC(pc)
+		repipeline = true
 	}
 
 	writebackStr := ""
@@ -237,8 +234,8 @@ func (ldm LDM) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		writebackStr = "!"
 	}
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("ldm r%d%s, {%v}\t # %08x\n", rn, writebackStr, registers, address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldm r%d%s, {%v}\t # %08x", rn, writebackStr, registers, final)
 	}
 	return
 }
@@ -247,85 +244,107 @@ type STM struct {
 	instruction uint32
 }
 
-func (stm STM) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (stm STM) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
-	p := stm.instruction&(1<<24)>>24 == 1
+	pre := stm.instruction&(1<<24)>>24 == 1
 	// Bit 23 == 1 means the offset is added to the base register (up)
-	u := stm.instruction&(1<<23)>>23 == 1
-	rn := uint8((stm.instruction >> 16) & 0b1111)
-	rnval := cpu.ReadRegister(rn)
-
-	n := 0
-	switch {
-	case p && u: // IB
-		for rs := 0; rs < 16; rs++ {
-			if stm.instruction&(1<<rs)>>rs == 1 {
-				cpu.WriteRegister(rn, cpu.ReadRegister(rn)+4)
-				err := cpu.GetMMIO().Write32(cpu.ReadRegister(rn), cpu.ReadRegister(uint8(rs)))
-				if err != nil {
-					panic(err)
-				}
-				n++
-			}
-		}
-	case !p && u: // IA
-		for rs := 0; rs < 16; rs++ {
-			if stm.instruction&(1<<rs)>>rs == 1 {
-				err := cpu.GetMMIO().Write32(cpu.ReadRegister(rn), cpu.ReadRegister(uint8(rs)))
-				if err != nil {
-					panic(err)
-				}
-				cpu.WriteRegister(rn, cpu.ReadRegister(rn)+4)
-				n++
-			}
-		}
-	case p && !u: // DB, push
-		for rs := 15; rs >= 0; rs-- {
-			if stm.instruction&(1<<rs)>>rs == 1 {
-				cpu.WriteRegister(rn, cpu.ReadRegister(rn)-4)
-				err := cpu.GetMMIO().Write32(cpu.ReadRegister(rn), cpu.ReadRegister(uint8(rs)))
-				if err != nil {
-					panic(err)
-				}
-				n++
-			}
-		}
-	case !p && !u: // DA
-		for rs := 15; rs >= 0; rs-- {
-			if stm.instruction&(1<<rs)>>rs == 1 {
-				err := cpu.GetMMIO().Write32(cpu.ReadRegister(rn), cpu.ReadRegister(uint8(rs)))
-				if err != nil {
-					panic(err)
-				}
-				cpu.WriteRegister(rn, cpu.ReadRegister(rn)-4)
-				n++
-			}
-		}
-	}
-
-	// Pre-indexing, write-back is optional
-	writeBack := stm.instruction&(1<<21)>>21 == 1
-	if p && !writeBack {
-		cpu.WriteRegister(rn, rnval)
+	up := stm.instruction&(1<<23)>>23 == 1
+	// Bit 22 == 1 means to store the user mode registers
+	psr := stm.instruction&(1<<22)>>22 == 1
+	// Bit 21 == 1 means the base register is written back to
+	writeback := stm.instruction&(1<<21)>>21 == 1
+
+	// Bits 19-16 are the base register
+	rn := uint8((stm.instruction >> 16) & 0xF)
+
+	// Bits 15-0 are the register list
+	registers, count := blockRegisters(stm.instruction & 0xFFFF)
+
+	address, final := blockAddresses(cpu.ReadRegister(rn), pre, up, count)
+
+	cpsr := cpu.ReadCPSR()
+	if psr {
+		cpu.WriteCPSR(cpsr&^0x1F | systemMode)
+	}
+	for i, register := range registers {
+		value := cpu.ReadRegister(register)
+		switch {
+		case register == 15:
+			// The PC is stored 12 bytes ahead of the instruction
+			value += 4
+		case register == rn && i > 0 && writeback:
+			// The base is written back after the first register is stored
+			value = final
+		}
+		err = cpu.GetBus().Write32(address&^3, value)
+		if err != nil {
+			break
+		}
+		address += 4
+	}
+	if psr {
+		cpu.WriteCPSR(cpsr)
+	}
+	if err != nil {
+		return repipeline, cycles, err
+	}
+
+	if writeback {
+		cpu.WriteRegister(rn, final)
 	}
 
 	writebackStr := ""
-	if writeBack {
+	if writeback {
 		writebackStr = "!"
 	}
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("stm r%d%s\n", rn, writebackStr)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("stm r%d%s, {%v}\t # %08x", rn, writebackStr, registers, final)
 	}
 
 	return
 }
 
+// blockRegisters lists the registers of a block transfer in the order they
+// are transferred, with the number of words it spans. An empty list
+// transfers only the PC but moves the base as if it held all 16.
+func blockRegisters(registerList uint32) ([]uint8, uint32) {
+	if registerList == 0 {
+		return []uint8{15}, 16
+	}
+	var registers []uint8
+	for i := uint8(0); i < 16; i++ {
+		if registerList&(1<<i) != 0 {
+			registers = append(registers, i)
+		}
+	}
+	return registers, uint32(len(registers))
+}
+
+// blockAddresses returns the address the lowest register of a block
+// transfer of count words goes to, and the base written back after it
+func blockAddresses(base uint32, pre, up bool, count uint32) (start, final uint32) {
+	if up {
+		final = base + 4*count
+		start = base
+		if pre {
+			start += 4
+		}
+		return start, final
+	}
+	final = base - 4*count
+	start = final
+	if !pre {
+		start += 4
+	}
+	return start, final
+}
+
 type LDRSH struct {
 	instruction uint32
 }
 
-func (ldrsh LDRSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (ldrsh LDRSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
 	pre := ldrsh.instruction&(1<<24)>>24 == 1
 	// // Bit 23 == 1 means the offset is added to the base register (up)
@@ -354,25 +373,15 @@ func (ldrsh LDRSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16)
 		}
 	}
 
-	fmt.Printf("ldrsh r%d, [r%d, #%d]\n", rd, rn, offsetHigh<<4|offsetLow)
-
-	halfword, err := cpu.GetMMIO().Read16(address & 0xFFFFFFFE)
-	if err != nil {
-		panic(err)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrsh r%d, [r%d, #%d]", rd, rn, offsetHigh<<4|offsetLow)
 	}
-	signedHalfword := int32(int16(halfword))
 
-	if address&1 == 1 {
-		val := signedHalfword
-		// Right rotate the halfword by 8 bits
-		is := 8
-		is %= 32
-		tmp0 := (val) >> (is)
-		tmp1 := (val) << (32 - (is))
-		cpu.WriteRegister(rd, uint32(tmp0|tmp1))
-	} else {
-		cpu.WriteRegister(rd, uint32(signedHalfword))
+	value, err := loadSignedHalfword(cpu, address)
+	if err != nil {
+		return repipeline, cycles, err
 	}
+	cpu.WriteRegister(rd, value)
 
 	if pre {
 		if writeback && rn != rd {
@@ -396,7 +405,7 @@ type LDRSB struct {
 	instruction uint32
 }
 
-func (ldrsb LDRSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (ldrsb LDRSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
 	pre := ldrsb.instruction&(1<<24)>>24 == 1
 	// Bit 23 == 1 means the offset is added to the base register (up)
@@ -425,11 +434,13 @@ func (ldrsb LDRSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16)
 		}
 	}
 
-	fmt.Printf("ldrsb r%d, [r%d, #%d]\n", rd, rn, offsetHigh<<4|offsetLow)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrsb r%d, [r%d, #%d]", rd, rn, offsetHigh<<4|offsetLow)
+	}
 
-	b, err := cpu.GetMMIO().Read8(address)
+	b, err := cpu.GetBus().Read8(address)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	signedByte := int32(int8(b))
 
@@ -450,21 +461,6 @@ func (ldrsb LDRSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16)
 		}
 	}
 
-	if pre {
-		if writeback && rn != rd {
-			cpu.WriteRegister(rn, address)
-		}
-	} else {
-		if up {
-			address += uint32(offsetHigh<<4 | offsetLow)
-		} else {
-			address -= uint32(offsetHigh<<4 | offsetLow)
-		}
-		if rn != rd {
-			cpu.WriteRegister(rn, address)
-		}
-	}
-
 	return
 }
 
@@ -472,7 +468,7 @@ type LDRH struct {
 	instruction uint32
 }
 
-func (ldrh LDRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (ldrh LDRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
 	pre := ldrh.instruction&(1<<24)>>24 == 1
 	// Bit 23 == 1 means the offset is added to the base register (up)
@@ -501,14 +497,14 @@ func (ldrh LDRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		}
 	}
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("ldrh r%d, [r%d, #%d]\n", rd, rn, offsetHigh<<4|offsetLow)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrh r%d, [r%d, #%d]", rd, rn, offsetHigh<<4|offsetLow)
 	}
 
 	// Load halfword from memory
-	halfword, err := cpu.GetMMIO().Read16(address & 0xFFFFFFFE)
+	halfword, err := cpu.GetBus().Read16(address & 0xFFFFFFFE)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	if address&1 == 1 {
@@ -541,63 +537,11 @@ func (ldrh LDRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	return
 }
 
-type STRSH struct {
-	instruction uint32
-}
-
-func (strsh STRSH) Execute(_ interfaces.CPU) (repipeline bool, cycles uint16) {
-	// // Bit 24 == 1 means pre-indexed addressing
-	// pre := strsh.instruction&(1<<24)>>24 == 1
-	// // Bit 23 == 1 means the offset is added to the base register (up)
-	// up := strsh.instruction&(1<<23)>>23 == 1
-	// // Bit 21 == 1 means the base register is written back to
-	// writeback := strsh.instruction&(1<<21)>>21 == 1
-
-	// // Bits 19-16 are the base register
-	// rn := uint8((strsh.instruction >> 16) & 0xF)
-
-	// // Bits 15-12 are the destination register
-	// rd := uint8((strsh.instruction >> 12) & 0xF)
-
-	// // Bits 11-8 are the offset's high nibble
-	// offsetHigh := uint8((strsh.instruction >> 8) & 0xF)
-
-	// // Bits 3-0 are the offset's low nibble
-	// offsetLow := uint8(strsh.instruction & 0xF)
-	panic("STRSH Not implemented")
-}
-
-type STRSB struct {
-	instruction uint32
-}
-
-func (strsb STRSB) Execute(_ interfaces.CPU) (repipeline bool, cycles uint16) {
-	// // Bit 24 == 1 means pre-indexed addressing
-	// pre := strsb.instruction&(1<<24)>>24 == 1
-	// // Bit 23 == 1 means the offset is added to the base register (up)
-	// up := strsb.instruction&(1<<23)>>23 == 1
-	// // Bit 21 == 1 means the base register is written back to
-	// writeback := strsb.instruction&(1<<21)>>21 == 1
-
-	// // Bits 19-16 are the base register
-	// rn := uint8((strsb.instruction >> 16) & 0xF)
-
-	// // Bits 15-12 are the destination register
-	// rd := uint8((strsb.instruction >> 12) & 0xF)
-
-	// // Bits 11-8 are the offset's high nibble
-	// offsetHigh := uint8((strsb.instruction >> 8) & 0xF)
-
-	// // Bits 3-0 are the offset's low nibble
-	// offsetLow := uint8(strsb.instruction & 0xF)
-	panic("STRSB Not implemented")
-}
-
 type STRH struct {
 	instruction uint32
 }
 
-func (strh STRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (strh STRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
 	pre := strh.instruction&(1<<24)>>24 == 1
 	// Bit 23 == 1 means the offset is added to the base register (up)
@@ -631,14 +575,14 @@ func (strh STRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		}
 	}
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("strh r%d, [r%d, #0x%X]  # 0x%08x\n", rd, rn, offset, address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("strh r%d, [r%d, #0x%X]  # 0x%08x", rd, rn, offset, address)
 	}
 
 	// Store unsigned halfword
-	err := cpu.GetMMIO().Write16(address, uint16(cpu.ReadRegister(rd)))
+	err = cpu.GetBus().Write16(address, uint16(cpu.ReadRegister(rd)))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	if !pre {
@@ -657,23 +601,127 @@ type LDRSHRegisterOffset struct {
 	instruction uint32
 }
 
-func (ldrsh LDRSHRegisterOffset) Execute(_ interfaces.CPU) (repipeline bool, cycles uint16) {
-	panic("LDRSHRegisterOffset Not implemented")
+func (ldrsh LDRSHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	// Bit 24 == 1 means pre-indexed addressing
+	pre := ldrsh.instruction&(1<<24)>>24 == 1
+	// Bit 23 == 1 means the offset is added to the base register (up)
+	up := ldrsh.instruction&(1<<23)>>23 == 1
+	// Bit 21 == 1 means the base register is written back to
+	writeback := ldrsh.instruction&(1<<21)>>21 == 1
+
+	// Bits 19-16 are the base register
+	rn := uint8((ldrsh.instruction >> 16) & 0xF)
+
+	// Bits 15-12 are the destination register
+	rd := uint8((ldrsh.instruction >> 12) & 0xF)
+
+	// Bits 3-0 are the offset register
+	rm := uint8(ldrsh.instruction & 0xF)
+
+	offset := cpu.ReadRegister(rm)
+
+	address := cpu.ReadRegister(rn)
+	if pre {
+		if up {
+			address += offset
+		} else {
+			address -= offset
+		}
+	}
+
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrsh r%d, [r%d, r%d]  # 0x%08x", rd, rn, rm, address)
+	}
+
+	value, err := loadSignedHalfword(cpu, address)
+	if err != nil {
+		return repipeline, cycles, err
+	}
+	cpu.WriteRegister(rd, value)
+
+	if pre {
+		if writeback && rn != rd {
+			cpu.WriteRegister(rn, address)
+		}
+	} else {
+		if up {
+			address += offset
+		} else {
+			address -= offset
+		}
+		if rn != rd {
+			cpu.WriteRegister(rn, address)
+		}
+	}
+
+	return
 }
 
 type LDRSBRegisterOffset struct {
 	instruction uint32
 }
 
-func (ldrsb LDRSBRegisterOffset) Execute(_ interfaces.CPU) (repipeline bool, cycles uint16) {
-	panic("LDRSBRegisterOffset Not implemented")
+func (ldrsb LDRSBRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	// Bit 24 == 1 means pre-indexed addressing
+	pre := ldrsb.instruction&(1<<24)>>24 == 1
+	// Bit 23 == 1 means the offset is added to the base register (up)
+	up := ldrsb.instruction&(1<<23)>>23 == 1
+	// Bit 21 == 1 means the base register is written back to
+	writeback := ldrsb.instruction&(1<<21)>>21 == 1
+
+	// Bits 19-16 are the base register
+	rn := uint8((ldrsb.instruction >> 16) & 0xF)
+
+	// Bits 15-12 are the destination register
+	rd := uint8((ldrsb.instruction >> 12) & 0xF)
+
+	// Bits 3-0 are the offset register
+	rm := uint8(ldrsb.instruction & 0xF)
+
+	offset := cpu.ReadRegister(rm)
+
+	address := cpu.ReadRegister(rn)
+	if pre {
+		if up {
+			address += offset
+		} else {
+			address -= offset
+		}
+	}
+
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrsb r%d, [r%d, r%d]  # 0x%08x", rd, rn, rm, address)
+	}
+
+	b, err := cpu.GetBus().Read8(address)
+	if err != nil {
+		return repipeline, cycles, err
+	}
+	cpu.WriteRegister(rd, uint32(int32(int8(b))))
+
+	if pre {
+		if writeback && rn != rd {
+			cpu.WriteRegister(rn, address)
+		}
+	} else {
+		if up {
+			address += offset
+		} else {
+			address -= offset
+		}
+		if rn != rd {
+			cpu.WriteRegister(rn, address)
+		}
+	}
+
+	return
 }
 
 type LDRHRegisterOffset struct {
 	instruction uint32
 }
 
-func (ldrh LDRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (ldrh LDRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
 	pre := ldrh.instruction&(1<<24)>>24 == 1
 	// Bit 23 == 1 means the offset is added to the base register (up)
@@ -701,15 +749,18 @@ func (ldrh LDRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cyc
 		}
 	}
 
-	fmt.Printf("ldrh r%d, [r%d, r%d]  # 0x%08x\n", rd, rn, rm, address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrh r%d, [r%d, r%d]  # 0x%08x", rd, rn, rm, address)
+	}
 
 	// Load halfword from memory
-	halfword, err := cpu.GetMMIO().Read16(address)
+	halfword, err := cpu.GetBus().Read16(address & 0xFFFFFFFE)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
-	cpu.WriteRegister(rd, uint32(halfword))
+	// A misaligned halfword is rotated into place
+	cpu.WriteRegister(rd, bits.RotateLeft32(uint32(halfword), -int(address&1)*8))
 
 	if pre {
 		if writeback && rn != rd {
@@ -729,27 +780,11 @@ func (ldrh LDRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cyc
 	return
 }
 
-type STRSHRegisterOffset struct {
-	instruction uint32
-}
-
-func (strsh STRSHRegisterOffset) Execute(_ interfaces.CPU) (repipeline bool, cycles uint16) {
-	panic("STRSHRegisterOffset Not implemented")
-}
-
-type STRSBRegisterOffset struct {
-	instruction uint32
-}
-
-func (strsb STRSBRegisterOffset) Execute(_ interfaces.CPU) (repipeline bool, cycles uint16) {
-	panic("STRSBRegisterOffset Not implemented")
-}
-
 type STRHRegisterOffset struct {
 	instruction uint32
 }
 
-func (strh STRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (strh STRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 24 == 1 means pre-indexed addressing
 	pre := strh.instruction&(1<<24)>>24 == 1
 	// Bit 23 == 1 means the offset is added to the base register (up)
@@ -780,14 +815,14 @@ func (strh STRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cyc
 		}
 	}
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("strh r%d, [r%d, r%d]  # 0x%08x\n", rd, rn, rm, address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("strh r%d, [r%d, r%d]  # 0x%08x", rd, rn, rm, address)
 	}
 
 	// Store unsigned halfword
-	err := cpu.GetMMIO().Write16(address, uint16(cpu.ReadRegister(rd)))
+	err = cpu.GetBus().Write16(address, uint16(cpu.ReadRegister(rd)))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	if !pre {
@@ -801,3 +836,14 @@ func (strh STRHRegisterOffset) Execute(cpu interfaces.CPU) (repipeline bool, cyc
 
 	return
 }
+
+// loadSignedHalfword sign-extends the halfword at address. The ARM7TDMI
+// loads a sign-extended byte from a misaligned address instead.
+func loadSignedHalfword(cpu interfaces.CPU, address uint32) (uint32, error) {
+	if address&1 == 1 {
+		b, err := cpu.GetBus().Read8(address)
+		return uint32(int32(int8(b))), err
+	}
+	halfword, err := cpu.GetBus().Read16(address)
+	return uint32(int32(int16(halfword))), err
+}
@@ -0,0 +1,4 @@
+package arm
+
+// HandlerFor lets the tests compare the lookup table with DecodeInstruction
+var HandlerFor = handlerFor
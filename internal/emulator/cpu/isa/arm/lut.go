@@ -0,0 +1,145 @@
+package arm
+
+import (
+	"fmt"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+)
+
+// Handler executes an ARM instruction
+type Handler func(cpu interfaces.CPU, instruction uint32) (repipeline bool, cycles uint16, err error)
+
+// handlers is indexed by bits 27-20 and 7-4 of an instruction, which tell
+// every ARMv4T instruction apart
+//
+//nolint:golint,gochecknoglobals
+var handlers [4096]Handler
+
+func init() {
+	for i := range handlers {
+		handlers[i] = handlerFor(DecodeInstruction(representative(uint32(i))))
+	}
+}
+
+// Lookup returns the handler that executes instruction
+func Lookup(instruction uint32) Handler {
+	return handlers[instruction>>16&0xFF0|instruction>>4&0xF]
+}
+
+// representative is an instruction with the table index i. The bits the
+// table skips are set like the encodings that check them fix them: bits
+// 19-12 are ones for MRS and MSR, bits 11-8 are zero for the halfword
+// transfers and ones for BX.
+func representative(i uint32) uint32 {
+	instruction := i>>4<<20 | i&0xF<<4 | 0x000FF000
+	if instruction|0xF00 == BranchExchangeFormat {
+		instruction |= 0xF00
+	}
+	return instruction
+}
+
+// decoded is any ARM instruction type
+type decoded interface {
+	~struct{ instruction uint32 }
+	isa.Instruction
+}
+
+func execute[T decoded](cpu interfaces.CPU, instruction uint32) (bool, uint16, error) {
+	return T{instruction}.Execute(cpu)
+}
+
+//nolint:golint,gocyclo
+func handlerFor(instruction isa.Instruction) Handler {
+	switch instruction.(type) {
+	case B:
+		return execute[B]
+	case BL:
+		return execute[BL]
+	case BX:
+		return execute[BX]
+	case AND:
+		return execute[AND]
+	case EOR:
+		return execute[EOR]
+	case SUB:
+		return execute[SUB]
+	case RSB:
+		return execute[RSB]
+	case ADD:
+		return execute[ADD]
+	case ADC:
+		return execute[ADC]
+	case SBC:
+		return execute[SBC]
+	case RSC:
+		return execute[RSC]
+	case TST:
+		return execute[TST]
+	case TEQ:
+		return execute[TEQ]
+	case CMP:
+		return execute[CMP]
+	case CMN:
+		return execute[CMN]
+	case ORR:
+		return execute[ORR]
+	case MOV:
+		return execute[MOV]
+	case BIC:
+		return execute[BIC]
+	case MVN:
+		return execute[MVN]
+	case LDR:
+		return execute[LDR]
+	case STR:
+		return execute[STR]
+	case LDM:
+		return execute[LDM]
+	case STM:
+		return execute[STM]
+	case LDRSH:
+		return execute[LDRSH]
+	case LDRSB:
+		return execute[LDRSB]
+	case LDRH:
+		return execute[LDRH]
+	case STRH:
+		return execute[STRH]
+	case LDRSHRegisterOffset:
+		return execute[LDRSHRegisterOffset]
+	case LDRSBRegisterOffset:
+		return execute[LDRSBRegisterOffset]
+	case LDRHRegisterOffset:
+		return execute[LDRHRegisterOffset]
+	case STRHRegisterOffset:
+		return execute[STRHRegisterOffset]
+	case MLA:
+		return execute[MLA]
+	case MUL:
+		return execute[MUL]
+	case UMULL:
+		return execute[UMULL]
+	case UMLAL:
+		return execute[UMLAL]
+	case SMULL:
+		return execute[SMULL]
+	case SMLAL:
+		return execute[SMLAL]
+	case MSR:
+		return execute[MSR]
+	case MRS:
+		return execute[MRS]
+	case SWP:
+		return execute[SWP]
+	case SWPB:
+		return execute[SWPB]
+	case SWI:
+		return execute[SWI]
+	case Undefined:
+		return execute[Undefined]
+	case nil:
+		return nil
+	}
+	panic(fmt.Sprintf("no handler for %T", instruction))
+}
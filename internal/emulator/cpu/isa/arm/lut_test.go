@@ -0,0 +1,72 @@
+package arm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/arm"
+)
+
+// opcodes is a mix of the instructions games run most
+//
+//nolint:golint,gochecknoglobals
+var opcodes = []uint32{
+	0xE3A000FF, // mov r0, #0xFF
+	0xE0910002, // adds r0, r1, r2
+	0xE1510002, // cmp r1, r2
+	0xE1B00211, // movs r0, r1, lsl r2
+	0xE5910004, // ldr r0, [r1, #4]
+	0xE5210004, // str r0, [r1, #-4]!
+	0xE1D100B2, // ldrh r0, [r1, #2]
+	0xE8B00006, // ldmia r0!, {r1, r2}
+	0xE92D4003, // stmdb sp!, {r0, r1, lr}
+	0xEA000000, // b
+	0xEB000000, // bl
+	0xE12FFF10, // bx r0
+	0xE0000291, // mul r0, r1, r2
+	0xE10F0000, // mrs r0, cpsr
+	0xE1020091, // swp r0, r1, [r2]
+	0xEF060000, // swi 0x06
+}
+
+func TestLookupMatchesDecodeInstruction(t *testing.T) {
+	t.Parallel()
+	for i := uint32(0); i < 4096; i++ {
+		// Fill the bits the table skips like the encodings that check them
+		// fix them, any other value is unpredictable
+		fill := uint32(0x000FF000)
+		if i == 0x121 {
+			fill |= 0xF00
+		}
+		for cond := uint32(0); cond < 16; cond++ {
+			instruction := cond<<28 | i>>4<<20 | fill | i&0xF<<4 | (i+cond)&0xF
+			decoded := arm.DecodeInstruction(instruction)
+			if !sameHandler(arm.Lookup(instruction), arm.HandlerFor(decoded)) {
+				t.Fatalf("0x%08X: lookup disagrees with DecodeInstruction, which returns %T", instruction, decoded)
+			}
+		}
+	}
+}
+
+func sameHandler(a, b arm.Handler) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+//nolint:golint,gochecknoglobals
+var (
+	instructionSink isa.Instruction
+	handlerSink     arm.Handler
+)
+
+func BenchmarkDecodeInstruction(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		instructionSink = arm.DecodeInstruction(opcodes[i%len(opcodes)])
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		handlerSink = arm.Lookup(opcodes[i%len(opcodes)])
+	}
+}
@@ -1,16 +1,15 @@
 package arm
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type MLA struct {
 	instruction uint32
 }
 
-func (m MLA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (m MLA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 20 determines whether or not to update the condition codes
 	updateConditionCodes := (m.instruction&(1<<20))>>20 == 1
 
@@ -26,9 +25,9 @@ func (m MLA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 3-0 are the Rn register
 	rm := uint8(m.instruction & 0x0000000F)
 
-	if cpu.GetConfig().Debug {
+	if logging.CPU.Enabled(logging.LevelTrace) {
 		//nolint:golint,dupword
-		fmt.Printf("mla r%d, r%d, r%d, r%d\n", rd, rm, rs, rn)
+		logging.CPU.Tracef("mla r%d, r%d, r%d, r%d", rd, rm, rs, rn)
 	}
 
 	// Rd := Rm * Rs + Rn
@@ -52,7 +51,7 @@ type MUL struct {
 	instruction uint32
 }
 
-func (m MUL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (m MUL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 20 determines whether or not to update the condition codes
 	updateConditionCodes := (m.instruction&(1<<20))>>20 == 1
 
@@ -65,9 +64,9 @@ func (m MUL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 3-0 are the Rn register
 	rm := uint8(m.instruction & 0x0000000F)
 
-	if cpu.GetConfig().Debug {
+	if logging.CPU.Enabled(logging.LevelTrace) {
 		//nolint:golint,dupword
-		fmt.Printf("mul r%d, r%d, r%d\n", rd, rm, rs)
+		logging.CPU.Tracef("mul r%d, r%d, r%d", rd, rm, rs)
 	}
 
 	// Rd := Rm * Rs + Rn
@@ -1,16 +1,15 @@
 package arm
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type UMULL struct {
 	instruction uint32
 }
 
-func (u UMULL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (u UMULL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 20 is the S flag to update the CPSR
 	s := u.instruction&(1<<20) != 0
 
@@ -29,7 +28,9 @@ func (u UMULL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rmVal := uint64(cpu.ReadRegister(rm))
 
 	//nolint:golint,dupword
-	fmt.Printf("umull r%d, r%d, r%d, r%d\n", rdLo, rdHi, rm, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("umull r%d, r%d, r%d, r%d", rdLo, rdHi, rm, rs)
+	}
 
 	res := rsVal * rmVal
 
@@ -48,7 +49,7 @@ type UMLAL struct {
 	instruction uint32
 }
 
-func (u UMLAL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (u UMLAL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 20 is the S flag to update the CPSR
 	s := u.instruction&(1<<20) != 0
 
@@ -71,7 +72,9 @@ func (u UMLAL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rmVal := uint64(cpu.ReadRegister(rm))
 
 	//nolint:golint,dupword
-	fmt.Printf("umlal r%d, r%d, r%d, r%d\n", rdLo, rdHi, rm, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("umlal r%d, r%d, r%d, r%d", rdLo, rdHi, rm, rs)
+	}
 
 	res := rsVal*rmVal + accVal
 
@@ -90,7 +93,7 @@ type SMULL struct {
 	instruction uint32
 }
 
-func (s SMULL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (s SMULL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 20 is the S flag to update the CPSR
 	save := s.instruction&(1<<20) != 0
 
@@ -109,7 +112,9 @@ func (s SMULL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rmVal := int32(cpu.ReadRegister(rm))
 
 	//nolint:golint,dupword
-	fmt.Printf("smull r%d, r%d, r%d, r%d\n", rdLo, rdHi, rm, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("smull r%d, r%d, r%d, r%d", rdLo, rdHi, rm, rs)
+	}
 
 	var res int64 = int64(rsVal) * int64(rmVal)
 
@@ -129,7 +134,7 @@ type SMLAL struct {
 	instruction uint32
 }
 
-func (s SMLAL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (s SMLAL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 20 is the S flag to update the CPSR
 	save := s.instruction&(1<<20) != 0
 
@@ -152,7 +157,9 @@ func (s SMLAL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rmVal := int32(cpu.ReadRegister(rm))
 
 	//nolint:golint,dupword
-	fmt.Printf("smlal r%d, r%d, r%d, r%d\n", rdLo, rdHi, rm, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("smlal r%d, r%d, r%d, r%d", rdLo, rdHi, rm, rs)
+	}
 
 	var res int64 = int64(rsVal)*int64(rmVal) + accVal
 
@@ -1,19 +1,19 @@
 package arm
 
 import (
-	"fmt"
 	"math/bits"
 
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type MSR struct {
 	instruction uint32
 }
 
-func (m MSR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("PSR Transfer MSR")
+func (m MSR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("PSR Transfer MSR")
 	}
 	mask := uint32(0)
 	if c := m.instruction&(1<<16)>>16 == 1; c {
@@ -69,9 +69,9 @@ type MRS struct {
 	instruction uint32
 }
 
-func (m MRS) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	if cpu.GetConfig().Debug {
-		fmt.Println("PSR Transfer MRS")
+func (m MRS) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Trace("PSR Transfer MRS")
 	}
 
 	// Bits 15-12 are the destination register
@@ -83,7 +83,7 @@ func (m MRS) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	if spsr {
 		cpu.WriteRegister(rd, cpu.ReadSPSR())
 	} else {
-		cpu.WriteRegister(rd, cpu.ReadRegister(16))
+		cpu.WriteRegister(rd, cpu.ReadCPSR())
 	}
 	return
 }
@@ -0,0 +1,69 @@
+package arm
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+)
+
+type SWP struct {
+	instruction uint32
+}
+
+func (s SWP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	// Bits 19-16 are the base register
+	rn := uint8((s.instruction >> 16) & 0xF)
+	// Bits 15-12 are the destination register
+	rd := uint8((s.instruction >> 12) & 0xF)
+	// Bits 3-0 are the source register
+	rm := uint8(s.instruction & 0xF)
+
+	address := cpu.ReadRegister(rn)
+	source := cpu.ReadRegister(rm)
+
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("swp r%d, r%d, [r%d]  # 0x%08x", rd, rm, rn, address)
+	}
+
+	// A misaligned word is rotated like LDR, the store is aligned
+	value, err := cpu.GetBus().Read32(address)
+	if err != nil {
+		return repipeline, cycles, err
+	}
+	err = cpu.GetBus().Write32(address, source)
+	if err != nil {
+		return repipeline, cycles, err
+	}
+	cpu.WriteRegister(rd, value)
+	return
+}
+
+type SWPB struct {
+	instruction uint32
+}
+
+func (s SWPB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	// Bits 19-16 are the base register
+	rn := uint8((s.instruction >> 16) & 0xF)
+	// Bits 15-12 are the destination register
+	rd := uint8((s.instruction >> 12) & 0xF)
+	// Bits 3-0 are the source register
+	rm := uint8(s.instruction & 0xF)
+
+	address := cpu.ReadRegister(rn)
+	source := cpu.ReadRegister(rm)
+
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("swpb r%d, r%d, [r%d]  # 0x%08x", rd, rm, rn, address)
+	}
+
+	value, err := cpu.GetBus().Read8(address)
+	if err != nil {
+		return repipeline, cycles, err
+	}
+	err = cpu.GetBus().Write8(address, uint8(source))
+	if err != nil {
+		return repipeline, cycles, err
+	}
+	cpu.WriteRegister(rd, uint32(value))
+	return
+}
@@ -1,9 +1,7 @@
 package arm
 
 import (
-	"fmt"
-	"math"
-
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
 )
 
@@ -11,18 +9,10 @@ type SWI struct {
 	instruction uint32
 }
 
-func (s SWI) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	// Bits 23-0 are the comment field
-	comment := s.instruction & 0x00FFFFFF
-	// DIV BIOS call
-	if comment == 0x60000 {
-		numerator := cpu.ReadRegister(0)
-		denominator := cpu.ReadRegister(1)
-		cpu.WriteRegister(0, numerator/denominator)
-		cpu.WriteRegister(1, numerator%denominator)
-		cpu.WriteRegister(3, uint32(math.Abs(float64(numerator)/float64(denominator))))
-	} else {
-		panic(fmt.Sprintf("SWI: %d", comment))
-	}
+func (s SWI) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	// Bits 23-0 are the comment field, the BIOS takes the call number from
+	// bits 23-16. The call returns to the next instruction, PC is 8 ahead.
+	number := uint8(s.instruction >> 16)
+	repipeline, err = isa.SoftwareInterrupt(cpu, number, cpu.ReadPC()-4)
 	return
 }
@@ -0,0 +1,40 @@
+package arm
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+)
+
+// CPSR mode bits
+const (
+	undefinedMode = 0b11011
+	systemMode    = 0b11111
+)
+
+// undefinedVector is where the undefined instruction trap jumps to
+const undefinedVector = 0x00000004
+
+// Undefined is an instruction the ARM7TDMI has no meaning for, including
+// the coprocessor instructions, since the GBA has no coprocessors. It
+// traps to the undefined instruction vector in the BIOS.
+type Undefined struct {
+	instruction uint32
+}
+
+func (u Undefined) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	if logging.CPU.Enabled(logging.LevelDebug) {
+		logging.CPU.Debug("Undefined instruction trap", "opcode", logging.Hex(u.instruction), "pc", logging.Hex(cpu.ReadPC()-8))
+	}
+
+	// The trap returns to the instruction after this one, and PC is 8 ahead
+	ret := cpu.ReadPC() - 4
+	cpsr := cpu.ReadCPSR()
+
+	// Enter undefined mode in ARM state with IRQs disabled
+	cpu.WriteCPSR(cpsr&^0x3F | 1<<7 | undefinedMode)
+	cpu.WriteSPSR(cpsr)
+	cpu.WriteLR(ret)
+	cpu.WritePC(undefinedVector)
+	repipeline = true
+	return
+}
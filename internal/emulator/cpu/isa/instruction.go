@@ -3,5 +3,5 @@ package isa
 import "github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
 
 type Instruction interface {
-	Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16)
+	Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error)
 }
@@ -0,0 +1,174 @@
+package isa_test
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
+)
+
+const (
+	fiqMode        = 0b10001
+	irqMode        = 0b10010
+	supervisorMode = 0b10011
+	abortMode      = 0b10111
+	undefinedMode  = 0b11011
+)
+
+// mockCPU is a register file with the ARM7TDMI banking and no pipeline.
+// SPSR and the banked r13 and r14 are in the vector order: fiq, svc, abt,
+// irq, und.
+type mockCPU struct {
+	r      [16]uint32
+	fiq    [7]uint32
+	banked [5][2]uint32
+	cpsr   uint32
+	spsr   [5]uint32
+	bus    *mockBus
+}
+
+// bank is the index of the banked registers of mode, -1 for user and
+// system mode
+func bank(mode uint32) int {
+	switch mode {
+	case fiqMode:
+		return 0
+	case supervisorMode:
+		return 1
+	case abortMode:
+		return 2
+	case irqMode:
+		return 3
+	case undefinedMode:
+		return 4
+	}
+	return -1
+}
+
+func (m *mockCPU) reg(reg uint8) *uint32 {
+	b := bank(m.cpsr & 0x1F)
+	switch {
+	case b == 0 && reg >= 8 && reg <= 14:
+		return &m.fiq[reg-8]
+	case b > 0 && (reg == 13 || reg == 14):
+		return &m.banked[b][reg-13]
+	}
+	return &m.r[reg]
+}
+
+func (m *mockCPU) ReadRegister(reg uint8) uint32 { return *m.reg(reg) }
+func (m *mockCPU) WriteRegister(reg uint8, value uint32) {
+	if reg == 15 {
+		m.WritePC(value)
+		return
+	}
+	*m.reg(reg) = value
+}
+func (m *mockCPU) ReadHighRegister(reg uint8) uint32 { return *m.reg(reg + 8) }
+func (m *mockCPU) WriteHighRegister(reg uint8, value uint32) error {
+	if reg == 7 {
+		m.WritePC(value)
+		return nil
+	}
+	*m.reg(reg + 8) = value
+	return nil
+}
+func (m *mockCPU) ReadSP() uint32         { return *m.reg(13) }
+func (m *mockCPU) WriteSP(value uint32)   { *m.reg(13) = value }
+func (m *mockCPU) ReadLR() uint32         { return *m.reg(14) }
+func (m *mockCPU) WriteLR(value uint32)   { *m.reg(14) = value }
+func (m *mockCPU) ReadPC() uint32         { return m.r[15] }
+func (m *mockCPU) ReadCPSR() uint32       { return m.cpsr }
+func (m *mockCPU) WriteCPSR(value uint32) { m.cpsr = value }
+
+func (m *mockCPU) WritePC(value uint32) {
+	if m.GetThumbMode() {
+		m.r[15] = value &^ 1
+	} else {
+		m.r[15] = value &^ 3
+	}
+}
+
+// ReadSPSR reads the CPSR in user and system mode, which have no SPSR
+func (m *mockCPU) ReadSPSR() uint32 {
+	if b := bank(m.cpsr & 0x1F); b >= 0 {
+		return m.spsr[b]
+	}
+	return m.cpsr
+}
+
+func (m *mockCPU) WriteSPSR(value uint32) {
+	if b := bank(m.cpsr & 0x1F); b >= 0 {
+		m.spsr[b] = value
+	}
+}
+
+// FlushPipeline does nothing, the harness accounts for the refill itself
+func (m *mockCPU) FlushPipeline() error      { return nil }
+func (m *mockCPU) GetConfig() *config.Config { return &config.Config{} }
+func (m *mockCPU) GetBus() memory.Bus        { return m.bus }
+
+// HasBIOS is true, the vectors take SWIs through the BIOS like hardware
+func (m *mockCPU) HasBIOS() bool { return true }
+
+func (m *mockCPU) setFlag(bit uint, value bool) {
+	if value {
+		m.cpsr |= 1 << bit
+	} else {
+		m.cpsr &^= 1 << bit
+	}
+}
+
+func (m *mockCPU) SetN(value bool)         { m.setFlag(31, value) }
+func (m *mockCPU) SetZ(value bool)         { m.setFlag(30, value) }
+func (m *mockCPU) SetC(value bool)         { m.setFlag(29, value) }
+func (m *mockCPU) SetV(value bool)         { m.setFlag(28, value) }
+func (m *mockCPU) SetThumbMode(value bool) { m.setFlag(5, value) }
+func (m *mockCPU) GetN() bool              { return m.cpsr&(1<<31) != 0 }
+func (m *mockCPU) GetZ() bool              { return m.cpsr&(1<<30) != 0 }
+func (m *mockCPU) GetC() bool              { return m.cpsr&(1<<29) != 0 }
+func (m *mockCPU) GetV() bool              { return m.cpsr&(1<<28) != 0 }
+func (m *mockCPU) GetThumbMode() bool      { return m.cpsr&(1<<5) != 0 }
+
+// mockBus answers reads from the data reads of a vector, in order, and
+// records writes
+type mockBus struct {
+	reads  []transaction
+	writes []transaction
+}
+
+func (b *mockBus) read(addr uint32, size uint8) (uint32, error) {
+	aligned := addr &^ uint32(size-1)
+	for i, t := range b.reads {
+		if t.Size == size && t.Addr&^uint32(size-1) == aligned {
+			b.reads = append(b.reads[:i:i], b.reads[i+1:]...)
+			return t.Data, nil
+		}
+	}
+	return 0, fmt.Errorf("unexpected %d-bit read at 0x%08X", int(size)*8, addr)
+}
+
+func (b *mockBus) write(addr uint32, size uint8, data uint32) error {
+	b.writes = append(b.writes, transaction{Kind: dataWrite, Size: size, Addr: addr &^ uint32(size-1), Data: data})
+	return nil
+}
+
+func (b *mockBus) Read8(addr uint32) (uint8, error) {
+	v, err := b.read(addr, 1)
+	return uint8(v), err
+}
+
+func (b *mockBus) Read16(addr uint32) (uint16, error) {
+	v, err := b.read(addr, 2)
+	return uint16(v), err
+}
+
+func (b *mockBus) Read32(addr uint32) (uint32, error) {
+	v, err := b.read(addr, 4)
+	return bits.RotateLeft32(v, -int(addr&3)*8), err
+}
+
+func (b *mockBus) Write8(addr uint32, data uint8) error   { return b.write(addr, 1, uint32(data)) }
+func (b *mockBus) Write16(addr uint32, data uint16) error { return b.write(addr, 2, uint32(data)) }
+func (b *mockBus) Write32(addr uint32, data uint32) error { return b.write(addr, 4, data) }
@@ -0,0 +1,346 @@
+package isa_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/arm"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/thumb"
+)
+
+// Transaction kinds
+const (
+	instructionRead = 0
+	dataRead        = 1
+	dataWrite       = 2
+)
+
+// maxReported is how many failing vectors of a file are shown
+const maxReported = 10
+
+// vectorState is a CPU state in the ARM7TDMI single-step vectors. R holds
+// the user bank, the banked registers and SPSRs are per mode.
+type vectorState struct {
+	R    [16]uint32 `json:"R"`
+	RFIQ [7]uint32  `json:"R_fiq"`
+	RSVC [2]uint32  `json:"R_svc"`
+	RABT [2]uint32  `json:"R_abt"`
+	RIRQ [2]uint32  `json:"R_irq"`
+	RUND [2]uint32  `json:"R_und"`
+	CPSR uint32     `json:"CPSR"`
+	SPSR [5]uint32  `json:"SPSR"`
+}
+
+type transaction struct {
+	Kind  int    `json:"kind"`
+	Size  uint8  `json:"size"`
+	Addr  uint32 `json:"addr"`
+	Data  uint32 `json:"data"`
+	Cycle int    `json:"cycle"`
+}
+
+// vector runs one instruction from Initial and expects Final and the data
+// accesses in Transactions. R15 is the address of the instruction plus
+// two instructions, and moves on by one unless the instruction branches.
+type vector struct {
+	Initial      vectorState   `json:"initial"`
+	Final        vectorState   `json:"final"`
+	Transactions []transaction `json:"transactions"`
+	Opcode       uint32        `json:"opcode"`
+}
+
+// TestSingleStep runs the single-step vectors found under the directory in
+// GBA_SINGLESTEP_TESTS. Cycle timing is out of scope, N, S and I cycles
+// aren't modeled and every instruction takes one cycle. Only with
+// GBA_SINGLESTEP_CYCLES set are the counts checked, and most vectors that
+// touch the bus fail them.
+func TestSingleStep(t *testing.T) {
+	t.Parallel()
+	dir := os.Getenv("GBA_SINGLESTEP_TESTS")
+	if dir == "" {
+		t.Skip("set GBA_SINGLESTEP_TESTS to a directory holding single-step vectors")
+	}
+	cycles := os.Getenv("GBA_SINGLESTEP_CYCLES") != ""
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && (strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".json.gz")) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no vectors found in %s", dir)
+	}
+	for _, path := range files {
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".json")
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			vectors, err := loadVectors(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runVectors(t, vectors, cycles)
+		})
+	}
+}
+
+// TestSingleStepSubset runs testdata/vectors.json, a few vectors for every
+// ARM and THUMB format worked out by hand, so the decoders and the harness
+// are checked without the full vectors checked out
+func TestSingleStepSubset(t *testing.T) {
+	t.Parallel()
+	vectors, err := loadVectors(filepath.Join("testdata", "vectors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runVectors(t, vectors, false)
+}
+
+// TestHiRegisterLowOperands runs the hi register operations with both
+// operands low, which the ARM7TDMI executes as the low register forms
+func TestHiRegisterLowOperands(t *testing.T) {
+	t.Parallel()
+	initial := vectorState{R: [16]uint32{0: 2, 1: 3, 15: 0x08000004}, CPSR: 0x3F}
+	for _, tt := range []struct {
+		name   string
+		opcode uint32
+		r0     uint32
+		cpsr   uint32
+	}{
+		{"add r0, r1", 0x4408, 5, 0x3F},
+		{"cmp r0, r1", 0x4508, 2, 0x8000003F},
+		{"mov r0, r1", 0x4608, 3, 0x3F},
+	} {
+		final := initial
+		final.R[0], final.R[15], final.CPSR = tt.r0, 0x08000006, tt.cpsr
+		if problems := runVector(vector{Initial: initial, Final: final, Opcode: tt.opcode}, false); len(problems) > 0 {
+			t.Errorf("%s: %s", tt.name, strings.Join(problems, ", "))
+		}
+	}
+}
+
+// TestCompareHiRegisters checks every hi register form of cmp sets V
+func TestCompareHiRegisters(t *testing.T) {
+	t.Parallel()
+	initial := vectorState{R: [16]uint32{0: 0x80000000, 1: 1, 8: 0x80000000, 9: 1, 15: 0x08000004}, CPSR: 0x3F}
+	for _, tt := range []struct {
+		name   string
+		opcode uint32
+	}{
+		{"cmp r0, r9", 0x4548},
+		{"cmp r8, r1", 0x4588},
+		{"cmp r8, r9", 0x45C8},
+	} {
+		// 0x80000000 - 1 overflows to 0x7FFFFFFF without a borrow
+		final := initial
+		final.R[15], final.CPSR = 0x08000006, 0x3000003F
+		if problems := runVector(vector{Initial: initial, Final: final, Opcode: tt.opcode}, false); len(problems) > 0 {
+			t.Errorf("%s: %s", tt.name, strings.Join(problems, ", "))
+		}
+	}
+}
+
+func loadVectors(path string) ([]vector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	var vectors []vector
+	if err := json.NewDecoder(r).Decode(&vectors); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vectors, nil
+}
+
+func runVectors(t *testing.T, vectors []vector, cycles bool) {
+	t.Helper()
+	failed := 0
+	for i, v := range vectors {
+		problems := runVector(v, cycles)
+		if len(problems) == 0 {
+			continue
+		}
+		if failed < maxReported {
+			t.Errorf("vector %d, opcode 0x%08X: %s", i, v.Opcode, strings.Join(problems, ", "))
+		}
+		failed++
+	}
+	if failed > maxReported {
+		t.Errorf("%d of %d vectors failed", failed, len(vectors))
+	}
+}
+
+// runVector executes the opcode of v on a mock CPU and lists everything
+// that doesn't match the final state
+func runVector(v vector, checkCycles bool) []string {
+	m := newMockCPU(v)
+	instructionSize := func() uint32 {
+		if m.GetThumbMode() {
+			return 2
+		}
+		return 4
+	}
+
+	var execute func() (bool, uint16, error)
+	switch {
+	case m.GetThumbMode():
+		if handler := thumb.Lookup(uint16(v.Opcode)); handler != nil {
+			execute = func() (bool, uint16, error) { return handler(m, uint16(v.Opcode)) }
+		}
+	case arm.ConditionPassed(v.Opcode>>28, m.cpsr):
+		if handler := arm.Lookup(v.Opcode); handler != nil {
+			execute = func() (bool, uint16, error) { return handler(m, v.Opcode) }
+		}
+	default:
+		// The condition failed, only PC moves on
+		execute = func() (bool, uint16, error) { return false, 0, nil }
+	}
+	if execute == nil {
+		return []string{"not decoded"}
+	}
+
+	pc := m.r[15]
+	repipeline, extra, err := execute()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	cycles := 1 + int(extra)
+	if repipeline || m.r[15] != pc {
+		// The refill fetches the target and the instruction after it
+		m.r[15] += instructionSize() * 2
+	} else {
+		m.r[15] += instructionSize()
+	}
+
+	problems := diffState(m.state(), v.Final)
+	problems = append(problems, diffWrites(m.bus.writes, v.Transactions)...)
+	for _, t := range m.bus.reads {
+		problems = append(problems, fmt.Sprintf("missing %d-bit read at 0x%08X", int(t.Size)*8, t.Addr))
+	}
+	if want := vectorCycles(v); checkCycles && want > 0 && cycles != want {
+		problems = append(problems, fmt.Sprintf("%d cycles, want %d", cycles, want))
+	}
+	return problems
+}
+
+func newMockCPU(v vector) *mockCPU {
+	s := v.Initial
+	m := &mockCPU{r: s.R, fiq: s.RFIQ, cpsr: s.CPSR, spsr: s.SPSR, bus: &mockBus{}}
+	m.banked[1], m.banked[2], m.banked[3], m.banked[4] = s.RSVC, s.RABT, s.RIRQ, s.RUND
+	for _, t := range v.Transactions {
+		if t.Kind == dataRead {
+			m.bus.reads = append(m.bus.reads, t)
+		}
+	}
+	return m
+}
+
+func (m *mockCPU) state() vectorState {
+	return vectorState{
+		R:    m.r,
+		RFIQ: m.fiq,
+		RSVC: m.banked[1],
+		RABT: m.banked[2],
+		RIRQ: m.banked[3],
+		RUND: m.banked[4],
+		CPSR: m.cpsr,
+		SPSR: m.spsr,
+	}
+}
+
+var bankNames = [5]string{"fiq", "svc", "abt", "irq", "und"}
+
+func diffState(got, want vectorState) []string {
+	var problems []string
+	check := func(name string, got, want uint32) {
+		if got != want {
+			problems = append(problems, fmt.Sprintf("%s = 0x%08X, want 0x%08X", name, got, want))
+		}
+	}
+	for i := range got.R {
+		check(fmt.Sprintf("r%d", i), got.R[i], want.R[i])
+	}
+	for i := range got.RFIQ {
+		check(fmt.Sprintf("r%d_fiq", i+8), got.RFIQ[i], want.RFIQ[i])
+	}
+	gotBanks := [][2]uint32{got.RSVC, got.RABT, got.RIRQ, got.RUND}
+	wantBanks := [][2]uint32{want.RSVC, want.RABT, want.RIRQ, want.RUND}
+	for b := range gotBanks {
+		for i := range gotBanks[b] {
+			check(fmt.Sprintf("r%d_%s", i+13, bankNames[b+1]), gotBanks[b][i], wantBanks[b][i])
+		}
+	}
+	check("cpsr", got.CPSR, want.CPSR)
+	for i := range got.SPSR {
+		check("spsr_"+bankNames[i], got.SPSR[i], want.SPSR[i])
+	}
+	return problems
+}
+
+// diffWrites compares the writes made with the writes in transactions, in
+// order
+func diffWrites(got []transaction, transactions []transaction) []string {
+	var want []transaction
+	for _, t := range transactions {
+		if t.Kind == dataWrite {
+			want = append(want, t)
+		}
+	}
+	var problems []string
+	for i := 0; i < len(got) || i < len(want); i++ {
+		switch {
+		case i >= len(want):
+			problems = append(problems, fmt.Sprintf("unexpected %d-bit write of 0x%08X at 0x%08X", int(got[i].Size)*8, got[i].Data, got[i].Addr))
+		case i >= len(got):
+			problems = append(problems, fmt.Sprintf("missing %d-bit write of 0x%08X at 0x%08X", int(want[i].Size)*8, want[i].Data, want[i].Addr))
+		default:
+			g, w := got[i], want[i]
+			mask := uint32(1)<<(8*uint32(w.Size)) - 1
+			if w.Size == 4 {
+				mask = 0xFFFFFFFF
+			}
+			if g.Size != w.Size || g.Addr != w.Addr&^uint32(w.Size-1) || g.Data&mask != w.Data&mask {
+				problems = append(problems, fmt.Sprintf("%d-bit write of 0x%08X at 0x%08X, want %d-bit write of 0x%08X at 0x%08X",
+					int(g.Size)*8, g.Data, g.Addr, int(w.Size)*8, w.Data, w.Addr))
+			}
+		}
+	}
+	return problems
+}
+
+// vectorCycles is the span of the bus cycles used by v, 0 if it has no
+// transactions
+func vectorCycles(v vector) int {
+	if len(v.Transactions) == 0 {
+		return 0
+	}
+	first, last := v.Transactions[0].Cycle, v.Transactions[0].Cycle
+	for _, t := range v.Transactions {
+		first = min(first, t.Cycle)
+		last = max(last, t.Cycle)
+	}
+	return last - first + 1
+}
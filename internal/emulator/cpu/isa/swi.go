@@ -0,0 +1,68 @@
+package isa
+
+import (
+	"fmt"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+)
+
+// supervisorMode is the CPSR mode software interrupts enter
+const supervisorMode = 0b10011
+
+// swiVector is where software interrupts jump to in the BIOS
+const swiVector = 0x00000008
+
+// haltcnt is the address of HALTCNT
+const haltcnt = 0x04000301
+
+// ErrSWI is returned by a BIOS call that can't run without a BIOS
+type ErrSWI struct {
+	Number uint8
+	Reason string
+}
+
+func (e *ErrSWI) Error() string {
+	return fmt.Sprintf("SWI 0x%02X %s", e.Number, e.Reason)
+}
+
+// SoftwareInterrupt makes BIOS call number, returning to ret. With a BIOS
+// it enters supervisor mode through the SWI vector, without one the few
+// calls go-gba knows are done in its place.
+func SoftwareInterrupt(cpu interfaces.CPU, number uint8, ret uint32) (repipeline bool, err error) {
+	if cpu.HasBIOS() {
+		cpsr := cpu.ReadCPSR()
+		// Enter supervisor mode in ARM state with IRQs disabled
+		cpu.WriteCPSR(cpsr&^0x3F | 1<<7 | supervisorMode)
+		cpu.WriteSPSR(cpsr)
+		cpu.WriteLR(ret)
+		cpu.WritePC(swiVector)
+		return true, nil
+	}
+
+	switch number {
+	case 0x02:
+		// Halt, which halts through HALTCNT
+		err = cpu.GetBus().Write8(haltcnt, 0)
+	case 0x03:
+		// Stop
+		err = cpu.GetBus().Write8(haltcnt, 0x80)
+	case 0x06:
+		// Div, a signed division
+		numerator := int32(cpu.ReadRegister(0))
+		denominator := int32(cpu.ReadRegister(1))
+		if denominator == 0 {
+			// The BIOS never returns from a division by zero
+			return false, &ErrSWI{Number: number, Reason: "divides by zero"}
+		}
+		quotient := numerator / denominator
+		cpu.WriteRegister(0, uint32(quotient))
+		cpu.WriteRegister(1, uint32(numerator%denominator))
+		if quotient < 0 {
+			quotient = -quotient
+		}
+		cpu.WriteRegister(3, uint32(quotient))
+	default:
+		err = &ErrSWI{Number: number, Reason: "needs a BIOS"}
+	}
+	return
+}
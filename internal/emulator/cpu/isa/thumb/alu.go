@@ -1,24 +1,23 @@
 package thumb
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type AND struct {
 	instruction uint16
 }
 
-func (a AND) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (a AND) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(a.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
 	// Bits 2-0 are the destination register
 	rd := uint8(a.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	if cpu.GetConfig().Debug {
-		fmt.Printf("and r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("and r%d, r%d", rd, rs)
 	}
 
 	res := cpu.ReadRegister(rd) & cpu.ReadRegister(rs)
@@ -35,16 +34,16 @@ type EOR struct {
 	instruction uint16
 }
 
-func (e EOR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("EOR")
-
+func (e EOR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(e.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
 	// Bits 2-0 are the destination register
 	rd := uint8(e.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("eor r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("eor r%d, r%d", rd, rs)
+	}
 
 	res := cpu.ReadRegister(rd) ^ cpu.ReadRegister(rs)
 
@@ -60,9 +59,7 @@ type LSL struct {
 	instruction uint16
 }
 
-func (l LSL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LSL")
-
+func (l LSL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(l.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -71,7 +68,9 @@ func (l LSL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 	rdVal := cpu.ReadRegister(rd)
 
-	fmt.Printf("lsl r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("lsl r%d, r%d", rd, rs)
+	}
 
 	res := rdVal << rsVal
 
@@ -91,9 +90,7 @@ type LSR struct {
 	instruction uint16
 }
 
-func (l LSR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LSR")
-
+func (l LSR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(l.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -102,7 +99,9 @@ func (l LSR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 	rdVal := cpu.ReadRegister(rd)
 
-	fmt.Printf("lsr r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("lsr r%d, r%d", rd, rs)
+	}
 
 	res := rdVal >> rsVal
 
@@ -123,9 +122,7 @@ type ASR struct {
 	instruction uint16
 }
 
-func (a ASR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ASR")
-
+func (a ASR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(a.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -134,7 +131,9 @@ func (a ASR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(a.instruction & (1<<2 | 1<<1 | 1<<0))
 	rdVal := cpu.ReadRegister(rd)
 
-	fmt.Printf("asr r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("asr r%d, r%d", rd, rs)
+	}
 
 	msb := rdVal & 0x8000_0000
 	res := rdVal
@@ -158,9 +157,7 @@ type ADC struct {
 	instruction uint16
 }
 
-func (a ADC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ADC")
-
+func (a ADC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(a.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -169,7 +166,9 @@ func (a ADC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(a.instruction & (1<<2 | 1<<1 | 1<<0))
 	rdVal := cpu.ReadRegister(rd)
 
-	fmt.Printf("adc r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("adc r%d, r%d", rd, rs)
+	}
 
 	res := rdVal + rsVal
 	if cpu.GetC() {
@@ -192,9 +191,7 @@ type SBC struct {
 	instruction uint16
 }
 
-func (s SBC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("SBC")
-
+func (s SBC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(s.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -203,7 +200,9 @@ func (s SBC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(s.instruction & (1<<2 | 1<<1 | 1<<0))
 	rdVal := cpu.ReadRegister(rd)
 
-	fmt.Printf("sbc r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("sbc r%d, r%d", rd, rs)
+	}
 
 	diff := rdVal - rsVal
 	if !cpu.GetC() {
@@ -228,16 +227,16 @@ type ROR struct {
 	instruction uint16
 }
 
-func (r ROR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ROR")
-
+func (r ROR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(r.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
 	// Bits 2-0 are the destination register
 	rd := uint8(r.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("ror r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ror r%d, r%d", rd, rs)
+	}
 
 	// Rotate rs right by the value in rd
 	// Then set the carry flag to the last bit we rotated out of rs
@@ -267,16 +266,16 @@ type TST struct {
 	instruction uint16
 }
 
-func (t TST) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("TST")
-
+func (t TST) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(t.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
 	// Bits 2-0 are the destination register
 	rd := uint8(t.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("tst r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("tst r%d, r%d", rd, rs)
+	}
 
 	// TST performs a bitwise AND on the two registers, but does not store the result
 	// It only updates the status register
@@ -293,9 +292,7 @@ type NEG struct {
 	instruction uint16
 }
 
-func (n NEG) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("NEG")
-
+func (n NEG) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(n.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -303,7 +300,9 @@ func (n NEG) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination register
 	rd := uint8(n.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("neg r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("neg r%d, r%d", rd, rs)
+	}
 
 	// Reverse subtract 0 from Rn
 	res := 0 - rsVal
@@ -326,9 +325,7 @@ type CMPALU struct {
 	instruction uint16
 }
 
-func (c CMPALU) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("CMP")
-
+func (c CMPALU) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(c.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -337,30 +334,34 @@ func (c CMPALU) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(c.instruction & (1<<2 | 1<<1 | 1<<0))
 	rdVal := cpu.ReadRegister(rd)
 
-	fmt.Printf("cmp r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("cmp r%d, r%d", rd, rs)
+	}
 
-	res := rdVal - rsVal
+	setCompareFlags(cpu, rdVal, rsVal)
+	return
+}
+
+// setCompareFlags sets the flags of a - b like every form of cmp does
+func setCompareFlags(cpu interfaces.CPU, a, b uint32) {
+	res := a - b
 
 	// Set carry flag if the subtraction would make a positive number.
-	carry := rdVal >= rsVal
+	carry := a >= b
 
 	// Set overflow flag if the subtraction would overflow.
-	overflow := (rdVal^rsVal)>>31 == 1 && (rdVal^res)>>31 == 1
+	overflow := (a^b)>>31 == 1 && (a^res)>>31 == 1
 	cpu.SetN(res&(1<<31)>>31 != 0)
 	cpu.SetZ(res == 0)
 	cpu.SetV(overflow)
 	cpu.SetC(carry)
-
-	return
 }
 
 type CMN struct {
 	instruction uint16
 }
 
-func (c CMN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("CMN")
-
+func (c CMN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(c.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 	rsVal := cpu.ReadRegister(rs)
@@ -369,7 +370,9 @@ func (c CMN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(c.instruction & (1<<2 | 1<<1 | 1<<0))
 	rdVal := cpu.ReadRegister(rd)
 
-	fmt.Printf("cmn r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("cmn r%d, r%d", rd, rs)
+	}
 
 	res := rdVal + rsVal
 
@@ -387,9 +390,7 @@ type ORR struct {
 	instruction uint16
 }
 
-func (o ORR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ORR")
-
+func (o ORR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(o.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
@@ -401,7 +402,9 @@ func (o ORR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	res := rdVal | rsVal
 	cpu.WriteRegister(rd, res)
 
-	fmt.Printf("orr r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("orr r%d, r%d", rd, rs)
+	}
 
 	// update the status registers
 	cpu.SetZ(res == 0)
@@ -413,16 +416,16 @@ type MUL struct {
 	instruction uint16
 }
 
-func (m MUL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("MUL")
-
+func (m MUL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(m.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
 	// Bits 2-0 are the destination register
 	rd := uint8(m.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("mul r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("mul r%d, r%d", rd, rs)
+	}
 
 	res := cpu.ReadRegister(rd) * cpu.ReadRegister(rs)
 	cpu.WriteRegister(rd, res)
@@ -438,16 +441,16 @@ type BIC struct {
 	instruction uint16
 }
 
-func (b BIC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("BIC")
-
+func (b BIC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(b.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
 	// Bits 2-0 are the destination register
 	rd := uint8(b.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("bic r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("bic r%d, r%d", rd, rs)
+	}
 
 	res := cpu.ReadRegister(rd) &^ cpu.ReadRegister(rs)
 	cpu.WriteRegister(rd, res)
@@ -463,16 +466,16 @@ type MVN struct {
 	instruction uint16
 }
 
-func (m MVN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("MVN")
-
+func (m MVN) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 5-3 are the source register
 	rs := uint8(m.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
 	// Bits 2-0 are the destination register
 	rd := uint8(m.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("mvns r%d, r%d\n", rd, rs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("mvns r%d, r%d", rd, rs)
+	}
 
 	// Store the bitwise inverse of the source register in the destination register
 	cpu.WriteRegister(rd, ^cpu.ReadRegister(rs))
@@ -487,9 +490,7 @@ type LSLMoveShifted struct {
 	instruction uint16
 }
 
-func (l LSLMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LSL")
-
+func (l LSLMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint8(l.instruction & (1<<10 | 1<<9 | 1<<8 | 1<<7 | 1<<6) >> 6)
 
@@ -501,7 +502,9 @@ func (l LSLMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uin
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 
 	//nolint:golint,dupword
-	fmt.Printf("lsls r%d, r%d, #0x%X\n", rd, rs, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("lsls r%d, r%d, #0x%X", rd, rs, offset)
+	}
 
 	// Shift the source register left by the offset and store the result in the destination register
 	res := rsVal << offset
@@ -523,9 +526,7 @@ type LSRMoveShifted struct {
 	instruction uint16
 }
 
-func (l LSRMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LSR")
-
+func (l LSRMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint8(l.instruction & (1<<10 | 1<<9 | 1<<8 | 1<<7 | 1<<6) >> 6)
 	if offset == 0 {
@@ -540,7 +541,9 @@ func (l LSRMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uin
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 
 	//nolint:golint,dupword
-	fmt.Printf("lsrs r%d, r%d, #0x%X\n", rd, rs, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("lsrs r%d, r%d, #0x%X", rd, rs, offset)
+	}
 
 	res := rsVal >> offset
 
@@ -562,9 +565,7 @@ type ASRMoveShifted struct {
 	instruction uint16
 }
 
-func (a ASRMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ASR")
-
+func (a ASRMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint8(a.instruction & (1<<10 | 1<<9 | 1<<8 | 1<<7 | 1<<6) >> 6)
 	if offset == 0 || offset > 32 {
@@ -579,7 +580,9 @@ func (a ASRMoveShifted) Execute(cpu interfaces.CPU) (repipeline bool, cycles uin
 	rd := uint8(a.instruction & (1<<2 | 1<<1 | 1<<0))
 
 	//nolint:golint,dupword
-	fmt.Printf("asrs r%d, r%d, #0x%X\n", rd, rs, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("asrs r%d, r%d, #0x%X", rd, rs, offset)
+	}
 
 	msb := rsVal & 0x8000_0000
 	res := rsVal
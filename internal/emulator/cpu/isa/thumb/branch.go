@@ -1,25 +1,24 @@
 package thumb
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type UnconditionalBranch struct {
 	instruction uint16
 }
 
-func (u UnconditionalBranch) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("UnconditionalBranch")
-
+func (u UnconditionalBranch) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-0 are the offset
 	offset := int32(u.instruction & 0b11111111111)
 	offset <<= 21
 	offset >>= 20
 
-	fmt.Printf("Offset: %d\n", offset)
-	fmt.Printf("Address: 0x%08X\n", cpu.ReadPC()+uint32(offset))
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Offset: %d", offset)
+		logging.CPU.Tracef("Address: 0x%08X", cpu.ReadPC()+uint32(offset))
+	}
 	cpu.WritePC(cpu.ReadPC() + uint32(offset))
 	return
 }
@@ -28,13 +27,13 @@ type B struct {
 	instruction uint16
 }
 
-func (a B) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("B")
-	fmt.Println("ConditionalBranch")
+func (a B) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 11-8 are the condition
 	cond := a.instruction & (1<<11 | 1<<10 | 1<<9 | 1<<8) >> 8
 
-	fmt.Printf("Condition: 0b%04b\n", cond)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Condition: 0b%04b", cond)
+	}
 
 	// Bits 7-0 are the 8-bit signed offset
 	offset := int8(a.instruction&0xFF) << 1
@@ -78,7 +77,7 @@ func (a B) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		}
 		cpu.WritePC(cpu.ReadPC() + uint32(offset))
 	} else {
-		fmt.Println("Branch condition not met")
+		logging.CPU.Trace("Branch condition not met")
 	}
 	return
 }
@@ -87,18 +86,17 @@ type BX struct {
 	instruction uint16
 }
 
-func (a BX) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("BX")
-
+func (a BX) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 7-6 are the hi operand flags
 	rshs := uint8(a.instruction & (1<<5 | 1<<4 | 1<<3) >> 3)
 
-	fmt.Printf("BLUG bx r%d\n", rshs)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("BLUG bx r%d", rshs)
+	}
 
 	addr := cpu.ReadRegister(rshs)
 
 	if addr&1 != 1 {
-		fmt.Println("ARM mode")
 		cpu.SetThumbMode(false)
 	}
 
@@ -112,9 +110,7 @@ type LBL struct {
 	instruction uint16
 }
 
-func (a LBL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LBL")
-
+func (a LBL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 11 == 1 is low offset
 	low := a.instruction&(1<<11)>>11 == 1
 
@@ -123,7 +119,6 @@ func (a LBL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 
 	if low {
 		offset <<= 1
-		fmt.Println("bl Low offset")
 		// Take the LR
 		lr := cpu.ReadLR()
 		// Write the current PC to the LR
@@ -136,8 +131,9 @@ func (a LBL) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	} else {
 		offset <<= 12
 		signedOffset := int16(offset)
-		fmt.Println("bl High offset")
-		fmt.Printf("Adding %d to PC (%08X)\n", signedOffset, cpu.ReadPC())
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Adding %d to PC (%08X)", signedOffset, cpu.ReadPC())
+		}
 		newPC := cpu.ReadPC() + uint32(signedOffset)
 		// Add the offset to the PC and store it in LR
 		cpu.WriteLR(newPC)
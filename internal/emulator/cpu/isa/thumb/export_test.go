@@ -0,0 +1,4 @@
+package thumb
+
+// HandlerFor lets the tests compare the lookup table with DecodeInstruction
+var HandlerFor = handlerFor
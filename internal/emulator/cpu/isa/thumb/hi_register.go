@@ -1,18 +1,15 @@
 package thumb
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type ADDH struct {
 	instruction uint16
 }
 
-func (a ADDH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ADDH")
-
+func (a ADDH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 7-6 are the hi operand flags
 	hof := a.instruction & (1<<7 | 1<<6) >> 6
 	// Bits 5-3 are the source register
@@ -21,23 +18,34 @@ func (a ADDH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(a.instruction & (1<<2 | 1<<1 | 1<<0))
 
 	switch hof {
+	case 0b00:
+		// add Rd, Rs, which the ARM7TDMI runs like the other forms without
+		// touching the flags
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("add r%d, r%d", rd, rs)
+		}
+		cpu.WriteRegister(rd, cpu.ReadRegister(rd)+cpu.ReadRegister(rs))
 	case 0b01:
 		// add Rd, Hs
-		fmt.Printf("add r%d, r%d\n", rd, rs+8)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("add r%d, r%d", rd, rs+8)
+		}
 		res := cpu.ReadRegister(rd) + cpu.ReadHighRegister(rs)
 		cpu.WriteRegister(rd, res)
 	case 0b10:
 		// add Hd, Rs
-		fmt.Printf("add r%d, r%d\n", rd+8, rs)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("add r%d, r%d", rd+8, rs)
+		}
 		res := cpu.ReadHighRegister(rd) + cpu.ReadRegister(rs)
-		cpu.WriteHighRegister(rd, res)
+		err = cpu.WriteHighRegister(rd, res)
 	case 0b11:
 		// add Hd, Hs
-		fmt.Printf("add r%d, r%d\n", rd+8, rs+8)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("add r%d, r%d", rd+8, rs+8)
+		}
 		res := cpu.ReadHighRegister(rd) + cpu.ReadHighRegister(rs)
-		cpu.WriteHighRegister(rd, res)
-	default:
-		panic("Invalid hi operand flags")
+		err = cpu.WriteHighRegister(rd, res)
 	}
 
 	return
@@ -47,9 +55,7 @@ type CMPH struct {
 	instruction uint16
 }
 
-func (c CMPH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("CMPH")
-
+func (c CMPH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 7-6 are the hi operand flags
 	hof := c.instruction & (1<<7 | 1<<6) >> 6
 	// Bits 5-3 are the source register
@@ -58,31 +64,27 @@ func (c CMPH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(c.instruction & (1<<2 | 1<<1 | 1<<0))
 
 	switch hof {
+	case 0b00:
+		// cmp Rd, Rs, the same as the ALU operation
+		return CMPALU(c).Execute(cpu)
 	case 0b01:
 		// cmp Rd, Hs
-		fmt.Printf("cmp r%d, r%d\n", rd, rs+8)
-		res := cpu.ReadRegister(rd) - cpu.ReadHighRegister(rs)
-		cpu.SetZ(res == 0)
-		cpu.SetN(res&(1<<31)>>31 != 0)
-		cpu.SetC(cpu.ReadRegister(rd) >= cpu.ReadHighRegister(rs))
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("cmp r%d, r%d", rd, rs+8)
+		}
+		setCompareFlags(cpu, cpu.ReadRegister(rd), cpu.ReadHighRegister(rs))
 	case 0b10:
 		// cmp Hd, Rs
-		fmt.Printf("cmp r%d, r%d\n", rd+8, rs)
-		res := cpu.ReadHighRegister(rd) - cpu.ReadRegister(rs)
-		fmt.Printf("res: %08X\n", res)
-		cpu.SetZ(res == 0)
-		cpu.SetN(res&(1<<31)>>31 != 0)
-		cpu.SetC(cpu.ReadHighRegister(rd) >= cpu.ReadRegister(rs))
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("cmp r%d, r%d", rd+8, rs)
+		}
+		setCompareFlags(cpu, cpu.ReadHighRegister(rd), cpu.ReadRegister(rs))
 	case 0b11:
 		// cmp Hd, Hs
-		fmt.Printf("cmp r%d, r%d\n", rd+8, rs+8)
-		res := cpu.ReadHighRegister(rd) - cpu.ReadHighRegister(rs)
-		fmt.Printf("res: %08X\n", res)
-		cpu.SetZ(res == 0)
-		cpu.SetN(res&(1<<31)>>31 != 0)
-		cpu.SetC(cpu.ReadHighRegister(rd) >= cpu.ReadHighRegister(rs))
-	default:
-		panic("Invalid hi operand flag")
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("cmp r%d, r%d", rd+8, rs+8)
+		}
+		setCompareFlags(cpu, cpu.ReadHighRegister(rd), cpu.ReadHighRegister(rs))
 	}
 
 	return
@@ -92,9 +94,7 @@ type MOVH struct {
 	instruction uint16
 }
 
-func (m MOVH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("MOVH")
-
+func (m MOVH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 7-6 are the hi operand flags
 	hof := m.instruction & (1<<7 | 1<<6) >> 6
 	// Bits 5-3 are the source register
@@ -103,20 +103,31 @@ func (m MOVH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	rd := uint8(m.instruction & (1<<2 | 1<<1 | 1<<0))
 
 	switch hof {
+	case 0b00:
+		// move low register source to low register destination, leaving
+		// the flags alone unlike the ALU form
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("mov r%d, r%d", rd, rs)
+		}
+		cpu.WriteRegister(rd, cpu.ReadRegister(rs))
 	case 0b01:
 		// move hi register source to low register destination
-		fmt.Printf("mov r%d, r%d\n", rd, rs+8)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("mov r%d, r%d", rd, rs+8)
+		}
 		cpu.WriteRegister(rd, cpu.ReadHighRegister(rs))
 	case 0b10:
 		// move low register source to hi register destination
-		fmt.Printf("mov r%d, r%d\n", rd+8, rs)
-		cpu.WriteHighRegister(rd, cpu.ReadRegister(rs))
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("mov r%d, r%d", rd+8, rs)
+		}
+		err = cpu.WriteHighRegister(rd, cpu.ReadRegister(rs))
 	case 0b11:
 		// move hi register source to hi register destination
-		fmt.Printf("mov r%d, r%d\n", rd+8, rs+8)
-		cpu.WriteHighRegister(rd, cpu.ReadHighRegister(rs))
-	default:
-		panic("Invalid hi operand flag")
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("mov r%d, r%d", rd+8, rs+8)
+		}
+		err = cpu.WriteHighRegister(rd, cpu.ReadHighRegister(rs))
 	}
 
 	return
@@ -1,23 +1,24 @@
 package thumb
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type ADDSP struct {
 	instruction uint16
 }
 
-func (a ADDSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (a ADDSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(a.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 
 	// Bits 7-0 are the immediate value
 	imm := (a.instruction & 0xFF) << 2
 
-	fmt.Printf("add r%d, sp, #0x%X\n", rd, imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("add r%d, sp, #0x%X", rd, imm)
+	}
 
 	cpu.WriteRegister(rd, cpu.ReadSP()+uint32(imm))
 
@@ -28,14 +29,16 @@ type ADDPC struct {
 	instruction uint16
 }
 
-func (a ADDPC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (a ADDPC) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(a.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 
 	// Bits 7-0 are the immediate value
 	imm := (a.instruction & 0xFF) << 2
 
-	fmt.Printf("ADDPC add r%d, pc, #0x%X\n", rd, imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ADDPC add r%d, pc, #0x%X", rd, imm)
+	}
 
 	cpu.WriteRegister(rd, (cpu.ReadPC()&0xFFFFFFFC)+uint32(imm))
 
@@ -0,0 +1,159 @@
+package thumb
+
+import (
+	"fmt"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+)
+
+// Handler executes a THUMB instruction
+type Handler func(cpu interfaces.CPU, instruction uint16) (repipeline bool, cycles uint16, err error)
+
+// handlers is indexed by the top 10 bits of an instruction, which tell
+// every THUMB instruction apart. Instructions that don't decode have no
+// handler.
+//
+//nolint:golint,gochecknoglobals
+var handlers [1024]Handler
+
+func init() {
+	for i := range handlers {
+		handlers[i] = handlerFor(DecodeInstruction(uint16(i) << 6))
+	}
+}
+
+// Lookup returns the handler that executes instruction, nil if it doesn't
+// decode
+func Lookup(instruction uint16) Handler {
+	return handlers[instruction>>6]
+}
+
+// decoded is any THUMB instruction type
+type decoded interface {
+	~struct{ instruction uint16 }
+	isa.Instruction
+}
+
+func execute[T decoded](cpu interfaces.CPU, instruction uint16) (bool, uint16, error) {
+	return T{instruction}.Execute(cpu)
+}
+
+//nolint:golint,gocyclo
+func handlerFor(instruction isa.Instruction) Handler {
+	switch instruction.(type) {
+	case AND:
+		return execute[AND]
+	case EOR:
+		return execute[EOR]
+	case LSL:
+		return execute[LSL]
+	case LSR:
+		return execute[LSR]
+	case ASR:
+		return execute[ASR]
+	case ADC:
+		return execute[ADC]
+	case SBC:
+		return execute[SBC]
+	case ROR:
+		return execute[ROR]
+	case TST:
+		return execute[TST]
+	case NEG:
+		return execute[NEG]
+	case CMPALU:
+		return execute[CMPALU]
+	case CMN:
+		return execute[CMN]
+	case ORR:
+		return execute[ORR]
+	case MUL:
+		return execute[MUL]
+	case BIC:
+		return execute[BIC]
+	case MVN:
+		return execute[MVN]
+	case LSLMoveShifted:
+		return execute[LSLMoveShifted]
+	case LSRMoveShifted:
+		return execute[LSRMoveShifted]
+	case ASRMoveShifted:
+		return execute[ASRMoveShifted]
+	case UnconditionalBranch:
+		return execute[UnconditionalBranch]
+	case B:
+		return execute[B]
+	case BX:
+		return execute[BX]
+	case LBL:
+		return execute[LBL]
+	case SWI:
+		return execute[SWI]
+	case ADDH:
+		return execute[ADDH]
+	case CMPH:
+		return execute[CMPH]
+	case MOVH:
+		return execute[MOVH]
+	case ADDSP:
+		return execute[ADDSP]
+	case ADDPC:
+		return execute[ADDPC]
+	case MOV:
+		return execute[MOV]
+	case CMP:
+		return execute[CMP]
+	case ADD:
+		return execute[ADD]
+	case SUB:
+		return execute[SUB]
+	case ADD2:
+		return execute[ADD2]
+	case SUB2:
+		return execute[SUB2]
+	case SUBSP:
+		return execute[SUBSP]
+	case PUSH:
+		return execute[PUSH]
+	case POP:
+		return execute[POP]
+	case LDR:
+		return execute[LDR]
+	case LDRR:
+		return execute[LDRR]
+	case STRR:
+		return execute[STRR]
+	case STRSP:
+		return execute[STRSP]
+	case LDRSP:
+		return execute[LDRSP]
+	case LDRH:
+		return execute[LDRH]
+	case STRH:
+		return execute[STRH]
+	case LDRBImm:
+		return execute[LDRBImm]
+	case STRBImm:
+		return execute[STRBImm]
+	case LDRWImm:
+		return execute[LDRWImm]
+	case STRWImm:
+		return execute[STRWImm]
+	case LDMIA:
+		return execute[LDMIA]
+	case STMIA:
+		return execute[STMIA]
+	case STRNSH:
+		return execute[STRNSH]
+	case LDRNSH:
+		return execute[LDRNSH]
+	case LDRSB:
+		return execute[LDRSB]
+	case LDRSH:
+		return execute[LDRSH]
+	case nil:
+		return nil
+	}
+	panic(fmt.Sprintf("no handler for %T", instruction))
+}
@@ -0,0 +1,66 @@
+package thumb_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa/thumb"
+)
+
+// opcodes is a mix of the instructions games run most
+//
+//nolint:golint,gochecknoglobals
+var opcodes = []uint16{
+	0x2001, // mov r0, #1
+	0x1888, // add r0, r1, r2
+	0x4288, // cmp r0, r1
+	0x0048, // lsl r0, r1, #1
+	0x6808, // ldr r0, [r1]
+	0x6008, // str r0, [r1]
+	0x8808, // ldrh r0, [r1]
+	0x4800, // ldr r0, [pc]
+	0xB500, // push {lr}
+	0xBD00, // pop {pc}
+	0xD000, // beq
+	0xE000, // b
+	0xF000, // bl, first half
+	0x4700, // bx r0
+	0x4348, // mul r0, r1
+	0xC806, // ldmia r0!, {r1, r2}
+}
+
+// TestLookupMatchesDecodeInstruction checks every THUMB instruction, the
+// table skips no bit that DecodeInstruction looks at
+func TestLookupMatchesDecodeInstruction(t *testing.T) {
+	t.Parallel()
+	for i := 0; i < 0x10000; i++ {
+		instruction := uint16(i)
+		decoded := thumb.DecodeInstruction(instruction)
+		if !sameHandler(thumb.Lookup(instruction), thumb.HandlerFor(decoded)) {
+			t.Fatalf("0x%04X: lookup disagrees with DecodeInstruction, which returns %T", instruction, decoded)
+		}
+	}
+}
+
+func sameHandler(a, b thumb.Handler) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+//nolint:golint,gochecknoglobals
+var (
+	instructionSink isa.Instruction
+	handlerSink     thumb.Handler
+)
+
+func BenchmarkDecodeInstruction(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		instructionSink = thumb.DecodeInstruction(opcodes[i%len(opcodes)])
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		handlerSink = thumb.Lookup(opcodes[i%len(opcodes)])
+	}
+}
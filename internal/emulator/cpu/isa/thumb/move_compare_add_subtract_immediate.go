@@ -1,22 +1,23 @@
 package thumb
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type MOV struct {
 	instruction uint16
 }
 
-func (m MOV) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (m MOV) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(m.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 	// Bits 7-0 are the immediate value
 	imm := uint32(m.instruction & 0xFF)
 
-	fmt.Printf("mov r%d, #%d\n", rd, imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("mov r%d, #%d", rd, imm)
+	}
 
 	cpu.WriteRegister(rd, imm)
 
@@ -29,16 +30,17 @@ type CMP struct {
 	instruction uint16
 }
 
-func (c CMP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("CMP")
+func (c CMP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(c.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 	rdVal := cpu.ReadRegister(rd)
 	// Bits 7-0 are the immediate value
 	imm := uint32(c.instruction & 0xFF)
 
-	fmt.Printf("Destination register: %d\n", rd)
-	fmt.Printf("Immediate value: %d\n", imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Destination register: %d", rd)
+		logging.CPU.Tracef("Immediate value: %d", imm)
+	}
 
 	// Subtract the immediate value from the destination register
 	res := rdVal - imm
@@ -60,16 +62,17 @@ type ADD struct {
 	instruction uint16
 }
 
-func (a ADD) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ADD")
+func (a ADD) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(a.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 	rdVal := cpu.ReadRegister(rd)
 	// Bits 7-0 are the immediate value
 	imm := uint32(a.instruction & 0xFF)
 
-	fmt.Printf("Destination register: %d\n", rd)
-	fmt.Printf("Immediate value: %d\n", imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Destination register: %d", rd)
+		logging.CPU.Tracef("Immediate value: %d", imm)
+	}
 
 	// Add the immediate value to the destination register
 	res := rdVal + imm
@@ -86,16 +89,17 @@ type SUB struct {
 	instruction uint16
 }
 
-func (s SUB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("SUB")
+func (s SUB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(s.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 	rdVal := cpu.ReadRegister(rd)
 	// Bits 7-0 are the immediate value
 	imm := uint32(s.instruction & 0xFF)
 
-	fmt.Printf("Destination register: %d\n", rd)
-	fmt.Printf("Immediate value: %d\n", imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("Destination register: %d", rd)
+		logging.CPU.Tracef("Immediate value: %d", imm)
+	}
 
 	// Subtract the immediate value from the destination register
 	res := rdVal - imm
@@ -112,9 +116,7 @@ type ADD2 struct {
 	instruction uint16
 }
 
-func (a ADD2) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("ADD2")
-
+func (a ADD2) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 8-6 are the immediate value
 	imm := uint8((a.instruction & (1<<8 | 1<<7 | 1<<6)) >> 6)
 	// Bits 5-3 are the source register
@@ -129,14 +131,18 @@ func (a ADD2) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// bit 10 == 1 means the operand is an immediate value
 	if a.instruction&(1<<10)>>10 == 1 {
 		//nolint:golint,dupword
-		fmt.Printf("ADD2 r%d, r%d, #%d\n", rd, rs, imm)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("ADD2 r%d, r%d, #%d", rd, rs, imm)
+		}
 		cpu.WriteRegister(rd, rsVal+uint32(imm))
 
 		// Set the C flag if the addition overflowed
 		uint64Val = uint64(rsVal) + uint64(imm)
 	} else {
 		//nolint:golint,dupword
-		fmt.Printf("ADD2 r%d, r%d, r%d\n", rd, rs, imm)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("ADD2 r%d, r%d, r%d", rd, rs, imm)
+		}
 		immVal := cpu.ReadRegister(imm)
 		cpu.WriteRegister(rd, rsVal+immVal)
 
@@ -156,7 +162,6 @@ func (a ADD2) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	cpu.SetN(rdVal&(1<<31)>>31 == 1)
 	cpu.SetZ(rdVal == 0)
 
-	fmt.Println("Not setting V flag")
 	return
 }
 
@@ -164,9 +169,7 @@ type SUB2 struct {
 	instruction uint16
 }
 
-func (s SUB2) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("SUB2")
-
+func (s SUB2) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 8-6 are the immediate value
 	imm := uint32((s.instruction & (1<<8 | 1<<7 | 1<<6)) >> 6)
 	// Bits 5-3 are the source register
@@ -178,10 +181,14 @@ func (s SUB2) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// bit 10 == 1 means the operand is an immediate value
 	if s.instruction&(1<<10)>>10 == 1 {
 		//nolint:golint,dupword
-		fmt.Printf("SUB2 r%d, r%d, #%d\n", rd, rs, imm)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("SUB2 r%d, r%d, #%d", rd, rs, imm)
+		}
 	} else {
 		//nolint:golint,dupword
-		fmt.Printf("SUB2 r%d, r%d, r%d\n", rd, rs, imm)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("SUB2 r%d, r%d, r%d", rd, rs, imm)
+		}
 		imm = cpu.ReadRegister(uint8(imm))
 	}
 
@@ -200,37 +207,27 @@ type SUBSP struct {
 	instruction uint16
 }
 
-func (a SUBSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("SUBSP")
-
+func (a SUBSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 7 == 1 if the offset is negative
 	negative := a.instruction&(1<<7)>>7 == 1
 
 	// Bits 6-0 are the immediate offset
-	imm := uint32(a.instruction & 0b111111)
+	imm := uint32(a.instruction & 0b1111111)
 
 	offset := imm << 2
 
+	// The flags are left alone
 	sp := cpu.ReadSP()
-
 	if negative {
-		fmt.Printf("SUBSP #-%d\n", offset)
-		cpu.WriteSP(sp - offset)
-		// Set the C flag if sp - offset underflows
-		if sp-offset > sp {
-			cpu.SetC(false)
-		} else {
-			cpu.SetC(true)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("SUBSP #-%d", offset)
 		}
+		cpu.WriteSP(sp - offset)
 	} else {
-		fmt.Printf("SUBSP #%d\n", offset)
-		cpu.WriteSP(sp + offset)
-		// Set the C flag if sp + offset overflows
-		if sp+offset < sp {
-			cpu.SetC(false)
-		} else {
-			cpu.SetC(true)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("SUBSP #%d", offset)
 		}
+		cpu.WriteSP(sp + offset)
 	}
 	return
 }
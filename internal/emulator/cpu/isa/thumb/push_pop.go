@@ -1,18 +1,15 @@
 package thumb
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type PUSH struct {
 	instruction uint16
 }
 
-func (p PUSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("PUSH")
-
+func (p PUSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 8 denotes storing LR
 	storeLR := p.instruction&(1<<8)>>8 == 1
 
@@ -20,26 +17,29 @@ func (p PUSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	registers := p.instruction & 0xFF
 
 	var pushRegisters []uint8
-	// Push LR if needed
-	if storeLR {
-		pushRegisters = append(pushRegisters, 14)
-	}
-
-	// Collect the registers to push in backwards order so that they are pushed in the correct order
-	for i := 7; i >= 0; i-- {
+	for i := 0; i < 8; i++ {
 		if registers&(1<<i)>>i == 1 {
 			pushRegisters = append(pushRegisters, uint8(i))
 		}
 	}
+	// Push LR if needed
+	if storeLR {
+		pushRegisters = append(pushRegisters, 14)
+	}
 
-	// Push the registers
-	for _, reg := range pushRegisters {
-		cpu.WriteSP(cpu.ReadSP() - 4)
-		err := cpu.GetMMIO().Write32(cpu.ReadSP(), cpu.ReadRegister(reg))
+	// Like the hardware, store the lowest register at the lowest address
+	// first
+	sp := cpu.ReadSP() - uint32(len(pushRegisters))*4
+	cpu.WriteSP(sp)
+	for i, reg := range pushRegisters {
+		addr := sp + uint32(i)*4
+		err := cpu.GetBus().Write32(addr, cpu.ReadRegister(reg))
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
+		}
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Pushing register r%d @ %08X", reg, addr)
 		}
-		fmt.Printf("Pushing register r%d @ %08X\n", reg, cpu.ReadSP())
 	}
 	return
 }
@@ -48,9 +48,7 @@ type POP struct {
 	instruction uint16
 }
 
-func (p POP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("POP")
-
+func (p POP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 8 denotes loading PC
 	loadPC := p.instruction&(1<<8)>>8 == 1
 
@@ -72,12 +70,14 @@ func (p POP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 
 	// Pop the registers
 	for _, reg := range popRegisters {
-		contents, err := cpu.GetMMIO().Read32(cpu.ReadSP())
+		contents, err := cpu.GetBus().Read32(cpu.ReadSP())
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 		cpu.WriteRegister(reg, contents)
-		fmt.Printf("Popping register r%d @ %08X\n", reg, cpu.ReadSP())
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Popping register r%d @ %08X", reg, cpu.ReadSP())
+		}
 		cpu.WriteSP(cpu.ReadSP() + 4)
 	}
 	return
@@ -1,33 +1,36 @@
 package thumb
 
 import (
-	"fmt"
-
 	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type LDR struct {
 	instruction uint16
 }
 
-func (l LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (l LDR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(l.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 
 	// Bits 7-0 are the immediate value
 	imm := (l.instruction & 0xFF) << 2
 
-	fmt.Printf("ldr r%d, [pc, #0x%X]\n", rd, imm)
-	memory := cpu.GetMMIO()
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldr r%d, [pc, #0x%X]", rd, imm)
+	}
+	memory := cpu.GetBus()
 
 	address := cpu.ReadPC() + uint32(imm)
 	// Clear bit 1 of the address to ensure it's word aligned
 	address &= 0xFFFFFFFC
-	fmt.Printf("ldr r%d, [0x%X]\n", rd, address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldr r%d, [0x%X]", rd, address)
+	}
 
 	read, err := memory.Read32(address)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	cpu.WriteRegister(rd, read)
 	return
@@ -37,7 +40,7 @@ type LDRR struct {
 	instruction uint16
 }
 
-func (l LDRR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (l LDRR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 10 is the B bit, which determines whether this is a byt or word
 	byt := l.instruction&(1<<10)>>10 == 1
 
@@ -55,7 +58,9 @@ func (l LDRR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		b = "b"
 	}
 
-	fmt.Printf("ldr%s r%d, [r%d, r%d]\n", b, destinationSourceRegister, baseRegister, offsetRegister)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldr%s r%d, [r%d, r%d]", b, destinationSourceRegister, baseRegister, offsetRegister)
+	}
 
 	base := cpu.ReadRegister(baseRegister)
 	offset := cpu.ReadRegister(offsetRegister)
@@ -63,15 +68,15 @@ func (l LDRR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	address := base + offset
 
 	if byt {
-		res, err := cpu.GetMMIO().Read8(address)
+		res, err := cpu.GetBus().Read8(address)
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 		cpu.WriteRegister(destinationSourceRegister, uint32(res))
 	} else {
-		res, err := cpu.GetMMIO().Read32(address)
+		res, err := cpu.GetBus().Read32(address)
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 		cpu.WriteRegister(destinationSourceRegister, res)
 	}
@@ -83,7 +88,7 @@ type STRR struct {
 	instruction uint16
 }
 
-func (s STRR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (s STRR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bit 10 is the B bit, which determines whether this is a byt or word
 	byt := s.instruction&(1<<10)>>10 == 1
 
@@ -101,24 +106,28 @@ func (s STRR) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		b = "b"
 	}
 
-	fmt.Printf("str%s r%d, [r%d, r%d]\n", b, destinationSourceRegister, baseRegister, offsetRegister)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("str%s r%d, [r%d, r%d]", b, destinationSourceRegister, baseRegister, offsetRegister)
+	}
 
-	memory := cpu.GetMMIO()
+	memory := cpu.GetBus()
 
 	offset := cpu.ReadRegister(uint8(offsetRegister))
 	address := cpu.ReadRegister(uint8(baseRegister)) + offset
-	fmt.Printf("offset=%d\n", offset)
-	fmt.Printf("base address=0x%08X\n", int64(cpu.ReadRegister(uint8(baseRegister))))
-	fmt.Printf("address=0x%08X\n", address)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("offset=%d", offset)
+		logging.CPU.Tracef("base address=0x%08X", int64(cpu.ReadRegister(uint8(baseRegister))))
+		logging.CPU.Tracef("address=0x%08X", address)
+	}
 	write := cpu.ReadRegister(uint8(destinationSourceRegister))
 
 	if byt {
 		write &= 0xFF
 	}
 
-	err := memory.Write32(address, write)
+	err = memory.Write32(address, write)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	return
 }
@@ -127,20 +136,20 @@ type STRSP struct {
 	instruction uint16
 }
 
-func (s STRSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("STRSP")
-
+func (s STRSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(s.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 
 	// Bits 7-0 are the immediate value
 	imm := (s.instruction & 0xFF) << 2
 
-	fmt.Printf("str r%d, [sp, #0x%X]\n", rd, imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("str r%d, [sp, #0x%X]", rd, imm)
+	}
 
-	err := cpu.GetMMIO().Write32(cpu.ReadSP()+uint32(imm), cpu.ReadRegister(rd))
+	err = cpu.GetBus().Write32(cpu.ReadSP()+uint32(imm), cpu.ReadRegister(rd))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	cpu.SetN(cpu.ReadRegister(rd)&(1<<31)>>31 != 0)
@@ -151,20 +160,20 @@ type LDRSP struct {
 	instruction uint16
 }
 
-func (l LDRSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LDRSP")
-
+func (l LDRSP) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the destination register
 	rd := uint8(l.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 
 	// Bits 7-0 are the immediate value
 	imm := (l.instruction & 0xFF) << 2
 
-	fmt.Printf("ldr r%d, [sp, #0x%X]\n", rd, imm)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldr r%d, [sp, #0x%X]", rd, imm)
+	}
 
-	mem, err := cpu.GetMMIO().Read32(cpu.ReadSP() + uint32(imm))
+	mem, err := cpu.GetBus().Read32(cpu.ReadSP() + uint32(imm))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	cpu.WriteRegister(rd, mem)
 	return
@@ -174,9 +183,7 @@ type LDRH struct {
 	instruction uint16
 }
 
-func (l LDRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LDRH")
-
+func (l LDRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := (l.instruction & (1<<10 | 1<<9 | 1<<8 | 1<<7 | 1<<6) >> 6) << 1
 
@@ -186,14 +193,16 @@ func (l LDRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("ldrh r%d, [r%d, #0x%X]\n", rd, rb, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrh r%d, [r%d, #0x%X]", rd, rb, offset)
+	}
 
 	addr := cpu.ReadRegister(rb) + uint32(offset)
 
 	// Load the halfword at rb + ro into rd
-	mem, err := cpu.GetMMIO().Read16(addr & 0xFFFFFFFE)
+	mem, err := cpu.GetBus().Read16(addr & 0xFFFFFFFE)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	if addr&1 == 1 {
@@ -215,9 +224,7 @@ type STRH struct {
 	instruction uint16
 }
 
-func (s STRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("STRH")
-
+func (s STRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint32(s.instruction&(1<<10|1<<9|1<<8|1<<7|1<<6)>>6) << 1
 
@@ -227,12 +234,14 @@ func (s STRH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(s.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("strh r%d, [r%d, #0x%X]\n", rd, rb, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("strh r%d, [r%d, #0x%X]", rd, rb, offset)
+	}
 
 	// Store the lower 16 bits of the rd into the address at rb + offset
-	err := cpu.GetMMIO().Write16(cpu.ReadRegister(rb)+offset, uint16(cpu.ReadRegister(rd)&0xFFFF))
+	err = cpu.GetBus().Write16(cpu.ReadRegister(rb)+offset, uint16(cpu.ReadRegister(rd)&0xFFFF))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	return
 }
@@ -241,9 +250,7 @@ type LDRBImm struct {
 	instruction uint16
 }
 
-func (l LDRBImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LDRBImm")
-
+func (l LDRBImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint32(l.instruction & (1<<10 | 1<<9 | 1<<8 | 1<<7 | 1<<6) >> 6)
 
@@ -253,12 +260,14 @@ func (l LDRBImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("ldrb r%d, [r%d, #0x%X]\n", rd, rb, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrb r%d, [r%d, #0x%X]", rd, rb, offset)
+	}
 
 	// Load the byte at rb + offset into rd
-	readByte, err := cpu.GetMMIO().Read8(cpu.ReadRegister(rb) + offset)
+	readByte, err := cpu.GetBus().Read8(cpu.ReadRegister(rb) + offset)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	cpu.WriteRegister(rd, uint32(readByte))
 	return
@@ -268,9 +277,7 @@ type STRBImm struct {
 	instruction uint16
 }
 
-func (s STRBImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("STRBImm")
-
+func (s STRBImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint32(s.instruction & (1<<10 | 1<<9 | 1<<8 | 1<<7 | 1<<6) >> 6)
 
@@ -280,12 +287,14 @@ func (s STRBImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(s.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("strb r%d, [r%d, #0x%X]\n", rd, rb, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("strb r%d, [r%d, #0x%X]", rd, rb, offset)
+	}
 
 	// Store the byte in rd into the address at rb + offset
-	err := cpu.GetMMIO().Write8(cpu.ReadRegister(rb)+offset, uint8(cpu.ReadRegister(rd)&0xFF))
+	err = cpu.GetBus().Write8(cpu.ReadRegister(rb)+offset, uint8(cpu.ReadRegister(rd)&0xFF))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	return
 }
@@ -294,9 +303,7 @@ type LDRWImm struct {
 	instruction uint16
 }
 
-func (l LDRWImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("LDRWImm")
-
+func (l LDRWImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint32(l.instruction&(1<<10|1<<9|1<<8|1<<7|1<<6)>>6) << 2
 
@@ -306,12 +313,14 @@ func (l LDRWImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("ldr r%d, [r%d, #0x%X]\n", rd, rb, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldr r%d, [r%d, #0x%X]", rd, rb, offset)
+	}
 
 	// Load the word at rb + offset into rd
-	mem, err := cpu.GetMMIO().Read32(cpu.ReadRegister(rb) + offset)
+	mem, err := cpu.GetBus().Read32(cpu.ReadRegister(rb) + offset)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	cpu.WriteRegister(rd, mem)
@@ -322,9 +331,7 @@ type STRWImm struct {
 	instruction uint16
 }
 
-func (s STRWImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
-	fmt.Println("STRWImm")
-
+func (s STRWImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-6 are the offset
 	offset := uint32(s.instruction&(1<<10|1<<9|1<<8|1<<7|1<<6)>>6) << 2
 
@@ -334,12 +341,14 @@ func (s STRWImm) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(s.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("str r%d, [r%d, #0x%X]\n", rd, rb, offset)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("str r%d, [r%d, #0x%X]", rd, rb, offset)
+	}
 
 	// Store the word in rd into the address at rb + offset
-	err := cpu.GetMMIO().Write32(cpu.ReadRegister(rb)+offset, cpu.ReadRegister(rd))
+	err = cpu.GetBus().Write32(cpu.ReadRegister(rb)+offset, cpu.ReadRegister(rd))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 	return
 }
@@ -348,7 +357,7 @@ type LDMIA struct {
 	instruction uint16
 }
 
-func (l LDMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (l LDMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the base register
 	rb := uint8(l.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 	rbVal := cpu.ReadRegister(rb)
@@ -365,7 +374,9 @@ func (l LDMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		}
 	}
 
-	fmt.Printf("ldmia r%d!, {%v}\n", rb, popRegisters)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldmia r%d!, {%v}", rb, popRegisters)
+	}
 
 	// If the register list is empty, then PC is loaded and add 0x40 to rb
 	if len(popRegisters) == 0 {
@@ -377,10 +388,12 @@ func (l LDMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	address := rbVal
 	for _, register := range popRegisters {
 		// Load the word at address into register
-		fmt.Printf("Loading word at 0x%X into r%d\n", address, register)
-		mem, err := cpu.GetMMIO().Read32(address)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Loading word at 0x%X into r%d", address, register)
+		}
+		mem, err := cpu.GetBus().Read32(address)
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 		cpu.WriteRegister(register, mem)
 		if register != rb {
@@ -396,7 +409,7 @@ type STMIA struct {
 	instruction uint16
 }
 
-func (s STMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (s STMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 10-8 are the base register
 	rb := uint8(s.instruction & (1<<10 | 1<<9 | 1<<8) >> 8)
 	rbVal := cpu.ReadRegister(rb)
@@ -413,7 +426,9 @@ func (s STMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 		}
 	}
 
-	fmt.Printf("stmia r%d!, {%v}\n", rb, pushRegisters)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("stmia r%d!, {%v}", rb, pushRegisters)
+	}
 
 	emptyRlist := false
 	if len(pushRegisters) == 0 {
@@ -426,7 +441,9 @@ func (s STMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	cnt := 0
 	for _, register := range pushRegisters {
 		// Store the word in register into address
-		fmt.Printf("Storing word in r%d into 0x%X\n", register, address)
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Storing word in r%d into 0x%X", register, address)
+		}
 		regVal := cpu.ReadRegister(register)
 		if emptyRlist {
 			regVal += 2
@@ -439,9 +456,9 @@ func (s STMIA) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 			}
 		}
 
-		err := cpu.GetMMIO().Write32(address, regVal)
+		err := cpu.GetBus().Write32(address, regVal)
 		if err != nil {
-			panic(err)
+			return repipeline, cycles, err
 		}
 		address += 4
 		if !emptyRlist {
@@ -458,7 +475,7 @@ type STRNSH struct {
 }
 
 // strh unsigned offset
-func (s STRNSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (s STRNSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Store halfword:
 	// Add Ro to base address in Rb. Store bits 0-
 	// 15 of Rd at the resulting address
@@ -472,12 +489,14 @@ func (s STRNSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(s.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("strh r%d, [r%d, r%d]\n", rd, rb, ro)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("strh r%d, [r%d, r%d]", rd, rb, ro)
+	}
 
 	// Store the halfword in rd into the address at rb + ro
-	err := cpu.GetMMIO().Write16(cpu.ReadRegister(rb)+cpu.ReadRegister(ro), uint16(cpu.ReadRegister(rd)))
+	err = cpu.GetBus().Write16(cpu.ReadRegister(rb)+cpu.ReadRegister(ro), uint16(cpu.ReadRegister(rd)))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	return
@@ -488,7 +507,7 @@ type LDRNSH struct {
 }
 
 // ldrh unsigned offset
-func (l LDRNSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (l LDRNSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Load halfword:
 	// Add Ro to base address in Rb. Load bits 0-
 	// 15 of Rd from the resulting address, and set
@@ -503,14 +522,16 @@ func (l LDRNSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// Bits 2-0 are the destination/source register
 	rd := uint8(l.instruction & (1<<2 | 1<<1 | 1<<0))
 
-	fmt.Printf("ldrh r%d, [r%d, r%d]\n", rd, rb, ro)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrh r%d, [r%d, r%d]", rd, rb, ro)
+	}
 
 	addr := cpu.ReadRegister(rb) + cpu.ReadRegister(ro)
 
 	// Load the halfword at rb + ro into rd
-	mem, err := cpu.GetMMIO().Read16(addr & 0xFFFFFFFE)
+	mem, err := cpu.GetBus().Read16(addr & 0xFFFFFFFE)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	if addr&1 == 1 {
@@ -535,7 +556,7 @@ type LDRSB struct {
 }
 
 // ldrsb signed offset
-func (l LDRSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (l LDRSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 8-6 are the offset register
 	ro := uint8(l.instruction & (1<<8 | 1<<7 | 1<<6) >> 6)
 	// Bits 5-3 are the base register
@@ -548,12 +569,14 @@ func (l LDRSB) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// 7 of Rd from the resulting address, and set
 	// bits 8-31 of Rd to bit 7.
 
-	fmt.Printf("ldrsb r%d, [r%d, r%d]\n", rd, rb, ro)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrsb r%d, [r%d, r%d]", rd, rb, ro)
+	}
 
 	// Load the byte at rb + ro into rd
-	mem, err := cpu.GetMMIO().Read8(cpu.ReadRegister(rb) + cpu.ReadRegister(ro))
+	mem, err := cpu.GetBus().Read8(cpu.ReadRegister(rb) + cpu.ReadRegister(ro))
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	val := int32(mem)
@@ -569,7 +592,7 @@ type LDRSH struct {
 }
 
 // ldrsh signed offset
-func (l LDRSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
+func (l LDRSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
 	// Bits 8-6 are the offset register
 	ro := uint8(l.instruction & (1<<8 | 1<<7 | 1<<6) >> 6)
 	// Bits 5-3 are the base register
@@ -582,14 +605,16 @@ func (l LDRSH) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16) {
 	// 15 of Rd from the resulting address, and set
 	// bits 16-31 of Rd to bit 15.
 
-	fmt.Printf("ldrsh r%d, [r%d, r%d]\n", rd, rb, ro)
+	if logging.CPU.Enabled(logging.LevelTrace) {
+		logging.CPU.Tracef("ldrsh r%d, [r%d, r%d]", rd, rb, ro)
+	}
 
 	addr := cpu.ReadRegister(rb) + cpu.ReadRegister(ro)
 
 	// Load the halfword at rb + ro into rd
-	mem, err := cpu.GetMMIO().Read16(addr)
+	mem, err := cpu.GetBus().Read16(addr)
 	if err != nil {
-		panic(err)
+		return repipeline, cycles, err
 	}
 
 	val := uint32(mem)
@@ -0,0 +1,18 @@
+package thumb
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interfaces"
+)
+
+type SWI struct {
+	instruction uint16
+}
+
+func (s SWI) Execute(cpu interfaces.CPU) (repipeline bool, cycles uint16, err error) {
+	// Bits 7-0 are the call number. The call returns to the next
+	// instruction, PC is 4 ahead.
+	number := uint8(s.instruction)
+	repipeline, err = isa.SoftwareInterrupt(cpu, number, cpu.ReadPC()-2)
+	return
+}
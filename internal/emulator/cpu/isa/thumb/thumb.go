@@ -1,10 +1,6 @@
 package thumb
 
-import (
-	"fmt"
-
-	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
-)
+import "github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
 
 // http://bear.ces.cwru.edu/eecs_382/ARM7-TDMI-manual-pt3.pdf, Figure 5-1
 const (
@@ -48,6 +44,9 @@ const (
 	LongBranchWithLinkFormat                   uint16 = 0b1111_0000_0000_0000
 )
 
+// DecodeInstruction decodes instruction by the masks and formats of the
+// manual. The CPU goes through Lookup, whose table is built from it.
+//
 //nolint:golint,gocyclo
 func DecodeInstruction(instruction uint16) isa.Instruction {
 	// This function will check masks against the instruction to determine which
@@ -217,14 +216,13 @@ func DecodeInstruction(instruction uint16) isa.Instruction {
 		}
 		return nil
 	default:
-		panic(fmt.Sprintf("Unknown THUMB instruction: %016b", instruction))
+		return nil
 	}
 	return nil
 }
 
-func matchSoftwareInterrupt(_ uint16) isa.Instruction {
-	fmt.Println("SoftwareInterrupt")
-	return nil
+func matchSoftwareInterrupt(instruction uint16) isa.Instruction {
+	return SWI{instruction}
 }
 
 func matchLoadStoreWithRegisterOffset(instruction uint16) isa.Instruction {
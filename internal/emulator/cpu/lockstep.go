@@ -0,0 +1,89 @@
+package cpu
+
+import "fmt"
+
+// registerNames names the values of registerState. The stored copies of
+// the bank in use are stale, but the same in both CPUs.
+//
+//nolint:golint,gochecknoglobals
+var registerNames = [...]string{
+	"r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7",
+	"r8", "r9", "r10", "r11", "r12", "sp", "lr", "pc", "cpsr",
+	"r8_usr", "r9_usr", "r10_usr", "r11_usr", "r12_usr",
+	"r8_fiq", "r9_fiq", "r10_fiq", "r11_fiq", "r12_fiq",
+	"sp_usr", "lr_usr", "sp_fiq", "lr_fiq", "sp_irq", "lr_irq",
+	"sp_svc", "lr_svc", "sp_abt", "lr_abt", "sp_und", "lr_und",
+	"spsr_usr", "spsr_fiq", "spsr_irq", "spsr_svc", "spsr_abt", "spsr_und",
+}
+
+// lockstepNames names the values lockstepState adds to the registers
+//
+//nolint:golint,gochecknoglobals
+var lockstepNames = [...]string{
+	"ARM prefetch 0", "ARM prefetch 1", "THUMB prefetch 0", "THUMB prefetch 1",
+	"wait cycles", "halted", "power mode", "cycles",
+}
+
+// newShadow sets up the CPU that lockstep mode checks c against. It runs
+// the same BIOS and ROM on the plain interpreter, and gets the same keys.
+// Writes made by the debugger or cheats aren't passed on to it.
+func (c *ARM7TDMI) newShadow() *ARM7TDMI {
+	config := *c.config
+	config.BlockCache = false
+	config.Lockstep = false
+	config.TraceRegisters = false
+	config.TracePath = ""
+	shadow := newARM7TDMI(&config)
+	shadow.biosROM = c.biosROM
+	shadow.gamePakROM = c.gamePakROM
+	shadow.header = c.header
+	shadow.hasBIOS = c.hasBIOS
+	shadow.hasSRAM = c.hasSRAM
+	shadow.sram = c.sram
+	return shadow
+}
+
+// registerState is every register of every mode
+func (c *ARM7TDMI) registerState() [len(registerNames)]uint32 {
+	var state [len(registerNames)]uint32
+	n := copy(state[:], c.r[:])
+	n += copy(state[n:], c.highUser[:])
+	n += copy(state[n:], c.highFIQ[:])
+	for _, banked := range c.spLR {
+		n += copy(state[n:], banked[:])
+	}
+	copy(state[n:], c.spsr[:])
+	return state
+}
+
+// lockstepState is the registers followed by the rest of the CPU state,
+// named by registerNames and lockstepNames
+func (c *ARM7TDMI) lockstepState() []uint32 {
+	halted := uint32(0)
+	if c.halted {
+		halted = 1
+	}
+	registers := c.registerState()
+	return append(registers[:],
+		c.prefetchARMPipeline[0], c.prefetchARMPipeline[1],
+		uint32(c.prefetchThumbPipeline[0]), uint32(c.prefetchThumbPipeline[1]),
+		uint32(c.waitCycles), halted, uint32(c.power), uint32(c.scheduler.Now()),
+	)
+}
+
+// checkShadow steps the shadow CPU and compares it with c, which just ran
+// the step at pc and got err
+func (c *ARM7TDMI) checkShadow(pc uint32, err error) error {
+	shadowErr := c.shadow.step()
+	if fmt.Sprint(err) != fmt.Sprint(shadowErr) {
+		return &ErrLockstep{PC: pc, Detail: fmt.Sprintf("the step returned %v, interpreter returned %v", err, shadowErr)}
+	}
+	got, want := c.lockstepState(), c.shadow.lockstepState()
+	names := append(registerNames[:], lockstepNames[:]...)
+	for i := range got {
+		if got[i] != want[i] {
+			return &ErrLockstep{PC: pc, Detail: fmt.Sprintf("%s is 0x%08X, interpreter has 0x%08X", names[i], got[i], want[i])}
+		}
+	}
+	return err
+}
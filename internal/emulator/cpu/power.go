@@ -0,0 +1,91 @@
+package cpu
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interrupts"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+)
+
+// powerMode is the low-power state HALTCNT puts the CPU in
+type powerMode uint8
+
+const (
+	powerRunning powerMode = iota
+	// powerHalt waits for an interrupt enabled in IE
+	powerHalt
+	// powerStop waits for a keypad, serial or Game Pak interrupt
+	powerStop
+)
+
+// stopWakeMask are the interrupts that end Stop mode
+const stopWakeMask = 1<<interrupts.Serial | 1<<interrupts.Keypad | 1<<interrupts.GamePak
+
+// longestIdleLoop is the most bytes a loop can span and still be skipped
+// as idle
+const longestIdleLoop = 64
+
+// writeHALTCNT enters Halt mode, or Stop mode when bit 7 is set
+func (c *ARM7TDMI) writeHALTCNT(_, value byte) byte {
+	if value&0x80 != 0 {
+		c.power = powerStop
+	} else {
+		c.power = powerHalt
+	}
+	if logging.CPU.Enabled(logging.LevelDebug) {
+		logging.CPU.Debug("Entering low-power mode", "stop", c.power == powerStop)
+	}
+	return value
+}
+
+// sleep lets the time pass up to the next event while the CPU is halted
+// or stopped, and reports whether it still is. The PPU keeps running in
+// Stop mode, so frames keep coming while the game waits for a key.
+func (c *ARM7TDMI) sleep() bool {
+	mask := uint16(0x3FFF)
+	if c.power == powerStop {
+		mask = stopWakeMask
+	}
+	if c.interrupts.PendingIn(mask) {
+		c.power = powerRunning
+		return false
+	}
+	c.skipToEvent()
+	return true
+}
+
+// skipToEvent lets the time pass up to the next event
+func (c *ARM7TDMI) skipToEvent() {
+	cycles := c.scheduler.UntilNext()
+	if cycles == ^uint64(0) {
+		// Nothing would ever wake the CPU, let a cycle pass at a time
+		cycles = 1
+	}
+	c.cycles += cycles
+	c.scheduler.Advance(cycles)
+}
+
+// idleLoop tracks the innermost backward branch, to tell when it spins
+// without changing anything
+type idleLoop struct {
+	target    uint32
+	registers [len(registerNames)]uint32
+	wrote     bool
+}
+
+// branched checks a taken branch from address to target for an idle loop:
+// a short loop that went around once without writing memory and came back
+// with the same registers can only leave once an event changes what it
+// reads, so the time skips to the next event. Only the CPU and events
+// change memory, so the skip is exact as far as the loop can tell.
+func (c *ARM7TDMI) branched(address, target uint32) {
+	if target > address || address-target > longestIdleLoop {
+		return
+	}
+	registers := c.registerState()
+	if target == c.idle.target && !c.idle.wrote && registers == c.idle.registers {
+		if logging.CPU.Enabled(logging.LevelTrace) {
+			logging.CPU.Tracef("Skipping idle loop at 0x%08X", target)
+		}
+		c.skipToEvent()
+	}
+	c.idle = idleLoop{target: target, registers: registers}
+}
@@ -0,0 +1,134 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// halter enables the VBlank IRQ and halts through the BIOS in a loop,
+// counting wake-ups in r5. Its handler counts IRQs in r4.
+//
+//nolint:golint,gochecknoglobals
+var halter = []uint32{
+	0xE3A00301, // mov r0, #0x04000000
+	0xE28F10F4, // add r1, pc, #0xF4
+	0xE5001004, // str r1, [r0, #-4]
+	0xE3A01008, // mov r1, #8
+	0xE1C010B4, // strh r1, [r0, #4]
+	0xE2802C02, // add r2, r0, #0x200
+	0xE3A01001, // mov r1, #1
+	0xE1C210B0, // strh r1, [r2]
+	0xE5821008, // str r1, [r2, #8]
+	0xEF020000, // loop: swi 0x20000
+	0xE2855001, // add r5, r5, #1
+	0xEAFFFFFC, // b loop
+	0x100 / 4:  0xE3A00301, // handler: mov r0, #0x04000000
+	0xE2802C02, // add r2, r0, #0x200
+	0xE1D210B2, // ldrh r1, [r2, #2]
+	0xE1C210B2, // strh r1, [r2, #2]
+	0xE2844001, // add r4, r4, #1
+	0xE12FFF1E, // bx lr
+}
+
+// stopper enables the keypad IRQ for A and stops through HALTCNT,
+// counting wake-ups in r5. Its handler counts IRQs in r4.
+//
+//nolint:golint,gochecknoglobals
+var stopper = []uint32{
+	0xE3A00301, // mov r0, #0x04000000
+	0xE28F10F4, // add r1, pc, #0xF4
+	0xE5001004, // str r1, [r0, #-4]
+	0xE3A01901, // mov r1, #0x4000
+	0xE3811001, // orr r1, r1, #1
+	0xE2803C01, // add r3, r0, #0x100
+	0xE1C313B2, // strh r1, [r3, #0x32]
+	0xE2802C02, // add r2, r0, #0x200
+	0xE3A01A01, // mov r1, #0x1000
+	0xE1C210B0, // strh r1, [r2]
+	0xE3A01001, // mov r1, #1
+	0xE5821008, // str r1, [r2, #8]
+	0xE3A01080, // loop: mov r1, #0x80
+	0xE5C31201, // strb r1, [r3, #0x201]
+	0xE2855001, // add r5, r5, #1
+	0xEAFFFFFB, // b loop
+	0x100 / 4:  0xE3A00301, // handler: mov r0, #0x04000000
+	0xE2802C02, // add r2, r0, #0x200
+	0xE1D210B2, // ldrh r1, [r2, #2]
+	0xE1C210B2, // strh r1, [r2, #2]
+	0xE2844001, // add r4, r4, #1
+	0xE12FFF1E, // bx lr
+}
+
+func TestHalt(t *testing.T) {
+	t.Parallel()
+	c := newLockstepCPU(t, halter)
+	steps := 0
+	for ; c.PPU.Frames() < 2; steps++ {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if irqs, wakes := c.ReadDebugRegister(4), c.ReadDebugRegister(5); irqs != 2 || wakes != 2 {
+		t.Errorf("%d IRQs and %d wake-ups, expected 2 of each", irqs, wakes)
+	}
+	// Halted, the CPU only steps from one event to the next
+	if steps > 2*2*228+100 {
+		t.Errorf("took %d steps for 2 frames", steps)
+	}
+}
+
+func TestStop(t *testing.T) {
+	t.Parallel()
+	c := newLockstepCPU(t, stopper)
+	for c.PPU.Frames() < 2 {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// VBlank and HBlank don't end Stop mode
+	if wakes := c.ReadDebugRegister(5); wakes != 0 {
+		t.Fatalf("woke up %d times without a key press", wakes)
+	}
+
+	c.SetKeyInput(1)
+	for i := 0; i < 100; i++ {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if irqs, wakes := c.ReadDebugRegister(4), c.ReadDebugRegister(5); irqs != 1 || wakes != 1 {
+		t.Errorf("%d IRQs and %d wake-ups after pressing A, expected 1 of each", irqs, wakes)
+	}
+}
+
+func TestIdleSkip(t *testing.T) {
+	t.Parallel()
+	// Wait for VBlank by polling VCOUNT
+	program := []uint32{
+		0xE3A00301, // mov r0, #0x04000000
+		0xE1D010B6, // loop: ldrh r1, [r0, #6]
+		0xE35100A0, // cmp r1, #160
+		0x1AFFFFFC, // bne loop
+		0xEAFFFFFE, // b .
+	}
+	run := func(idleSkip bool) int {
+		t.Helper()
+		c := testutil.NewCPU(t, program, &config.Config{BlockCache: true, Lockstep: true, IdleSkip: idleSkip})
+		steps := 0
+		for ; c.NextInstructionAddress() != 0x08000010; steps++ {
+			if err := c.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if vcount := c.ReadDebugRegister(1); vcount != 160 {
+			t.Errorf("left the loop with VCOUNT %d", vcount)
+		}
+		return steps
+	}
+	polling, skipping := run(false), run(true)
+	if skipping*10 > polling {
+		t.Errorf("took %d steps skipping the loop and %d polling", skipping, polling)
+	}
+}
@@ -0,0 +1,184 @@
+package cpu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// bank is a set of banked registers. User and system mode share one, and
+// so do the invalid mode values, which the ARM7TDMI decodes to no banked
+// mode at all.
+type bank uint8
+
+const (
+	bankUser bank = iota
+	bankFIQ
+	bankIRQ
+	bankSupervisor
+	bankAbort
+	bankUndefined
+	bankCount
+)
+
+// bankOf returns the bank of the mode in cpsr
+func bankOf(cpsr uint32) bank {
+	switch cpuMode(cpsr & 0x1F) {
+	case fiqMode:
+		return bankFIQ
+	case irqMode:
+		return bankIRQ
+	case supervisorMode:
+		return bankSupervisor
+	case abortMode:
+		return bankAbort
+	case undefinedMode:
+		return bankUndefined
+	default:
+		return bankUser
+	}
+}
+
+// swapBank puts the registers of the bank being left back in storage and
+// brings in the ones of the bank being entered. Only FIQ mode has its own
+// R8-R12, every bank has its own SP and LR.
+func (c *ARM7TDMI) swapBank(from, to bank) {
+	if from == to {
+		return
+	}
+	c.spLR[from] = [2]uint32{c.r[SP_REG], c.r[LR_REG]}
+	switch {
+	case from == bankFIQ:
+		copy(c.highFIQ[:], c.r[8:13])
+		copy(c.r[8:13], c.highUser[:])
+	case to == bankFIQ:
+		copy(c.highUser[:], c.r[8:13])
+		copy(c.r[8:13], c.highFIQ[:])
+	}
+	c.r[SP_REG], c.r[LR_REG] = c.spLR[to][0], c.spLR[to][1]
+}
+
+func (c *ARM7TDMI) ReadSPSR() uint32 {
+	b := bankOf(c.r[CPSR_REG])
+	if b == bankUser {
+		// There is no SPSR, it reads as the CPSR
+		return c.ReadCPSR()
+	}
+	return c.spsr[b]
+}
+
+func (c *ARM7TDMI) WriteSPSR(value uint32) {
+	b := bankOf(c.r[CPSR_REG])
+	if b == bankUser {
+		c.WriteCPSR(value)
+		return
+	}
+	c.spsr[b] = value
+}
+
+// ReadHighRegister reads R8-R15, numbered 0-7
+func (c *ARM7TDMI) ReadHighRegister(reg uint8) uint32 {
+	if !c.GetThumbMode() {
+		panic("Cannot read high register in ARM mode, use ReadRegister")
+	}
+	if reg > 7 {
+		panic(fmt.Sprintf("Invalid high register number %d", reg))
+	}
+	return c.r[reg+8]
+}
+
+// WriteHighRegister writes R8-R15, numbered 0-7. Writes to PC are aligned
+// like any other.
+func (c *ARM7TDMI) WriteHighRegister(reg uint8, value uint32) error {
+	if !c.GetThumbMode() {
+		return errors.New("cannot write a high register in ARM mode, use WriteRegister")
+	}
+	if reg > 7 {
+		return fmt.Errorf("invalid high register number %d", reg)
+	}
+	if reg+8 == PC_REG {
+		c.WritePC(value)
+		return nil
+	}
+	c.r[reg+8] = value
+	return nil
+}
+
+func (c *ARM7TDMI) ReadRegister(reg uint8) uint32 {
+	if c.GetThumbMode() {
+		if reg > 7 && reg != PC_REG && reg != LR_REG && reg != SP_REG && reg != CPSR_REG {
+			panic(fmt.Sprintf("Invalid register number %d", reg))
+		}
+		if reg == CPSR_REG {
+			return c.ReadCPSR()
+		}
+		return c.r[reg]
+	}
+	if reg > 16 {
+		panic(fmt.Sprintf("Invalid register number %d", reg))
+	}
+	return c.r[reg]
+}
+
+func (c *ARM7TDMI) WriteRegister(reg uint8, value uint32) {
+	if c.GetThumbMode() {
+		if reg > 7 && reg != PC_REG && reg != LR_REG && reg != SP_REG && reg != CPSR_REG {
+			panic(fmt.Sprintf("Invalid register number %d", reg))
+		}
+	} else if reg > 16 {
+		panic(fmt.Sprintf("Invalid register number %d", reg))
+	}
+	switch reg {
+	case PC_REG:
+		c.WritePC(value)
+	case CPSR_REG:
+		c.WriteCPSR(value)
+	default:
+		c.r[reg] = value
+	}
+}
+
+func (c *ARM7TDMI) ReadSP() uint32 {
+	return c.r[SP_REG]
+}
+
+func (c *ARM7TDMI) WriteSP(value uint32) {
+	c.r[SP_REG] = value
+}
+
+func (c *ARM7TDMI) ReadLR() uint32 {
+	return c.r[LR_REG]
+}
+
+func (c *ARM7TDMI) WriteLR(value uint32) {
+	c.r[LR_REG] = value
+}
+
+func (c *ARM7TDMI) ReadPC() uint32 {
+	return c.r[PC_REG]
+}
+
+func (c *ARM7TDMI) WritePC(value uint32) {
+	// Mask out the bottom two bits in arm mode
+	if c.GetThumbMode() {
+		value &= 0xFFFFFFFE
+	} else {
+		value &= 0xFFFFFFFC
+	}
+	c.r[PC_REG] = value
+}
+
+func (c *ARM7TDMI) ReadCPSR() uint32 {
+	cpsr := c.r[CPSR_REG]
+	// Top bit of mode is always 1
+	cpsr |= (1 << 4)
+	return cpsr
+}
+
+// WriteCPSR swaps the banked registers in when the mode changes, which
+// covers MSR, exceptions and SPSR restores alike
+func (c *ARM7TDMI) WriteCPSR(value uint32) {
+	// Top bit of mode is always 1, the bank goes by the mode it reads as
+	value |= 1 << 4
+	c.swapBank(bankOf(c.r[CPSR_REG]), bankOf(value))
+	c.r[CPSR_REG] = value
+}
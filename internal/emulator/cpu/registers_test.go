@@ -0,0 +1,52 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+func TestWriteHighRegister(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, []uint32{0xEAFFFFFE}, nil) // b .
+	if err := c.WriteHighRegister(0, 1); err == nil {
+		t.Error("wrote a high register in ARM mode")
+	}
+	c.SetThumbMode(true)
+	for _, reg := range []uint8{8, 9, 15} {
+		if err := c.WriteHighRegister(reg, 1); err == nil {
+			t.Errorf("wrote high register %d", reg)
+		}
+	}
+	if err := c.WriteHighRegister(4, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if r12 := c.ReadHighRegister(4); r12 != 0x1234 {
+		t.Errorf("r12 = 0x%08X, want 0x00001234", r12)
+	}
+	// PC drops the THUMB bit
+	if err := c.WriteHighRegister(7, 0x08000103); err != nil {
+		t.Fatal(err)
+	}
+	if pc := c.ReadPC(); pc != 0x08000102 {
+		t.Errorf("pc = 0x%08X, want 0x08000102", pc)
+	}
+}
+
+func TestWriteCPSRMode(t *testing.T) {
+	t.Parallel()
+	c := testutil.NewCPU(t, []uint32{0xEAFFFFFE}, nil) // b .
+	c.WriteRegister(8, 0x1234)
+	// Mode 0x01 reads as FIQ mode 0x11, and banks like it
+	c.WriteCPSR(0x01)
+	if cpsr := c.ReadCPSR(); cpsr != 0x11 {
+		t.Errorf("cpsr = 0x%08X, want 0x00000011", cpsr)
+	}
+	if r8 := c.ReadRegister(8); r8 != 0 {
+		t.Errorf("r8 = 0x%08X in FIQ mode, want its own bank", r8)
+	}
+	c.WriteCPSR(0x1F)
+	if r8 := c.ReadRegister(8); r8 != 0x1234 {
+		t.Errorf("r8 = 0x%08X back in system mode, want 0x00001234", r8)
+	}
+}
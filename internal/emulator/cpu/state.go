@@ -0,0 +1,128 @@
+package cpu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/ppu"
+)
+
+// stateMagic starts every save state, followed by stateVersion
+const (
+	stateMagic   = "GBAS"
+	stateVersion = 1
+)
+
+// savedState is everything a save state holds. It only has fixed-size
+// fields, so it goes through encoding/binary as is. The ROM and BIOS
+// aren't part of it, only the game code to check it is loaded back into
+// the same game.
+type savedState struct {
+	Magic    [4]byte
+	Version  uint32
+	GameCode [4]byte
+
+	Registers     [17]uint32
+	HighUser      [5]uint32
+	HighFIQ       [5]uint32
+	SPLR          [bankCount][2]uint32
+	SPSR          [bankCount]uint32
+	PrefetchARM   [2]uint32
+	PrefetchThumb [2]uint16
+	WaitCycles    uint16
+	Power         uint8
+	Cycles        uint64
+	Now           uint64
+
+	OnBoardRAM [OnBoardRAMSize]byte
+	OnChipRAM  [OnChipRAMSize]byte
+	IORAM      [IORAMSize]byte
+	SRAM       [SRAMSize]byte
+	PPU        ppu.State
+}
+
+// SaveState writes the state of the running game to w
+func (c *ARM7TDMI) SaveState(w io.Writer) error {
+	state := &savedState{
+		Version:       stateVersion,
+		Registers:     c.r,
+		HighUser:      c.highUser,
+		HighFIQ:       c.highFIQ,
+		SPLR:          c.spLR,
+		SPSR:          c.spsr,
+		PrefetchARM:   c.prefetchARMPipeline,
+		PrefetchThumb: c.prefetchThumbPipeline,
+		WaitCycles:    c.waitCycles,
+		Power:         uint8(c.power),
+		Cycles:        c.cycles,
+		Now:           c.scheduler.Now(),
+		OnBoardRAM:    c.onBoardRAM,
+		OnChipRAM:     c.onChipRAM,
+		IORAM:         c.ioRAM,
+		SRAM:          c.sram,
+	}
+	copy(state.Magic[:], stateMagic)
+	copy(state.GameCode[:], c.gameCode())
+	c.PPU.SaveState(&state.PPU)
+	return binary.Write(w, binary.LittleEndian, state)
+}
+
+// LoadState restores a state written by SaveState for the same game
+func (c *ARM7TDMI) LoadState(r io.Reader) error {
+	state := &savedState{}
+	if err := binary.Read(r, binary.LittleEndian, state); err != nil {
+		return fmt.Errorf("failed to read save state: %w", err)
+	}
+	if string(state.Magic[:]) != stateMagic {
+		return errors.New("not a save state")
+	}
+	if state.Version != stateVersion {
+		return fmt.Errorf("save state version is %d, expected %d", state.Version, stateVersion)
+	}
+	var gameCode [4]byte
+	copy(gameCode[:], c.gameCode())
+	if state.GameCode != gameCode {
+		return fmt.Errorf("save state is for game %q, not %q", state.GameCode[:], gameCode[:])
+	}
+	c.restore(state)
+	return nil
+}
+
+func (c *ARM7TDMI) restore(state *savedState) {
+	c.r = state.Registers
+	c.highUser = state.HighUser
+	c.highFIQ = state.HighFIQ
+	c.spLR = state.SPLR
+	c.spsr = state.SPSR
+	c.prefetchARMPipeline = state.PrefetchARM
+	c.prefetchThumbPipeline = state.PrefetchThumb
+	c.waitCycles = state.WaitCycles
+	c.power = powerMode(state.Power)
+	c.cycles = state.Cycles
+	c.onBoardRAM = state.OnBoardRAM
+	c.onChipRAM = state.OnChipRAM
+	c.ioRAM = state.IORAM
+	c.sram = state.SRAM
+	c.scheduler.Restore(state.Now)
+	c.PPU.LoadState(&state.PPU)
+
+	// The code in RAM changed behind the block cache's back
+	if c.blocks != nil {
+		c.blocks.flush()
+	}
+	c.idle = idleLoop{}
+	if c.shadow != nil {
+		c.shadow.restore(state)
+	}
+}
+
+// gameCode returns the game code from the cartridge header, or "" if it
+// couldn't be parsed
+func (c *ARM7TDMI) gameCode() string {
+	if c.header == nil {
+		return ""
+	}
+	return c.header.GameCode
+}
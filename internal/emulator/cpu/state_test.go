@@ -0,0 +1,40 @@
+package cpu_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSaveStateLockstep(t *testing.T) {
+	t.Parallel()
+	c := newLockstepCPU(t, irqCounter)
+	for c.PPU.Frames() < 1 {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var state bytes.Buffer
+	if err := c.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	for c.PPU.Frames() < 3 {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if frames, vblanks := c.PPU.Frames(), c.ReadDebugRegister(4); frames != 1 || vblanks != 1 {
+		t.Fatalf("back at frame %d with %d VBlank IRQs, expected 1 and 1", frames, vblanks)
+	}
+	// The shadow CPU is restored too, or the steps after would diverge
+	for c.PPU.Frames() < 2 {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if vblanks, matches := c.ReadDebugRegister(4), c.ReadDebugRegister(5); vblanks != 2 || matches != 2 {
+		t.Errorf("%d VBlank and %d VCount IRQs after loading, expected 2 of each", vblanks, matches)
+	}
+}
@@ -0,0 +1,83 @@
+package cpu_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// bios jumps from the reset vector to the ROM and loops at the SWI vector
+func bios() []byte {
+	bios := make([]byte, cpu.BIOSROMSize)
+	binary.LittleEndian.PutUint32(bios[0x00:], 0xE3A0F302) // mov pc, #0x08000000
+	binary.LittleEndian.PutUint32(bios[0x08:], 0xEAFFFFFE) // b .
+	return bios
+}
+
+func TestSoftwareInterrupt(t *testing.T) {
+	t.Parallel()
+	arm := []uint32{
+		0xEF0B0000, // swi 0x0B0000
+		0xEAFFFFFE, // b .
+	}
+	thumb := []uint32{
+		0xE28F2001, // add r2, pc, #1
+		0xE12FFF12, // bx r2
+		0xE7FEDF0B, // swi 0x0B ; b .
+	}
+	tests := []struct {
+		name    string
+		program []uint32
+		steps   int
+		ret     uint32
+		cpsr    uint32
+	}{
+		{"arm", arm, 1, 0x08000004, 0x0000001F},
+		{"thumb", thumb, 3, 0x0800000A, 0x0000003F},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" without a BIOS", func(t *testing.T) {
+			t.Parallel()
+			c := testutil.NewCPU(t, tt.program, nil)
+			var err error
+			for i := 0; i < tt.steps && err == nil; i++ {
+				err = c.StepInstruction()
+			}
+			var swi *isa.ErrSWI
+			if !errors.As(err, &swi) || swi.Number != 0x0B {
+				t.Fatalf("got error %v, expected an ErrSWI for call 0x0B", err)
+			}
+		})
+		t.Run(tt.name+" with a BIOS", func(t *testing.T) {
+			t.Parallel()
+			c, err := cpu.New(&config.Config{}, bios(), testutil.ROM(tt.program))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(c.Close)
+			// The reset vector jumps to the ROM first
+			for i := 0; i < tt.steps+1; i++ {
+				if err := c.StepInstruction(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if pc := c.NextInstructionAddress(); pc != 0x08 {
+				t.Errorf("pc = 0x%08X, want the SWI vector", pc)
+			}
+			if cpsr := c.ReadCPSR(); cpsr != 0x93 {
+				t.Errorf("cpsr = 0x%08X, want supervisor mode in ARM state with IRQs disabled", cpsr)
+			}
+			if spsr := c.ReadSPSR(); spsr != tt.cpsr {
+				t.Errorf("spsr = 0x%08X, want 0x%08X", spsr, tt.cpsr)
+			}
+			if lr := c.ReadDebugRegister(14); lr != tt.ret {
+				t.Errorf("lr = 0x%08X, want 0x%08X", lr, tt.ret)
+			}
+		})
+	}
+}
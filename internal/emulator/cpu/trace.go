@@ -0,0 +1,153 @@
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+	"github.com/USA-RedDragon/go-gba/internal/trace"
+)
+
+// openTrace opens the execution trace set up in the config
+func (c *ARM7TDMI) openTrace() error {
+	format, err := trace.ParseFormat(c.config.TraceFormat)
+	if err != nil {
+		return fmt.Errorf("failed to open trace: %w", err)
+	}
+	opts := trace.Options{Format: format, RingSize: c.config.TraceRing}
+	for _, text := range c.config.TraceRanges {
+		r, err := trace.ParseRange(text)
+		if err != nil {
+			return fmt.Errorf("failed to open trace: %w", err)
+		}
+		opts.Filter.Ranges = append(opts.Filter.Ranges, r)
+	}
+	if opts.Filter.Modes, err = trace.ParseModes(c.config.TraceModes); err != nil {
+		return fmt.Errorf("failed to open trace: %w", err)
+	}
+	if c.config.TraceFrames != "" {
+		opts.Filter.FirstFrame, opts.Filter.EndFrame, err = trace.ParseFrames(c.config.TraceFrames)
+		if err != nil {
+			return fmt.Errorf("failed to open trace: %w", err)
+		}
+	}
+	c.traceFile, err = trace.Create(c.config.TracePath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to open trace: %w", err)
+	}
+	c.SetTracer(c.traceFile, c.config.TraceWrites)
+	return nil
+}
+
+// SetTracer records every executed instruction the tracer matches, with
+// its memory writes if writes is set. Passing a nil tracer removes it.
+func (c *ARM7TDMI) SetTracer(tracer trace.Tracer, writes bool) {
+	c.tracer = tracer
+	c.traceWrites = tracer != nil && writes
+	c.updateAccessHook()
+}
+
+// updateAccessHook picks the memory access hook used while instructions
+// execute
+func (c *ARM7TDMI) updateAccessHook() {
+	if c.traceWrites || c.config.IdleSkip {
+		c.accessHook = c.recordAccess
+	} else {
+		c.accessHook = c.watchHook
+	}
+}
+
+// recordAccess notes the writes of the traced instruction and of idle
+// loops, then passes the access on to the watch hook
+func (c *ARM7TDMI) recordAccess(addr uint32, size uint8, write bool) {
+	if write {
+		c.idle.wrote = true
+	}
+	if write && c.traceRecord != nil {
+		c.traceRecord.Writes = append(c.traceRecord.Writes, trace.Write{Address: addr, Size: size})
+	}
+	if c.watchHook != nil {
+		c.watchHook(addr, size, write)
+	}
+}
+
+// traceInstruction starts the record of the instruction fetched from
+// address, with the state before it executes
+func (c *ARM7TDMI) traceInstruction(address, opcode uint32) {
+	cpsr := c.ReadCPSR()
+	frame := c.PPU.Frames()
+	if !c.tracer.Match(address, uint8(cpsr&0x1F), frame) {
+		return
+	}
+	c.traceRecord = &trace.Record{
+		Cycles:  c.cycles,
+		Frame:   frame,
+		Address: address,
+		Opcode:  opcode,
+		CPSR:    cpsr,
+	}
+	for reg := uint8(0); reg < PC_REG; reg++ {
+		c.traceRecord.Registers[reg] = c.ReadDebugRegister(reg)
+	}
+	c.traceRecord.Registers[PC_REG] = c.r[PC_REG]
+}
+
+// finishTrace writes the record of the instruction that just executed,
+// reading back the values it wrote
+func (c *ARM7TDMI) finishTrace() {
+	record := c.traceRecord
+	c.traceRecord = nil
+	for i := range record.Writes {
+		write := &record.Writes[i]
+		switch write.Size {
+		case 1:
+			value, _ := c.virtualMemory.Read8(write.Address)
+			write.Value = uint32(value)
+		case 2:
+			value, _ := c.virtualMemory.Read16(write.Address)
+			write.Value = uint32(value)
+		default:
+			write.Value, _ = c.virtualMemory.Read32(write.Address)
+		}
+	}
+	if err := c.tracer.Write(record); err != nil {
+		logging.CPU.Error("Failed to write trace", "err", err)
+		c.SetTracer(nil, false)
+	}
+}
+
+// crashTrace writes out the trace ring buffer if the CPU panics, ending
+// with the instruction that crashed
+func (c *ARM7TDMI) crashTrace() {
+	if r := recover(); r != nil {
+		c.dumpTrace()
+		panic(r)
+	}
+}
+
+// dumpTrace writes out the trace ring buffer after the CPU stopped on an
+// error, ending with the instruction that failed
+func (c *ARM7TDMI) dumpTrace() {
+	if c.traceFile == nil {
+		return
+	}
+	if c.traceRecord != nil && c.tracer != nil {
+		c.finishTrace()
+	}
+	if err := c.traceFile.Crash(); err != nil {
+		logging.CPU.Error("Failed to write trace", "err", err)
+	}
+}
+
+// Close flushes and closes the execution trace, if there is one
+func (c *ARM7TDMI) Close() {
+	if c.traceFile == nil {
+		return
+	}
+	if c.tracer == trace.Tracer(c.traceFile) {
+		c.SetTracer(nil, false)
+	}
+	if err := c.traceFile.Close(); err != nil {
+		logging.CPU.Error("Failed to close trace", "err", err)
+	}
+	c.traceFile = nil
+}
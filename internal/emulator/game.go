@@ -2,66 +2,193 @@ package emulator
 
 import (
 	"fmt"
-	"os"
+	"image"
+	"image/color"
 	"runtime/pprof"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cheats"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/core"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/ppu"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 )
 
+// Emulator shows the frames of a core running on its own goroutine, and
+// feeds it the keys. Update and Draw only talk to the core.
 type Emulator struct {
-	config    *config.Config
-	cpu       *cpu.ARM7TDMI
-	stopped   bool
-	frametime int
+	config  *config.Config
+	cpu     *cpu.ARM7TDMI
+	core    *core.Core
+	stopped atomic.Bool
+	stop    sync.Once
+	paused  bool
+	scaler  *ppu.Scaler
+	frame   *ebiten.Image
+	keys    []keyBinding
+	cheats  *cheats.Engine
 }
 
-func New(config *config.Config) *Emulator {
+func New(config *config.Config) (*Emulator, error) {
+	filter, err := ppu.ParseFilter(config.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ppu.ParseColorCorrection(config.ColorCorrection); err != nil {
+		return nil, err
+	}
+	keys, err := parseKeyBindings(config.KeyBindings)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cpu.NewARM7TDMI(config)
+	if err != nil {
+		return nil, err
+	}
 	emu := &Emulator{
 		config: config,
-		cpu:    cpu.NewARM7TDMI(config),
+		cpu:    c,
+		scaler: ppu.NewScaler(filter),
+		keys:   keys,
+	}
+	if err := emu.loadCheats(); err != nil {
+		return nil, err
 	}
-	return emu
+	emu.core = core.New(c, core.FrameDuration, emu.frameDone)
+	emu.core.Start()
+	return emu, nil
 }
 
-func (e *Emulator) Update() error {
-	start := time.Now()
-	for {
-		if e.stopped {
-			break
+// loadCheats compiles the enabled cheats for the ROM. With a master code the
+// cheats run each time the game reaches the hook, otherwise once per frame.
+func (e *Emulator) loadCheats() error {
+	path := e.config.CheatsPath
+	if path == "" {
+		if e.config.ROMPath == "" {
+			return nil
 		}
-		e.cpu.Step()
-		if e.cpu.PPU.FrameReady() {
-			e.cpu.PPU.ClearFrameReady()
-			e.frametime = int(time.Since(start).Milliseconds())
-			break
+		path = cheats.FilePath(e.config.ROMPath)
+	}
+	file, err := cheats.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	engine, err := cheats.NewEngine(file.Cheats)
+	if err != nil {
+		logging.MMIO.Warn("Skipping cheats", "err", err)
+	}
+	if engine.Len() == 0 {
+		return nil
+	}
+	logging.MMIO.Info("Loaded cheats", "count", engine.Len(), "path", path)
+
+	bus := e.cpu.GetMMIO()
+	if err := engine.PatchROM(bus); err != nil {
+		return err
+	}
+	if hook, ok := engine.Hook(); ok {
+		e.cpu.SetExecHook(hook, func() {
+			e.applyCheats()
+		})
+	}
+	e.cheats = engine
+	return nil
+}
+
+func (e *Emulator) applyCheats() {
+	if err := e.cheats.Apply(e.cpu.GetMMIO()); err != nil {
+		logging.MMIO.Warn("Failed to apply cheats", "err", err)
+	}
+}
+
+// Title returns the game title from the cartridge header
+func (e *Emulator) Title() string {
+	if header := e.cpu.GetCartridgeHeader(); header != nil {
+		return header.Title
+	}
+	return ""
+}
+
+// frameDone applies the cheats once a frame unless the game has a hook
+// for them. It runs on the core goroutine.
+func (e *Emulator) frameDone() {
+	if e.cheats != nil {
+		if _, hooked := e.cheats.Hook(); !hooked {
+			e.applyCheats()
 		}
 	}
+}
+
+func (e *Emulator) Update() error {
+	if e.stopped.Load() {
+		return ebiten.Termination
+	}
+	e.handleHotkeys()
+	e.core.SetKeys(e.pressedButtons())
 	return nil
 }
 
 func (e *Emulator) Draw(screen *ebiten.Image) {
-	if e.stopped {
+	if e.stopped.Load() {
+		return
+	}
+	if crash := e.core.Crash(); crash != "" {
+		screen.Fill(color.Black)
+		ebitenutil.DebugPrint(screen, crash)
 		return
 	}
-	fb := e.cpu.PPU.FrameBuffer()
-	if fb != nil {
-		screen.WritePixels(fb)
+	if fb := e.core.Frame(); fb != nil {
+		e.drawFrame(screen, fb)
 	}
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %0.2f\nFrame Time: %dms\nTPS: %0.2f", 1000.0/float64(e.frametime), e.frametime, ebiten.ActualTPS()))
+	frameTime := e.core.FrameTime()
+	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %0.2f\nFrame Time: %dms\nTPS: %0.2f", float64(time.Second)/float64(frameTime), frameTime.Milliseconds(), ebiten.ActualTPS()))
 	// ebitenutil.DebugPrint(screen, e.cpu.DebugRegisters())
 }
 
-func (e *Emulator) Layout(_, _ int) (int, int) {
-	return int(e.config.Scale * 240), int(e.config.Scale * 160)
+// drawFrame letterboxes the frame into the screen, keeping the 3:2 aspect ratio
+func (e *Emulator) drawFrame(screen *ebiten.Image, fb *image.RGBA) {
+	viewport := ppu.Viewport(screen.Bounds().Dx(), screen.Bounds().Dy(), e.config.IntegerScaling)
+	if viewport.Empty() {
+		return
+	}
+
+	upscaled := e.scaler.Upscale(fb, viewport.Dx(), viewport.Dy())
+	bounds := upscaled.Bounds()
+	if e.frame == nil || e.frame.Bounds().Dx() != bounds.Dx() || e.frame.Bounds().Dy() != bounds.Dy() {
+		if e.frame != nil {
+			e.frame.Deallocate()
+		}
+		e.frame = ebiten.NewImage(bounds.Dx(), bounds.Dy())
+	}
+	e.frame.WritePixels(upscaled.Pix)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(viewport.Dx())/float64(bounds.Dx()), float64(viewport.Dy())/float64(bounds.Dy()))
+	op.GeoM.Translate(float64(viewport.Min.X), float64(viewport.Min.Y))
+	op.Filter = ebiten.FilterNearest
+	if e.scaler.Filter().Smooth() {
+		op.Filter = ebiten.FilterLinear
+	}
+	screen.DrawImage(e.frame, op)
+}
+
+func (e *Emulator) Layout(outsideWidth, outsideHeight int) (int, int) {
+	scale := ebiten.Monitor().DeviceScaleFactor()
+	return int(float64(outsideWidth) * scale), int(float64(outsideHeight) * scale)
 }
 
+// Stop ends the emulation and makes the next Update end the game loop.
+// It can be called from any goroutine, and more than once.
 func (e *Emulator) Stop() {
-	e.stopped = true
-	pprof.StopCPUProfile()
-	e.cpu.Halt()
-	os.Exit(0)
+	e.stop.Do(func() {
+		e.core.Stop()
+		e.stopped.Store(true)
+		pprof.StopCPUProfile()
+		e.cpu.Close()
+	})
 }
@@ -0,0 +1,76 @@
+package emulator
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// buttonBits maps each GBA button to its bit in KEYINPUT
+//
+//nolint:golint,gochecknoglobals
+var buttonBits = map[string]uint16{
+	"a":      1 << 0,
+	"b":      1 << 1,
+	"select": 1 << 2,
+	"start":  1 << 3,
+	"right":  1 << 4,
+	"left":   1 << 5,
+	"up":     1 << 6,
+	"down":   1 << 7,
+	"r":      1 << 8,
+	"l":      1 << 9,
+}
+
+type keyBinding struct {
+	key ebiten.Key
+	bit uint16
+}
+
+// parseKeyBindings resolves the configured key names into ebiten keys
+func parseKeyBindings(bindings map[string]string) ([]keyBinding, error) {
+	parsed := make([]keyBinding, 0, len(bindings))
+	for button, name := range bindings {
+		bit, ok := buttonBits[button]
+		if !ok {
+			return nil, fmt.Errorf("unknown GBA button %q in key bindings", button)
+		}
+		var key ebiten.Key
+		if err := key.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("invalid key %q for button %q: %w", name, button, err)
+		}
+		parsed = append(parsed, keyBinding{key: key, bit: bit})
+	}
+	return parsed, nil
+}
+
+// pressedButtons returns the KEYINPUT bits of every button currently held
+func (e *Emulator) pressedButtons() uint16 {
+	var pressed uint16
+	for _, binding := range e.keys {
+		if ebiten.IsKeyPressed(binding.key) {
+			pressed |= binding.bit
+		}
+	}
+	return pressed
+}
+
+// handleHotkeys sends the core the commands of the emulator hotkeys: P
+// pauses and resumes, N runs a single frame and Ctrl+R resets
+func (e *Emulator) handleHotkeys() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyP):
+		e.paused = !e.paused
+		if e.paused {
+			e.core.Pause()
+		} else {
+			e.core.Resume()
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyN):
+		e.paused = true
+		e.core.Step()
+	case inpututil.IsKeyJustPressed(ebiten.KeyR) && ebiten.IsKeyPressed(ebiten.KeyControl):
+		e.core.Reset()
+	}
+}
@@ -9,7 +9,7 @@ type CPU interface {
 	ReadRegister(reg uint8) uint32
 	WriteRegister(reg uint8, value uint32)
 	ReadHighRegister(reg uint8) uint32
-	WriteHighRegister(reg uint8, value uint32)
+	WriteHighRegister(reg uint8, value uint32) error
 	ReadSP() uint32
 	WriteSP(value uint32)
 	ReadLR() uint32
@@ -20,10 +20,11 @@ type CPU interface {
 	WriteCPSR(value uint32)
 	ReadSPSR() uint32
 	WriteSPSR(value uint32)
-	FlushPipeline()
+	FlushPipeline() error
 	GetConfig() *config.Config
+	HasBIOS() bool
 
-	GetMMIO() *memory.MMIO
+	GetBus() memory.Bus
 
 	SetZ(value bool)
 	SetN(value bool)
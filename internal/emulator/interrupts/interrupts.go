@@ -0,0 +1,76 @@
+package interrupts
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
+)
+
+// Interrupt is a bit of IE and IF
+type Interrupt uint8
+
+const (
+	VBlank Interrupt = iota
+	HBlank
+	VCount
+	Timer0
+	Timer1
+	Timer2
+	Timer3
+	Serial
+	DMA0
+	DMA1
+	DMA2
+	DMA3
+	Keypad
+	GamePak
+)
+
+// Offsets of the interrupt registers in the I/O registers
+const (
+	ieOffset  = 0x200
+	ifOffset  = 0x202
+	imeOffset = 0x208
+)
+
+// Controller raises interrupts through IE, IF and IME, which live in the
+// I/O registers
+type Controller struct {
+	ioRAM []byte
+}
+
+// NewController makes IF acknowledge interrupts: writing a 1 to a bit
+// clears it
+func NewController(mmio *memory.MMIO, ioRAM []byte) *Controller {
+	acknowledge := func(old, value byte) byte {
+		return old &^ value
+	}
+	mmio.SetWriteFilter(0x04000000+ifOffset, acknowledge)
+	mmio.SetWriteFilter(0x04000000+ifOffset+1, acknowledge)
+	return &Controller{ioRAM: ioRAM}
+}
+
+func (c *Controller) read16(offset int) uint16 {
+	return uint16(c.ioRAM[offset]) | uint16(c.ioRAM[offset+1])<<8
+}
+
+// Request sets the IF bit of irq, whether or not it is enabled
+func (c *Controller) Request(irq Interrupt) {
+	flags := c.read16(ifOffset) | 1<<irq
+	c.ioRAM[ifOffset] = byte(flags)
+	c.ioRAM[ifOffset+1] = byte(flags >> 8)
+}
+
+// Pending reports whether an interrupt is requested and enabled in IE,
+// whether or not IME lets it through
+func (c *Controller) Pending() bool {
+	return c.PendingIn(0x3FFF)
+}
+
+// PendingIn is Pending for the interrupts whose bits are set in mask
+func (c *Controller) PendingIn(mask uint16) bool {
+	return c.read16(ieOffset)&c.read16(ifOffset)&mask != 0
+}
+
+// Enabled reports whether IME lets interrupts through
+func (c *Controller) Enabled() bool {
+	return c.ioRAM[imeOffset]&1 != 0
+}
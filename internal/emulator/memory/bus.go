@@ -0,0 +1,15 @@
+package memory
+
+// Bus is the memory an instruction reads and writes. MMIO is the bus of
+// the emulated GBA, tests can put anything behind it.
+//
+// Read32 at an unaligned address returns the aligned word rotated right by
+// the misalignment, like the ARM7TDMI bus does.
+type Bus interface {
+	Read8(addr uint32) (uint8, error)
+	Write8(addr uint32, data uint8) error
+	Read16(addr uint32) (uint16, error)
+	Write16(addr uint32, data uint16) error
+	Read32(addr uint32) (uint32, error)
+	Write32(addr uint32, data uint32) error
+}
@@ -0,0 +1,21 @@
+package memory
+
+import "fmt"
+
+// ErrBusFault is returned when an access hits an address that nothing is
+// mapped at, or that cannot be accessed the way it was attempted.
+type ErrBusFault struct {
+	Addr  uint32
+	Width uint8
+	Write bool
+	// Reason says why the access faulted, e.g. "not mapped".
+	Reason string
+}
+
+func (e *ErrBusFault) Error() string {
+	access := "read"
+	if e.Write {
+		access = "write"
+	}
+	return fmt.Sprintf("bus fault: %d-bit %s at 0x%08X: %s", int(e.Width)*8, access, e.Addr, e.Reason)
+}
@@ -1,10 +1,9 @@
 package memory
 
 import (
-	"fmt"
 	"sort"
 
-	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 type mmioMapping struct {
@@ -15,8 +14,54 @@ type mmioMapping struct {
 }
 
 type MMIO struct {
-	mmios  []mmioMapping
-	Config *config.Config
+	mmios []mmioMapping
+
+	accessHook func(addr uint32, size uint8, write bool)
+	writeHook  func(addr uint32)
+	filters    map[uint32]WriteFilter
+}
+
+// WriteFilter decides the byte an I/O register holds after the CPU writes
+// value over old, for registers with read-only or write-to-clear bits
+type WriteFilter func(old, value byte) byte
+
+// SetAccessHook calls hook on every read and write until it is replaced.
+// Passing a nil hook removes it.
+func (h *MMIO) SetAccessHook(hook func(addr uint32, size uint8, write bool)) {
+	h.accessHook = hook
+}
+
+// SetWriteHook calls hook with the unmirrored address of every write that
+// changes memory, including patches. Passing a nil hook removes it.
+func (h *MMIO) SetWriteHook(hook func(addr uint32)) {
+	h.writeHook = hook
+}
+
+// SetWriteFilter runs every write to the I/O register byte at addr through
+// filter. Patches aren't filtered.
+func (h *MMIO) SetWriteFilter(addr uint32, filter WriteFilter) {
+	if h.filters == nil {
+		h.filters = map[uint32]WriteFilter{}
+	}
+	h.filters[addr] = filter
+}
+
+// store writes a byte offset bytes into the device at index
+func (h *MMIO) store(index int, offset uint32, value byte) {
+	mapping := &h.mmios[index]
+	if h.filters != nil {
+		if filter, ok := h.filters[mapping.address+offset]; ok {
+			value = filter(mapping.data[offset], value)
+		}
+	}
+	mapping.data[offset] = value
+}
+
+// wrote reports a write at addr to the write hook
+func (h *MMIO) wrote(addr uint32) {
+	if h.writeHook != nil {
+		h.writeHook(addr)
+	}
 }
 
 func (h *MMIO) checkWritable(addr uint32) bool {
@@ -65,64 +110,64 @@ func (h *MMIO) mapMemory(addr uint32) uint32 {
 	// 0x02040000 - 0x02FFFFFF should map repeatedly to 0x02000000 - 0x0203FFFF
 	if addr >= 0x02040000 && addr < 0x03000000 {
 		mod := addr % 0x40000
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x02000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x02000000+mod)
 		}
 		addr = 0x02000000 + mod
 	}
 	// 0x03008000 - 0x03FFFFFF should map repeatedly to 0x03000000 - 0x03007FFF
 	if addr >= 0x03008000 && addr < 0x04000000 {
 		mod := addr % 0x8000
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x03000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x03000000+mod)
 		}
 		addr = 0x03000000 + mod
 	}
 	// 0x05000400 - 0x05FFFFFF should map repeatedly to 0x05000000 - 0x050003FF
 	if addr >= 0x05000400 && addr < 0x06000000 {
 		mod := addr % 0x400
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x05000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x05000000+mod)
 		}
 		addr = 0x05000000 + mod
 	}
 	// 0x06018000 - 0x06FFFFFF should map repeatedly to 0x06000000 - 0x06017FFF
 	if addr >= 0x06018000 && addr < 0x07000000 {
 		mod := addr % 0x18000
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x06000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x06000000+mod)
 		}
 		addr = 0x06000000 + mod
 	}
 	// 0x07000400 - 0x07FFFFFF should map repeatedly to 0x07000000 - 0x070003FF
 	if addr >= 0x07000400 && addr < 0x08000000 {
 		mod := addr % 0x400
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x07000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x07000000+mod)
 		}
 		addr = 0x07000000 + mod
 	}
 	// 0x0A000000 - 0x0BFFFFFF should map to 0x08000000 - 0x09FFFFFF
 	if addr >= 0x0A000000 && addr < 0x0C000000 {
 		mod := addr % 0x2000000
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x08000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x08000000+mod)
 		}
 		addr = 0x08000000 + mod
 	}
 	// 0x0C000000 - 0x0DFFFFFF should map to 0x08000000 - 0x09FFFFFF
 	if addr >= 0x0C000000 && addr < 0x0E000000 {
 		mod := addr % 0x2000000
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x08000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x08000000+mod)
 		}
 		addr = 0x08000000 + mod
 	}
 	// 0xE000000 - 0xFFFFFFFF should map to 0x0E000000 - 0x0E00FFFF
 	if addr >= 0x0E000000 && addr < 0x10000000 {
 		mod := addr % 0x10000
-		if h.Config.Debug {
-			fmt.Printf("MMIO address 0x%08x mapped to 0x%08x\n", addr, 0x0E000000+mod)
+		if logging.MMIO.Enabled(logging.LevelTrace) {
+			logging.MMIO.Tracef("MMIO address 0x%08x mapped to 0x%08x", addr, 0x0E000000+mod)
 		}
 		addr = 0x0E000000 + mod
 	}
@@ -131,90 +176,134 @@ func (h *MMIO) mapMemory(addr uint32) uint32 {
 }
 
 // findMMIO finds the MMIO device index that contains the given address
-func (h *MMIO) findMMIOIndex(addr *uint32) (int, error) {
+func (h *MMIO) findMMIOIndex(addr *uint32) (int, bool) {
 	*addr = h.mapMemory(*addr)
 
 	switch {
 	case *addr >= 0x0E000000 && *addr <= 0x0E00FFFF:
-		return 9, nil
+		return 9, true
 	case *addr >= 0x08000000 && *addr <= 0x09FFFFFF:
-		return 8, nil
+		return 8, true
 	case *addr >= 0x07000000 && *addr <= 0x070003FF:
-		return 7, nil
+		return 7, true
 	case *addr >= 0x06000000 && *addr <= 0x06017FFF:
-		return 6, nil
+		return 6, true
 	case *addr >= 0x05000000 && *addr <= 0x050003FF:
-		return 5, nil
+		return 5, true
 	case *addr >= 0x04000410 && *addr <= 0x04000411:
-		return 4, nil
+		return 4, true
 	case *addr >= 0x04000000 && *addr <= 0x040003FE:
-		return 3, nil
+		return 3, true
 	case *addr >= 0x03000000 && *addr <= 0x03007FFF:
-		return 2, nil
+		return 2, true
 	case *addr >= 0x02000000 && *addr <= 0x0203FFFF:
-		return 1, nil
+		return 1, true
 	case *addr < 0x00003FFF:
-		return 0, nil
+		return 0, true
 	}
 
-	return 0, fmt.Errorf("MMIO address %08x not found", addr)
+	return 0, false
 }
 
 // Read8 reads a 8-bit value from the MMIO address space and returns it.
 func (h *MMIO) Read8(addr uint32) (uint8, error) {
+	if h.accessHook != nil {
+		h.accessHook(addr, 1, false)
+	}
 	if (addr >= 0x00004000 && addr <= 0x01FFFFFF) || (addr >= 0x10000000 && addr <= 0xFFFFFFFF) {
 		return 0, nil
 	}
-	index, err := h.findMMIOIndex(&addr)
-	if err != nil {
-		return 0, err
+	index, ok := h.findMMIOIndex(&addr)
+	if !ok {
+		return 0, &ErrBusFault{Addr: addr, Width: 1, Write: false, Reason: "not mapped"}
 	}
 	nonMapped := addr - h.mmios[index].address
 	if nonMapped >= h.mmios[index].size {
-		return 0, fmt.Errorf("MMIO address %08x not found", addr)
+		return 0, &ErrBusFault{Addr: addr, Width: 1, Write: false, Reason: "not mapped"}
 	}
 	return h.mmios[index].data[nonMapped], nil
 }
 
 // Write8 writes a 8-bit value to the MMIO address space.
 func (h *MMIO) Write8(addr uint32, data uint8) error {
+	if h.accessHook != nil {
+		h.accessHook(addr, 1, true)
+	}
 	if (addr >= 0x00004000 && addr <= 0x01FFFFFF) || (addr >= 0x10000000 && addr <= 0xFFFFFFFF) {
 		return nil
 	}
-	if addr >= 0x06000000 && addr < 0x06FFFFFF {
-		// VRAM is not byte-addressable
-		return fmt.Errorf("VRAM address %08x not byte-addressable", addr)
+	index, ok := h.findMMIOIndex(&addr)
+	if !ok {
+		return &ErrBusFault{Addr: addr, Width: 1, Write: true, Reason: "not mapped"}
 	}
-	index, err := h.findMMIOIndex(&addr)
-	if err != nil {
-		return err
-	}
-	if h.Config.Debug {
-		fmt.Printf("MMIO write: 0x%08x 0x%02x\n", addr, data)
+	if logging.MMIO.Enabled(logging.LevelTrace) {
+		logging.MMIO.Tracef("MMIO write: 0x%08x 0x%02x", addr, data)
 	}
 	if !h.checkWritable(addr) {
-		return fmt.Errorf("MMIO address %08x not writable", addr)
+		h.dropWrite(addr, 1)
+		return nil
 	}
 	nonMapped := addr - h.mmios[index].address
 	if nonMapped >= h.mmios[index].size {
-		return fmt.Errorf("MMIO address %08x not found", addr)
+		return &ErrBusFault{Addr: addr, Width: 1, Write: true, Reason: "not mapped"}
+	}
+	if isVRAM(addr) {
+		// VRAM only takes halfwords. Bytes written to the background area
+		// land in both halves, bytes written to the object area are lost.
+		if addr >= h.objectVRAM() {
+			h.dropWrite(addr, 1)
+			return nil
+		}
+		nonMapped &^= 1
+		h.store(index, nonMapped, data)
+		h.store(index, nonMapped+1, data)
+		h.wrote(addr &^ 1)
+		return nil
 	}
-	h.mmios[index].data[nonMapped] = data
+	h.store(index, nonMapped, data)
+	h.wrote(addr)
 	return nil
 }
 
+// isVRAM reports whether the unmirrored addr is in VRAM
+func isVRAM(addr uint32) bool {
+	return addr >= 0x06000000 && addr < 0x06018000
+}
+
+// objectVRAM is where the object tiles start in VRAM, which depends on
+// whether DISPCNT selects a bitmap mode
+func (h *MMIO) objectVRAM() uint32 {
+	dispcnt := uint32(0x04000000)
+	index, ok := h.findMMIOIndex(&dispcnt)
+	if ok && h.mmios[index].address == dispcnt && h.mmios[index].data[0]&7 >= 3 {
+		return 0x06014000
+	}
+	return 0x06010000
+}
+
+// dropWrite ignores a write to memory the CPU can't write to, like games
+// writing to the ROM for the GPIO port or to the BIOS
+func (h *MMIO) dropWrite(addr uint32, width uint8) {
+	if logging.MMIO.Enabled(logging.LevelDebug) {
+		logging.MMIO.Debug("Ignoring write", "addr", logging.Hex(addr), "bits", int(width)*8)
+	}
+}
+
 // Read16 reads a 16-bit value from the MMIO address space and returns it.
 func (h *MMIO) Read16(addr uint32) (uint16, error) {
+	if h.accessHook != nil {
+		h.accessHook(addr, 2, false)
+	}
 	if (addr >= 0x00004000 && addr <= 0x01FFFFFF) || (addr >= 0x10000000 && addr <= 0xFFFFFFFF) {
 		return 0, nil
 	}
-	index, err := h.findMMIOIndex(&addr)
-	if err != nil {
-		return 0, err
+	index, ok := h.findMMIOIndex(&addr)
+	if !ok {
+		return 0, &ErrBusFault{Addr: addr, Width: 2, Write: false, Reason: "not mapped"}
 	}
 	nonMapped := addr - h.mmios[index].address
-	if nonMapped >= h.mmios[index].size {
-		return 0, fmt.Errorf("MMIO address %08x not found", addr)
+	if nonMapped+1 >= h.mmios[index].size {
+		return 0, &ErrBusFault{Addr: addr, Width: 2, Write: false, Reason: "not mapped"}
 	}
 	dataBytes := h.mmios[index].data[nonMapped : nonMapped+2]
 	return uint16(dataBytes[0]) | uint16(dataBytes[1])<<8, nil
@@ -222,48 +311,100 @@ func (h *MMIO) Read16(addr uint32) (uint16, error) {
 
 // Write16 writes a 16-bit value to the MMIO address space.
 func (h *MMIO) Write16(addr uint32, data uint16) error {
+	if h.accessHook != nil {
+		h.accessHook(addr, 2, true)
+	}
 	addr &= ^uint32(1)
 	if (addr >= 0x00004000 && addr <= 0x01FFFFFF) || (addr >= 0x10000000 && addr <= 0xFFFFFFFF) {
 		return nil
 	}
-	index, err := h.findMMIOIndex(&addr)
-	if err != nil {
-		return err
+	index, ok := h.findMMIOIndex(&addr)
+	if !ok {
+		return &ErrBusFault{Addr: addr, Width: 2, Write: true, Reason: "not mapped"}
 	}
 	if !h.checkWritable(addr) {
-		panic(fmt.Errorf("MMIO address %08x not writable", addr))
-		// return fmt.Errorf("MMIO address %08x not writable", addr)
+		h.dropWrite(addr, 2)
+		return nil
 	}
-	if h.Config.Debug {
-		fmt.Printf("MMIO write: 0x%08x 0x%04x\n", addr, data)
+	if logging.MMIO.Enabled(logging.LevelTrace) {
+		logging.MMIO.Tracef("MMIO write: 0x%08x 0x%04x", addr, data)
 	}
 	nonMapped := addr - h.mmios[index].address
-	if nonMapped >= h.mmios[index].size {
-		return fmt.Errorf("MMIO address %08x not found", addr)
+	if nonMapped+1 >= h.mmios[index].size {
+		return &ErrBusFault{Addr: addr, Width: 2, Write: true, Reason: "not mapped"}
+	}
+	h.store(index, nonMapped, byte(data))
+	h.store(index, nonMapped+1, byte(data>>8))
+	h.wrote(addr)
+	return nil
+}
+
+// Patch16 writes a 16-bit value even where the bus is read-only, so ROM
+// patches can be applied to the Game Pak.
+func (h *MMIO) Patch16(addr uint32, data uint16) error {
+	addr &= ^uint32(1)
+	index, ok := h.findMMIOIndex(&addr)
+	if !ok {
+		return &ErrBusFault{Addr: addr, Width: 2, Write: true, Reason: "not mapped"}
+	}
+	nonMapped := addr - h.mmios[index].address
+	if nonMapped+1 >= h.mmios[index].size {
+		return &ErrBusFault{Addr: addr, Width: 2, Write: true, Reason: "not mapped"}
 	}
 	h.mmios[index].data[nonMapped] = byte(data)
 	h.mmios[index].data[nonMapped+1] = byte(data >> 8)
+	h.wrote(addr)
+	return nil
+}
+
+// Poke writes bytes even where the bus is read-only or only takes
+// halfwords, like ROM and VRAM, so debuggers can patch anything mapped
+func (h *MMIO) Poke(addr uint32, data []byte) error {
+	for i, b := range data {
+		at := addr + uint32(i)
+		if mapped := h.mapMemory(at); h.checkWritable(mapped) && !isVRAM(mapped) {
+			if err := h.Write8(at, b); err != nil {
+				return err
+			}
+			continue
+		}
+		half, err := h.Read16(at &^ 1)
+		if err != nil {
+			return err
+		}
+		if at&1 == 0 {
+			half = half&0xFF00 | uint16(b)
+		} else {
+			half = half&0x00FF | uint16(b)<<8
+		}
+		if err := h.Patch16(at&^1, half); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // Read32 reads a 32-bit value from the MMIO address space and returns it.
 func (h *MMIO) Read32(addr uint32) (uint32, error) {
+	if h.accessHook != nil {
+		h.accessHook(addr, 4, false)
+	}
 	if (addr >= 0x00004000 && addr <= 0x01FFFFFF) || (addr >= 0x10000000 && addr <= 0xFFFFFFFF) {
 		return 0, nil
 	}
 	origAddr := addr
 	addr &= ^uint32(3)
-	index, err := h.findMMIOIndex(&addr)
-	if err != nil {
-		return 0, err
+	index, ok := h.findMMIOIndex(&addr)
+	if !ok {
+		return 0, &ErrBusFault{Addr: addr, Width: 4, Write: false, Reason: "not mapped"}
 	}
 	if addr >= 0x03007FFF && addr < 0x04000000 {
 		mod := addr % 0x8000
 		addr = 0x03000000 + mod
 	}
 	nonMapped := addr - h.mmios[index].address
-	if nonMapped >= h.mmios[index].size {
-		return 0, fmt.Errorf("MMIO address %08x not found", addr)
+	if nonMapped+3 >= h.mmios[index].size {
+		return 0, &ErrBusFault{Addr: addr, Width: 4, Write: false, Reason: "not mapped"}
 	}
 	dataBytes := h.mmios[index].data[nonMapped : nonMapped+4]
 	val := uint32(dataBytes[0]) | uint32(dataBytes[1])<<8 | uint32(dataBytes[2])<<16 | uint32(dataBytes[3])<<24
@@ -279,32 +420,37 @@ func (h *MMIO) Read32(addr uint32) (uint32, error) {
 
 // Write32 writes a 32-bit value to the MMIO address space.
 func (h *MMIO) Write32(addr uint32, data uint32) error {
+	if h.accessHook != nil {
+		h.accessHook(addr, 4, true)
+	}
 	addr &= ^uint32(3)
 	if (addr >= 0x00004000 && addr <= 0x01FFFFFF) || (addr >= 0x10000000 && addr <= 0xFFFFFFFF) {
 		return nil
 	}
-	index, err := h.findMMIOIndex(&addr)
-	if err != nil {
-		return err
+	index, ok := h.findMMIOIndex(&addr)
+	if !ok {
+		return &ErrBusFault{Addr: addr, Width: 4, Write: true, Reason: "not mapped"}
 	}
 	if addr >= 0x03007FFF && addr < 0x04000000 {
 		mod := addr % 0x8000
 		addr = 0x03000000 + mod
 	}
-	if h.Config.Debug {
-		fmt.Printf("MMIO write: 0x%08x 0x%08x\n", addr, data)
+	if logging.MMIO.Enabled(logging.LevelTrace) {
+		logging.MMIO.Tracef("MMIO write: 0x%08x 0x%08x", addr, data)
 	}
 	if !h.checkWritable(addr) {
-		return fmt.Errorf("MMIO address %08x not writable", addr)
+		h.dropWrite(addr, 4)
+		return nil
 	}
 	nonMapped := addr - h.mmios[index].address
-	if nonMapped >= h.mmios[index].size {
-		return fmt.Errorf("MMIO address %08x not found", addr)
-	}
-	h.mmios[index].data[nonMapped] = byte(data)
-	h.mmios[index].data[nonMapped+1] = byte(data >> 8)
-	h.mmios[index].data[nonMapped+2] = byte(data >> 16)
-	h.mmios[index].data[nonMapped+3] = byte(data >> 24)
+	if nonMapped+3 >= h.mmios[index].size {
+		return &ErrBusFault{Addr: addr, Width: 4, Write: true, Reason: "not mapped"}
+	}
+	h.store(index, nonMapped, byte(data))
+	h.store(index, nonMapped+1, byte(data>>8))
+	h.store(index, nonMapped+2, byte(data>>16))
+	h.store(index, nonMapped+3, byte(data>>24))
+	h.wrote(addr)
 	return nil
 }
 
@@ -320,9 +466,5 @@ func (h *MMIO) AddMMIO(data []byte, address uint32, size uint32) {
 		return h.mmios[i].address < h.mmios[j].address
 	})
 
-	// Print the ordered MMIO list
-	fmt.Println("MMIO mappings:")
-	for _, mmio := range h.mmios {
-		fmt.Printf("  %08x - %08x\n", mmio.address, mmio.address+mmio.size)
-	}
+	logging.MMIO.Debug("Mapped MMIO", "start", logging.Hex(address), "end", logging.Hex(address+size))
 }
@@ -0,0 +1,141 @@
+package ppu
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// ColorCorrection selects how XBGR1555 colors are mapped to the host display
+type ColorCorrection string
+
+const (
+	// ColorCorrectionNone expands the 5-bit channels linearly
+	ColorCorrectionNone ColorCorrection = "none"
+	// ColorCorrectionGBA mimics the dark, washed out original GBA LCD
+	ColorCorrectionGBA ColorCorrection = "gba"
+	// ColorCorrectionGBASP mimics the frontlit GBA SP (AGS-101) LCD
+	ColorCorrectionGBASP ColorCorrection = "gbasp"
+	// ColorCorrectionGBPlayer mimics the Game Boy Player output on a TV
+	ColorCorrectionGBPlayer ColorCorrection = "gbplayer"
+)
+
+// ColorCorrections lists every supported color correction profile
+//
+//nolint:golint,gochecknoglobals
+var ColorCorrections = []ColorCorrection{
+	ColorCorrectionNone,
+	ColorCorrectionGBA,
+	ColorCorrectionGBASP,
+	ColorCorrectionGBPlayer,
+}
+
+// colorProfile describes a screen as a gamma curve and a channel crosstalk matrix
+type colorProfile struct {
+	// Each row is the contribution of the input red, green and blue to one output channel
+	matrix       [3][3]float64
+	luminance    float64
+	targetGamma  float64
+	displayGamma float64
+}
+
+//nolint:golint,gochecknoglobals
+var colorProfiles = map[ColorCorrection]colorProfile{
+	ColorCorrectionGBA: {
+		matrix: [3][3]float64{
+			{0.82, 0.24, -0.06},
+			{0.125, 0.665, 0.21},
+			{0.195, 0.075, 0.73},
+		},
+		luminance:    0.94,
+		targetGamma:  2.7,
+		displayGamma: 2.2,
+	},
+	ColorCorrectionGBASP: {
+		matrix: [3][3]float64{
+			{0.86, 0.19, -0.05},
+			{0.11, 0.66, 0.23},
+			{0.1325, 0.0575, 0.81},
+		},
+		luminance:    1.0,
+		targetGamma:  2.2,
+		displayGamma: 2.2,
+	},
+	ColorCorrectionGBPlayer: {
+		matrix: [3][3]float64{
+			{0.9, 0.1, 0.0},
+			{0.03, 0.94, 0.03},
+			{0.0, 0.1, 0.9},
+		},
+		luminance:    1.0,
+		targetGamma:  2.2,
+		displayGamma: 2.2,
+	},
+}
+
+// ParseColorCorrection converts a profile name into a ColorCorrection
+func ParseColorCorrection(name string) (ColorCorrection, error) {
+	if name == "" {
+		return ColorCorrectionNone, nil
+	}
+	for _, profile := range ColorCorrections {
+		if string(profile) == name {
+			return profile, nil
+		}
+	}
+	return "", fmt.Errorf("unknown color correction %q, expected one of %v", name, ColorCorrections)
+}
+
+// colorTable maps every XBGR1555 color to a packed RGBA pixel
+type colorTable [0x8000]uint32
+
+func newColorTable(correction ColorCorrection) *colorTable {
+	table := &colorTable{}
+	profile, ok := colorProfiles[correction]
+	for color := range table {
+		r := uint32(color & 0x1F)
+		g := uint32((color >> 5) & 0x1F)
+		b := uint32((color >> 10) & 0x1F)
+		if !ok {
+			// Replicate the top bits into the bottom so 0x1F maps to 0xFF
+			table[color] = (r<<3 | r>>2) | (g<<3|g>>2)<<8 | (b<<3|b>>2)<<16 | 0xFF<<24
+			continue
+		}
+		in := [3]float64{
+			math.Pow(float64(r)/31, profile.targetGamma),
+			math.Pow(float64(g)/31, profile.targetGamma),
+			math.Pow(float64(b)/31, profile.targetGamma),
+		}
+		var out [3]uint32
+		for channel, row := range profile.matrix {
+			v := (row[0]*in[0] + row[1]*in[1] + row[2]*in[2]) * profile.luminance
+			v = math.Max(0, math.Min(1, v))
+			out[channel] = uint32(math.Round(math.Pow(v, 1/profile.displayGamma) * 255))
+		}
+		table[color] = out[0] | out[1]<<8 | out[2]<<16 | 0xFF<<24
+	}
+	return table
+}
+
+// writeColor converts an XBGR1555 color and stores it as RGBA at dest
+func (p *PPU) writeColor(dest []byte, color uint16) {
+	rgba := p.colors[color&0x7FFF]
+	dest[0] = byte(rgba)
+	dest[1] = byte(rgba >> 8)
+	dest[2] = byte(rgba >> 16)
+	dest[3] = byte(rgba >> 24)
+}
+
+// blendFrame mixes the frame with the previous one in place to emulate LCD
+// ghosting. The unblended frame is kept so the ghosting doesn't accumulate.
+func (p *PPU) blendFrame(frame *image.RGBA) {
+	if !p.hasPreviousFrame {
+		copy(p.previousFrame[:], frame.Pix)
+		p.hasPreviousFrame = true
+		return
+	}
+	for i, raw := range frame.Pix[:len(p.previousFrame)] {
+		frame.Pix[i] = byte((uint16(raw) + uint16(p.previousFrame[i]) + 1) / 2)
+		p.previousFrame[i] = raw
+	}
+}
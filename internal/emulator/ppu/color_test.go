@@ -0,0 +1,62 @@
+package ppu_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/ppu"
+)
+
+func TestColorCorrection(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		correction ppu.ColorCorrection
+		color      uint16
+		want       uint32
+	}{
+		// Linear expansion copies the top bits into the bottom ones
+		{ppu.ColorCorrectionNone, 0x0000, 0xFF000000},
+		{ppu.ColorCorrectionNone, 0x7FFF, 0xFFFFFFFF},
+		{ppu.ColorCorrectionNone, 0x4210, 0xFF848484},
+		{ppu.ColorCorrectionNone, 0x001F, 0xFF0000FF},
+		// The GBA screen is dimmed to 94%, so white is 0.94^(1/2.2)*255
+		{ppu.ColorCorrectionGBA, 0x0000, 0xFF000000},
+		{ppu.ColorCorrectionGBA, 0x7FFF, 0xFFF8F8F8},
+		{ppu.ColorCorrectionGBA, 0x4210, 0xFF6E6E6E},
+		{ppu.ColorCorrectionGBA, 0x001F, 0xFF7660E3},
+		// Blue loses its red to the negative crosstalk
+		{ppu.ColorCorrectionGBA, 0x7C00, 0xFFD77A00},
+		// The SP uses the same gamma in and out, so grays are unchanged
+		{ppu.ColorCorrectionGBASP, 0x0000, 0xFF000000},
+		{ppu.ColorCorrectionGBASP, 0x7FFF, 0xFFFFFFFF},
+		{ppu.ColorCorrectionGBASP, 0x4210, 0xFF848484},
+		{ppu.ColorCorrectionGBASP, 0x03E0, 0xFF46D378},
+		{ppu.ColorCorrectionGBPlayer, 0x0000, 0xFF000000},
+		{ppu.ColorCorrectionGBPlayer, 0x7FFF, 0xFFFFFFFF},
+		{ppu.ColorCorrectionGBPlayer, 0x4210, 0xFF848484},
+		{ppu.ColorCorrectionGBPlayer, 0x03E0, 0xFF5AF85A},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s 0x%04X", tt.correction, tt.color), func(t *testing.T) {
+			t.Parallel()
+			if got := ppu.CorrectColor(tt.correction, tt.color); got != tt.want {
+				t.Errorf("got 0x%08X, expected 0x%08X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseColorCorrection(t *testing.T) {
+	t.Parallel()
+	for _, correction := range ppu.ColorCorrections {
+		if got, err := ppu.ParseColorCorrection(string(correction)); err != nil || got != correction {
+			t.Errorf("%s: got %q, %v", correction, got, err)
+		}
+	}
+	if got, err := ppu.ParseColorCorrection(""); err != nil || got != ppu.ColorCorrectionNone {
+		t.Errorf("empty name: got %q, %v, expected none", got, err)
+	}
+	if _, err := ppu.ParseColorCorrection("crt"); err == nil {
+		t.Error("parsed an unknown color correction")
+	}
+}
@@ -0,0 +1,7 @@
+package ppu
+
+// CorrectColor converts an XBGR1555 color to packed RGBA like the PPU does
+// with correction
+func CorrectColor(correction ColorCorrection, color uint16) uint32 {
+	return newColorTable(correction)[color]
+}
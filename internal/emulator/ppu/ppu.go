@@ -5,8 +5,10 @@ import (
 	"image"
 
 	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/interrupts"
 	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
-	"golang.org/x/image/draw"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/scheduler"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
 )
 
 const (
@@ -17,36 +19,88 @@ const (
 	// PaletteRAMSize is 1KB
 	PaletteRAMSize = 1 * 1024
 	NumPixels      = 240 * 160
+
+	// DrawCycles is how long a line is drawn for before HBlank
+	DrawCycles = 240 * 4
+	// HBlankCycles is how long HBlank lasts, 1232 cycles a line in all
+	HBlankCycles = 272
+	// VisibleLines are drawn before VBlank
+	VisibleLines = 160
+	// Lines is the number of lines in a frame, including VBlank
+	Lines = 228
+)
+
+// DISPSTAT bits
+const (
+	dispstatVBlank       = 1 << 0
+	dispstatHBlank       = 1 << 1
+	dispstatVCount       = 1 << 2
+	dispstatVBlankIRQ    = 1 << 3
+	dispstatHBlankIRQ    = 1 << 4
+	dispstatVCountIRQ    = 1 << 5
+	dispstatWritableMask = dispstatVBlankIRQ | dispstatHBlankIRQ | dispstatVCountIRQ
 )
 
+// modeLayouts describes each display mode
+//
+//nolint:golint,gochecknoglobals
+var modeLayouts = [6]string{
+	"Tiled 240x160 8-bpp with 4 backgrounds",
+	"Tiled 240x160 8-bpp with 3 backgrounds",
+	"Tiled 240x160 8-bpp with 2 backgrounds",
+	"Bitmap 240x160 16-bpp with 1 background",
+	"Bitmap 240x160 8-bpp with 2 backgrounds",
+	"Bitmap 160x128 16-bpp with 2 backgrounds",
+}
+
 type PPU struct {
 	virtualMemory *memory.MMIO
 	vRAM          [VRAMSize]byte
 	oam           [OAMSize]byte
 	paletteRAM    [PaletteRAMSize]byte
 	ioRAM         []byte
-	cycle         int
-	pixelIndex    int
+	scheduler     *scheduler.Scheduler
+	interrupts    *interrupts.Controller
 	scanlineIndex uint8
 	frameReady    bool
+	frames        uint64
 	config        *config.Config
 	HBlank        bool
 	VBlank        bool
+	colors        *colorTable
+	// previousFrame is the last unblended frame, when hasPreviousFrame
+	previousFrame    [NumPixels * 4]byte
+	hasPreviousFrame bool
 }
 
-func NewPPU(config *config.Config, mmio *memory.MMIO, ioRAM []byte) *PPU {
+// NewPPU creates a PPU that starts drawing the first line on sched and
+// raises its interrupts through irqs
+func NewPPU(config *config.Config, mmio *memory.MMIO, ioRAM []byte, sched *scheduler.Scheduler, irqs *interrupts.Controller) *PPU {
 	ppu := &PPU{
 		virtualMemory: mmio,
-		cycle:         0,
+		scheduler:     sched,
+		interrupts:    irqs,
 		frameReady:    false,
 		config:        config,
 		ioRAM:         ioRAM,
+		colors:        newColorTable(ColorCorrection(config.ColorCorrection)),
 	}
 
 	mmio.AddMMIO(ppu.paletteRAM[:], 0x05000000, PaletteRAMSize)
 	mmio.AddMMIO(ppu.vRAM[:], 0x06000000, VRAMSize)
 	mmio.AddMMIO(ppu.oam[:], 0x07000000, OAMSize)
 
+	// The flags in DISPSTAT and VCOUNT are read-only
+	mmio.SetWriteFilter(0x04000004, func(old, value byte) byte {
+		return old&^dispstatWritableMask | value&dispstatWritableMask
+	})
+	readOnly := func(old, _ byte) byte { return old }
+	mmio.SetWriteFilter(0x04000006, readOnly)
+	mmio.SetWriteFilter(0x04000007, readOnly)
+
+	ppu.updateVCount()
+	sched.Schedule(scheduler.HBlankStart, DrawCycles, ppu.startHBlank)
+
 	return ppu
 }
 
@@ -71,145 +125,112 @@ func (p *PPU) FrameReady() bool {
 	return p.frameReady
 }
 
+// Frames returns the number of frames drawn since power on
+func (p *PPU) Frames() uint64 {
+	return p.frames
+}
+
 func (p *PPU) ClearFrameReady() {
 	p.frameReady = false
 }
 
-func (p *PPU) FrameBuffer() []byte {
+// FrameBuffer renders the current frame at the native 240x160 resolution
+// into a new image, or returns nil in the display modes it can't draw yet
+func (p *PPU) FrameBuffer() *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, 240, 160))
+	if !p.RenderFrame(frame) {
+		return nil
+	}
+	return frame
+}
+
+// RenderFrame renders the current frame into frame, which has to be
+// 240x160, without allocating. It reports false and leaves frame alone in
+// the display modes it can't draw yet.
+func (p *PPU) RenderFrame(frame *image.RGBA) bool {
 	// Grab the first 16 bites of ioRAM
 	dispCNT := uint16(p.ioRAM[0]) | uint16(p.ioRAM[1])<<8
 
 	// Grab bits 0-2 of dispCNT to get the display mode
 	displayMode := dispCNT & 0x7
 
-	var originalRender *image.RGBA
+	if displayMode > 5 {
+		logging.PPU.Warn("Invalid display mode", "mode", displayMode)
+		return false
+	}
+	if logging.PPU.Enabled(logging.LevelDebug) {
+		logging.PPU.Debug("Rendering frame", "mode", displayMode, "layout", modeLayouts[displayMode])
+	}
 
 	switch displayMode {
-	case 0:
-		fmt.Println("Mode 0: Tiled 240x160 8-bpp with 4 backgrounds")
-	case 1:
-		fmt.Println("Mode 1: Tiled 240x160 8-bpp with 3 backgrounds")
-	case 2:
-		fmt.Println("Mode 2: Tiled 240x160 8-bpp with 2 backgrounds")
 	case 3:
-		fmt.Println("Mode 3: Bitmap 240x160 16-bpp with 1 background")
-		originalRender = p.renderMode3()
+		p.renderMode3(frame)
 	case 4:
-		fmt.Println("Mode 4: Bitmap 240x160 8-bpp with 2 backgrounds")
-		originalRender = p.renderMode4()
-	case 5:
-		fmt.Println("Mode 5: Bitmap 160x128 16-bpp with 2 backgrounds")
+		p.renderMode4(frame)
 	default:
-		panic(fmt.Sprintf("Invalid display mode: %d", displayMode))
+		return false
 	}
 
-	if originalRender == nil {
-		return nil
+	if p.config.FrameBlending {
+		p.blendFrame(frame)
 	}
-
-	upscaled := p.upscale(originalRender)
-
-	return upscaled
+	return true
 }
 
-func (p *PPU) upscale(render *image.RGBA) []byte {
-	// Calculate the target dimensions
-	// We use constants here because we want the scaled
-	// image to always be a factor of 240x160
-	targetWidth := int(240 * p.config.Scale)
-	targetHeight := int(160 * p.config.Scale)
-
-	// Create a new blank image with the target dimensions
-	upscaledImage := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
-
-	// Perform bicubic interpolation
-	draw.CatmullRom.Scale(upscaledImage, upscaledImage.Bounds(), render, render.Bounds(), draw.Src, nil)
-
-	// Convert the upscaled image to a byte array for the renderer
-	upscaled := make([]byte, targetWidth*targetHeight*4)
-	copy(upscaled, upscaledImage.Pix)
-
-	return upscaled
-}
-
-func (p *PPU) Step() {
-	dispCNT := uint16(p.ioRAM[0]) | uint16(p.ioRAM[1])<<8
-
-	// Grab bits 0-2 of dispCNT to get the display mode
-	displayMode := dispCNT & 0x7
-
-	if displayMode > 5 {
-		panic(fmt.Sprintf("Invalid display mode: %d", displayMode))
-	}
-
-	if p.config.Debug {
-		fmt.Printf("PPU Cycle: %d\n", p.cycle)
+// startHBlank runs when a line has been drawn, VBlank lines included
+func (p *PPU) startHBlank() {
+	p.HBlank = true
+	p.ioRAM[0x04] |= dispstatHBlank
+	if p.ioRAM[0x04]&dispstatHBlankIRQ != 0 {
+		p.interrupts.Request(interrupts.HBlank)
 	}
-	// Every 4 cycles is a pixel
-	if p.cycle%4 == 0 {
-		// Grab the current pixel
-		if p.config.Debug {
-			fmt.Println("Pixel")
-		}
-		p.pixelIndex++
-	}
-
-	newlyHBlank := false
-	newlyNotHBlank := false
-	newlyVBlank := false
-	newlyNotVBlank := false
+	p.scheduler.Schedule(scheduler.HBlankEnd, HBlankCycles, p.endHBlank)
+}
 
-	// Every 240+68 pixelIndexes is a scanline
-	if p.pixelIndex > 240+68 {
-		// Scanline is done
-		if p.config.Debug {
-			fmt.Println("Scanline")
-		}
-		p.scanlineIndex++
-		p.ioRAM[0x06] = p.scanlineIndex
-		p.pixelIndex = 0
-		newlyNotHBlank = true
-		p.HBlank = false
-	} else if p.pixelIndex == 240 {
-		// HBlank
-		newlyHBlank = true
-		p.HBlank = true
+// endHBlank runs at the end of a line and starts the next one
+func (p *PPU) endHBlank() {
+	if logging.PPU.Enabled(logging.LevelDebug) {
+		logging.PPU.Debug("Scanline")
 	}
+	p.HBlank = false
+	p.ioRAM[0x04] &^= dispstatHBlank
+	p.scanlineIndex++
 
-	// Every 160+68 scanlines is a frame
-	if p.scanlineIndex > 160+68 {
+	switch p.scanlineIndex {
+	case Lines:
 		// Frame is done
-		if p.config.Debug {
-			fmt.Println("Frame")
+		if logging.PPU.Enabled(logging.LevelDebug) {
+			logging.PPU.Debug("Frame")
 		}
 		p.frameReady = true
-		p.VBlank = false
-		p.HBlank = false
-		newlyNotVBlank = true
-		newlyNotHBlank = true
+		p.frames++
 		p.scanlineIndex = 0
-		p.cycle = 0
-	} else if p.scanlineIndex == 160 {
-		// VBlank
+	case VisibleLines:
 		p.VBlank = true
-		newlyVBlank = true
-	}
-
-	p.cycle++
-
-	if newlyHBlank {
-		p.ioRAM[0x04] |= 0x2
-	}
-
-	if newlyNotHBlank {
-		p.ioRAM[0x04] &= 0xFD
+		p.ioRAM[0x04] |= dispstatVBlank
+		if p.ioRAM[0x04]&dispstatVBlankIRQ != 0 {
+			p.interrupts.Request(interrupts.VBlank)
+		}
+	case Lines - 1:
+		// The flag is already clear on the last line
+		p.VBlank = false
+		p.ioRAM[0x04] &^= dispstatVBlank
 	}
+	p.updateVCount()
+	p.scheduler.Schedule(scheduler.HBlankStart, DrawCycles, p.startHBlank)
+}
 
-	if newlyVBlank {
-		p.ioRAM[0x04] |= 0x1
+// updateVCount shows the current line in VCOUNT and compares it with the
+// line set in DISPSTAT
+func (p *PPU) updateVCount() {
+	p.ioRAM[0x06] = p.scanlineIndex
+	p.ioRAM[0x07] = 0
+	if p.scanlineIndex != p.ioRAM[0x05] {
+		p.ioRAM[0x04] &^= dispstatVCount
+		return
 	}
-
-	if newlyNotVBlank {
-		p.ioRAM[0x04] &= 0xFE
+	p.ioRAM[0x04] |= dispstatVCount
+	if p.ioRAM[0x04]&dispstatVCountIRQ != 0 {
+		p.interrupts.Request(interrupts.VCount)
 	}
 }
@@ -0,0 +1,45 @@
+package ppu_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+func write16(t *testing.T, c *cpu.ARM7TDMI, addr uint32, value uint16) {
+	t.Helper()
+	if err := c.GetBus().Write16(addr, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRenderFrame isn't parallel, AllocsPerRun can't count with other
+// tests running
+func TestRenderFrame(t *testing.T) {
+	c := testutil.NewCPU(t, nil, &config.Config{FrameBlending: true})
+	frame := image.NewRGBA(image.Rect(0, 0, 240, 160))
+	if c.PPU.RenderFrame(frame) {
+		t.Error("rendered display mode 0, which isn't drawn yet")
+	}
+
+	write16(t, c, 0x04000000, 3)
+	write16(t, c, 0x06000000, 0x001F) // red
+	if !c.PPU.RenderFrame(frame) {
+		t.Fatal("didn't render display mode 3")
+	}
+	if got := frame.Pix[:4]; got[0] != 0xFF || got[1] != 0 || got[2] != 0 {
+		t.Errorf("first frame starts with % X, expected it unblended", got)
+	}
+	write16(t, c, 0x06000000, 0x7C00) // blue
+	c.PPU.RenderFrame(frame)
+	if got := frame.Pix[:4]; got[0] != 0x80 || got[1] != 0 || got[2] != 0x80 {
+		t.Errorf("second frame starts with % X, expected red and blue blended", got)
+	}
+
+	if allocs := testing.AllocsPerRun(10, func() { c.PPU.RenderFrame(frame) }); allocs != 0 {
+		t.Errorf("rendering a blended frame allocates %v times", allocs)
+	}
+}
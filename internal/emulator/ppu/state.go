@@ -0,0 +1,59 @@
+package ppu
+
+import "github.com/USA-RedDragon/go-gba/internal/emulator/scheduler"
+
+// State is what a save state holds of the PPU. It only has fixed-size
+// fields, so it can go through encoding/binary.
+type State struct {
+	VRAM       [VRAMSize]byte
+	OAM        [OAMSize]byte
+	PaletteRAM [PaletteRAMSize]byte
+	Scanline   uint8
+	FrameReady bool
+	Frames     uint64
+	HBlank     bool
+	VBlank     bool
+	// InHBlank tells which of HBlankEnd and HBlankStart is due at EventAt
+	InHBlank bool
+	EventAt  uint64
+}
+
+// SaveState copies the PPU state into state
+func (p *PPU) SaveState(state *State) {
+	state.VRAM = p.vRAM
+	state.OAM = p.oam
+	state.PaletteRAM = p.paletteRAM
+	state.Scanline = p.scanlineIndex
+	state.FrameReady = p.frameReady
+	state.Frames = p.frames
+	state.HBlank = p.HBlank
+	state.VBlank = p.VBlank
+	state.EventAt, state.InHBlank = p.scheduler.Due(scheduler.HBlankEnd)
+	if !state.InHBlank {
+		state.EventAt, _ = p.scheduler.Due(scheduler.HBlankStart)
+	}
+}
+
+// LoadState restores the PPU from state. The scheduler has to be restored
+// first, as the PPU schedules its next event from there.
+func (p *PPU) LoadState(state *State) {
+	p.vRAM = state.VRAM
+	p.oam = state.OAM
+	p.paletteRAM = state.PaletteRAM
+	p.scanlineIndex = state.Scanline
+	p.frameReady = state.FrameReady
+	p.frames = state.Frames
+	p.HBlank = state.HBlank
+	p.VBlank = state.VBlank
+	p.hasPreviousFrame = false
+
+	delay := uint64(0)
+	if now := p.scheduler.Now(); state.EventAt > now {
+		delay = state.EventAt - now
+	}
+	if state.InHBlank {
+		p.scheduler.Schedule(scheduler.HBlankEnd, delay, p.endHBlank)
+	} else {
+		p.scheduler.Schedule(scheduler.HBlankStart, delay, p.startHBlank)
+	}
+}
@@ -0,0 +1,474 @@
+package ppu
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Filter selects how the 240x160 frame is scaled up to the window
+type Filter string
+
+const (
+	// FilterNearest scales with nearest-neighbor sampling on the GPU
+	FilterNearest Filter = "nearest"
+	// FilterBilinear scales with bilinear sampling on the GPU
+	FilterBilinear Filter = "bilinear"
+	// FilterCatmullRom scales with bicubic interpolation on the CPU
+	FilterCatmullRom Filter = "catmullrom"
+	// FilterScale2x runs the Scale2x (EPX) pixel-art scaler
+	FilterScale2x Filter = "scale2x"
+	// FilterScale3x runs the Scale3x pixel-art scaler
+	FilterScale3x Filter = "scale3x"
+	// FilterScale4x runs Scale2x twice
+	FilterScale4x Filter = "scale4x"
+	// FilterXBR runs the 2xBR pixel-art scaler
+	FilterXBR Filter = "xbr"
+	// FilterHQ2x runs the HQ2x pixel-art scaler
+	FilterHQ2x Filter = "hq2x"
+	// FilterHQ3x runs the HQ3x pixel-art scaler
+	FilterHQ3x Filter = "hq3x"
+	// FilterHQ4x runs the HQ4x pixel-art scaler
+	FilterHQ4x Filter = "hq4x"
+)
+
+// Filters lists every supported upscaling filter
+//
+//nolint:golint,gochecknoglobals
+var Filters = []Filter{
+	FilterNearest,
+	FilterBilinear,
+	FilterCatmullRom,
+	FilterScale2x,
+	FilterScale3x,
+	FilterScale4x,
+	FilterXBR,
+	FilterHQ2x,
+	FilterHQ3x,
+	FilterHQ4x,
+}
+
+// ParseFilter converts a filter name into a Filter
+func ParseFilter(name string) (Filter, error) {
+	if name == "" {
+		return FilterNearest, nil
+	}
+	for _, filter := range Filters {
+		if string(filter) == name {
+			return filter, nil
+		}
+	}
+	return "", fmt.Errorf("unknown filter %q, expected one of %v", name, Filters)
+}
+
+// Smooth reports whether the remaining GPU scaling should use linear sampling
+func (f Filter) Smooth() bool {
+	return f != FilterNearest
+}
+
+// Scaler runs the CPU part of a filter. Like RenderFrame it draws into
+// buffers it keeps between frames, so the image Upscale returns is only
+// valid until the next call.
+type Scaler struct {
+	filter Filter
+	// Scale4x runs Scale2x twice, so it needs a second grid and image
+	grids [2]pixelGrid
+	outs  [2]*image.RGBA
+}
+
+// NewScaler returns a scaler for filter
+func NewScaler(filter Filter) *Scaler {
+	return &Scaler{filter: filter}
+}
+
+// Filter returns the filter the scaler runs
+func (s *Scaler) Filter() Filter {
+	return s.filter
+}
+
+// Upscale runs the CPU part of the filter on a 240x160 frame. The result still
+// needs to be scaled to width x height by the renderer.
+func (s *Scaler) Upscale(render *image.RGBA, width, height int) *image.RGBA {
+	switch s.filter {
+	case FilterCatmullRom:
+		if width <= 0 || height <= 0 {
+			return render
+		}
+		out := s.output(0, width, height)
+		draw.CatmullRom.Scale(out, out.Bounds(), render, render.Bounds(), draw.Src, nil)
+		return out
+	case FilterScale2x:
+		return scale2x(s.grid(0, render), s.scaled(0, render, 2))
+	case FilterScale3x:
+		return scale3x(s.grid(0, render), s.scaled(0, render, 3))
+	case FilterScale4x:
+		twice := scale2x(s.grid(0, render), s.scaled(0, render, 2))
+		return scale2x(s.grid(1, twice), s.scaled(1, twice, 2))
+	case FilterXBR:
+		return xbr2x(s.grid(0, render), s.scaled(0, render, 2))
+	case FilterHQ2x:
+		return hqx(s.grid(0, render), s.scaled(0, render, 2), 2)
+	case FilterHQ3x:
+		return hqx(s.grid(0, render), s.scaled(0, render, 3), 3)
+	case FilterHQ4x:
+		return hqx(s.grid(0, render), s.scaled(0, render, 4), 4)
+	case FilterNearest, FilterBilinear:
+		return render
+	}
+	return render
+}
+
+// grid loads render into grid n
+func (s *Scaler) grid(n int, render *image.RGBA) pixelGrid {
+	s.grids[n].load(render)
+	return s.grids[n]
+}
+
+// scaled returns output image n, sized to render scaled by factor
+func (s *Scaler) scaled(n int, render *image.RGBA, factor int) *image.RGBA {
+	return s.output(n, render.Bounds().Dx()*factor, render.Bounds().Dy()*factor)
+}
+
+// output returns output image n, only allocating it when the size changes.
+// The filters overwrite every pixel, so it isn't cleared.
+func (s *Scaler) output(n, width, height int) *image.RGBA {
+	if s.outs[n] == nil || s.outs[n].Rect.Dx() != width || s.outs[n].Rect.Dy() != height {
+		s.outs[n] = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	return s.outs[n]
+}
+
+// Viewport returns the largest rectangle with the GBA's 3:2 aspect ratio that
+// fits inside width x height, centered. With integer set, the scale factor is
+// rounded down to a whole number so every GBA pixel has the same size.
+func Viewport(width, height int, integer bool) image.Rectangle {
+	scale := float64(width) / 240
+	if s := float64(height) / 160; s < scale {
+		scale = s
+	}
+	if integer && scale >= 1 {
+		scale = float64(int(scale))
+	}
+	w := int(240 * scale)
+	h := int(160 * scale)
+	x := (width - w) / 2
+	y := (height - h) / 2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// pixelGrid gives clamped access to the pixels of an image as packed uint32s
+type pixelGrid struct {
+	pix    []uint32
+	width  int
+	height int
+}
+
+// load copies the pixels of render into the grid, reusing its buffer
+func (g *pixelGrid) load(render *image.RGBA) {
+	bounds := render.Bounds()
+	g.width, g.height = bounds.Dx(), bounds.Dy()
+	if size := g.width * g.height; cap(g.pix) < size {
+		g.pix = make([]uint32, size)
+	} else {
+		g.pix = g.pix[:size]
+	}
+	for y := 0; y < g.height; y++ {
+		row := render.Pix[y*render.Stride:]
+		for x := 0; x < g.width; x++ {
+			i := x * 4
+			g.pix[y*g.width+x] = uint32(row[i]) | uint32(row[i+1])<<8 | uint32(row[i+2])<<16 | uint32(row[i+3])<<24
+		}
+	}
+}
+
+func (g pixelGrid) at(x, y int) uint32 {
+	if x < 0 {
+		x = 0
+	} else if x >= g.width {
+		x = g.width - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= g.height {
+		y = g.height - 1
+	}
+	return g.pix[y*g.width+x]
+}
+
+func setPixel(img *image.RGBA, x, y int, c uint32) {
+	i := y*img.Stride + x*4
+	img.Pix[i] = byte(c)
+	img.Pix[i+1] = byte(c >> 8)
+	img.Pix[i+2] = byte(c >> 16)
+	img.Pix[i+3] = byte(c >> 24)
+}
+
+func scale2x(src pixelGrid, out *image.RGBA) *image.RGBA {
+	for y := 0; y < src.height; y++ {
+		for x := 0; x < src.width; x++ {
+			//   A
+			// C P B
+			//   D
+			p := src.at(x, y)
+			a := src.at(x, y-1)
+			b := src.at(x+1, y)
+			c := src.at(x-1, y)
+			d := src.at(x, y+1)
+
+			e0, e1, e2, e3 := p, p, p, p
+			if c == a && c != d && a != b {
+				e0 = a
+			}
+			if a == b && a != c && b != d {
+				e1 = b
+			}
+			if d == c && d != b && c != a {
+				e2 = c
+			}
+			if b == d && b != a && d != c {
+				e3 = d
+			}
+			setPixel(out, x*2, y*2, e0)
+			setPixel(out, x*2+1, y*2, e1)
+			setPixel(out, x*2, y*2+1, e2)
+			setPixel(out, x*2+1, y*2+1, e3)
+		}
+	}
+	return out
+}
+
+func scale3x(src pixelGrid, out *image.RGBA) *image.RGBA {
+	for y := 0; y < src.height; y++ {
+		for x := 0; x < src.width; x++ {
+			// A B C
+			// D E F
+			// G H I
+			a, b, c := src.at(x-1, y-1), src.at(x, y-1), src.at(x+1, y-1)
+			d, e, f := src.at(x-1, y), src.at(x, y), src.at(x+1, y)
+			g, h, i := src.at(x-1, y+1), src.at(x, y+1), src.at(x+1, y+1)
+
+			out3 := [9]uint32{e, e, e, e, e, e, e, e, e}
+			if b != h && d != f {
+				if d == b {
+					out3[0] = d
+				}
+				if (d == b && e != c) || (b == f && e != a) {
+					out3[1] = b
+				}
+				if b == f {
+					out3[2] = f
+				}
+				if (d == b && e != g) || (d == h && e != a) {
+					out3[3] = d
+				}
+				if (b == f && e != i) || (h == f && e != c) {
+					out3[5] = f
+				}
+				if d == h {
+					out3[6] = d
+				}
+				if (d == h && e != i) || (h == f && e != g) {
+					out3[7] = h
+				}
+				if h == f {
+					out3[8] = f
+				}
+			}
+			for n, px := range out3 {
+				setPixel(out, x*3+n%3, y*3+n/3, px)
+			}
+		}
+	}
+	return out
+}
+
+// yuvDistance is the perceptual color distance used by the xBR edge detection
+func yuvDistance(a, b uint32) int {
+	dr := int(a&0xFF) - int(b&0xFF)
+	dg := int((a>>8)&0xFF) - int((b>>8)&0xFF)
+	db := int((a>>16)&0xFF) - int((b>>16)&0xFF)
+	y := abs(dr*299+dg*587+db*114) / 1000
+	u := abs(-dr*169-dg*331+db*500) / 1000
+	v := abs(dr*500-dg*419-db*81) / 1000
+	return 48*y + 7*u + 6*v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// blend mixes two packed pixels 50/50
+func blend(a, b uint32) uint32 {
+	return ((a & 0xFEFEFEFE) >> 1) + ((b & 0xFEFEFEFE) >> 1) + (a & b & 0x01010101)
+}
+
+func xbr2x(src pixelGrid, out *image.RGBA) *image.RGBA {
+	for y := 0; y < src.height; y++ {
+		for x := 0; x < src.width; x++ {
+			// Each of the four output pixels is computed from the same
+			// neighborhood, rotated so the corner being filled is bottom-right.
+			for rotation := 0; rotation < 4; rotation++ {
+				px := xbrCorner(src, x, y, rotation)
+				ox, oy := rotate(1, 1, rotation)
+				setPixel(out, x*2+(ox+1)/2, y*2+(oy+1)/2, px)
+			}
+		}
+	}
+	return out
+}
+
+// rotate turns a neighborhood offset by rotation * 90 degrees clockwise
+func rotate(dx, dy, rotation int) (int, int) {
+	for i := 0; i < rotation; i++ {
+		dx, dy = -dy, dx
+	}
+	return dx, dy
+}
+
+// xbrCorner computes the bottom-right output pixel of the 2xBR level 1 rule
+// for the pixel at x, y with the neighborhood rotated by rotation.
+//
+//	   A1 B1 C1
+//	A0 A  B  C  C4
+//	D0 D  E  F  F4
+//	G0 G  H  I  I4
+//	   G5 H5 I5
+func xbrCorner(src pixelGrid, x, y, rotation int) uint32 {
+	at := func(dx, dy int) uint32 {
+		rx, ry := rotate(dx, dy, rotation)
+		return src.at(x+rx, y+ry)
+	}
+	e := at(0, 0)
+	b, c := at(0, -1), at(1, -1)
+	d, f := at(-1, 0), at(1, 0)
+	g, h, i := at(-1, 1), at(0, 1), at(1, 1)
+	f4, i4 := at(2, 0), at(2, 1)
+	h5, i5 := at(0, 2), at(1, 2)
+
+	if e == f || e == h {
+		return e
+	}
+
+	wd1 := yuvDistance(e, c) + yuvDistance(e, g) + yuvDistance(i, f4) + yuvDistance(i, h5) + 4*yuvDistance(h, f)
+	wd2 := yuvDistance(h, d) + yuvDistance(h, i5) + yuvDistance(f, i4) + yuvDistance(f, b) + 4*yuvDistance(e, i)
+	if wd1 >= wd2 {
+		return e
+	}
+
+	px := h
+	if yuvDistance(e, f) <= yuvDistance(e, h) {
+		px = f
+	}
+	return blend(e, px)
+}
+
+// hqSimilar is the hqx test for two colors looking alike: their luma and
+// chroma differ by at most 48, 7 and 6
+func hqSimilar(a, b uint32) bool {
+	if a == b {
+		return true
+	}
+	yuv := func(c uint32) (int, int, int) {
+		r, g, b := int(c&0xFF), int((c>>8)&0xFF), int((c>>16)&0xFF)
+		return (r + g + b) >> 2, 128 + ((r - b) >> 2), 128 + ((-r + 2*g - b) >> 3)
+	}
+	y1, u1, v1 := yuv(a)
+	y2, u2, v2 := yuv(b)
+	return abs(y1-y2) <= 0x30 && abs(u1-u2) <= 0x07 && abs(v1-v2) <= 0x06
+}
+
+// mix averages packed pixels channel by channel with the given weights
+func mix(pixels [3]uint32, weights [3]int) uint32 {
+	total := weights[0] + weights[1] + weights[2]
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		sum := 0
+		for i, px := range pixels {
+			sum += int((px>>shift)&0xFF) * weights[i]
+		}
+		out |= uint32(sum/total) << shift
+	}
+	return out
+}
+
+// hqCorner is the weights of the center pixel and the two neighbors a
+// corner is blended with, for the subpixel right in the corner and the ones
+// next to it
+type hqCorner struct {
+	pixels       [3]uint32
+	outer, inner [3]int
+}
+
+// newHQCorner classifies the corner of e between its sides s1 and s2 and
+// the diagonal d. When the sides look alike and unlike e, an edge runs
+// across the corner and it is cut off; when only the diagonal differs it is
+// softened.
+func newHQCorner(e, s1, s2, d uint32) hqCorner {
+	switch {
+	case hqSimilar(s1, s2) && !hqSimilar(e, s1):
+		return hqCorner{pixels: [3]uint32{e, s1, s2}, outer: [3]int{2, 1, 1}, inner: [3]int{6, 1, 1}}
+	case !hqSimilar(e, d) && hqSimilar(e, s1) && hqSimilar(e, s2):
+		return hqCorner{pixels: [3]uint32{e, d, d}, outer: [3]int{6, 1, 1}, inner: [3]int{1, 0, 0}}
+	}
+	return hqCorner{pixels: [3]uint32{e, e, e}, outer: [3]int{1, 0, 0}, inner: [3]int{1, 0, 0}}
+}
+
+// hqx scales by n into out with the hqx similarity test. Rather than the 256 case
+// tables of the original, each n x n block is split into its four corners,
+// which blend with the neighbors around them as classified by newHQCorner.
+// The middle row and column of odd sizes keep the center pixel.
+func hqx(src pixelGrid, out *image.RGBA, n int) *image.RGBA {
+	half := n / 2
+	for y := 0; y < src.height; y++ {
+		for x := 0; x < src.width; x++ {
+			// A B C
+			// D E F
+			// G H I
+			a, b, c := src.at(x-1, y-1), src.at(x, y-1), src.at(x+1, y-1)
+			d, e, f := src.at(x-1, y), src.at(x, y), src.at(x+1, y)
+			g, h, i := src.at(x-1, y+1), src.at(x, y+1), src.at(x+1, y+1)
+			corners := [4]hqCorner{
+				newHQCorner(e, b, d, a),
+				newHQCorner(e, b, f, c),
+				newHQCorner(e, h, d, g),
+				newHQCorner(e, h, f, i),
+			}
+			for sy := 0; sy < n; sy++ {
+				for sx := 0; sx < n; sx++ {
+					px := e
+					// How far the subpixel is from the outside of the block
+					dx, dy := sx, sy
+					right, bottom := sx >= n-half, sy >= n-half
+					if right {
+						dx = n - 1 - sx
+					}
+					if bottom {
+						dy = n - 1 - sy
+					}
+					if dx < half && dy < half {
+						corner := &corners[btoi(bottom)*2+btoi(right)]
+						switch dx + dy {
+						case 0:
+							px = mix(corner.pixels, corner.outer)
+						case 1:
+							px = mix(corner.pixels, corner.inner)
+						}
+					}
+					setPixel(out, x*n+sx, y*n+sy, px)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
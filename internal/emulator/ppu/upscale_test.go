@@ -0,0 +1,303 @@
+package ppu_test
+
+import (
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/ppu"
+)
+
+// shades maps the characters of the golden images to gray levels, so the
+// blends of the scalers show up as the in-between characters
+//
+//nolint:golint,gochecknoglobals
+var shades = map[byte]byte{'#': 0x00, '1': 0x3F, '2': 0x7F, '3': 0xBF, '.': 0xFF}
+
+//nolint:golint,gochecknoglobals
+var (
+	checkerboard = []string{
+		"#.#",
+		".#.",
+		"#.#",
+	}
+	diagonal = []string{
+		"#...",
+		".#..",
+		"..#.",
+		"...#",
+	}
+)
+
+// grayImage draws an image from rows of shade characters
+func grayImage(rows []string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := range row {
+			v := shades[row[x]]
+			copy(img.Pix[y*img.Stride+x*4:], []byte{v, v, v, 0xFF})
+		}
+	}
+	return img
+}
+
+// shadeRows turns a gray image back into rows of shade characters, with ?
+// for anything else
+func shadeRows(img *image.RGBA) []string {
+	rows := make([]string, img.Rect.Dy())
+	for y := range rows {
+		var row strings.Builder
+		for x := 0; x < img.Rect.Dx(); x++ {
+			px := img.Pix[y*img.Stride+x*4:]
+			c := byte('?')
+			for ch, v := range shades {
+				if px[0] == v && px[1] == v && px[2] == v && px[3] == 0xFF {
+					c = ch
+				}
+			}
+			row.WriteByte(c)
+		}
+		rows[y] = row.String()
+	}
+	return rows
+}
+
+func TestUpscale(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		filter ppu.Filter
+		in     []string
+		want   []string
+	}{
+		{
+			name:   "scale2x checkerboard",
+			filter: ppu.FilterScale2x,
+			in:     checkerboard,
+			want: []string{
+				"##..##",
+				"#....#",
+				"..##..",
+				"..##..",
+				"#....#",
+				"##..##",
+			},
+		},
+		{
+			name:   "scale2x diagonal",
+			filter: ppu.FilterScale2x,
+			in:     diagonal,
+			want: []string{
+				"##......",
+				"#.#.....",
+				".###....",
+				"..###...",
+				"...###..",
+				"....###.",
+				".....#.#",
+				"......##",
+			},
+		},
+		{
+			name:   "scale3x diagonal",
+			filter: ppu.FilterScale3x,
+			in:     diagonal,
+			want: []string{
+				"###.........",
+				"##.#........",
+				"#..#........",
+				".#####......",
+				"...###......",
+				"...####.....",
+				".....####...",
+				"......###...",
+				"......#####.",
+				"........#..#",
+				"........#.##",
+				".........###",
+			},
+		},
+		{
+			name:   "scale4x is scale2x twice",
+			filter: ppu.FilterScale4x,
+			in:     []string{"#."},
+			want: []string{
+				"####....",
+				"####....",
+				"####....",
+				"####....",
+			},
+		},
+		{
+			name:   "xbr checkerboard",
+			filter: ppu.FilterXBR,
+			in:     checkerboard,
+			want: []string{
+				"##..##",
+				"##..##",
+				"..##..",
+				"..##..",
+				"##..##",
+				"##..##",
+			},
+		},
+		{
+			name:   "xbr diagonal",
+			filter: ppu.FilterXBR,
+			in:     diagonal,
+			want: []string{
+				"##......",
+				"##2.....",
+				".2#2....",
+				"..2#2...",
+				"...2#2..",
+				"....2#2.",
+				".....2##",
+				"......##",
+			},
+		},
+		{
+			name:   "hq2x diagonal",
+			filter: ppu.FilterHQ2x,
+			in:     diagonal,
+			want: []string{
+				"##......",
+				"#22.3...",
+				".222....",
+				"..222.3.",
+				".3.222..",
+				"....222.",
+				"...3.22#",
+				"......##",
+			},
+		},
+		{
+			name:   "hq3x diagonal",
+			filter: ppu.FilterHQ3x,
+			in:     diagonal,
+			want: []string{
+				"###.........",
+				"###.........",
+				"##22..3.....",
+				"..22#2......",
+				"...###......",
+				"...2#22..3..",
+				"..3..22#2...",
+				"......###...",
+				"......2#22..",
+				".....3..22##",
+				".........###",
+				".........###",
+			},
+		},
+		{
+			name:   "hq4x checkerboard",
+			filter: ppu.FilterHQ4x,
+			in:     checkerboard,
+			want: []string{
+				"####....####",
+				"####....####",
+				"###13..31###",
+				"##12233221##",
+				"..32211223..",
+				"...31##13...",
+				"...31##13...",
+				"..32211223..",
+				"##12233221##",
+				"###13..31###",
+				"####....####",
+				"####....####",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := shadeRows(ppu.NewScaler(tt.filter).Upscale(grayImage(tt.in), 0, 0))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestUpscaleCatmullRom(t *testing.T) {
+	t.Parallel()
+	in := grayImage(diagonal)
+	if got := ppu.NewScaler(ppu.FilterCatmullRom).Upscale(in, 10, 6).Rect; got != image.Rect(0, 0, 10, 6) {
+		t.Errorf("scaled to %v, expected the window size", got)
+	}
+	for _, filter := range []ppu.Filter{ppu.FilterNearest, ppu.FilterBilinear} {
+		if got := ppu.NewScaler(filter).Upscale(in, 10, 6); got != in {
+			t.Errorf("%s scaled on the CPU, expected the GPU to do it", filter)
+		}
+	}
+}
+
+// TestScalerReuse checks the scalers draw every frame into the same image
+// without allocating. It doesn't run in parallel, so the other tests don't
+// add to the allocations.
+func TestScalerReuse(t *testing.T) {
+	first, second := grayImage([]string{"##..", "##..", "..##", "..##"}), grayImage(diagonal)
+	for _, filter := range ppu.Filters {
+		scaler := ppu.NewScaler(filter)
+		out := scaler.Upscale(first, 10, 6)
+		if got := scaler.Upscale(second, 10, 6); got != out && got != second {
+			t.Errorf("%s: drew the second frame into a new image", filter)
+		}
+		// Nothing is left over from the first frame
+		want := shadeRows(ppu.NewScaler(filter).Upscale(second, 10, 6))
+		if got := shadeRows(scaler.Upscale(second, 10, 6)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got\n%s\nexpected\n%s", filter, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+		// Catmull-Rom allocates its weights in x/image/draw
+		if filter == ppu.FilterCatmullRom {
+			continue
+		}
+		if allocs := testing.AllocsPerRun(10, func() { scaler.Upscale(second, 10, 6) }); allocs != 0 {
+			t.Errorf("%s: %v allocations a frame", filter, allocs)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+	if filter, err := ppu.ParseFilter(""); err != nil || filter != ppu.FilterNearest {
+		t.Errorf("parsed the default filter as %q, %v", filter, err)
+	}
+	for _, want := range ppu.Filters {
+		if filter, err := ppu.ParseFilter(string(want)); err != nil || filter != want {
+			t.Errorf("parsed %q as %q, %v", want, filter, err)
+		}
+	}
+	if _, err := ppu.ParseFilter("hq5x"); err == nil {
+		t.Error("expected an error for an unknown filter")
+	}
+}
+
+func TestViewport(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		width, height int
+		integer       bool
+		want          image.Rectangle
+	}{
+		{name: "native", width: 240, height: 160, want: image.Rect(0, 0, 240, 160)},
+		{name: "native integer", width: 240, height: 160, integer: true, want: image.Rect(0, 0, 240, 160)},
+		{name: "letterbox", width: 480, height: 480, want: image.Rect(0, 80, 480, 400)},
+		{name: "pillarbox", width: 800, height: 320, want: image.Rect(160, 0, 640, 320)},
+		{name: "fractional", width: 500, height: 400, want: image.Rect(0, 33, 500, 366)},
+		{name: "integer", width: 500, height: 400, integer: true, want: image.Rect(10, 40, 490, 360)},
+		{name: "integer just short of 3x", width: 719, height: 1000, integer: true, want: image.Rect(119, 340, 599, 660)},
+		// Below 1x there is no whole scale, so it scales down to fit
+		{name: "integer smaller than native", width: 120, height: 120, integer: true, want: image.Rect(0, 20, 120, 100)},
+		{name: "empty", width: 0, height: 0, want: image.Rect(0, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		if got := ppu.Viewport(tt.width, tt.height, tt.integer); got != tt.want {
+			t.Errorf("%s: got %v for %dx%d, expected %v", tt.name, got, tt.width, tt.height, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import "container/heap"
+
+// EventType tells what an event is for, so pending events can be found
+// and canceled
+type EventType uint8
+
+const (
+	HBlankStart EventType = iota
+	HBlankEnd
+	VBlank
+	TimerOverflow
+	APUSample
+	DMATrigger
+	IRQ
+)
+
+type event struct {
+	at        uint64
+	seq       uint64
+	eventType EventType
+	handler   func()
+}
+
+type eventHeap []event
+
+func (h eventHeap) Len() int { return len(h) }
+
+// Less orders events by time, and events due at the same cycle in the
+// order they were scheduled
+func (h eventHeap) Less(i, j int) bool {
+	if h[i].at != h[j].at {
+		return h[i].at < h[j].at
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *eventHeap) Push(x any) { *h = append(*h, x.(event)) }
+
+func (h *eventHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Scheduler keeps the time in CPU cycles and the events due in the future.
+// Subsystems schedule their own next event from their handlers, so the CPU
+// only has to advance the time instead of ticking each of them.
+type Scheduler struct {
+	now    uint64
+	seq    uint64
+	events eventHeap
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Now returns the cycles elapsed since power on
+func (s *Scheduler) Now() uint64 {
+	return s.now
+}
+
+// Schedule calls handler once delay cycles have passed. While the handler
+// runs, Now is the cycle it was due at, even when Advance went past it.
+func (s *Scheduler) Schedule(eventType EventType, delay uint64, handler func()) {
+	heap.Push(&s.events, event{at: s.now + delay, seq: s.seq, eventType: eventType, handler: handler})
+	s.seq++
+}
+
+// Cancel removes the pending events of a type
+func (s *Scheduler) Cancel(eventType EventType) {
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if e.eventType != eventType {
+			kept = append(kept, e)
+		}
+	}
+	s.events = kept
+	heap.Init(&s.events)
+}
+
+// Pending reports whether an event of a type is scheduled
+func (s *Scheduler) Pending(eventType EventType) bool {
+	for _, e := range s.events {
+		if e.eventType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Due returns when the next event of a type is due
+func (s *Scheduler) Due(eventType EventType) (uint64, bool) {
+	due, found := uint64(0), false
+	for _, e := range s.events {
+		if e.eventType == eventType && (!found || e.at < due) {
+			due, found = e.at, true
+		}
+	}
+	return due, found
+}
+
+// Restore drops every pending event and sets the time, for loading a save
+// state. The subsystems schedule their events again afterwards.
+func (s *Scheduler) Restore(now uint64) {
+	s.now = now
+	s.events = s.events[:0]
+}
+
+// UntilNext returns the cycles left before the next event, 0 if it is due
+// and ^uint64(0) if nothing is scheduled
+func (s *Scheduler) UntilNext() uint64 {
+	if len(s.events) == 0 {
+		return ^uint64(0)
+	}
+	if s.events[0].at <= s.now {
+		return 0
+	}
+	return s.events[0].at - s.now
+}
+
+// Advance moves the time forward by cycles, running the events due on the
+// way in order
+func (s *Scheduler) Advance(cycles uint64) {
+	target := s.now + cycles
+	for len(s.events) > 0 && s.events[0].at <= target {
+		e := heap.Pop(&s.events).(event)
+		s.now = e.at
+		e.handler()
+	}
+	s.now = target
+}
@@ -0,0 +1,110 @@
+package scheduler_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/scheduler"
+)
+
+// record schedules an event that appends its name and the time it ran at
+func record(s *scheduler.Scheduler, log *[]string, eventType scheduler.EventType, delay uint64, name string) {
+	s.Schedule(eventType, delay, func() {
+		*log = append(*log, name+"@"+strconv.FormatUint(s.Now(), 10))
+	})
+}
+
+func TestAdvanceRunsEventsInOrder(t *testing.T) {
+	t.Parallel()
+	s := scheduler.New()
+	var log []string
+	record(s, &log, scheduler.TimerOverflow, 30, "timer")
+	record(s, &log, scheduler.HBlankStart, 10, "hblank")
+	record(s, &log, scheduler.DMATrigger, 10, "dma")
+	record(s, &log, scheduler.IRQ, 50, "irq")
+
+	s.Advance(40)
+	want := []string{"hblank@10", "dma@10", "timer@30"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ran %v, expected %v", log, want)
+	}
+	if s.Now() != 40 {
+		t.Errorf("now is %d, expected 40", s.Now())
+	}
+	if until := s.UntilNext(); until != 10 {
+		t.Errorf("next event in %d cycles, expected 10", until)
+	}
+}
+
+func TestHandlersScheduleFromTheirDueTime(t *testing.T) {
+	t.Parallel()
+	s := scheduler.New()
+	var log []string
+	var tick func()
+	tick = func() {
+		log = append(log, "tick@"+strconv.FormatUint(s.Now(), 10))
+		s.Schedule(scheduler.APUSample, 4, tick)
+	}
+	s.Schedule(scheduler.APUSample, 4, tick)
+
+	s.Advance(13)
+	want := []string{"tick@4", "tick@8", "tick@12"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ran %v, expected %v", log, want)
+	}
+	if until := s.UntilNext(); until != 3 {
+		t.Errorf("next event in %d cycles, expected 3", until)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	t.Parallel()
+	s := scheduler.New()
+	var log []string
+	for i := uint64(1); i <= 5; i++ {
+		record(s, &log, scheduler.TimerOverflow, i*10, "timer")
+		record(s, &log, scheduler.HBlankStart, i*10+5, "hblank")
+	}
+	s.Cancel(scheduler.TimerOverflow)
+	if s.Pending(scheduler.TimerOverflow) {
+		t.Error("timer events are still pending")
+	}
+	if !s.Pending(scheduler.HBlankStart) {
+		t.Error("hblank events were canceled too")
+	}
+
+	s.Advance(100)
+	want := []string{"hblank@15", "hblank@25", "hblank@35", "hblank@45", "hblank@55"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ran %v, expected %v", log, want)
+	}
+	if until := s.UntilNext(); until != ^uint64(0) {
+		t.Errorf("next event in %d cycles, expected none", until)
+	}
+}
+
+func TestDueAndRestore(t *testing.T) {
+	t.Parallel()
+	s := scheduler.New()
+	var log []string
+	record(s, &log, scheduler.VBlank, 40, "late")
+	record(s, &log, scheduler.VBlank, 20, "early")
+	if due, ok := s.Due(scheduler.VBlank); !ok || due != 20 {
+		t.Errorf("VBlank due at %d (%v), expected 20", due, ok)
+	}
+	if _, ok := s.Due(scheduler.IRQ); ok {
+		t.Error("IRQ is due without being scheduled")
+	}
+
+	s.Restore(1000)
+	if s.Now() != 1000 || s.Pending(scheduler.VBlank) {
+		t.Errorf("now is %d with VBlank pending %v, expected 1000 and nothing pending", s.Now(), s.Pending(scheduler.VBlank))
+	}
+	record(s, &log, scheduler.VBlank, 5, "restored")
+	s.Advance(10)
+	want := []string{"restored@1005"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ran %v, expected %v", log, want)
+	}
+}
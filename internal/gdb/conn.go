@@ -0,0 +1,101 @@
+package gdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// interruptByte is sent by GDB to stop a running target (Ctrl-C)
+const interruptByte = 0x03
+
+// conn frames remote serial protocol packets. A reader goroutine acks
+// incoming packets and reports interrupts as soon as they arrive, so they
+// are seen while the target runs.
+type conn struct {
+	rw        io.ReadWriter
+	packets   chan string
+	done      chan struct{}
+	noAck     atomic.Bool
+	writeLock sync.Mutex
+}
+
+func newConn(rw io.ReadWriter, interrupt func()) *conn {
+	c := &conn{
+		rw:      rw,
+		packets: make(chan string),
+		done:    make(chan struct{}),
+	}
+	go c.read(interrupt)
+	return c
+}
+
+// read delivers packets until the connection fails or is closed. A failed
+// connection also interrupts the target, so a running target doesn't run
+// forever.
+func (c *conn) read(interrupt func()) {
+	defer close(c.packets)
+	defer interrupt()
+	reader := bufio.NewReader(c.rw)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case interruptByte:
+			interrupt()
+		case '$':
+			data, err := reader.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+			var sum [2]byte
+			if _, err := io.ReadFull(reader, sum[:]); err != nil {
+				return
+			}
+			if fmt.Sprintf("%02x", checksum(data)) != string(sum[:]) {
+				if !c.noAck.Load() {
+					c.write("-")
+				}
+				continue
+			}
+			if !c.noAck.Load() {
+				c.write("+")
+			}
+			select {
+			case c.packets <- data:
+			case <-c.done:
+				return
+			}
+		}
+	}
+}
+
+// close stops delivering packets once the session is over, so the reader
+// doesn't block on a packet nobody takes
+func (c *conn) close() {
+	close(c.done)
+}
+
+// send writes a packet. Acks from GDB are not waited for.
+func (c *conn) send(data string) {
+	c.write(fmt.Sprintf("$%s#%02x", data, checksum(data)))
+}
+
+func (c *conn) write(s string) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, _ = io.WriteString(c.rw, s)
+}
+
+func checksum(data string) uint8 {
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
@@ -0,0 +1,430 @@
+// Package gdb serves the GDB remote serial protocol, so programs running in
+// the emulator can be debugged with arm-none-eabi-gdb:
+//
+//	(gdb) target remote localhost:2345
+package gdb
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/isa"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+	"github.com/USA-RedDragon/go-gba/internal/watch"
+)
+
+// Stop replies
+const (
+	sigInt  = "S02"
+	sigILL  = "S04"
+	sigTrap = "S05"
+	sigSEGV = "S0b"
+)
+
+// packetSize is the largest packet we accept, advertised to GDB
+const packetSize = 0x4000
+
+// watchKinds maps the Z packet types of watchpoints to their kinds
+//
+//nolint:golint,gochecknoglobals
+var watchKinds = map[string]watch.Kind{
+	"2": watch.Write,
+	"3": watch.Read,
+	"4": watch.Access,
+}
+
+// Server debugs a single target. Clients are served one at a time and the
+// target stays stopped between them.
+type Server struct {
+	target      watch.Target
+	breakpoints map[uint32]bool
+	watchpoints []watch.Point
+	hit         *watch.Point
+	interrupted atomic.Bool
+	lastStop    string
+	killed      bool
+}
+
+// NewServer returns a server for target. The target doesn't run until a
+// client continues it.
+func NewServer(target watch.Target) *Server {
+	return &Server{
+		target:      target,
+		breakpoints: map[uint32]bool{},
+		lastStop:    sigTrap,
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves clients until one
+// kills the target
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	logging.CPU.Info("Waiting for GDB", "addr", listener.Addr().String())
+	return s.Serve(listener)
+}
+
+// Serve accepts clients on listener until one kills the target
+func (s *Server) Serve(listener net.Listener) error {
+	for !s.killed {
+		client, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		logging.CPU.Info("GDB connected", "addr", client.RemoteAddr().String())
+		s.ServeConn(client)
+		client.Close()
+	}
+	return nil
+}
+
+// ServeConn serves one client until it detaches, kills the target or
+// disconnects
+func (s *Server) ServeConn(rw io.ReadWriter) {
+	c := newConn(rw, func() { s.interrupted.Store(true) })
+	defer c.close()
+	for data := range c.packets {
+		reply, done := s.handle(c, data)
+		c.send(reply)
+		if done {
+			return
+		}
+	}
+}
+
+// handle answers a packet. done ends the session.
+//
+//nolint:golint,gocyclo
+func (s *Server) handle(c *conn, data string) (reply string, done bool) {
+	if data == "" {
+		return "", false
+	}
+	args := data[1:]
+	switch data[0] {
+	case '?':
+		return s.lastStop, false
+	case 'g':
+		return s.readRegisters(), false
+	case 'G':
+		return s.writeRegisters(args), false
+	case 'p':
+		return s.readRegister(args), false
+	case 'P':
+		return s.writeRegister(args), false
+	case 'm':
+		return s.readMemory(args), false
+	case 'M':
+		return s.writeMemory(args), false
+	case 'c', 's':
+		if err := s.jumpTo(args); err != nil {
+			return "E01", false
+		}
+		return s.resume(data[0] == 's'), false
+	case 'C', 'S':
+		// The signal is ignored, there is nothing to deliver it to
+		_, addr, _ := strings.Cut(args, ";")
+		if err := s.jumpTo(addr); err != nil {
+			return "E01", false
+		}
+		return s.resume(data[0] == 'S'), false
+	case 'Z', 'z':
+		return s.setBreakpoint(data[0] == 'Z', args), false
+	case 'H', 'T':
+		// There is a single thread
+		return "OK", false
+	case 'D':
+		return "OK", true
+	case 'k':
+		s.killed = true
+		return "OK", true
+	case 'v':
+		return s.handleV(args), false
+	case 'q', 'Q':
+		return s.handleQuery(c, data), false
+	}
+	return "", false
+}
+
+func (s *Server) handleV(args string) string {
+	switch {
+	case args == "Cont?":
+		return "vCont;c;C;s;S"
+	case strings.HasPrefix(args, "Cont;"):
+		step := false
+		for _, action := range strings.Split(args[len("Cont;"):], ";") {
+			if action != "" && (action[0] == 's' || action[0] == 'S') {
+				step = true
+			}
+		}
+		return s.resume(step)
+	}
+	return ""
+}
+
+func (s *Server) handleQuery(c *conn, data string) string {
+	switch {
+	case strings.HasPrefix(data, "qSupported"):
+		return fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+;swbreak+;hwbreak+", packetSize)
+	case data == "QStartNoAckMode":
+		c.noAck.Store(true)
+		return "OK"
+	case strings.HasPrefix(data, "qXfer:features:read:target.xml:"):
+		return readXfer(targetXML, data[len("qXfer:features:read:target.xml:"):])
+	case data == "qAttached":
+		return "1"
+	case data == "qC":
+		return "QC1"
+	case data == "qfThreadInfo":
+		return "m1"
+	case data == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(data, "qSymbol"):
+		return "OK"
+	}
+	return ""
+}
+
+// readXfer answers a qXfer read of offset,length into document
+func readXfer(document, args string) string {
+	offset, length, err := parseAddressLength(args)
+	if err != nil {
+		return "E01"
+	}
+	if int(offset) >= len(document) {
+		return "l"
+	}
+	rest := document[offset:]
+	if int(length) < len(rest) {
+		return "m" + rest[:length]
+	}
+	return "l" + rest
+}
+
+// resume runs the target until it hits a breakpoint or watchpoint, is
+// interrupted, or has executed one instruction when stepping
+func (s *Server) resume(step bool) string {
+	s.interrupted.Store(false)
+	s.hit = nil
+	for {
+		err := s.target.StepInstruction()
+		switch {
+		case err != nil:
+			s.lastStop = crashSignal(err)
+		case s.hit != nil:
+			s.lastStop = fmt.Sprintf("T05%s:%x;", s.hit.Kind, s.hit.Addr)
+		case step || s.breakpoints[s.target.ReadDebugRegister(pcRegister)]:
+			s.lastStop = sigTrap
+		case s.interrupted.Load():
+			s.lastStop = sigInt
+		default:
+			continue
+		}
+		s.hit = nil
+		return s.lastStop
+	}
+}
+
+// crashSignal picks the signal GDB reports for an error that stopped the
+// CPU
+func crashSignal(err error) string {
+	var unknown *cpu.ErrUnknownInstruction
+	var swi *isa.ErrSWI
+	if errors.As(err, &unknown) || errors.As(err, &swi) {
+		return sigILL
+	}
+	var fault *memory.ErrBusFault
+	if errors.As(err, &fault) {
+		return sigSEGV
+	}
+	return sigTrap
+}
+
+// jumpTo writes PC if the resume packet carries an address
+func (s *Server) jumpTo(addr string) error {
+	if addr == "" {
+		return nil
+	}
+	pc, err := strconv.ParseUint(addr, 16, 32)
+	if err != nil {
+		return err
+	}
+	return s.target.WriteDebugRegister(pcRegister, uint32(pc))
+}
+
+func (s *Server) readRegisters() string {
+	var sb strings.Builder
+	for reg := uint8(0); reg < coreRegs; reg++ {
+		sb.WriteString(encodeRegister(s.target.ReadDebugRegister(reg)))
+	}
+	sb.WriteString(encodeRegister(s.target.ReadDebugRegister(cpuCPSR)))
+	return sb.String()
+}
+
+func (s *Server) writeRegisters(args string) string {
+	data, err := hex.DecodeString(args)
+	if err != nil || len(data) < (coreRegs+1)*4 {
+		return "E01"
+	}
+	for reg := uint8(0); reg < coreRegs; reg++ {
+		if err := s.target.WriteDebugRegister(reg, binary.LittleEndian.Uint32(data[reg*4:])); err != nil {
+			return "E01"
+		}
+	}
+	// Write the CPSR last, since it decides which PC alignment applies
+	if err := s.target.WriteDebugRegister(cpuCPSR, binary.LittleEndian.Uint32(data[coreRegs*4:])); err != nil {
+		return "E01"
+	}
+	return "OK"
+}
+
+func (s *Server) readRegister(args string) string {
+	n, err := strconv.ParseUint(args, 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	reg, ok := targetRegister(n)
+	if !ok {
+		return "E01"
+	}
+	return encodeRegister(s.target.ReadDebugRegister(reg))
+}
+
+func (s *Server) writeRegister(args string) string {
+	number, value, ok := strings.Cut(args, "=")
+	if !ok {
+		return "E01"
+	}
+	n, err := strconv.ParseUint(number, 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	reg, ok := targetRegister(n)
+	data, err := hex.DecodeString(value)
+	if !ok || err != nil || len(data) != 4 {
+		return "E01"
+	}
+	if err := s.target.WriteDebugRegister(reg, binary.LittleEndian.Uint32(data)); err != nil {
+		return "E01"
+	}
+	return "OK"
+}
+
+func (s *Server) readMemory(args string) string {
+	addr, length, err := parseAddressLength(args)
+	if err != nil {
+		return "E01"
+	}
+	data := readMemory(s.target.GetMMIO(), addr, min(length, packetSize/2))
+	if len(data) == 0 && length > 0 {
+		return "E14"
+	}
+	return hex.EncodeToString(data)
+}
+
+func (s *Server) writeMemory(args string) string {
+	location, value, ok := strings.Cut(args, ":")
+	if !ok {
+		return "E01"
+	}
+	addr, length, err := parseAddressLength(location)
+	if err != nil {
+		return "E01"
+	}
+	data, err := hex.DecodeString(value)
+	if err != nil || uint32(len(data)) != length {
+		return "E01"
+	}
+	if err := s.target.GetMMIO().Poke(addr, data); err != nil {
+		return "E14"
+	}
+	return "OK"
+}
+
+// setBreakpoint handles Z and z packets. Software and hardware breakpoints
+// are the same thing here, neither touches memory.
+func (s *Server) setBreakpoint(insert bool, args string) string {
+	kind, location, ok := strings.Cut(args, ",")
+	if !ok {
+		return "E01"
+	}
+	addr, length, err := parseAddressLength(location)
+	if err != nil {
+		return "E01"
+	}
+	switch kind {
+	case "0", "1":
+		if insert {
+			s.breakpoints[addr&^1] = true
+		} else {
+			delete(s.breakpoints, addr&^1)
+		}
+	case "2", "3", "4":
+		wp := watch.Point{Kind: watchKinds[kind], Addr: addr, Length: length}
+		if insert {
+			s.watchpoints = append(s.watchpoints, wp)
+		} else {
+			for i := range s.watchpoints {
+				if s.watchpoints[i] == wp {
+					s.watchpoints = append(s.watchpoints[:i], s.watchpoints[i+1:]...)
+					break
+				}
+			}
+		}
+		if len(s.watchpoints) > 0 {
+			s.target.SetWatchHook(s.watch)
+		} else {
+			s.target.SetWatchHook(nil)
+		}
+	default:
+		return ""
+	}
+	return "OK"
+}
+
+// watch is called for every memory access of an executing instruction
+func (s *Server) watch(addr uint32, size uint8, write bool) {
+	for i := range s.watchpoints {
+		if s.watchpoints[i].Matches(addr, size, write) {
+			s.hit = &s.watchpoints[i]
+			return
+		}
+	}
+}
+
+// parseAddressLength parses the "addr,length" argument of many packets.
+// Breakpoint packets may follow the length with ";cond_list".
+func parseAddressLength(args string) (uint32, uint32, error) {
+	addr, length, ok := strings.Cut(args, ",")
+	if !ok {
+		return 0, 0, errors.New("missing length")
+	}
+	length, _, _ = strings.Cut(length, ";")
+	a, err := strconv.ParseUint(addr, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	l, err := strconv.ParseUint(length, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(a), uint32(l), nil
+}
+
+// encodeRegister formats a register as GDB expects, in target byte order
+func encodeRegister(value uint32) string {
+	var data [4]byte
+	binary.LittleEndian.PutUint32(data[:], value)
+	return hex.EncodeToString(data[:])
+}
@@ -0,0 +1,184 @@
+package gdb_test
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/USA-RedDragon/go-gba/internal/gdb"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// testROM runs a few ARM instructions, switches to THUMB and loops forever
+//
+//nolint:golint,gochecknoglobals
+var testROM = []uint32{
+	0xE3A00001, // 08000000 mov r0, #1
+	0xE2800001, // 08000004 add r0, r0, #1
+	0xE3A01402, // 08000008 mov r1, #0x02000000
+	0xE5810000, // 0800000C str r0, [r1]
+	0xE28F2001, // 08000010 add r2, pc, #1
+	0xE12FFF12, // 08000014 bx r2
+	0x33012305, // 08000018 movs r3, #5 ; adds r3, #1
+	0x0000E7FE, // 0800001C b .
+}
+
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func startServer(t *testing.T) (*client, chan error) {
+	t.Helper()
+	target := testutil.NewCPU(t, testROM, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	done := make(chan error, 1)
+	go func() {
+		done <- gdb.NewServer(target).Serve(listener)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, reader: bufio.NewReader(conn)}, done
+}
+
+func (c *client) send(data string) {
+	c.t.Helper()
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	if _, err := fmt.Fprintf(c.conn, "$%s#%02x", data, sum); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) receive() string {
+	c.t.Helper()
+	if err := c.conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if b != '$' {
+			continue
+		}
+		packet, err := c.reader.ReadString('#')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if _, err := c.reader.Discard(2); err != nil {
+			c.t.Fatal(err)
+		}
+		if _, err := c.conn.Write([]byte("+")); err != nil {
+			c.t.Fatal(err)
+		}
+		return strings.TrimSuffix(packet, "#")
+	}
+}
+
+func (c *client) expect(data, want string) {
+	c.t.Helper()
+	c.send(data)
+	if got := c.receive(); got != want {
+		c.t.Fatalf("%s: got %q, want %q", data, got, want)
+	}
+}
+
+func le(value uint32) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+	c, done := startServer(t)
+
+	c.send("qSupported:multiprocess+;swbreak+;hwbreak+")
+	if reply := c.receive(); !strings.Contains(reply, "qXfer:features:read+") {
+		t.Fatalf("qSupported: %q", reply)
+	}
+	c.send("qXfer:features:read:target.xml:0,ffff")
+	if reply := c.receive(); !strings.HasPrefix(reply, "l") || !strings.Contains(reply, `<reg name="cpsr" bitsize="32" regnum="25"/>`) {
+		t.Fatalf("target.xml: %q", reply)
+	}
+	c.expect("?", "S05")
+
+	c.send("g")
+	regs := c.receive()
+	if len(regs) != 17*8 || regs[15*8:16*8] != le(0x08000000) || regs[16*8:] != le(0x6000001F) {
+		t.Fatalf("g: %q", regs)
+	}
+
+	// Single stepping in ARM state
+	c.expect("s", "S05")
+	c.expect("pf", le(0x08000004))
+	c.expect("p0", le(1))
+
+	// A write watchpoint stops after the store
+	c.expect("Z2,02000000,4", "OK")
+	c.expect("c", "T05watch:2000000;")
+	c.expect("pf", le(0x08000010))
+	c.expect("m02000000,4", le(2))
+	c.expect("z2,02000000,4", "OK")
+
+	// Breakpoints in THUMB code and THUMB-aware stepping
+	c.expect("Z0,0800001a,2", "OK")
+	c.expect("c", "S05")
+	c.expect("pf", le(0x0800001A))
+	c.send("p19")
+	if cpsr, err := hex.DecodeString(c.receive()); err != nil || len(cpsr) != 4 || cpsr[0]&0x20 == 0 {
+		t.Fatalf("expected THUMB state, CPSR %x", cpsr)
+	}
+	c.expect("p3", le(5))
+	c.expect("s", "S05")
+	c.expect("pf", le(0x0800001C))
+	c.expect("p3", le(6))
+	c.expect("z0,0800001a,2", "OK")
+
+	// Memory and register writes
+	c.expect("M03000000,2:cdab", "OK")
+	c.expect("m03000000,2", "cdab")
+	c.expect("M08000100,2:3412", "OK")
+	c.expect("m08000100,2", "3412")
+	c.expect("P4="+le(0xDEADBEEF), "OK")
+	c.expect("p4", le(0xDEADBEEF))
+
+	// Ctrl-C stops a running target
+	c.send("c")
+	time.Sleep(50 * time.Millisecond)
+	if _, err := c.conn.Write([]byte{0x03}); err != nil {
+		t.Fatal(err)
+	}
+	if reply := c.receive(); reply != "S02" {
+		t.Fatalf("interrupt: %q", reply)
+	}
+	c.expect("pf", le(0x0800001C))
+
+	// An unknown instruction stops the target with SIGILL on it
+	c.expect("M08000100,2:00b1", "OK") // undefined in THUMB
+	c.expect("P19="+le(0x0000003F), "OK")
+	c.expect("Pf="+le(0x08000100), "OK")
+	c.expect("c", "S04")
+	c.expect("pf", le(0x08000100))
+
+	c.expect("k", "OK")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,65 @@
+package gdb
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
+)
+
+// GDB numbers the CPSR 25 in the ARM target description, after the
+// registers of the FPA coprocessor the GBA doesn't have
+const (
+	pcRegister   = 15
+	cpsrRegister = 25
+	cpuCPSR      = 16
+	coreRegs     = 16
+)
+
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <architecture>arm</architecture>
+  <feature name="org.gnu.gdb.arm.core">
+    <reg name="r0" bitsize="32" type="uint32"/>
+    <reg name="r1" bitsize="32" type="uint32"/>
+    <reg name="r2" bitsize="32" type="uint32"/>
+    <reg name="r3" bitsize="32" type="uint32"/>
+    <reg name="r4" bitsize="32" type="uint32"/>
+    <reg name="r5" bitsize="32" type="uint32"/>
+    <reg name="r6" bitsize="32" type="uint32"/>
+    <reg name="r7" bitsize="32" type="uint32"/>
+    <reg name="r8" bitsize="32" type="uint32"/>
+    <reg name="r9" bitsize="32" type="uint32"/>
+    <reg name="r10" bitsize="32" type="uint32"/>
+    <reg name="r11" bitsize="32" type="uint32"/>
+    <reg name="r12" bitsize="32" type="uint32"/>
+    <reg name="sp" bitsize="32" type="data_ptr"/>
+    <reg name="lr" bitsize="32"/>
+    <reg name="pc" bitsize="32" type="code_ptr"/>
+    <reg name="cpsr" bitsize="32" regnum="25"/>
+  </feature>
+</target>
+`
+
+// targetRegister maps a GDB register number to the target's, which is
+// R0-R15 and 16 for the CPSR
+func targetRegister(n uint64) (uint8, bool) {
+	switch {
+	case n < coreRegs:
+		return uint8(n), true
+	case n == cpsrRegister:
+		return cpuCPSR, true
+	}
+	return 0, false
+}
+
+// readMemory reads up to length bytes, stopping at the first unmapped one
+func readMemory(bus *memory.MMIO, addr uint32, length uint32) []byte {
+	data := make([]byte, 0, length)
+	for i := uint32(0); i < length; i++ {
+		b, err := bus.Read8(addr + i)
+		if err != nil {
+			break
+		}
+		data = append(data, b)
+	}
+	return data
+}
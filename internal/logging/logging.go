@@ -0,0 +1,197 @@
+// Package logging sends the emulator's diagnostics through log/slog, with
+// a separate level for each subsystem
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Subsystem is a part of the emulator with its own log level
+type Subsystem uint8
+
+const (
+	CPU Subsystem = iota
+	MMIO
+	PPU
+	DMA
+	APU
+	BIOS
+	numSubsystems
+)
+
+// LevelTrace is below debug, for messages logged on every instruction
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
+)
+
+//nolint:golint,gochecknoglobals
+var (
+	subsystemNames = [numSubsystems]string{"cpu", "mmio", "ppu", "dma", "apu", "bios"}
+	// levels are only written by Configure, before the emulator starts
+	levels  = [numSubsystems]slog.Level{LevelInfo, LevelInfo, LevelInfo, LevelInfo, LevelInfo, LevelInfo}
+	loggers [numSubsystems]*slog.Logger
+)
+
+//nolint:golint,gochecknoinits
+func init() {
+	setHandler(slog.Default().Handler())
+}
+
+func setHandler(handler slog.Handler) {
+	for s := range loggers {
+		loggers[s] = slog.New(handler).With("subsystem", subsystemNames[s])
+	}
+}
+
+func (s Subsystem) String() string {
+	return subsystemNames[s]
+}
+
+// Enabled reports whether s logs messages at level. It is cheap, so hot
+// paths check it before building a message.
+func (s Subsystem) Enabled(level slog.Level) bool {
+	return level >= levels[s]
+}
+
+// Logger returns the slog logger of s, which does no level filtering of
+// its own
+func (s Subsystem) Logger() *slog.Logger {
+	return loggers[s]
+}
+
+func (s Subsystem) log(level slog.Level, msg string, args ...any) {
+	if s.Enabled(level) {
+		loggers[s].Log(context.Background(), level, msg, args...)
+	}
+}
+
+// Trace logs a message at LevelTrace with slog style key value pairs
+func (s Subsystem) Trace(msg string, args ...any) { s.log(LevelTrace, msg, args...) }
+
+// Debug logs a message at LevelDebug
+func (s Subsystem) Debug(msg string, args ...any) { s.log(LevelDebug, msg, args...) }
+
+// Info logs a message at LevelInfo
+func (s Subsystem) Info(msg string, args ...any) { s.log(LevelInfo, msg, args...) }
+
+// Warn logs a message at LevelWarn
+func (s Subsystem) Warn(msg string, args ...any) { s.log(LevelWarn, msg, args...) }
+
+// Error logs a message at LevelError
+func (s Subsystem) Error(msg string, args ...any) { s.log(LevelError, msg, args...) }
+
+// Tracef logs a printf style message at LevelTrace
+func (s Subsystem) Tracef(format string, args ...any) {
+	if s.Enabled(LevelTrace) {
+		loggers[s].Log(context.Background(), LevelTrace, fmt.Sprintf(format, args...))
+	}
+}
+
+// Debugf logs a printf style message at LevelDebug
+func (s Subsystem) Debugf(format string, args ...any) {
+	if s.Enabled(LevelDebug) {
+		loggers[s].Log(context.Background(), LevelDebug, fmt.Sprintf(format, args...))
+	}
+}
+
+// Hex formats a value as 8 hex digits in log output
+type Hex uint32
+
+func (h Hex) String() string {
+	return fmt.Sprintf("0x%08X", uint32(h))
+}
+
+// ParseLevel parses trace, debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "trace":
+		return LevelTrace, nil
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected trace, debug, info, warn or error", name)
+}
+
+// ParseSubsystem parses a subsystem name, like "ppu"
+func ParseSubsystem(name string) (Subsystem, bool) {
+	for s, subsystemName := range subsystemNames {
+		if strings.EqualFold(strings.TrimSpace(name), subsystemName) {
+			return Subsystem(s), true
+		}
+	}
+	return 0, false
+}
+
+// ParseLevels parses a comma separated level spec like "info,cpu=debug".
+// A level without a subsystem applies to every subsystem not listed.
+func ParseLevels(spec string) ([numSubsystems]slog.Level, error) {
+	var parsed [numSubsystems]slog.Level
+	var set [numSubsystems]bool
+	defaultLevel := LevelInfo
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, levelName, ok := strings.Cut(part, "=")
+		if !ok {
+			level, err := ParseLevel(part)
+			if err != nil {
+				return parsed, err
+			}
+			defaultLevel = level
+			continue
+		}
+		s, ok := ParseSubsystem(name)
+		if !ok {
+			return parsed, fmt.Errorf("unknown log subsystem %q, expected one of %s", name, strings.Join(subsystemNames[:], ", "))
+		}
+		level, err := ParseLevel(levelName)
+		if err != nil {
+			return parsed, err
+		}
+		parsed[s] = level
+		set[s] = true
+	}
+	for s := range parsed {
+		if !set[s] {
+			parsed[s] = defaultLevel
+		}
+	}
+	return parsed, nil
+}
+
+// Configure sets the subsystem levels from a spec like "info,cpu=debug"
+// and logs to w as text. It must be called before the emulator starts.
+func Configure(w io.Writer, spec string) error {
+	parsed, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+	levels = parsed
+	setHandler(slog.NewTextHandler(w, &slog.HandlerOptions{Level: LevelTrace, ReplaceAttr: replaceLevel}))
+	return nil
+}
+
+// replaceLevel names LevelTrace, which slog would print as DEBUG-4
+func replaceLevel(_ []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey {
+		if level, ok := attr.Value.Any().(slog.Level); ok && level == LevelTrace {
+			attr.Value = slog.StringValue("TRACE")
+		}
+	}
+	return attr
+}
@@ -0,0 +1,53 @@
+package logging_test
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/logging"
+)
+
+func TestParseLevels(t *testing.T) {
+	t.Parallel()
+	info, debug, trace, warn, errorLevel := logging.LevelInfo, logging.LevelDebug, logging.LevelTrace, logging.LevelWarn, logging.LevelError
+	tests := []struct {
+		spec string
+		// want is the level of cpu, mmio, ppu, dma, apu and bios
+		want []slog.Level
+		err  string
+	}{
+		{spec: "", want: []slog.Level{info, info, info, info, info, info}},
+		{spec: "debug", want: []slog.Level{debug, debug, debug, debug, debug, debug}},
+		{spec: " WARNING ", want: []slog.Level{warn, warn, warn, warn, warn, warn}},
+		{spec: "cpu=trace", want: []slog.Level{trace, info, info, info, info, info}},
+		{spec: "error,ppu=debug,DMA=warn", want: []slog.Level{errorLevel, errorLevel, debug, warn, errorLevel, errorLevel}},
+		// The default applies wherever it appears, overrides always win
+		{spec: "mmio=trace,warn", want: []slog.Level{warn, trace, warn, warn, warn, warn}},
+		{spec: "bios=debug,bios=error", want: []slog.Level{info, info, info, info, info, errorLevel}},
+		{spec: "info,,apu=debug,", want: []slog.Level{info, info, info, info, debug, info}},
+		{spec: "loud", err: `unknown log level "loud"`},
+		{spec: "cpu=loud", err: `unknown log level "loud"`},
+		{spec: "gpu=debug", err: `unknown log subsystem "gpu"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+			levels, err := logging.ParseLevels(tt.spec)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, expected one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for s, want := range tt.want {
+				if levels[s] != want {
+					t.Errorf("%s: got %v, expected %v", logging.Subsystem(s), levels[s], want)
+				}
+			}
+		})
+	}
+}
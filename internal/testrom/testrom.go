@@ -0,0 +1,206 @@
+// Package testrom runs test ROMs headless until they finish and decides
+// whether they passed
+package testrom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+)
+
+const (
+	armSelfBranch   = 0xEAFFFFFE // b .
+	thumbSelfBranch = 0xE7FE     // b .
+)
+
+// Marker stops a run once the word at Addr holds Value
+type Marker struct {
+	Addr  uint32
+	Value uint32
+}
+
+// Expectation is a register value checked once the ROM stopped
+type Expectation struct {
+	Register uint8
+	Value    uint32
+}
+
+// Options says when a run stops and what decides whether it passed
+type Options struct {
+	// SelfBranch stops at an instruction that branches to itself, which is
+	// how most test ROMs end
+	SelfBranch bool
+	// Marker stops once a word in memory holds a value
+	Marker *Marker
+	// Frames stops after this many frames, 0 doesn't
+	Frames uint64
+	// Timeout fails a run that hasn't stopped after this many frames, 0
+	// runs forever
+	Timeout uint64
+	// Expect are the register values a passing ROM stops with
+	Expect []Expectation
+	// FrameHash is the SHA-256 of the frame a passing ROM stops on
+	FrameHash string
+}
+
+// Result is the outcome of a run
+type Result struct {
+	Passed bool
+	// Reason says what stopped the ROM and which check failed
+	Reason       string
+	Frames       uint64
+	Instructions uint64
+	// FrameHash is the SHA-256 of the last frame, empty in display modes
+	// that aren't rendered
+	FrameHash string
+}
+
+// Run steps c until one of the stop conditions in opts is met and checks
+// the result. A CPU error fails the run.
+func Run(c *cpu.ARM7TDMI, opts Options) Result {
+	var result Result
+	for {
+		if err := c.StepInstruction(); err != nil {
+			result.Frames = c.PPU.Frames()
+			result.FrameHash = frameHash(c)
+			result.Reason = fmt.Sprintf("the CPU stopped at 0x%08X: %v", c.NextInstructionAddress(), err)
+			return result
+		}
+		result.Instructions++
+		result.Frames = c.PPU.Frames()
+
+		var stop string
+		switch {
+		case opts.SelfBranch && atSelfBranch(c):
+			stop = fmt.Sprintf("self-branch at 0x%08X", c.NextInstructionAddress())
+		case opts.Marker != nil && markerSet(c, opts.Marker):
+			stop = fmt.Sprintf("marker 0x%08X set to 0x%08X", opts.Marker.Addr, opts.Marker.Value)
+		case opts.Frames > 0 && result.Frames >= opts.Frames:
+			stop = fmt.Sprintf("%d frames", opts.Frames)
+		case opts.Timeout > 0 && result.Frames >= opts.Timeout:
+			result.FrameHash = frameHash(c)
+			result.Reason = fmt.Sprintf("timed out after %d frames", opts.Timeout)
+			return result
+		default:
+			continue
+		}
+
+		result.FrameHash = frameHash(c)
+		result.Reason, result.Passed = check(c, opts, result.FrameHash)
+		result.Reason = "stopped on " + stop + ", " + result.Reason
+		return result
+	}
+}
+
+// check compares the stopped ROM against the expectations in opts
+func check(c *cpu.ARM7TDMI, opts Options, hash string) (string, bool) {
+	for _, expect := range opts.Expect {
+		if got := c.ReadDebugRegister(expect.Register); got != expect.Value {
+			return fmt.Sprintf("%s is 0x%08X, expected 0x%08X", registerName(expect.Register), got, expect.Value), false
+		}
+	}
+	if opts.FrameHash != "" {
+		if hash == "" {
+			return "the display mode has no frame to hash", false
+		}
+		if !strings.EqualFold(hash, opts.FrameHash) {
+			return fmt.Sprintf("frame hash is %s, expected %s", hash, opts.FrameHash), false
+		}
+	}
+	return "all checks passed", true
+}
+
+func atSelfBranch(c *cpu.ARM7TDMI) bool {
+	pc := c.NextInstructionAddress()
+	if c.GetThumbMode() {
+		opcode, err := c.GetMMIO().Read16(pc)
+		return err == nil && opcode == thumbSelfBranch
+	}
+	opcode, err := c.GetMMIO().Read32(pc)
+	return err == nil && opcode == armSelfBranch
+}
+
+func markerSet(c *cpu.ARM7TDMI, marker *Marker) bool {
+	value, err := c.GetMMIO().Read32(marker.Addr)
+	return err == nil && value == marker.Value
+}
+
+// frameHash hashes the pixels of the current frame
+func frameHash(c *cpu.ARM7TDMI) string {
+	fb := c.PPU.FrameBuffer()
+	if fb == nil {
+		return ""
+	}
+	sum := sha256.Sum256(fb.Pix)
+	return hex.EncodeToString(sum[:])
+}
+
+func registerName(reg uint8) string {
+	switch reg {
+	case cpu.SP_REG:
+		return "sp"
+	case cpu.LR_REG:
+		return "lr"
+	case cpu.PC_REG:
+		return "pc"
+	}
+	return fmt.Sprintf("r%d", reg)
+}
+
+// ParseMarker parses a marker written as address=value
+func ParseMarker(text string) (*Marker, error) {
+	addr, value, ok := strings.Cut(text, "=")
+	if !ok {
+		return nil, fmt.Errorf("invalid marker %q, expected address=value", text)
+	}
+	a, err := strconv.ParseUint(strings.TrimSpace(addr), 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid marker address %q", addr)
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(value), 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid marker value %q", value)
+	}
+	return &Marker{Addr: uint32(a), Value: uint32(v)}, nil
+}
+
+// ParseExpectation parses a register value written as r12=0. R0-R15, sp,
+// lr and pc are accepted.
+func ParseExpectation(text string) (Expectation, error) {
+	name, value, ok := strings.Cut(text, "=")
+	if !ok {
+		return Expectation{}, fmt.Errorf("invalid expectation %q, expected register=value", text)
+	}
+	reg, ok := parseRegister(strings.ToLower(strings.TrimSpace(name)))
+	if !ok {
+		return Expectation{}, fmt.Errorf("unknown register %q", name)
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(value), 0, 32)
+	if err != nil {
+		return Expectation{}, fmt.Errorf("invalid register value %q", value)
+	}
+	return Expectation{Register: reg, Value: uint32(v)}, nil
+}
+
+func parseRegister(name string) (uint8, bool) {
+	switch name {
+	case "sp":
+		return cpu.SP_REG, true
+	case "lr":
+		return cpu.LR_REG, true
+	case "pc":
+		return cpu.PC_REG, true
+	}
+	if !strings.HasPrefix(name, "r") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(name[1:], 10, 8)
+	if err != nil || n > cpu.PC_REG {
+		return 0, false
+	}
+	return uint8(n), true
+}
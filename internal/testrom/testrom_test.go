@@ -0,0 +1,173 @@
+package testrom_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/testrom"
+	"github.com/USA-RedDragon/go-gba/internal/testutil"
+)
+
+// newCPU loads a ROM from the test suites
+func newCPU(t *testing.T, romPath string) *cpu.ARM7TDMI {
+	t.Helper()
+	c, err := cpu.NewARM7TDMI(&config.Config{ROMPath: romPath, BlockCache: true, Lockstep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	r12 := []testrom.Expectation{{Register: 12, Value: 0}}
+	tests := []struct {
+		name    string
+		program []uint32
+		opts    testrom.Options
+		passed  bool
+		reason  string
+	}{
+		{
+			name:    "self-branch pass",
+			program: []uint32{0xE3A0C000, 0xEAFFFFFE}, // mov r12, #0 ; b .
+			opts:    testrom.Options{SelfBranch: true, Timeout: 10, Expect: r12},
+			passed:  true,
+			reason:  "self-branch at 0x08000004",
+		},
+		{
+			name:    "self-branch fail",
+			program: []uint32{0xE3A0C003, 0xEAFFFFFE}, // mov r12, #3 ; b .
+			opts:    testrom.Options{SelfBranch: true, Timeout: 10, Expect: r12},
+			reason:  "r12 is 0x00000003, expected 0x00000000",
+		},
+		{
+			name: "marker",
+			program: []uint32{
+				0xE3A00402, // mov r0, #0x02000000
+				0xE3A01001, // mov r1, #1
+				0xE5801000, // str r1, [r0]
+				0xEAFFFFFE, // b .
+			},
+			opts:   testrom.Options{Marker: &testrom.Marker{Addr: 0x02000000, Value: 1}, Timeout: 10},
+			passed: true,
+			reason: "marker 0x02000000 set to 0x00000001",
+		},
+		{
+			name:    "frames",
+			program: []uint32{0xEAFFFFFE}, // b .
+			opts:    testrom.Options{Frames: 1, Timeout: 10},
+			passed:  true,
+			reason:  "1 frames",
+		},
+		{
+			name:    "timeout",
+			program: []uint32{0xEAFFFFFE}, // b .
+			opts:    testrom.Options{Timeout: 1},
+			reason:  "timed out after 1 frames",
+		},
+		{
+			name:    "frame hash",
+			program: []uint32{0xEAFFFFFE}, // b .
+			opts:    testrom.Options{SelfBranch: true, Timeout: 10, FrameHash: "00"},
+			reason:  "the display mode has no frame to hash",
+		},
+		{
+			name:    "crash",
+			program: []uint32{0xE3A00301, 0xE5800400}, // mov r0, #0x04000000 ; str r0, [r0, #0x400]
+			opts:    testrom.Options{SelfBranch: true, Timeout: 10},
+			reason:  "bus fault: 32-bit write at 0x04000400: not mapped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := testrom.Run(testutil.NewCPU(t, tt.program, &config.Config{BlockCache: true, Lockstep: true}), tt.opts)
+			if result.Passed != tt.passed || !strings.Contains(result.Reason, tt.reason) {
+				t.Fatalf("got passed=%v %q, want passed=%v containing %q", result.Passed, result.Reason, tt.passed, tt.reason)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	marker, err := testrom.ParseMarker("0x0203FFFC=0xDEADBEEF")
+	if err != nil || *marker != (testrom.Marker{Addr: 0x0203FFFC, Value: 0xDEADBEEF}) {
+		t.Fatalf("ParseMarker: %v %v", marker, err)
+	}
+	expect, err := testrom.ParseExpectation("R12=0")
+	if err != nil || expect != (testrom.Expectation{Register: 12, Value: 0}) {
+		t.Fatalf("ParseExpectation: %v %v", expect, err)
+	}
+	expect, err = testrom.ParseExpectation("lr=0x08000000")
+	if err != nil || expect != (testrom.Expectation{Register: cpu.LR_REG, Value: 0x08000000}) {
+		t.Fatalf("ParseExpectation: %v %v", expect, err)
+	}
+	for _, text := range []string{"r16=0", "r12", "x1=0", "r0=nope"} {
+		if _, err := testrom.ParseExpectation(text); err == nil {
+			t.Errorf("ParseExpectation(%q) succeeded", text)
+		}
+	}
+}
+
+// TestSuites runs the test ROM suites found under the directory in
+// GBA_TEST_ROMS. Suites that aren't there are skipped.
+func TestSuites(t *testing.T) {
+	t.Parallel()
+	dir := os.Getenv("GBA_TEST_ROMS")
+	if dir == "" {
+		t.Skip("set GBA_TEST_ROMS to a directory holding test ROMs")
+	}
+	// jsmolka's gba-tests store the number of the first failed test in r12
+	jsmolka := testrom.Options{SelfBranch: true, Timeout: 600, Expect: []testrom.Expectation{{Register: 12, Value: 0}}}
+	suites := []struct {
+		rom  string
+		opts testrom.Options
+	}{
+		{"arm.gba", jsmolka},
+		{"thumb.gba", jsmolka},
+		{"memory.gba", jsmolka},
+		{"nes.gba", jsmolka},
+	}
+	for _, suite := range suites {
+		t.Run(suite.rom, func(t *testing.T) {
+			t.Parallel()
+			path := findROM(t, dir, suite.rom)
+			result := testrom.Run(newCPU(t, path), suite.opts)
+			if !result.Passed {
+				t.Fatalf("%s after %d frames", result.Reason, result.Frames)
+			}
+		})
+	}
+}
+
+// findROM finds name anywhere under dir, so suites can be checked out as
+// they are
+func findROM(t *testing.T, dir, name string) string {
+	t.Helper()
+	var found string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == name {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == "" {
+		t.Skipf("%s not found in %s", name, dir)
+	}
+	return found
+}
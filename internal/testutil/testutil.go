@@ -0,0 +1,54 @@
+// Package testutil builds CPUs running small hand-assembled programs, for
+// the tests of the packages around the CPU
+package testutil
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+)
+
+// CountLoops counts to 100 in ARM, then switches to THUMB and counts to 50
+//
+//nolint:golint,gochecknoglobals
+var CountLoops = []uint32{
+	0xE3A00000, // mov r0, #0
+	0xE3A01064, // mov r1, #100
+	0xE0800001, // loop: add r0, r0, r1
+	0xE2511001, // subs r1, r1, #1
+	0x1AFFFFFC, // bne loop
+	0xE28F2001, // add r2, pc, #1
+	0xE12FFF12, // bx r2
+	0x34032332, // movs r3, #50 ; loop2: adds r4, #3
+	0xD1FC3B01, // subs r3, #1 ; bne loop2
+	0x0000E7FE, // b .
+}
+
+// minROMSize leaves room for the cartridge header after short programs
+const minROMSize = 0x200
+
+// ROM assembles program at the entry point of a ROM without a header
+func ROM(program []uint32) []byte {
+	rom := make([]byte, max(minROMSize, len(program)*4))
+	for i, word := range program {
+		binary.LittleEndian.PutUint32(rom[i*4:], word)
+	}
+	return rom
+}
+
+// NewCPU creates a CPU without a BIOS running program from the entry point,
+// closed at the end of the test. cfg may be nil.
+func NewCPU(t testing.TB, program []uint32, cfg *config.Config) *cpu.ARM7TDMI {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	c, err := cpu.New(cfg, nil, ROM(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
@@ -0,0 +1,65 @@
+package trace
+
+import "fmt"
+
+// Difference is a field that differs between a reference record and ours
+type Difference struct {
+	Field     string
+	Want, Got string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: want %s, got %s", d.Field, d.Want, d.Got)
+}
+
+func hexDifference(field string, want, got uint32) Difference {
+	return Difference{Field: field, Want: fmt.Sprintf("%08x", want), Got: fmt.Sprintf("%08x", got)}
+}
+
+// Compare lists the differences between the reference record want and
+// got. Memory writes are only compared if writes is set, as most traces
+// don't record them.
+func Compare(want, got *Record, writes bool) []Difference {
+	var differences []Difference
+	if want.Address != got.Address {
+		differences = append(differences, hexDifference("address", want.Address, got.Address))
+	}
+	if want.Opcode != got.Opcode {
+		differences = append(differences, hexDifference("opcode", want.Opcode, got.Opcode))
+	}
+	for i := range want.Registers {
+		if want.Registers[i] != got.Registers[i] {
+			differences = append(differences, hexDifference(fmt.Sprintf("r%d", i), want.Registers[i], got.Registers[i]))
+		}
+	}
+	if want.Mode() != got.Mode() {
+		differences = append(differences, Difference{Field: "mode", Want: ModeName(want.Mode()), Got: ModeName(got.Mode())})
+	}
+	if want.CPSR != got.CPSR {
+		differences = append(differences, hexDifference("cpsr", want.CPSR, got.CPSR))
+	}
+	if writes {
+		differences = append(differences, compareWrites(want.Writes, got.Writes)...)
+	}
+	return differences
+}
+
+func formatWrite(w Write) string {
+	return fmt.Sprintf("[%08x]=%0*x", w.Address, int(w.Size)*2, w.Value)
+}
+
+func compareWrites(want, got []Write) []Difference {
+	var differences []Difference
+	for i := 0; i < len(want) || i < len(got); i++ {
+		field := fmt.Sprintf("write %d", i)
+		switch {
+		case i >= len(got):
+			differences = append(differences, Difference{Field: field, Want: formatWrite(want[i]), Got: "nothing"})
+		case i >= len(want):
+			differences = append(differences, Difference{Field: field, Want: "nothing", Got: formatWrite(got[i])})
+		case want[i] != got[i]:
+			differences = append(differences, Difference{Field: field, Want: formatWrite(want[i]), Got: formatWrite(got[i])})
+		}
+	}
+	return differences
+}
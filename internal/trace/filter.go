@@ -0,0 +1,104 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range is a half-open address range
+type Range struct {
+	Start, End uint32
+}
+
+// Filter picks the instructions to trace. Empty fields match everything.
+type Filter struct {
+	Ranges []Range
+	Modes  []uint8
+	// FirstFrame and EndFrame bound the frames traced, with EndFrame
+	// exclusive. A zero EndFrame has no bound.
+	FirstFrame, EndFrame uint64
+}
+
+// Match reports whether an instruction should be traced
+func (f *Filter) Match(address uint32, mode uint8, frame uint64) bool {
+	if frame < f.FirstFrame || (f.EndFrame != 0 && frame >= f.EndFrame) {
+		return false
+	}
+	if len(f.Modes) > 0 {
+		found := false
+		for _, m := range f.Modes {
+			if m == mode {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Ranges) == 0 {
+		return true
+	}
+	for _, r := range f.Ranges {
+		if address >= r.Start && address < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRange parses "start-end", with end exclusive, or a single address
+func ParseRange(text string) (Range, error) {
+	startText, endText, isRange := strings.Cut(text, "-")
+	start, err := strconv.ParseUint(strings.TrimSpace(startText), 0, 32)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid address range %q", text)
+	}
+	if !isRange {
+		return Range{Start: uint32(start), End: uint32(start) + 1}, nil
+	}
+	end, err := strconv.ParseUint(strings.TrimSpace(endText), 0, 32)
+	if err != nil || end <= start {
+		return Range{}, fmt.Errorf("invalid address range %q", text)
+	}
+	return Range{Start: uint32(start), End: uint32(end)}, nil
+}
+
+// ParseModes parses a comma separated list of mode names, like "irq,svc"
+func ParseModes(text string) ([]uint8, error) {
+	var modes []uint8
+	for _, name := range strings.Split(text, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		mode, ok := ParseMode(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown CPU mode %q, expected usr, fiq, irq, svc, abt, und or sys", name)
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// ParseFrames parses "first-last", "first-" or a single frame into the
+// first and end frame of a Filter
+func ParseFrames(text string) (uint64, uint64, error) {
+	firstText, lastText, isRange := strings.Cut(text, "-")
+	first, err := strconv.ParseUint(strings.TrimSpace(firstText), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid frame window %q", text)
+	}
+	if !isRange {
+		return first, first + 1, nil
+	}
+	if strings.TrimSpace(lastText) == "" {
+		return first, 0, nil
+	}
+	last, err := strconv.ParseUint(strings.TrimSpace(lastText), 10, 64)
+	if err != nil || last < first {
+		return 0, 0, fmt.Errorf("invalid frame window %q", text)
+	}
+	return first, last + 1, nil
+}
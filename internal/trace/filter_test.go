@@ -0,0 +1,123 @@
+package trace_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/trace"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	const (
+		sys = 0x1F
+		irq = 0x12
+	)
+	tests := []struct {
+		name    string
+		filter  trace.Filter
+		address uint32
+		mode    uint8
+		frame   uint64
+		want    bool
+	}{
+		{name: "empty", address: 0x08000000, mode: sys, frame: 100, want: true},
+		{name: "in range", filter: trace.Filter{Ranges: []trace.Range{{0x08000000, 0x08000100}}}, address: 0x080000FF, want: true},
+		{name: "range end", filter: trace.Filter{Ranges: []trace.Range{{0x08000000, 0x08000100}}}, address: 0x08000100},
+		{name: "second range", filter: trace.Filter{Ranges: []trace.Range{{0, 0x4000}, {0x03000000, 0x03008000}}}, address: 0x03000000, want: true},
+		{name: "mode", filter: trace.Filter{Modes: []uint8{irq, sys}}, mode: sys, want: true},
+		{name: "other mode", filter: trace.Filter{Modes: []uint8{irq}}, mode: sys},
+		{name: "first frame", filter: trace.Filter{FirstFrame: 10, EndFrame: 12}, frame: 10, want: true},
+		{name: "before first frame", filter: trace.Filter{FirstFrame: 10, EndFrame: 12}, frame: 9},
+		{name: "end frame", filter: trace.Filter{FirstFrame: 10, EndFrame: 12}, frame: 12},
+		{name: "no end frame", filter: trace.Filter{FirstFrame: 10}, frame: 1000, want: true},
+		{
+			name:    "every field",
+			filter:  trace.Filter{Ranges: []trace.Range{{0x18, 0x1C}}, Modes: []uint8{irq}, FirstFrame: 5},
+			address: 0x18, mode: irq, frame: 5, want: true,
+		},
+		{
+			name:    "every field but the mode",
+			filter:  trace.Filter{Ranges: []trace.Range{{0x18, 0x1C}}, Modes: []uint8{irq}, FirstFrame: 5},
+			address: 0x18, mode: sys, frame: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.filter.Match(tt.address, tt.mode, tt.frame); got != tt.want {
+				t.Errorf("matched 0x%08X in mode 0x%02X on frame %d: %v, expected %v", tt.address, tt.mode, tt.frame, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text string
+		want trace.Range
+		err  bool
+	}{
+		{text: "0x08000000-0x08000100", want: trace.Range{0x08000000, 0x08000100}},
+		{text: " 0x100 - 0x200 ", want: trace.Range{0x100, 0x200}},
+		{text: "256-512", want: trace.Range{256, 512}},
+		{text: "0x18", want: trace.Range{0x18, 0x19}},
+		{text: "0x200-0x100", err: true},
+		{text: "0x100-0x100", err: true},
+		{text: "0x100-", err: true},
+		{text: "start-0x100", err: true},
+		{text: "0x100000000", err: true},
+	}
+	for _, tt := range tests {
+		got, err := trace.ParseRange(tt.text)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parsed %q as %+v, %v", tt.text, got, err)
+		}
+	}
+}
+
+func TestParseModes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text string
+		want []uint8
+		err  bool
+	}{
+		{text: ""},
+		{text: "irq", want: []uint8{0x12}},
+		{text: "IRQ, svc,,fiq", want: []uint8{0x12, 0x13, 0x11}},
+		{text: "usr,abt,und,sys", want: []uint8{0x10, 0x17, 0x1B, 0x1F}},
+		{text: "irq,hyp", err: true},
+	}
+	for _, tt := range tests {
+		got, err := trace.ParseModes(tt.text)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsed %q as %v, %v", tt.text, got, err)
+		}
+	}
+}
+
+func TestParseFrames(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text       string
+		first, end uint64
+		err        bool
+	}{
+		{text: "10", first: 10, end: 11},
+		{text: "10-20", first: 10, end: 21},
+		{text: "10-10", first: 10, end: 11},
+		{text: "10-", first: 10, end: 0},
+		{text: " 0 - 5 ", first: 0, end: 6},
+		{text: "20-10", err: true},
+		{text: "-10", err: true},
+		{text: "0x10", err: true},
+	}
+	for _, tt := range tests {
+		first, end, err := trace.ParseFrames(tt.text)
+		if (err != nil) != tt.err || first != tt.first || end != tt.end {
+			t.Errorf("parsed %q as %d, %d, %v", tt.text, first, end, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Reader reads a trace written by a Writer in any format, or an mGBA or
+// NanoBoyAdvance style log with the 16 registers and the CPSR on each line
+type Reader struct {
+	in          *bufio.Reader
+	format      Format
+	line        int
+	previous    Record
+	hasPrevious bool
+}
+
+// NewReader reads a trace from in. Binary traces are recognized by their
+// header, and the text formats by their first line.
+func NewReader(in io.Reader) (*Reader, error) {
+	r := &Reader{in: bufio.NewReaderSize(in, writeBufferSize)}
+	header, err := r.in.Peek(len(binaryMagic) + 1)
+	if err == nil && string(header[:len(binaryMagic)]) == binaryMagic {
+		if header[len(binaryMagic)] != binaryVersion {
+			return nil, fmt.Errorf("unsupported binary trace version %d", header[len(binaryMagic)])
+		}
+		if _, err := r.in.Discard(len(header)); err != nil {
+			return nil, err
+		}
+		r.format = FormatBinary
+	}
+	return r, nil
+}
+
+// Format returns the format of the trace, which is only known for text
+// traces once the first record has been read
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// Next reads the next record, returning io.EOF at the end of the trace
+func (r *Reader) Next() (*Record, error) {
+	var record *Record
+	var err error
+	if r.format == FormatBinary {
+		record, err = r.readBinary()
+	} else {
+		record, err = r.readLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.previous = *record
+	r.hasPrevious = true
+	return record, nil
+}
+
+func (r *Reader) readLine() (*Record, error) {
+	for {
+		text, err := r.in.ReadString('\n')
+		if err != nil && (err != io.EOF || text == "") {
+			return nil, err
+		}
+		r.line++
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if r.format == "" {
+			r.format = FormatText
+			if strings.Contains(strings.ToLower(text), "cpsr") {
+				r.format = FormatMGBA
+			}
+		}
+		var record *Record
+		if r.format == FormatMGBA {
+			record, err = parseMGBA(text)
+		} else {
+			record, err = r.parseText(text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return record, nil
+	}
+}
+
+// pipelineOffset is how far R15 is ahead of the executing instruction
+func pipelineOffset(cpsr uint32) uint32 {
+	if cpsr&(1<<5) != 0 {
+		return 4
+	}
+	return 8
+}
+
+func parseHex(text string) (uint32, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(text), "0x"), 16, 32)
+	return uint32(value), err
+}
+
+// parseMGBA parses "R0 ... R15 cpsr: CPSR | OPCODE: disassembly". The
+// labels and the opcode are optional.
+func parseMGBA(text string) (*Record, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 17 {
+		return nil, errors.New("expected 16 registers and the CPSR")
+	}
+	record := &Record{}
+	for i := 0; i < 16; i++ {
+		value, err := parseHex(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid r%d %q", i, fields[i])
+		}
+		record.Registers[i] = value
+	}
+	rest := fields[16:]
+	if strings.EqualFold(rest[0], "cpsr:") {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return nil, errors.New("missing CPSR")
+	}
+	cpsr, err := parseHex(rest[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid CPSR %q", rest[0])
+	}
+	record.CPSR = cpsr
+	record.Address = record.Registers[15] - pipelineOffset(cpsr)
+	rest = rest[1:]
+	if len(rest) > 0 && rest[0] == "|" {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && strings.HasSuffix(rest[0], ":") {
+		opcode, err := parseHex(strings.TrimSuffix(rest[0], ":"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid opcode %q", rest[0])
+		}
+		record.Opcode = opcode
+	}
+	return record, nil
+}
+
+// parseText parses a line written in FormatText. Registers that are not
+// listed keep their value from the previous line.
+func (r *Reader) parseText(text string) (*Record, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 5 {
+		return nil, errors.New("expected cycles, address, opcode, mode and CPSR")
+	}
+	if !r.hasPrevious && len(fields) < 20 {
+		return nil, errors.New("the first line must list r0-r14")
+	}
+	record := &Record{Registers: r.previous.Registers}
+	var err error
+	if record.Cycles, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid cycle count %q", fields[0])
+	}
+	if record.Address, err = parseHex(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid address %q", fields[1])
+	}
+	if record.Opcode, err = parseHex(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid opcode %q", fields[2])
+	}
+	if record.CPSR, err = parseHex(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid CPSR %q", fields[4])
+	}
+	record.Registers[15] = record.Address + pipelineOffset(record.CPSR)
+	for _, field := range fields[5:] {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+		parsed, err := parseHex(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in %q", field)
+		}
+		switch {
+		case strings.HasPrefix(name, "r"):
+			reg, err := strconv.Atoi(name[1:])
+			if err != nil || reg < 0 || reg > 14 {
+				return nil, fmt.Errorf("invalid register in %q", field)
+			}
+			record.Registers[reg] = parsed
+		case strings.HasPrefix(name, "w"):
+			bits, address, ok := strings.Cut(name[1:], ":")
+			size, err := strconv.Atoi(bits)
+			if !ok || err != nil || (size != 8 && size != 16 && size != 32) {
+				return nil, fmt.Errorf("invalid write %q", field)
+			}
+			writeAddress, err := parseHex(address)
+			if err != nil {
+				return nil, fmt.Errorf("invalid write %q", field)
+			}
+			record.Writes = append(record.Writes, Write{Address: writeAddress, Size: uint8(size / 8), Value: parsed})
+		default:
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+	}
+	return record, nil
+}
+
+func (r *Reader) readBinary() (*Record, error) {
+	var header [22]byte
+	if _, err := io.ReadFull(r.in, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated binary trace")
+		}
+		return nil, err
+	}
+	record := &Record{
+		Cycles:    binary.LittleEndian.Uint64(header[0:]),
+		Address:   binary.LittleEndian.Uint32(header[8:]),
+		Opcode:    binary.LittleEndian.Uint32(header[12:]),
+		CPSR:      binary.LittleEndian.Uint32(header[16:]),
+		Registers: r.previous.Registers,
+	}
+	mask := binary.LittleEndian.Uint16(header[20:])
+	var value [4]byte
+	for i := 0; i < 15; i++ {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		if _, err := io.ReadFull(r.in, value[:]); err != nil {
+			return nil, errors.New("truncated binary trace")
+		}
+		record.Registers[i] = binary.LittleEndian.Uint32(value[:])
+	}
+	record.Registers[15] = record.Address + pipelineOffset(record.CPSR)
+	count, err := r.in.ReadByte()
+	if err != nil {
+		return nil, errors.New("truncated binary trace")
+	}
+	for i := 0; i < int(count); i++ {
+		var write [9]byte
+		if _, err := io.ReadFull(r.in, write[:]); err != nil {
+			return nil, errors.New("truncated binary trace")
+		}
+		record.Writes = append(record.Writes, Write{
+			Address: binary.LittleEndian.Uint32(write[0:]),
+			Size:    write[4],
+			Value:   binary.LittleEndian.Uint32(write[5:]),
+		})
+	}
+	return record, nil
+}
@@ -0,0 +1,124 @@
+package trace_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/trace"
+)
+
+// readAll reads every record of a trace
+func readAll(t *testing.T, text string) ([]trace.Record, trace.Format, error) {
+	t.Helper()
+	r, err := trace.NewReader(strings.NewReader(text))
+	if err != nil {
+		return nil, "", err
+	}
+	var records []trace.Record
+	for {
+		record, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return records, r.Format(), nil
+		}
+		if err != nil {
+			return records, r.Format(), err
+		}
+		records = append(records, *record)
+	}
+}
+
+func TestReadMGBA(t *testing.T) {
+	t.Parallel()
+	log := strings.Join([]string{
+		"# comment",
+		"00000001 00000002 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 03007F00 00000000 08000008 cpsr: 6000001F | E3A00000: mov r0, #0",
+		"",
+		"00000000 00000002 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 03007F00 00000000 08000024 cpsr: 0000003F |     3B01: sub r3, #1",
+		// NanoBoyAdvance logs leave out the labels and the opcode
+		"0x0 0x2 0x0 0x0 0x0 0x0 0x0 0x0 0x0 0x0 0x0 0x0 0x0 0x3007f00 0x0 0x8000026 0x3f",
+	}, "\n")
+	records, format, err := readAll(t, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != trace.FormatMGBA {
+		t.Errorf("read the format as %q", format)
+	}
+	want := []trace.Record{
+		{Address: 0x08000000, Opcode: 0xE3A00000, CPSR: 0x6000001F, Registers: [16]uint32{1, 2, 13: 0x03007F00, 15: 0x08000008}},
+		{Address: 0x08000020, Opcode: 0x3B01, CPSR: 0x3F, Registers: [16]uint32{0, 2, 13: 0x03007F00, 15: 0x08000024}},
+		{Address: 0x08000022, CPSR: 0x3F, Registers: [16]uint32{0, 2, 13: 0x03007F00, 15: 0x08000026}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %+v, expected %+v", records, want)
+	}
+}
+
+func TestReadText(t *testing.T) {
+	t.Parallel()
+	log := strings.Join([]string{
+		"0 08000000 e3a00001 sys 0000001f r0=00000000 r1=00000000 r2=00000000 r3=00000000 r4=00000000 r5=00000000 r6=00000000 r7=00000000 r8=00000000 r9=00000000 r10=00000000 r11=00000000 r12=00000000 r13=03007f00 r14=00000000",
+		// Registers that aren't listed keep their previous value
+		"3 08000004 e5801000 sys 0000001f r0=00000001 r1=02000000 w32:02000000=00000001",
+		"6 08000020 3b01 sys 0000003f w8:03000000=ff w16:03000002=1234",
+	}, "\n")
+	records, format, err := readAll(t, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != trace.FormatText {
+		t.Errorf("read the format as %q", format)
+	}
+	want := []trace.Record{
+		{Address: 0x08000000, Opcode: 0xE3A00001, CPSR: 0x1F, Registers: [16]uint32{13: 0x03007F00, 15: 0x08000008}},
+		{
+			Cycles: 3, Address: 0x08000004, Opcode: 0xE5801000, CPSR: 0x1F,
+			Registers: [16]uint32{1, 0x02000000, 13: 0x03007F00, 15: 0x0800000C},
+			Writes:    []trace.Write{{Address: 0x02000000, Size: 4, Value: 1}},
+		},
+		{
+			Cycles: 6, Address: 0x08000020, Opcode: 0x3B01, CPSR: 0x3F,
+			Registers: [16]uint32{1, 0x02000000, 13: 0x03007F00, 15: 0x08000024},
+			Writes:    []trace.Write{{Address: 0x03000000, Size: 1, Value: 0xFF}, {Address: 0x03000002, Size: 2, Value: 0x1234}},
+		},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %+v, expected %+v", records, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	t.Parallel()
+	const first = "0 08000000 e3a00001 sys 0000001f r0=0 r1=0 r2=0 r3=0 r4=0 r5=0 r6=0 r7=0 r8=0 r9=0 r10=0 r11=0 r12=0 r13=0 r14=0\n"
+	const mgbaRegisters = "0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 08000008"
+	tests := []struct {
+		name string
+		log  string
+		err  string
+	}{
+		{name: "mgba without cpsr", log: mgbaRegisters + " cpsr:", err: "line 1: missing CPSR"},
+		{name: "mgba register", log: "# header\n0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 zz cpsr: 1f", err: `line 2: invalid r15 "zz"`},
+		{name: "mgba short", log: "0 0 0 cpsr: 1f", err: "expected 16 registers and the CPSR"},
+		{name: "mgba opcode", log: mgbaRegisters + " cpsr: 1f | xyz: mov", err: `invalid opcode "xyz:"`},
+		{name: "text without registers", log: "0 08000000 e3a00001 sys 0000001f", err: "the first line must list r0-r14"},
+		{name: "text short", log: first + "0 08000000 e3a00001", err: "line 2: expected cycles, address, opcode, mode and CPSR"},
+		{name: "text cycles", log: first + "x 08000000 e3a00001 sys 1f", err: `invalid cycle count "x"`},
+		{name: "text register", log: first + "0 08000000 e3a00001 sys 1f r15=0", err: `invalid register in "r15=0"`},
+		{name: "text write size", log: first + "0 08000000 e3a00001 sys 1f w24:0=0", err: `invalid write "w24:0=0"`},
+		{name: "text field", log: first + "0 08000000 e3a00001 sys 1f spsr", err: `invalid field "spsr"`},
+		{name: "binary version", log: "GBATRACE\x01", err: "unsupported binary trace version 1"},
+		{name: "binary truncated", log: "GBATRACE\x02\x00\x00", err: "truncated binary trace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := readAll(t, tt.log)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, expected one containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Package trace writes a log of every executed instruction, for diffing
+// against other emulators
+package trace
+
+// Record is the CPU state right before an instruction executes
+type Record struct {
+	// Cycles is the number of cycles run since reset
+	Cycles uint64
+	// Frame is the number of frames drawn since reset
+	Frame uint64
+	// Address is where the instruction was fetched from
+	Address uint32
+	Opcode  uint32
+	CPSR    uint32
+	// Registers holds R0-R15 of the current mode. R15 reads as the
+	// instruction sees it, 8 bytes ahead in ARM state and 4 in THUMB.
+	Registers [16]uint32
+	// Writes are the memory writes the instruction made, when they are
+	// traced
+	Writes []Write
+}
+
+// Write is a memory write, with the value read back after the instruction
+type Write struct {
+	Address uint32
+	// Size is 1, 2 or 4 bytes
+	Size  uint8
+	Value uint32
+}
+
+// Thumb reports whether the instruction is a THUMB one
+func (r *Record) Thumb() bool {
+	return r.CPSR&(1<<5) != 0
+}
+
+// Mode returns the CPU mode bits of the CPSR
+func (r *Record) Mode() uint8 {
+	return uint8(r.CPSR & 0x1F)
+}
+
+//nolint:golint,gochecknoglobals
+var modeNames = map[uint8]string{
+	0x10: "usr",
+	0x11: "fiq",
+	0x12: "irq",
+	0x13: "svc",
+	0x17: "abt",
+	0x1B: "und",
+	0x1F: "sys",
+}
+
+// ModeName returns the short name of a CPU mode, like "svc"
+func ModeName(mode uint8) string {
+	if name, ok := modeNames[mode]; ok {
+		return name
+	}
+	return "???"
+}
+
+// ParseMode parses a short mode name
+func ParseMode(name string) (uint8, bool) {
+	for mode, modeName := range modeNames {
+		if modeName == name {
+			return mode, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,271 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu/disasm"
+)
+
+// Format is how records are written
+type Format string
+
+const (
+	// FormatText is one line per instruction: cycles, address, opcode,
+	// mode, CPSR, the registers that changed since the previous line and
+	// the memory writes
+	FormatText Format = "text"
+	// FormatMGBA is the register dump used by mGBA and NanoBoyAdvance trace
+	// logs: R0-R15, the CPSR, then the opcode and its disassembly
+	FormatMGBA Format = "mgba"
+	// FormatBinary is the binary equivalent of FormatText
+	FormatBinary Format = "binary"
+)
+
+// binaryMagic starts a binary trace, followed by the format version
+const (
+	binaryMagic   = "GBATRACE"
+	binaryVersion = 2
+	// writeBufferSize is the buffer between the tracer and the file
+	writeBufferSize = 1 << 20
+)
+
+// ParseFormat parses a format name
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatMGBA:
+		return FormatMGBA, nil
+	case FormatBinary:
+		return FormatBinary, nil
+	}
+	return "", fmt.Errorf("unknown trace format %q, expected text, mgba or binary", name)
+}
+
+// Tracer receives the records of executed instructions
+type Tracer interface {
+	// Match reports whether the instruction should be recorded, before the
+	// record is built
+	Match(address uint32, mode uint8, frame uint64) bool
+	Write(r *Record) error
+}
+
+// Options configures a Writer
+type Options struct {
+	Format Format
+	Filter Filter
+	// RingSize keeps only the last RingSize records in memory. They are
+	// written by Crash, so a normal run leaves the file empty.
+	RingSize int
+}
+
+// Writer writes trace records through a buffer
+type Writer struct {
+	out         *bufio.Writer
+	closer      io.Closer
+	format      Format
+	filter      Filter
+	ring        []Record
+	ringNext    int
+	ringFull    bool
+	previous    [16]uint32
+	hasPrevious bool
+	// binaryBuffer is reused between binary records
+	binaryBuffer []byte
+}
+
+// Create opens a trace file
+func Create(path string, opts Options) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.closer = file
+	return w, nil
+}
+
+// NewWriter writes a trace to out
+func NewWriter(out io.Writer, opts Options) (*Writer, error) {
+	w := &Writer{
+		out:    bufio.NewWriterSize(out, writeBufferSize),
+		format: opts.Format,
+		filter: opts.Filter,
+	}
+	if w.format == "" {
+		w.format = FormatText
+	}
+	if opts.RingSize > 0 {
+		w.ring = make([]Record, opts.RingSize)
+	}
+	if w.format == FormatBinary {
+		if _, err := w.out.WriteString(binaryMagic); err != nil {
+			return nil, err
+		}
+		if err := w.out.WriteByte(binaryVersion); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Match reports whether the filter keeps an instruction, so callers can
+// skip building records that would be dropped
+func (w *Writer) Match(address uint32, mode uint8, frame uint64) bool {
+	return w.filter.Match(address, mode, frame)
+}
+
+// Write traces a record, or keeps it in the ring buffer
+func (w *Writer) Write(r *Record) error {
+	if w.ring != nil {
+		w.ring[w.ringNext] = *r
+		w.ringNext++
+		if w.ringNext == len(w.ring) {
+			w.ringNext = 0
+			w.ringFull = true
+		}
+		return nil
+	}
+	return w.write(r)
+}
+
+// Crash writes out the ring buffer, oldest record first, and flushes
+func (w *Writer) Crash() error {
+	if w.ring != nil {
+		start, count := 0, w.ringNext
+		if w.ringFull {
+			start, count = w.ringNext, len(w.ring)
+		}
+		for i := 0; i < count; i++ {
+			if err := w.write(&w.ring[(start+i)%len(w.ring)]); err != nil {
+				return err
+			}
+		}
+		w.ringNext, w.ringFull = 0, false
+	}
+	return w.out.Flush()
+}
+
+// Close flushes the trace and closes the file it was created with
+func (w *Writer) Close() error {
+	err := w.out.Flush()
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (w *Writer) write(r *Record) error {
+	var err error
+	switch w.format {
+	case FormatMGBA:
+		err = w.writeMGBA(r)
+	case FormatBinary:
+		err = w.writeBinary(r)
+	default:
+		err = w.writeText(r)
+	}
+	w.previous = r.Registers
+	w.hasPrevious = true
+	return err
+}
+
+// changed returns a mask of the registers that changed since the previous
+// record, leaving out R15 which changes every time
+func (w *Writer) changed(r *Record) uint16 {
+	var mask uint16
+	for i := 0; i < 15; i++ {
+		if !w.hasPrevious || r.Registers[i] != w.previous[i] {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
+
+func formatOpcode(r *Record) string {
+	if r.Thumb() {
+		return fmt.Sprintf("%04x", r.Opcode&0xFFFF)
+	}
+	return fmt.Sprintf("%08x", r.Opcode)
+}
+
+func (w *Writer) writeText(r *Record) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d %08x %s %s %08x", r.Cycles, r.Address, formatOpcode(r), ModeName(r.Mode()), r.CPSR)
+	mask := w.changed(r)
+	for i := 0; i < 15; i++ {
+		if mask&(1<<i) != 0 {
+			fmt.Fprintf(&sb, " r%d=%08x", i, r.Registers[i])
+		}
+	}
+	for _, write := range r.Writes {
+		fmt.Fprintf(&sb, " w%d:%08x=%0*x", write.Size*8, write.Address, write.Size*2, write.Value)
+	}
+	sb.WriteByte('\n')
+	_, err := w.out.WriteString(sb.String())
+	return err
+}
+
+func (w *Writer) writeMGBA(r *Record) error {
+	var sb strings.Builder
+	for _, value := range r.Registers {
+		fmt.Fprintf(&sb, "%08X ", value)
+	}
+	if r.Thumb() {
+		fmt.Fprintf(&sb, "cpsr: %08X |     %04X: %s\n", r.CPSR, r.Opcode&0xFFFF, disasm.Thumb(uint16(r.Opcode), r.Address))
+	} else {
+		fmt.Fprintf(&sb, "cpsr: %08X | %08X: %s\n", r.CPSR, r.Opcode, disasm.ARM(r.Opcode, r.Address))
+	}
+	_, err := w.out.WriteString(sb.String())
+	return err
+}
+
+// writeBinary writes a little endian record: cycles, address, opcode,
+// CPSR, the mask of changed registers, the value of each of them, the
+// number of memory writes (up to 255) and the address, size and value of
+// each write
+func (w *Writer) writeBinary(r *Record) error {
+	mask := w.changed(r)
+	writes := r.Writes
+	if len(writes) > 0xFF {
+		writes = writes[:0xFF]
+	}
+	size := 8 + 4 + 4 + 4 + 2 + 15*4 + 1 + len(writes)*9
+	if len(w.binaryBuffer) < size {
+		w.binaryBuffer = make([]byte, size)
+	}
+	buf := w.binaryBuffer
+	binary.LittleEndian.PutUint64(buf[0:], r.Cycles)
+	binary.LittleEndian.PutUint32(buf[8:], r.Address)
+	binary.LittleEndian.PutUint32(buf[12:], r.Opcode)
+	binary.LittleEndian.PutUint32(buf[16:], r.CPSR)
+	binary.LittleEndian.PutUint16(buf[20:], mask)
+	n := 22
+	for i := 0; i < 15; i++ {
+		if mask&(1<<i) != 0 {
+			binary.LittleEndian.PutUint32(buf[n:], r.Registers[i])
+			n += 4
+		}
+	}
+	buf[n] = byte(len(writes))
+	n++
+	for _, write := range writes {
+		binary.LittleEndian.PutUint32(buf[n:], write.Address)
+		buf[n+4] = write.Size
+		binary.LittleEndian.PutUint32(buf[n+5:], write.Value)
+		n += 9
+	}
+	_, err := w.out.Write(buf[:n])
+	return err
+}
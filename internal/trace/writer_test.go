@@ -0,0 +1,159 @@
+package trace_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/trace"
+)
+
+// program is a run of records where only some registers change each time,
+// with R15 ahead of the address the way the CPU records it
+//
+//nolint:golint,gochecknoglobals
+var program = []trace.Record{
+	{
+		Cycles: 0, Address: 0x08000000, Opcode: 0xE3A00001, CPSR: 0x6000001F,
+		Registers: [16]uint32{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x03007F00, 0, 0x08000008},
+	},
+	{
+		Cycles: 3, Address: 0x08000004, Opcode: 0xE5801000, CPSR: 0x6000001F,
+		Registers: [16]uint32{1, 0x02000000, 13: 0x03007F00, 15: 0x0800000C},
+		Writes:    []trace.Write{{Address: 0x02000000, Size: 4, Value: 1}},
+	},
+	{
+		Cycles: 7, Address: 0x08000020, Opcode: 0x7001, CPSR: 0x2000003F,
+		Registers: [16]uint32{1, 0x02000000, 13: 0x03007F00, 14: 0x08000009, 15: 0x08000024},
+		Writes:    []trace.Write{{Address: 0x03000000, Size: 1, Value: 0xFF}, {Address: 0x03000002, Size: 2, Value: 0x1234}},
+	},
+	{
+		Cycles: 9, Address: 0x08000022, Opcode: 0x4770, CPSR: 0x20000032,
+		Registers: [16]uint32{1, 0x02000000, 13: 0x03007FA0, 14: 0x08000009, 15: 0x08000026},
+	},
+}
+
+func write(t *testing.T, opts trace.Options, records []trace.Record) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := trace.NewWriter(&out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if err := w.Write(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	// mGBA logs have no cycle counts or memory writes
+	mgba := make([]trace.Record, len(program))
+	for i, record := range program {
+		mgba[i] = trace.Record{Address: record.Address, Opcode: record.Opcode, CPSR: record.CPSR, Registers: record.Registers}
+	}
+	tests := []struct {
+		format trace.Format
+		want   []trace.Record
+	}{
+		{format: trace.FormatText, want: program},
+		{format: trace.FormatMGBA, want: mgba},
+		{format: trace.FormatBinary, want: program},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			t.Parallel()
+			out := write(t, trace.Options{Format: tt.format}, program)
+			records, format, err := readAll(t, out.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.format {
+				t.Errorf("read the format as %q", format)
+			}
+			if !reflect.DeepEqual(records, tt.want) {
+				t.Errorf("got %+v, expected %+v", records, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextChangedRegisters(t *testing.T) {
+	t.Parallel()
+	lines := strings.Split(strings.TrimSpace(write(t, trace.Options{}, program).String()), "\n")
+	want := []string{
+		"0 08000000 e3a00001 sys 6000001f r0=00000000 r1=00000000 r2=00000000 r3=00000000 r4=00000000 r5=00000000 r6=00000000 r7=00000000 r8=00000000 r9=00000000 r10=00000000 r11=00000000 r12=00000000 r13=03007f00 r14=00000000",
+		"3 08000004 e5801000 sys 6000001f r0=00000001 r1=02000000 w32:02000000=00000001",
+		"7 08000020 7001 sys 2000003f r14=08000009 w8:03000000=ff w16:03000002=1234",
+		"9 08000022 4770 irq 20000032 r13=03007fa0",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRing(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	w, err := trace.NewWriter(&out, trace.Options{RingSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wrap around the ring more than once
+	for i := 0; i < 7; i++ {
+		if err := w.Write(&program[i%len(program)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("wrote %q before crashing", out.String())
+	}
+
+	if err := w.Crash(); err != nil {
+		t.Fatal(err)
+	}
+	// The ring is emptied by the crash
+	if err := w.Write(&program[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Crash(); err != nil {
+		t.Fatal(err)
+	}
+	records, _, err := readAll(t, out.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addresses []uint32
+	for _, record := range records {
+		addresses = append(addresses, record.Address)
+	}
+	want := []uint32{program[0].Address, program[1].Address, program[2].Address, program[0].Address}
+	if !reflect.DeepEqual(addresses, want) {
+		t.Errorf("got records at %08x, expected %08x", addresses, want)
+	}
+	if !reflect.DeepEqual(records[:3], []trace.Record{program[0], program[1], program[2]}) {
+		t.Errorf("got %+v, expected the oldest record first", records[:3])
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+	for name, want := range map[string]trace.Format{"": trace.FormatText, "TEXT": trace.FormatText, "mgba": trace.FormatMGBA, "binary": trace.FormatBinary} {
+		if got, err := trace.ParseFormat(name); err != nil || got != want {
+			t.Errorf("parsed %q as %q, %v, expected %q", name, got, err, want)
+		}
+	}
+	if _, err := trace.ParseFormat("json"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
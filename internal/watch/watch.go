@@ -0,0 +1,40 @@
+// Package watch holds what the command-line debugger and the GDB server
+// share: the CPU they debug and the watchpoints they stop it on
+package watch
+
+import (
+	"github.com/USA-RedDragon/go-gba/internal/emulator/memory"
+)
+
+// Target is the CPU being debugged. cpu.ARM7TDMI implements it.
+type Target interface {
+	ReadDebugRegister(reg uint8) uint32
+	WriteDebugRegister(reg uint8, value uint32) error
+	StepInstruction() error
+	SetWatchHook(hook func(addr uint32, size uint8, write bool))
+	GetMMIO() *memory.MMIO
+}
+
+// Kind is the accesses a watchpoint stops on, named like GDB's commands
+type Kind string
+
+const (
+	Write  Kind = "watch"
+	Read   Kind = "rwatch"
+	Access Kind = "awatch"
+)
+
+// Point watches Length bytes of memory from Addr
+type Point struct {
+	Kind   Kind
+	Addr   uint32
+	Length uint32
+}
+
+// Matches reports whether an access of size bytes at addr stops on p
+func (p Point) Matches(addr uint32, size uint8, write bool) bool {
+	if addr >= p.Addr+p.Length || addr+uint32(size) <= p.Addr {
+		return false
+	}
+	return p.Kind == Access || (p.Kind == Write) == write
+}
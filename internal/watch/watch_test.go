@@ -0,0 +1,40 @@
+package watch_test
+
+import (
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/internal/watch"
+)
+
+func TestMatches(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		kind  watch.Kind
+		addr  uint32
+		size  uint8
+		write bool
+		want  bool
+	}{
+		{"write", watch.Write, 0x02000004, 4, true, true},
+		{"read of a write watchpoint", watch.Write, 0x02000004, 4, false, false},
+		{"read", watch.Read, 0x02000004, 4, false, true},
+		{"write of a read watchpoint", watch.Read, 0x02000004, 4, true, false},
+		{"access read", watch.Access, 0x02000004, 4, false, true},
+		{"access write", watch.Access, 0x02000004, 4, true, true},
+		// The watchpoint covers 0x02000004-0x02000007
+		{"overlapping the start", watch.Write, 0x02000002, 4, true, true},
+		{"overlapping the end", watch.Write, 0x02000007, 1, true, true},
+		{"just before", watch.Write, 0x02000000, 4, true, false},
+		{"just after", watch.Write, 0x02000008, 2, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := watch.Point{Kind: tt.kind, Addr: 0x02000004, Length: 4}
+			if got := p.Matches(tt.addr, tt.size, tt.write); got != tt.want {
+				t.Errorf("got %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
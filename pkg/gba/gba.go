@@ -0,0 +1,202 @@
+// Package gba embeds the go-gba emulator core in other programs, like bots,
+// servers and test harnesses. It runs a frame at a time on the caller's
+// goroutine and has no window, audio or input backend of its own.
+package gba
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/USA-RedDragon/go-gba/internal/config"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/cpu"
+	"github.com/USA-RedDragon/go-gba/internal/emulator/ppu"
+)
+
+const (
+	// Width of the screen in pixels
+	Width = 240
+	// Height of the screen in pixels
+	Height = 160
+)
+
+// Key is a button of the GBA, as its bit in KEYINPUT. Keys are combined
+// into a mask with |.
+type Key uint16
+
+const (
+	KeyA Key = 1 << iota
+	KeyB
+	KeySelect
+	KeyStart
+	KeyRight
+	KeyLeft
+	KeyUp
+	KeyDown
+	KeyR
+	KeyL
+)
+
+// Options are what a GBA starts with. Only the ROM is required.
+type Options struct {
+	// ROM is the game, already patched
+	ROM io.Reader
+	// BIOS is the 16KB GBA BIOS. Without it the game starts at its entry
+	// point, and IRQs go through a stand-in for the BIOS handler.
+	BIOS io.Reader
+	// Save is the backup memory to start with. Only SRAM is emulated yet.
+	Save io.Reader
+	// ColorCorrection is one of the profiles of the --color-correction
+	// flag, "none" if empty
+	ColorCorrection string
+	// FrameBlending mixes each frame with the one before, like the LCD
+	FrameBlending bool
+	// IdleSkip skips ahead to the next event when the game polls in a loop
+	IdleSkip bool
+}
+
+// GBA is an emulated Game Boy Advance. It isn't safe for concurrent use.
+type GBA struct {
+	cpu   *cpu.ARM7TDMI
+	frame *image.RGBA
+	// err is the error that stopped the CPU, returned until a reset
+	err error
+}
+
+// New creates a GBA running the game in opts, ready to run its first frame
+func New(opts Options) (*GBA, error) {
+	if opts.ROM == nil {
+		return nil, errors.New("no ROM given")
+	}
+	rom, err := io.ReadAll(opts.ROM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ROM: %w", err)
+	}
+	var bios []byte
+	if opts.BIOS != nil {
+		bios, err = io.ReadAll(opts.BIOS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read BIOS: %w", err)
+		}
+	}
+	correction, err := ppu.ParseColorCorrection(opts.ColorCorrection)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cpu.New(&config.Config{
+		ColorCorrection: string(correction),
+		FrameBlending:   opts.FrameBlending,
+		IdleSkip:        opts.IdleSkip,
+		BlockCache:      true,
+	}, bios, rom)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Save != nil {
+		save, err := io.ReadAll(opts.Save)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read save: %w", err)
+		}
+		if err := c.LoadBackup(save); err != nil {
+			return nil, err
+		}
+	}
+	return &GBA{cpu: c, frame: image.NewRGBA(image.Rect(0, 0, Width, Height))}, nil
+}
+
+// Title returns the game title from the cartridge header
+func (g *GBA) Title() string {
+	if header := g.cpu.GetCartridgeHeader(); header != nil {
+		return header.Title
+	}
+	return ""
+}
+
+// RunFrame runs the game up to the start of the next VBlank, when the
+// frame is finished. Once the CPU failed it keeps returning the error
+// until Reset.
+func (g *GBA) RunFrame() error {
+	if g.err != nil {
+		return g.err
+	}
+	for !g.cpu.PPU.FrameReady() {
+		if err := g.cpu.Step(); err != nil {
+			g.err = err
+			return err
+		}
+	}
+	g.cpu.PPU.ClearFrameReady()
+	if frame := g.cpu.PPU.FrameBuffer(); frame != nil {
+		g.frame = frame
+	}
+	return nil
+}
+
+// SetKeys sets the buttons held, as a mask of Keys
+func (g *GBA) SetKeys(mask Key) {
+	g.cpu.SetKeyInput(uint16(mask))
+}
+
+// Framebuffer returns the frame finished by the last RunFrame. It is black
+// before the first frame, and keeps the last frame the PPU could draw in
+// the display modes it can't yet. The image is replaced rather than
+// updated, so it can be kept.
+func (g *GBA) Framebuffer() *image.RGBA {
+	return g.frame
+}
+
+// AudioSamples returns the interleaved stereo samples produced since the
+// last call. There is no APU yet, so there are none.
+func (g *GBA) AudioSamples() []int16 {
+	return nil
+}
+
+// ReadMemory reads length bytes from the bus at addr
+func (g *GBA) ReadMemory(addr uint32, length int) ([]byte, error) {
+	bus := g.cpu.GetMMIO()
+	data := make([]byte, length)
+	for i := range data {
+		b, err := bus.Read8(addr + uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		data[i] = b
+	}
+	return data, nil
+}
+
+// WriteMemory writes data to the bus at addr. Unlike a write by the game,
+// it also patches ROM and writes single bytes to VRAM.
+func (g *GBA) WriteMemory(addr uint32, data []byte) error {
+	return g.cpu.GetMMIO().Poke(addr, data)
+}
+
+// SaveData returns a copy of the backup memory of the cartridge to keep as
+// its save, or nil if it has none that is emulated
+func (g *GBA) SaveData() []byte {
+	return g.cpu.Backup()
+}
+
+// SaveState writes the state of the game to w, to be loaded back with
+// LoadState. The ROM and BIOS aren't part of it.
+func (g *GBA) SaveState(w io.Writer) error {
+	return g.cpu.SaveState(w)
+}
+
+// LoadState restores a state written by SaveState, which has to be for the
+// same game
+func (g *GBA) LoadState(r io.Reader) error {
+	if err := g.cpu.LoadState(r); err != nil {
+		return err
+	}
+	g.err = nil
+	return nil
+}
+
+// Reset starts the game over from the BIOS, or from its entry point
+// without one. Memory keeps its content, like after a soft reset.
+func (g *GBA) Reset() error {
+	g.err = g.cpu.Reset()
+	return g.err
+}
@@ -0,0 +1,183 @@
+package gba_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/USA-RedDragon/go-gba/pkg/gba"
+)
+
+// keysToScreen switches to display mode 3 and keeps copying KEYINPUT to
+// the first pixel
+//
+//nolint:golint,gochecknoglobals
+var keysToScreen = []uint32{
+	0xE3A00301, // mov r0, #0x04000000
+	0xE3A01003, // mov r1, #3
+	0xE1C010B0, // strh r1, [r0]
+	0xE3A02406, // mov r2, #0x06000000
+	0xE2803C01, // add r3, r0, #0x100
+	0xE1D313B0, // loop: ldrh r1, [r3, #0x30]
+	0xE1C210B0, // strh r1, [r2]
+	0xEAFFFFFC, // b loop
+}
+
+// sramWriter stores 0x42 at the start of SRAM
+//
+//nolint:golint,gochecknoglobals
+var sramWriter = []uint32{
+	0xE3A0040E, // mov r0, #0x0E000000
+	0xE3A01042, // mov r1, #0x42
+	0xE5C01000, // strb r1, [r0]
+	0xEAFFFFFE, // b .
+}
+
+// rom assembles program at the entry point, with a game code and any
+// extra data after the header
+func rom(program []uint32, gameCode string, extra string) []byte {
+	data := make([]byte, 0x200)
+	for i, opcode := range program {
+		binary.LittleEndian.PutUint32(data[i*4:], opcode)
+	}
+	copy(data[0xAC:], gameCode)
+	copy(data[0xC0:], extra)
+	return data
+}
+
+func newGBA(t *testing.T, opts gba.Options) *gba.GBA {
+	t.Helper()
+	g, err := gba.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func runFrames(t *testing.T, g *gba.GBA, frames int) {
+	t.Helper()
+	for i := 0; i < frames; i++ {
+		if err := g.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func firstPixel(t *testing.T, g *gba.GBA) uint16 {
+	t.Helper()
+	data, err := g.ReadMemory(0x06000000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return binary.LittleEndian.Uint16(data)
+}
+
+func TestNewNeedsROM(t *testing.T) {
+	t.Parallel()
+	if _, err := gba.New(gba.Options{}); err == nil {
+		t.Error("expected an error without a ROM")
+	}
+	if _, err := gba.New(gba.Options{ROM: bytes.NewReader(rom(keysToScreen, "", "")), ColorCorrection: "sepia"}); err == nil {
+		t.Error("expected an error for an unknown color correction")
+	}
+}
+
+func TestRunFrame(t *testing.T) {
+	t.Parallel()
+	g := newGBA(t, gba.Options{ROM: bytes.NewReader(rom(keysToScreen, "", ""))})
+	if frame := g.Framebuffer(); frame.Rect.Dx() != gba.Width || frame.Rect.Dy() != gba.Height {
+		t.Fatalf("framebuffer is %v before the first frame", frame.Rect)
+	}
+	runFrames(t, g, 1)
+	released := g.Framebuffer()
+	if pixel := firstPixel(t, g); pixel != 0x03FF {
+		t.Errorf("first pixel is 0x%04X with no keys held, expected 0x03FF", pixel)
+	}
+
+	g.SetKeys(gba.KeyA | gba.KeyStart)
+	runFrames(t, g, 1)
+	if pixel := firstPixel(t, g); pixel != 0x03F6 {
+		t.Errorf("first pixel is 0x%04X with A and Start held, expected 0x03F6", pixel)
+	}
+	if bytes.Equal(g.Framebuffer().Pix, released.Pix) {
+		t.Error("the frame didn't change with the keys")
+	}
+	if samples := g.AudioSamples(); len(samples) != 0 {
+		t.Errorf("got %d audio samples without an APU", len(samples))
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+	g := newGBA(t, gba.Options{ROM: bytes.NewReader(rom(keysToScreen, "", ""))})
+	for _, addr := range []uint32{0x02000001, 0x06000101, 0x08000101} {
+		if err := g.WriteMemory(addr, []byte{1, 2, 3}); err != nil {
+			t.Fatalf("writing 0x%08X: %v", addr, err)
+		}
+		data, err := g.ReadMemory(addr, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, []byte{1, 2, 3}) {
+			t.Errorf("read % X back from 0x%08X", data, addr)
+		}
+	}
+	if _, err := g.ReadMemory(0x04000400, 1); err == nil {
+		t.Error("expected an error reading unmapped memory")
+	}
+}
+
+func TestSaveState(t *testing.T) {
+	t.Parallel()
+	g := newGBA(t, gba.Options{ROM: bytes.NewReader(rom(keysToScreen, "TEST", ""))})
+	runFrames(t, g, 1)
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(t, g, 1)
+	want := append([]byte(nil), g.Framebuffer().Pix...)
+
+	g.SetKeys(gba.KeyA)
+	runFrames(t, g, 2)
+	if err := g.LoadState(bytes.NewReader(state.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	// The keys are restored along with the rest of the I/O registers
+	runFrames(t, g, 1)
+	if !bytes.Equal(g.Framebuffer().Pix, want) {
+		t.Error("the frame after loading the state differs from the one after saving it")
+	}
+
+	other := newGBA(t, gba.Options{ROM: bytes.NewReader(rom(keysToScreen, "ABCD", ""))})
+	if err := other.LoadState(bytes.NewReader(state.Bytes())); err == nil {
+		t.Error("expected an error loading the state of another game")
+	}
+	if err := g.LoadState(bytes.NewReader([]byte("GBAS"))); err == nil {
+		t.Error("expected an error loading a truncated state")
+	}
+}
+
+func TestSave(t *testing.T) {
+	t.Parallel()
+	g := newGBA(t, gba.Options{
+		ROM:  bytes.NewReader(rom(sramWriter, "", "SRAM_V113")),
+		Save: bytes.NewReader([]byte{0x99, 0x98}),
+	})
+	if data, _ := g.ReadMemory(0x0E000000, 3); !bytes.Equal(data, []byte{0x99, 0x98, 0xFF}) {
+		t.Errorf("SRAM starts with % X, expected the save and then erased bytes", data)
+	}
+	runFrames(t, g, 1)
+	save := g.SaveData()
+	if len(save) != 64*1024 || save[0] != 0x42 || save[1] != 0x98 {
+		t.Errorf("got a %d byte save starting with % X", len(save), save[:min(len(save), 2)])
+	}
+
+	noSRAM := rom(keysToScreen, "", "")
+	if g := newGBA(t, gba.Options{ROM: bytes.NewReader(noSRAM)}); g.SaveData() != nil {
+		t.Error("got save data from a cartridge without SRAM")
+	}
+	if _, err := gba.New(gba.Options{ROM: bytes.NewReader(noSRAM), Save: bytes.NewReader([]byte{0})}); err == nil {
+		t.Error("expected an error loading a save into a cartridge without SRAM")
+	}
+}